@@ -0,0 +1,113 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/library"
+	"github.com/czcorpus/vert-tagextract/v2/proc"
+	"github.com/rs/zerolog/log"
+)
+
+type batchResult struct {
+	corpus  string
+	stats   *proc.RunStats
+	err     error
+	elapsed time.Duration
+}
+
+func runBatchItem(ctx context.Context, manifest *cnf.Manifest, idx int) batchResult {
+	t0 := time.Now()
+	conf, err := manifest.LoadItemConf(idx)
+	if err != nil {
+		return batchResult{corpus: manifest.Corpora[idx].Config, err: err}
+	}
+	log.Info().
+		Str("corpus", conf.Corpus).
+		Int("item", idx+1).
+		Int("numItems", len(manifest.Corpora)).
+		Msg("Starting to process corpus from manifest")
+	ans := batchResult{corpus: conf.Corpus}
+	statusChan, err := library.ExtractDataContext(ctx, conf, manifest.IsAppend(idx), nil)
+	if err != nil {
+		ans.err = err
+		return ans
+	}
+	ans.stats, ans.err = consumeStatuses(statusChan, nil, nil)
+	if ans.err == nil && ans.stats == nil {
+		ans.err = fmt.Errorf("extraction did not finish")
+	}
+	ans.elapsed = time.Since(t0)
+	return ans
+}
+
+// runBatch processes all the corpora listed in a manifest
+// one after another and reports per-corpus results
+func runBatch(manifestPath string) error {
+	manifest, err := cnf.LoadManifest(manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to run batch: %w", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results := make([]batchResult, 0, len(manifest.Corpora))
+	for i := range manifest.Corpora {
+		if ctx.Err() != nil {
+			break
+		}
+		res := runBatchItem(ctx, manifest, i)
+		results = append(results, res)
+		if res.err != nil && !manifest.ContinueOnError {
+			break
+		}
+	}
+
+	var numFailed int
+	for _, res := range results {
+		if res.err != nil {
+			numFailed++
+			log.Error().Str("corpus", res.corpus).Err(res.err).Msg("Corpus failed")
+			continue
+		}
+		log.Info().
+			Str("corpus", res.corpus).
+			Int("processedTokens", res.stats.ProcessedTokens).
+			Int("insertedAtoms", res.stats.InsertedAtoms).
+			Dur("elapsed", res.elapsed).
+			Msg("Corpus done")
+	}
+	numSkipped := len(manifest.Corpora) - len(results)
+	log.Info().
+		Int("numDone", len(results)-numFailed).
+		Int("numFailed", numFailed).
+		Int("numSkipped", numSkipped).
+		Msg("Batch finished")
+	if numFailed > 0 || numSkipped > 0 {
+		return fmt.Errorf(
+			"batch not completed: %d of %d corpora failed, %d skipped",
+			numFailed, len(manifest.Corpora), numSkipped)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/czcorpus/vert-tagextract/v2/proc"
+)
+
+// benchReport collects information about a run in the benchmark mode
+// (i.e. with all the data discarded instead of being written to a database)
+type benchReport struct {
+	start time.Time
+
+	// lastStatus contains the latest progress status (the numbers
+	// are cumulative for all the processed files)
+	lastStatus proc.Status
+	files      map[string]bool
+	phases     []proc.PhaseTiming
+	numErrors  int
+}
+
+func (br *benchReport) update(status proc.Status) {
+	if status.Error != nil {
+		br.numErrors++
+	}
+	if status.Phase != nil {
+		br.phases = append(br.phases, *status.Phase)
+	}
+	if status.File != "" && status.Error == nil {
+		br.lastStatus = status
+		br.files[status.File] = true
+	}
+}
+
+func (br *benchReport) print() {
+	total := time.Since(br.start)
+	numTokens := br.lastStatus.ProcessedTokens
+	numAtoms := br.lastStatus.ProcessedAtoms
+	var parsingTime time.Duration
+	for _, ph := range br.phases {
+		if ph.Name == proc.PhaseParsing {
+			parsingTime += ph.Duration
+		}
+	}
+	perSec := func(v int, dur time.Duration) string {
+		if dur <= 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.0f", float64(v)/dur.Seconds())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nBenchmark results (no data written):")
+	fmt.Fprintf(w, "files\t%d\n", len(br.files))
+	fmt.Fprintf(w, "tokens\t%d\n", numTokens)
+	fmt.Fprintf(w, "atoms\t%d\n", numAtoms)
+	fmt.Fprintf(w, "errors\t%d\n", br.numErrors)
+	fmt.Fprintf(w, "tokens/sec (parsing)\t%s\n", perSec(numTokens, parsingTime))
+	fmt.Fprintf(w, "atoms/sec (parsing)\t%s\n", perSec(numAtoms, parsingTime))
+	fmt.Fprintf(w, "tokens/sec (total)\t%s\n", perSec(numTokens, total))
+	if rss := peakRSS(); rss >= 0 {
+		fmt.Fprintf(w, "peak RSS\t%.1f MB\n", float64(rss)/1024/1024)
+	}
+	fmt.Fprintln(w, "\nphase\tduration")
+	for _, ph := range br.phases {
+		fmt.Fprintf(w, "%s\t%s\n", ph.Name, ph.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "total\t%s\n", total.Round(time.Millisecond))
+	w.Flush()
+}
+
+func newBenchReport() *benchReport {
+	return &benchReport{
+		start: time.Now(),
+		files: make(map[string]bool),
+	}
+}
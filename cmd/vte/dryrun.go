@@ -0,0 +1,77 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/czcorpus/vert-tagextract/v2/proc"
+)
+
+// printDryRunReport prints tables (along with their columns)
+// and numbers of rows which would be written by the extraction
+func printDryRunReport(stats *proc.RunStats) {
+	if stats == nil {
+		fmt.Println("\nDry run did not finish, no report available")
+		return
+	}
+	tables := make([]string, 0, len(stats.TableColumns))
+	for table := range stats.TableColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nDry run results (no data written):")
+	fmt.Fprintf(w, "tokens\t%d\n", stats.ProcessedTokens)
+	fmt.Fprintf(w, "atoms\t%d\n", stats.InsertedAtoms)
+	fmt.Fprintf(w, "skipped lines\t%d\n", stats.SkippedLines)
+	fmt.Fprintf(w, "errors\t%d\n", stats.NumErrors)
+	fmt.Fprintln(w, "\ntable\trows\tcolumns")
+	for _, table := range tables {
+		fmt.Fprintf(
+			w, "%s\t%d\t%s\n", table, stats.RowsWritten[table], strings.Join(stats.TableColumns[table], ", "))
+	}
+	if len(stats.InferredColumns) > 0 {
+		attrs := make([]string, 0, len(stats.InferredColumns))
+		for attr := range stats.InferredColumns {
+			attrs = append(attrs, attr)
+		}
+		sort.Strings(attrs)
+		fmt.Fprintln(w, "\ninferred column\ttype\tvarchar size\tvalues\tmax. length")
+		for _, attr := range attrs {
+			col := stats.InferredColumns[attr]
+			fmt.Fprintf(
+				w, "%s\t%s\t%d\t%d\t%d\n", attr, col.Type, col.VarcharSize, col.NumValues, col.MaxLength)
+		}
+	}
+	if stats.NgramSummary != nil {
+		fmt.Fprintf(
+			w, "\ndistinct n-grams\t%d\t(%.2f%% hapaxes)\n",
+			stats.NgramSummary.Distinct, stats.NgramSummary.HapaxPercent())
+		if len(stats.NgramSummary.Top) > 0 {
+			fmt.Fprintln(w, "\nn-gram\tcount")
+			for _, item := range stats.NgramSummary.Top {
+				fmt.Fprintf(w, "%s\t%d\n", strings.Join(item.Values, " | "), item.Count)
+			}
+		}
+	}
+	w.Flush()
+}
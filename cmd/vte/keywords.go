@@ -0,0 +1,226 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/sqlite"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	keywordsTable = "keywords"
+)
+
+// keywordsArgs specifies a keyword comparison between two corpora.
+// In case refDBPath is empty, both the corpora are searched for
+// in the database focusDBPath.
+type keywordsArgs struct {
+	focusDBPath string
+	refDBPath   string
+	focusCorpus string
+	refCorpus   string
+}
+
+// ngramColumns returns names of n-gram columns (col0, col1_2, lemma,...)
+// of the colcounts table
+func ngramColumns(database *sql.DB) ([]string, error) {
+	rows, err := database.Query("SELECT name FROM pragma_table_info('colcounts')")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ans := make([]string, 0, 4)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if db.IsNgramColCountName(name) {
+			ans = append(ans, name)
+		}
+	}
+	if len(ans) == 0 {
+		return nil, fmt.Errorf("no colcounts table found")
+	}
+	return ans, rows.Err()
+}
+
+// corpusSize returns number of tokens of a corpus. The value is taken
+// from the corpus_sizes table. In case it is not available (older
+// databases), sum of colcounts frequencies is used.
+func corpusSize(database *sql.DB, corpusID string) (int, error) {
+	var ans int
+	err := database.QueryRow(
+		fmt.Sprintf("SELECT SUM(tokens) FROM %s WHERE corpus_id = ?", db.CorpusSizesTable),
+		corpusID,
+	).Scan(&ans)
+	if err == nil && ans > 0 {
+		return ans, nil
+	}
+	log.Warn().Str("corpus", corpusID).Msg("corpus size not found, using sum of colcounts frequencies")
+	var sum sql.NullInt64
+	err = database.QueryRow("SELECT SUM(count) FROM colcounts WHERE corpus_id = ?", corpusID).Scan(&sum)
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine size of corpus %s: %w", corpusID, err)
+	}
+	if sum.Int64 == 0 {
+		return 0, fmt.Errorf("corpus %s not found", corpusID)
+	}
+	return int(sum.Int64), nil
+}
+
+// loadCounts loads frequencies of all the n-grams of a corpus
+// identified by their hash_id
+func loadCounts(database *sql.DB, corpusID string) (map[string]int, error) {
+	rows, err := database.Query("SELECT hash_id, count FROM colcounts WHERE corpus_id = ?", corpusID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load counts of corpus %s: %w", corpusID, err)
+	}
+	defer rows.Close()
+	ans := make(map[string]int)
+	for rows.Next() {
+		var hashID string
+		var count int
+		if err := rows.Scan(&hashID, &count); err != nil {
+			return nil, fmt.Errorf("failed to load counts of corpus %s: %w", corpusID, err)
+		}
+		ans[hashID] = count
+	}
+	return ans, rows.Err()
+}
+
+// runKeywords compares n-gram frequencies of two corpora and writes
+// keyness scores of all the n-grams of the focus corpus into the table
+// keywords of the focus database. Previous results of the same
+// comparison are replaced. The number of written rows is returned.
+func runKeywords(args keywordsArgs) (int, error) {
+	focusDB, err := sql.Open(sqlite.DriverName, args.focusDBPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database %s: %w", args.focusDBPath, err)
+	}
+	defer focusDB.Close()
+	refDB := focusDB
+	if args.refDBPath != "" {
+		refDB, err = sql.Open(sqlite.DriverName, args.refDBPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open database %s: %w", args.refDBPath, err)
+		}
+		defer refDB.Close()
+	}
+	cols, err := ngramColumns(focusDB)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read database %s: %w", args.focusDBPath, err)
+	}
+	focusSize, err := corpusSize(focusDB, args.focusCorpus)
+	if err != nil {
+		return 0, err
+	}
+	refSize, err := corpusSize(refDB, args.refCorpus)
+	if err != nil {
+		return 0, err
+	}
+	refCounts, err := loadCounts(refDB, args.refCorpus)
+	if err != nil {
+		return 0, err
+	}
+	log.Info().
+		Int("focusSize", focusSize).
+		Int("refSize", refSize).
+		Int("refNgrams", len(refCounts)).
+		Msg("Loaded reference corpus data")
+
+	colDefs := make([]string, len(cols))
+	for i, c := range cols {
+		colDefs[i] = c + " TEXT"
+	}
+	_, err = focusDB.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (hash_id varchar(40), %s, corpus_id TEXT, ref_corpus_id TEXT, "+
+			"count INTEGER, ref_count INTEGER, loglik REAL, odds_ratio REAL)",
+		keywordsTable, strings.Join(colDefs, ", ")))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create table %s: %w", keywordsTable, err)
+	}
+	tx, err := focusDB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE corpus_id = ? AND ref_corpus_id = ?", keywordsTable),
+		args.focusCorpus, args.refCorpus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove previous keywords: %w", err)
+	}
+	// rows must be read completely before inserting as the database
+	// may not support multiple active statements
+	rows, err := tx.Query(
+		fmt.Sprintf("SELECT hash_id, %s, count FROM colcounts WHERE corpus_id = ?", strings.Join(cols, ", ")),
+		args.focusCorpus)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load counts of corpus %s: %w", args.focusCorpus, err)
+	}
+	items := make([][]any, 0, 1000)
+	for rows.Next() {
+		item := make([]any, len(cols)+2)
+		ptrs := make([]any, len(item))
+		for i := range item {
+			ptrs[i] = &item[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to load counts of corpus %s: %w", args.focusCorpus, err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)+7), ", ")
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s (hash_id, %s, corpus_id, ref_corpus_id, count, ref_count, loglik, odds_ratio) VALUES (%s)",
+		keywordsTable, strings.Join(cols, ", "), placeholders))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare keywords insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, item := range items {
+		hashID := fmt.Sprint(item[0])
+		count, ok := item[len(item)-1].(int64)
+		if !ok {
+			return 0, fmt.Errorf("invalid count of n-gram %s", hashID)
+		}
+		refCount := refCounts[hashID]
+		keyness := ptcount.CalcKeyness(int(count), refCount, focusSize, refSize)
+		values := append(item[:len(item)-1], args.focusCorpus, args.refCorpus, count, refCount,
+			keyness.LogLikelihood, keyness.OddsRatio)
+		if _, err := stmt.Exec(values...); err != nil {
+			return 0, fmt.Errorf("failed to insert keywords: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit keywords: %w", err)
+	}
+	return len(items), nil
+}
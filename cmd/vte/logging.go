@@ -0,0 +1,78 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logOptions configures logging of vte actions. We use zerolog
+// (instead of log/slog) as it is already used by all the packages
+// and slog is not available for the Go version the module targets.
+// With the JSON format, records contain fields like corpus, phase
+// and rows which can be processed by a log aggregator.
+type logOptions struct {
+	format string
+	level  string
+
+	// jsonLog is a deprecated alias of format = json
+	jsonLog bool
+}
+
+func (lopts *logOptions) registerFlags(fset *flag.FlagSet, dfltLevel string) {
+	fset.StringVar(&lopts.format, "log-format", logFormatText, "set logging format (text, json)")
+	fset.BoolVar(&lopts.jsonLog, "json-log", false, "set JSON logging format (deprecated, use -log-format json)")
+	fset.StringVar(&lopts.level, "log-level", dfltLevel, "set logging level (debug, info, warn, error)")
+}
+
+func setupLog(lopts logOptions) {
+	level, err := zerolog.ParseLevel(strings.ToLower(lopts.level))
+	if err != nil || level == zerolog.NoLevel {
+		fmt.Printf("Invalid log level: %s\n", lopts.level)
+		os.Exit(2)
+	}
+	zerolog.SetGlobalLevel(level)
+	format := strings.ToLower(lopts.format)
+	if lopts.jsonLog {
+		format = logFormatJSON
+	}
+	switch format {
+	case logFormatJSON:
+	case logFormatText:
+		log.Logger = log.Output(
+			zerolog.ConsoleWriter{
+				Out:        os.Stderr,
+				TimeFormat: time.RFC3339,
+			},
+		)
+	default:
+		fmt.Printf("Invalid log format: %s\n", lopts.format)
+		os.Exit(2)
+	}
+}
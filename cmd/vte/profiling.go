@@ -0,0 +1,161 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/proc"
+)
+
+// runOptions contains instrumentation options
+// of the create and append commands
+type runOptions struct {
+	cpuProfile      string
+	memProfile      string
+	metricsInterval time.Duration
+
+	// bench specifies that the data should be processed
+	// without writing them anywhere and that a throughput
+	// report should be printed in the end
+	bench bool
+
+	// dryRun specifies that the data should be processed without
+	// writing them anywhere and that tables, columns and numbers
+	// of rows which would be written should be reported
+	dryRun bool
+
+	// maxAtoms, if positive, overrides the maxAtoms config item
+	maxAtoms int
+}
+
+func (opts *runOptions) registerFlags(fset *flag.FlagSet) {
+	fset.StringVar(&opts.cpuProfile, "cpu-profile", "", "write CPU profile to a specified file")
+	fset.StringVar(&opts.memProfile, "mem-profile", "", "write heap profile (at the end of processing) to a specified file")
+	fset.DurationVar(
+		&opts.metricsInterval, "metrics-interval", 0,
+		"log runtime metrics (heap, goroutines, tokens/sec) periodically (e.g. 30s)")
+	fset.BoolVar(
+		&opts.bench, "bench", false,
+		"process data without writing them to the database and print a throughput report")
+	fset.BoolVar(
+		&opts.dryRun, "dry-run", false,
+		"process data without writing them anywhere and report tables, columns and rows which would be written")
+	fset.IntVar(&opts.maxAtoms, "max-atoms", 0, "process only the specified number of atoms (0 = all)")
+}
+
+// startCPUProfile starts CPU profiling and returns a function
+// which stops the profiling and closes the profile file.
+func startCPUProfile(path string) (func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	log.Info().Str("file", path).Msg("Started CPU profiling")
+	return func() {
+		pprof.StopCPUProfile()
+		if err := f.Close(); err != nil {
+			log.Error().Err(err).Msg("failed to close CPU profile")
+		}
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+	log.Info().Str("file", path).Msg("Written heap profile")
+	return nil
+}
+
+// metricsLogger periodically logs runtime metrics along
+// with processing speed based on the latest received status
+type metricsLogger struct {
+	mu         sync.Mutex
+	lastStatus proc.Status
+	prevTokens int
+	prevTime   time.Time
+}
+
+func (ml *metricsLogger) update(status proc.Status) {
+	ml.mu.Lock()
+	ml.lastStatus = status
+	ml.mu.Unlock()
+}
+
+func (ml *metricsLogger) logMetrics() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	ml.mu.Lock()
+	status := ml.lastStatus
+	ml.mu.Unlock()
+	now := time.Now()
+	newTokens := status.ProcessedTokens - ml.prevTokens
+	if newTokens < 0 { // a next vertical file started
+		newTokens = status.ProcessedTokens
+	}
+	var tokensPerSec float64
+	if elapsed := now.Sub(ml.prevTime).Seconds(); elapsed > 0 {
+		tokensPerSec = float64(newTokens) / elapsed
+	}
+	ml.prevTokens = status.ProcessedTokens
+	ml.prevTime = now
+	log.Info().
+		Uint64("heapAllocMB", mem.HeapAlloc/1024/1024).
+		Uint64("sysMB", mem.Sys/1024/1024).
+		Uint32("numGC", mem.NumGC).
+		Int("numGoroutines", runtime.NumGoroutine()).
+		Int("processedTokens", status.ProcessedTokens).
+		Int("processedLines", status.ProcessedLines).
+		Int64("processedBytes", status.ProcessedBytes).
+		Float64("progress", status.Progress()).
+		Float64("tokensPerSec", tokensPerSec).
+		Msg("Runtime metrics")
+}
+
+// run logs metrics each interval until the stop channel is closed
+func (ml *metricsLogger) run(interval time.Duration, stop <-chan struct{}) {
+	ml.prevTime = time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ml.logMetrics()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -29,8 +31,12 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
 	"github.com/czcorpus/vert-tagextract/v2/db/colgen"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+	"github.com/czcorpus/vert-tagextract/v2/input"
 	"github.com/czcorpus/vert-tagextract/v2/library"
+	"github.com/czcorpus/vert-tagextract/v2/proc"
 
 	"github.com/tomachalek/vertigo/v5"
 
@@ -47,11 +53,11 @@ func init() {
 	zerolog.DurationFieldUnit = time.Second
 }
 
-func dumpNewConf(corpusName string) {
-	conf := cnf.VTEConf{
+func newConfTemplate(corpusName string) *cnf.VTEConf {
+	conf := &cnf.VTEConf{
 		Corpus: corpusName,
 	}
-	conf.Encoding = "UTF-8"
+	conf.InputEncoding = "UTF-8"
 	conf.AtomStructure = "p"
 	conf.Structures = make(map[string][]string)
 	conf.Structures["doc"] = []string{"id", "title"}
@@ -61,6 +67,52 @@ func dumpNewConf(corpusName string) {
 	conf.BibView.IDAttr = "doc_id"
 	conf.SelfJoin.ArgColumns = []string{}
 	conf.VerticalFiles = []string{"./vertical"}
+	return conf
+}
+
+// newConfFromVertical creates a config template based on structures
+// and positional attributes found in the first scanMB megabytes
+// of a vertical file
+func newConfFromVertical(vertPath string, scanMB int) (*cnf.VTEConf, error) {
+	summary, err := input.ScanVertical(vertPath, int64(scanMB)*1024*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan vertical: %w", err)
+	}
+	log.Info().
+		Int("numTokens", summary.NumTokens).
+		Int("numColumns", summary.NumColumns).
+		Int("numStructures", len(summary.Structures)).
+		Msg("Scanned vertical file")
+	corpusName := filepath.Base(vertPath)
+	if i := strings.Index(corpusName, "."); i > 0 {
+		corpusName = corpusName[:i]
+	}
+	conf := newConfTemplate(corpusName)
+	conf.InputEncoding = summary.Encoding
+	conf.VerticalFiles = nil
+	conf.VerticalFile = vertPath
+	conf.Structures = summary.Structures
+	conf.AtomStructure = summary.RootStructure
+	conf.BibView.Cols = []string{}
+	conf.BibView.IDAttr = ""
+	for _, attr := range summary.Structures[summary.RootStructure] {
+		col := summary.RootStructure + "_" + attr
+		conf.BibView.Cols = append(conf.BibView.Cols, col)
+		if attr == "id" {
+			conf.BibView.IDAttr = col
+		}
+	}
+	if summary.NumColumns > 0 {
+		conf.Ngrams.NgramSize = 1
+		conf.Ngrams.VertColumns = make(db.VertColumns, summary.NumColumns)
+		for i := range conf.Ngrams.VertColumns {
+			conf.Ngrams.VertColumns[i] = db.VertColumn{Idx: i}
+		}
+	}
+	return conf, nil
+}
+
+func dumpNewConf(conf *cnf.VTEConf) {
 	b, err := encoder.EncodeIndented(conf, "", "  ", encoder.SortMapKeys)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to dump a new config")
@@ -69,38 +121,146 @@ func dumpNewConf(corpusName string) {
 	fmt.Println()
 }
 
-func exportData(confPath string, appendData bool) error {
+func dumpConfSchema() {
+	b, err := encoder.EncodeIndented(cnf.JSONSchema(), "", "  ", encoder.SortMapKeys)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to dump config schema")
+	}
+	fmt.Print(string(b))
+	fmt.Println()
+}
+
+// validateConf checks the config and prints all the problems found.
+// It returns false if the config is not valid.
+func validateConf(confPath string) (bool, error) {
+	problems, err := cnf.ValidateConfFile(confPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to validate config: %w", err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	return len(problems) == 0, nil
+}
+
+func exportData(confPath string, appendData bool, opts runOptions) error {
 	conf, err := cnf.LoadConf(confPath)
 	if err != nil {
 		return fmt.Errorf("failed to export data: %w", err)
 	}
+	if problems, err := cnf.ValidateConfFile(confPath); err == nil {
+		for _, p := range problems {
+			log.Warn().Str("item", p.Path).Msg("config problem: " + p.Message)
+		}
+	}
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt)
 	signal.Notify(signalChan, syscall.SIGTERM)
 
+	if opts.maxAtoms > 0 {
+		conf.MaxAtoms = opts.maxAtoms
+	}
+	if opts.dryRun {
+		log.Info().Msg("Running in the dry-run mode, no data will be written")
+		conf.DB.Type = "discard"
+		conf.SAttrExport = nil
+		conf.FreqListExport = nil
+		appendData = false
+	}
+	var bench *benchReport
+	if opts.bench {
+		log.Info().Msg("Running in the benchmark mode, no data will be written")
+		conf.DB.Type = "discard"
+		appendData = false
+		bench = newBenchReport()
+	}
+	if opts.cpuProfile != "" {
+		stopProfile, err := startCPUProfile(opts.cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to export data: %w", err)
+		}
+		defer stopProfile()
+	}
+	var metrics *metricsLogger
+	if opts.metricsInterval > 0 {
+		metrics = &metricsLogger{}
+		stopMetrics := make(chan struct{})
+		defer close(stopMetrics)
+		go metrics.run(opts.metricsInterval, stopMetrics)
+	}
+
 	t0 := time.Now()
 	statusChan, err := library.ExtractData(conf, appendData, signalChan)
 	if err != nil {
 		return fmt.Errorf("failed to export data: %w", err)
 	}
+	stats, _ := consumeStatuses(statusChan, metrics, bench)
+	log.Info().Dur("procTime", time.Since(t0)).Msg("Finished")
+	if bench != nil {
+		bench.print()
+	}
+	if opts.dryRun {
+		printDryRunReport(stats)
+	}
+	if opts.memProfile != "" {
+		if err := writeHeapProfile(opts.memProfile); err != nil {
+			return fmt.Errorf("failed to export data: %w", err)
+		}
+	}
+	return nil
+}
+
+// consumeStatuses reads all the statuses of an extraction run
+// and returns the run statistics and the first error reported
+// (if any)
+func consumeStatuses(
+	statusChan <-chan proc.Status,
+	metrics *metricsLogger,
+	bench *benchReport,
+) (*proc.RunStats, error) {
+	var stats *proc.RunStats
+	var firstErr error
 	for status := range statusChan {
 		if status.Error != nil {
 			log.Error().Err(status.Error).Msg("error during data extraction (not exiting)")
+			if firstErr == nil {
+				firstErr = status.Error
+			}
+		}
+		if status.Stats != nil {
+			stats = status.Stats
+			logRunStats(status.Stats)
+		}
+		if metrics != nil {
+			metrics.update(status)
+		}
+		if bench != nil {
+			bench.update(status)
 		}
 	}
-	log.Info().Dur("procTime", time.Since(t0)).Msg("Finished")
-	return nil
+	return stats, firstErr
 }
 
-func setupLog(jsonLog bool) {
-	if !jsonLog {
-		log.Logger = log.Output(
-			zerolog.ConsoleWriter{
-				Out:        os.Stderr,
-				TimeFormat: time.RFC3339,
-			},
-		)
+func logRunStats(stats *proc.RunStats) {
+	evt := log.Info().
+		Int("processedTokens", stats.ProcessedTokens).
+		Int("insertedAtoms", stats.InsertedAtoms).
+		Int("distinctNgrams", stats.DistinctNgrams).
+		Int("skippedLines", stats.SkippedLines).
+		Int("numErrors", stats.NumErrors).
+		Dur("elapsed", stats.Elapsed)
+	tables := make([]string, 0, len(stats.RowsWritten))
+	for table := range stats.RowsWritten {
+		tables = append(tables, table)
 	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		evt = evt.Int("rows_"+table, stats.RowsWritten[table])
+	}
+	for _, phase := range stats.Phases {
+		evt = evt.Dur("phase_"+phase.Name, phase.Duration)
+	}
+	evt.Msg("Extraction statistics")
 }
 
 func main() {
@@ -120,34 +280,72 @@ func main() {
 		fmt.Println("\nUsage:")
 		fmt.Println("vte create config.json\n\t(run an export configured in config.json, add data to a new database)")
 		fmt.Println("vte append config.json\n\t(run an export configured in config.json, add data to an existing database)")
-		fmt.Println("vte template\n\t(create a half empty sample config and write it to stdout)")
+		fmt.Println("vte batch manifest.json\n\t(run exports of all the corpora listed in manifest.json)")
+		fmt.Println("vte template corpus_name\n\t(create a half empty sample config and write it to stdout)")
+		fmt.Println("vte template vertical_file\n\t(create a sample config based on structures found in a vertical file)")
+		fmt.Println("vte init\n\t(interactively create a config based on a vertical file)")
+		fmt.Println("vte schema\n\t(write a JSON Schema of the config to stdout)")
+		fmt.Println("vte validate-config config.json\n\t(check config.json for unknown items, wrong types and missing required items)")
+		fmt.Println("vte keywords -focus-corpus c1 -ref-corpus c2 database.db\n\t(calculate keyness of n-grams of c1 compared to c2)")
 		fmt.Println("\n(config file should be named after a respective corpus name, e.g. syn_v4.json)")
 		fmt.Println("vte version\n\tshow detailed version information")
 	}
 	flag.Parse()
-	var jsonLog bool
+	var lopts logOptions
+	var opts runOptions
 
 	createCommand := flag.NewFlagSet("create", flag.ExitOnError)
-	createCommand.BoolVar(&jsonLog, "json-log", false, "set JSON logging format")
+	lopts.registerFlags(createCommand, "info")
+	opts.registerFlags(createCommand)
 	createCommand.Usage = func() {
 		fmt.Println("Usage: vte create conf.json")
 		fmt.Println("\nOptions:")
 		createCommand.PrintDefaults()
 	}
 	appendCommand := flag.NewFlagSet("append", flag.ExitOnError)
-	appendCommand.BoolVar(&jsonLog, "json-log", false, "set JSON logging format")
+	lopts.registerFlags(appendCommand, "info")
+	opts.registerFlags(appendCommand)
 	appendCommand.Usage = func() {
 		fmt.Println("Usage: vte append conf.json")
 		fmt.Println("\nOptions:")
 		createCommand.PrintDefaults()
 	}
+	batchCommand := flag.NewFlagSet("batch", flag.ExitOnError)
+	lopts.registerFlags(batchCommand, "info")
+	batchCommand.Usage = func() {
+		fmt.Println("Usage: vte batch manifest.json")
+		fmt.Println("\nOptions:")
+		batchCommand.PrintDefaults()
+	}
 	templateCommand := flag.NewFlagSet("template", flag.ExitOnError)
-	templateCommand.BoolVar(&jsonLog, "json-log", false, "set JSON logging format")
+	lopts.registerFlags(templateCommand, "info")
+	var scanMB int
+	templateCommand.IntVar(&scanMB, "scan-mb", 10, "number of megabytes of a vertical to scan")
 	templateCommand.Usage = func() {
-		fmt.Println("Usage: vte template [> conf.json]")
+		fmt.Println("Usage: vte template (corpus_name | vertical_file) [> conf.json]")
 		fmt.Println("\nOptions:")
 		createCommand.PrintDefaults()
 	}
+	initCommand := flag.NewFlagSet("init", flag.ExitOnError)
+	lopts.registerFlags(initCommand, "warn")
+	initCommand.IntVar(&scanMB, "scan-mb", 10, "number of megabytes of a vertical to scan")
+	initCommand.Usage = func() {
+		fmt.Println("Usage: vte init")
+		fmt.Println("\nOptions:")
+		initCommand.PrintDefaults()
+	}
+	keywordsCommand := flag.NewFlagSet("keywords", flag.ExitOnError)
+	lopts.registerFlags(keywordsCommand, "info")
+	var kwArgs keywordsArgs
+	keywordsCommand.StringVar(&kwArgs.focusCorpus, "focus-corpus", "", "corpus_id of the focus corpus")
+	keywordsCommand.StringVar(&kwArgs.refCorpus, "ref-corpus", "", "corpus_id of the reference corpus")
+	keywordsCommand.StringVar(
+		&kwArgs.refDBPath, "ref-db", "", "database with the reference corpus (default: the focus database)")
+	keywordsCommand.Usage = func() {
+		fmt.Println("Usage: vte keywords -focus-corpus corp1 -ref-corpus corp2 [-ref-db ref.db] focus.db")
+		fmt.Println("\nOptions:")
+		keywordsCommand.PrintDefaults()
+	}
 
 	if len(os.Args) < 2 {
 		fmt.Println("Action not specified")
@@ -160,8 +358,8 @@ func main() {
 			os.Exit(3)
 		}
 		createCommand.Parse(os.Args[2:])
-		setupLog(jsonLog)
-		if err := exportData(createCommand.Arg(0), false); err != nil {
+		setupLog(lopts)
+		if err := exportData(createCommand.Arg(0), false, opts); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -172,8 +370,19 @@ func main() {
 			os.Exit(3)
 		}
 		appendCommand.Parse(os.Args[2:])
-		setupLog(jsonLog)
-		if err := exportData(appendCommand.Arg(0), true); err != nil {
+		setupLog(lopts)
+		if err := exportData(appendCommand.Arg(0), true, opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	case "batch":
+		if len(os.Args) < 3 {
+			fmt.Println("Missing argument")
+			os.Exit(3)
+		}
+		batchCommand.Parse(os.Args[2:])
+		setupLog(lopts)
+		if err := runBatch(batchCommand.Arg(0)); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -183,8 +392,55 @@ func main() {
 			os.Exit(3)
 		}
 		templateCommand.Parse(os.Args[2:])
-		setupLog(jsonLog)
-		dumpNewConf(templateCommand.Arg(0))
+		setupLog(lopts)
+		if fs.IsFile(templateCommand.Arg(0)) {
+			conf, err := newConfFromVertical(templateCommand.Arg(0), scanMB)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			dumpNewConf(conf)
+
+		} else {
+			dumpNewConf(newConfTemplate(templateCommand.Arg(0)))
+		}
+	case "init":
+		initCommand.Parse(os.Args[2:])
+		setupLog(lopts)
+		if err := runInit(os.Stdin, os.Stdout, scanMB); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	case "schema":
+		dumpConfSchema()
+	case "validate-config":
+		if len(os.Args) < 3 {
+			fmt.Println("Missing argument")
+			os.Exit(3)
+		}
+		valid, err := validateConf(os.Args[2])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !valid {
+			os.Exit(1)
+		}
+		fmt.Println("Config is valid")
+	case "keywords":
+		keywordsCommand.Parse(os.Args[2:])
+		setupLog(lopts)
+		kwArgs.focusDBPath = keywordsCommand.Arg(0)
+		if kwArgs.focusDBPath == "" || kwArgs.focusCorpus == "" || kwArgs.refCorpus == "" {
+			keywordsCommand.Usage()
+			os.Exit(3)
+		}
+		numRows, err := runKeywords(kwArgs)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		log.Info().Int("numRows", numRows).Msg("Keywords written")
 	case "version":
 		fmt.Printf("vert-tagextract %s\nbuild date: %s\nlast commit: %s\n", version, build, gitCommit)
 	default:
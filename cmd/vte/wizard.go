@@ -0,0 +1,264 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bytedance/sonic/encoder"
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+)
+
+var serverDatabases = map[string]bool{
+	"mysql": true, "postgres": true, "mssql": true, "clickhouse": true, "elasticsearch": true, "redis": true,
+}
+
+type prompter struct {
+	rd  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints a question and returns an answer
+// (or the default value in case the answer is empty)
+func (p *prompter) ask(question, dflt string) (string, error) {
+	if dflt != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, dflt)
+
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.rd.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return dflt, nil
+	}
+	return line, nil
+}
+
+// askRequired repeats the question until a valid answer is provided
+func (p *prompter) askRequired(question, dflt string, validate func(string) error) (string, error) {
+	for {
+		ans, err := p.ask(question, dflt)
+		if err != nil {
+			return "", err
+		}
+		if ans == "" {
+			fmt.Fprintln(p.out, "  a value is required")
+			continue
+		}
+		if validate != nil {
+			if err := validate(ans); err != nil {
+				fmt.Fprintf(p.out, "  %s\n", err)
+				continue
+			}
+		}
+		return ans, nil
+	}
+}
+
+func (p *prompter) confirm(question string, dflt bool) (bool, error) {
+	dfltStr := "y/N"
+	if dflt {
+		dfltStr = "Y/n"
+	}
+	ans, err := p.ask(question+" ("+dfltStr+")", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(ans) {
+	case "":
+		return dflt, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// askStructures lets the user adjust the proposed structures
+// and their attributes
+func (p *prompter) askStructures(proposed map[string][]string, atomStruct string) (map[string][]string, error) {
+	names := make([]string, 0, len(proposed))
+	for name := range proposed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(p.out, "Structural attributes (comma separated, '-' to skip the structure):")
+	ans := make(map[string][]string)
+	for _, name := range names {
+		dflt := strings.Join(proposed[name], ",")
+		if dflt == "" {
+			dflt = "-"
+		}
+		attrs, err := p.ask("  "+name, dflt)
+		if err != nil {
+			return nil, err
+		}
+		if attrs == "-" {
+			if name == atomStruct {
+				ans[name] = []string{}
+			}
+			continue
+		}
+		ans[name] = make([]string, 0, len(proposed[name]))
+		for _, attr := range strings.Split(attrs, ",") {
+			if attr = strings.TrimSpace(attr); attr != "" {
+				ans[name] = append(ans[name], attr)
+			}
+		}
+	}
+	return ans, nil
+}
+
+func (p *prompter) askDatabase(conf *cnf.VTEConf) error {
+	var err error
+	conf.DB.Type, err = p.askRequired("Database type", "sqlite", nil)
+	if err != nil {
+		return err
+	}
+	dfltName := conf.Corpus
+	if conf.DB.Type == "sqlite" {
+		dfltName = conf.Corpus + ".db"
+	}
+	conf.DB.Name, err = p.askRequired("Database name (or file path)", dfltName, nil)
+	if err != nil {
+		return err
+	}
+	if serverDatabases[conf.DB.Type] {
+		if conf.DB.Host, err = p.ask("Database host", "localhost"); err != nil {
+			return err
+		}
+		if conf.DB.User, err = p.ask("Database user", ""); err != nil {
+			return err
+		}
+		// we do not want to store passwords in config files
+		conf.DB.Password = "${VTE_DB_PASSWORD}"
+		fmt.Fprintln(p.out, "  the password is read from the VTE_DB_PASSWORD environment variable")
+	}
+	return nil
+}
+
+// hasStructAttr tests whether a column name (e.g. doc_title)
+// refers to a configured structural attribute
+func hasStructAttr(structures map[string][]string, col string) bool {
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			if st+"_"+attr == col {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runInit interactively creates a new config based on a vertical file
+// and writes it to a file
+func runInit(in io.Reader, out io.Writer, scanMB int) error {
+	p := &prompter{rd: bufio.NewReader(in), out: out}
+	fmt.Fprintln(out, "This will create a new vert-tagextract config. Press enter to accept a proposed value.")
+	vertPath, err := p.askRequired("Path to the vertical file", "", func(v string) error {
+		if !fs.IsFile(v) {
+			return fmt.Errorf("file %s not found", v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Scanning the first %d MB of the vertical...\n", scanMB)
+	conf, err := newConfFromVertical(vertPath, scanMB)
+	if err != nil {
+		return err
+	}
+	if len(conf.Structures) == 0 {
+		return fmt.Errorf("no structures found in %s", vertPath)
+	}
+	if conf.Corpus, err = p.askRequired("Corpus name", conf.Corpus, nil); err != nil {
+		return err
+	}
+	conf.AtomStructure, err = p.askRequired("Atom structure", conf.AtomStructure, func(v string) error {
+		if _, ok := conf.Structures[v]; !ok {
+			return fmt.Errorf("structure %s not found in the vertical", v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if conf.Structures, err = p.askStructures(conf.Structures, conf.AtomStructure); err != nil {
+		return err
+	}
+	// keep only bibliography columns the user has not removed
+	bibCols := conf.BibView.Cols
+	conf.BibView.Cols = []string{}
+	for _, col := range bibCols {
+		if hasStructAttr(conf.Structures, col) {
+			conf.BibView.Cols = append(conf.BibView.Cols, col)
+		}
+	}
+	if !hasStructAttr(conf.Structures, conf.BibView.IDAttr) {
+		conf.BibView.IDAttr = ""
+	}
+	if err := p.askDatabase(conf); err != nil {
+		return err
+	}
+	// the password placeholder refers to a variable which
+	// may not be defined yet so we validate a copy without it
+	vconf := conf.WithoutPasswords()
+	vb, err := encoder.Encode(&vconf, 0)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	problems, err := cnf.ValidateConf(vb)
+	if err != nil {
+		return err
+	}
+	for _, prob := range problems {
+		fmt.Fprintf(out, "Warning: %s\n", prob)
+	}
+	outPath, err := p.askRequired("Write config to", conf.Corpus+".json", nil)
+	if err != nil {
+		return err
+	}
+	b, err := encoder.EncodeIndented(conf, "", "  ", encoder.SortMapKeys)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if fs.IsFile(outPath) {
+		overwrite, err := p.confirm(fmt.Sprintf("File %s exists. Overwrite?", outPath), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("config not written")
+		}
+	}
+	if err := os.WriteFile(outPath, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Fprintf(out, "Config written to %s. Run the extraction using: vte create %s\n", outPath, outPath)
+	return nil
+}
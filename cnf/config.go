@@ -18,7 +18,9 @@ package cnf
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/bytedance/sonic"
 	"github.com/czcorpus/vert-tagextract/v2/db"
@@ -27,6 +29,35 @@ import (
 
 const (
 	passwordReplacement = "*****"
+
+	// StructAttrWildcard used as an attribute of a structure
+	// (e.g. "doc": ["*"]) means that all the attributes found
+	// in the vertical are imported
+	StructAttrWildcard = "*"
+
+	InputFormatVertical = "vertical"
+	InputFormatTEI      = "tei"
+
+	StrictnessLenient = "lenient"
+	StrictnessStrict  = "strict"
+
+	DateInvalidError = "error"
+	DateInvalidNull  = "null"
+	DateInvalidKeep  = "keep"
+
+	// DateYearSuffix is appended to a date attribute to name
+	// its derived year attribute (see DateAttrs)
+	DateYearSuffix = "_year"
+
+	// DateMonthSuffix is appended to a date attribute to name
+	// its derived month attribute (see DateAttrs)
+	DateMonthSuffix = "_month"
+
+	HapaxesKeep     = "keep"
+	HapaxesDrop     = "drop"
+	HapaxesSeparate = "separate"
+
+	DfltCharNgramBoundaryMarker = "_"
 )
 
 // FilterConf specifies a plug-in containing
@@ -40,17 +71,140 @@ type FilterConf struct {
 // column position) we want to store and count as n-grams. This can
 // be used to extract all the unique PoS tags or frequency information
 // about words/lemmas.
+//
+// Some of the modes cannot be combined (see VTEConf.Validate):
+//   - CalcARF with MaxSkip, Spill and FlushEveryTokens,
+//   - Spill with DocFreqStructure, AssocMeasures and FlushEveryTokens,
+//   - FlushEveryTokens with MinFreq, DocFreqStructure, separate Hapaxes,
+//     AssocMeasures, IPM and DictEncoding,
+//   - separate Hapaxes with MinFreq,
+//   - CharNgrams with NgramSize > 1, MaxSkip and CalcARF.
+//
+// ARFSinglePass, DocFreqStructure and ItemCounts also disable
+// parallel processing (see VTEConf.NumWorkers).
 type NgramConf struct {
 	NgramSize   int            `json:"ngramSize"`
 	CalcARF     bool           `json:"calcARF"`
 	VertColumns db.VertColumns `json:"vertColumns"`
 
+	// PositionColumns, if set, makes each position of an n-gram stored
+	// in its own column (col0_1, col0_2,... for the vertical column 0)
+	// instead of a space separated string of the n-gram values.
+	PositionColumns bool `json:"positionColumns,omitempty"`
+
+	// Size is a shorthand for NgramSize with PositionColumns set
+	// (e.g. "size": 3 for trigrams). It is folded into NgramSize
+	// by ResolveSize so NgramSize remains the only n-gram order
+	// used by the extraction.
+	Size int `json:"size,omitempty"`
+
+	// MaxSkip, if positive, enables counting of skip-grams - i.e. n-grams
+	// of the configured size with up to MaxSkip tokens skipped between
+	// their positions (e.g. size 3 and MaxSkip 1 means all the 3-grams
+	// within a window of 4 tokens). N-grams with the same values are
+	// counted together regardless of the skipped positions.
+	MaxSkip int `json:"maxSkip,omitempty"`
+
+	// BoundaryStructures lists structures (e.g. s, doc) n-grams cannot
+	// cross. The token window is reset on both opening and closing tags
+	// of the structures. Please note that n-grams never cross atoms
+	// regardless of this setting.
+	BoundaryStructures []string `json:"boundaryStructures,omitempty"`
+
+	// DocFreqStructure, if set, enables counting of distinct occurrences
+	// of the structure (e.g. doc) each n-gram occurs in. The value is
+	// stored in the docfreq column of the colcounts table.
+	DocFreqStructure string `json:"docFreqStructure,omitempty"`
+
+	// AssocMeasures, if set, enables calculation of association measures
+	// (MI, t-score, logDice) of counted 2-grams. The values are stored
+	// in the mi, tscore and logdice columns of the colcounts table.
+	// The mode requires n-grams of size 2.
+	AssocMeasures bool `json:"assocMeasures,omitempty"`
+
+	// IPM, if set, adds an ipm column (instances per million tokens)
+	// to the colcounts table.
+	IPM bool `json:"ipm,omitempty"`
+
+	// ItemCounts, if set, enables writing of n-gram counts of individual
+	// atoms (a document-term matrix) into the corpus_itemcounts table
+	// keyed by item_id. The mode requires SelfJoin to be configured
+	// (to generate item_id).
+	// Please note that MinFreq and Hapaxes do not apply to the table.
+	ItemCounts bool `json:"itemCounts,omitempty"`
+
+	// TFIDF, if set, enables writing of TF-IDF values of n-grams per atom
+	// into the corpus_tfidf table. Atoms are used as documents so the mode
+	// requires DocFreqStructure to be set to the atom structure.
+	TFIDF bool `json:"tfidf,omitempty"`
+
+	// DictEncoding, if set, makes the colcounts table store integer
+	// IDs of n-gram column values instead of the values themselves.
+	// Distinct values of each vertical column are stored in a table
+	// colvalues_col[idx] (corpus_id, id, value).
+	DictEncoding bool `json:"dictEncoding,omitempty"`
+
+	// RoleColumnNames, if set, makes colcounts columns of vertical
+	// columns with a known role (word, lemma, sublemma, tag) named
+	// by the role (e.g. lemma instead of col2)
+	RoleColumnNames bool `json:"roleColumnNames,omitempty"`
+
+	// MinFreq, if greater than 1, specifies a minimum number
+	// of occurrences of an n-gram to be written to the colcounts
+	// table. Less frequent n-grams (e.g. hapaxes) are dropped.
+	MinFreq int `json:"minFreq,omitempty"`
+
+	// Hapaxes specifies how n-grams occurring only once are handled.
+	// They can be either kept in the colcounts table ("keep", default),
+	// dropped ("drop") or written to a separate table colcounts_hapax
+	// ("separate").
+	Hapaxes string `json:"hapaxes,omitempty"`
+
+	// ARFSinglePass, if set along with CalcARF, makes ARF calculated
+	// from token positions recorded while counting n-grams so the vertical
+	// is processed only once. This requires memory proportional to
+	// the number of tokens.
+	ARFSinglePass bool `json:"arfSinglePass,omitempty"`
+
+	// CharNgrams, if set, switches counting to character n-grams
+	// of values of the (only) configured vertical column. Each value
+	// is wrapped in boundary markers so n-grams at the beginning and
+	// at the end of words can be distinguished. The n-grams are stored
+	// in the colcounts table in place of token n-grams.
+	CharNgrams *CharNgramConf `json:"charNgrams,omitempty"`
+
+	// SummaryTopN specifies number of the most frequent n-grams
+	// logged (along with number of distinct n-grams and percentage
+	// of hapaxes) once the n-grams are written. If omitted, 10 is
+	// used. A negative value disables listing of the n-grams.
+	SummaryTopN int `json:"summaryTopN,omitempty"`
+
+	// NumShards specifies number of shards of the map storing
+	// counted n-grams. For larger corpora and parallel processing,
+	// higher values may reduce lock contention. If omitted,
+	// a default value is used.
+	NumShards int `json:"numShards,omitempty"`
+
+	// Spill, if set, enables writing partial n-gram counts to temporary
+	// files once the number of n-grams kept in memory reaches a limit.
+	// This allows processing of corpora with n-gram counts not fitting
+	// into memory.
+	Spill *SpillConf `json:"spill,omitempty"`
+
+	// FlushEveryTokens, if positive, specifies number of processed tokens
+	// after which partial n-gram counts are written into a staging table
+	// and removed from memory. Once the whole vertical is processed, staged
+	// counts are aggregated into the colcounts table. The mode requires
+	// a database backend supporting the staging (sqlite, mysql).
+	FlushEveryTokens int `json:"flushEveryTokens,omitempty"`
+
 	// Legacy values
 
-	// AttrColumns
+	// AttrColumns contains either indexes of vertical columns or names
+	// of positional attributes (see VTEConf.PosAttrs)
 	//
 	// Deprecated: please use VertColumns instead which groups idx and mod function
-	AttrColumns []int `json:"attrColumns,omitempty"`
+	AttrColumns []ColumnRef `json:"attrColumns,omitempty"`
 
 	// ColumnMods
 	//
@@ -58,6 +212,262 @@ type NgramConf struct {
 	ColumnMods []string `json:"columnMods,omitempty"`
 }
 
+// ColumnRef refers to a vertical column either by its index
+// or by a name of a positional attribute. In JSON, it is
+// represented either by a number or by a string.
+type ColumnRef struct {
+
+	// Idx is an index of the column. For references by name,
+	// it is set once the name is resolved (see VTEConf.ResolveColumnNames)
+	// and -1 is used until then.
+	Idx int
+
+	// Name is a name of a positional attribute (empty for
+	// references by index)
+	Name string
+}
+
+func (cr *ColumnRef) UnmarshalJSON(data []byte) error {
+	var idx int
+	if err := sonic.Unmarshal(data, &idx); err == nil {
+		cr.Idx = idx
+		cr.Name = ""
+		return nil
+	}
+	var name string
+	if err := sonic.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("column must be specified either by index or by name, found %s", string(data))
+	}
+	if name == "" {
+		return fmt.Errorf("empty column name")
+	}
+	cr.Idx = -1
+	cr.Name = name
+	return nil
+}
+
+func (cr ColumnRef) MarshalJSON() ([]byte, error) {
+	if cr.Name != "" {
+		return sonic.Marshal(cr.Name)
+	}
+	return sonic.Marshal(cr.Idx)
+}
+
+// CharNgramConf configures counting of character n-grams
+type CharNgramConf struct {
+
+	// Size specifies number of characters of counted n-grams
+	Size int `json:"size"`
+
+	// BoundaryMarker is added to the beginning and to the end of each
+	// value before n-grams are created. If omitted, "_" is used.
+	BoundaryMarker string `json:"boundaryMarker,omitempty"`
+}
+
+// GetBoundaryMarker returns a configured word boundary marker
+// or a default one
+func (cc *CharNgramConf) GetBoundaryMarker() string {
+	if cc.BoundaryMarker == "" {
+		return DfltCharNgramBoundaryMarker
+	}
+	return cc.BoundaryMarker
+}
+
+// SpillConf configures disk-based counting of n-grams
+type SpillConf struct {
+
+	// Dir is a directory for temporary files. If empty,
+	// the system default directory is used.
+	Dir string `json:"dir"`
+
+	// MaxNgramsInMemory is a memory limit expressed as a number
+	// of unique n-grams kept in memory before they are written
+	// to a temporary file. If omitted, a default value is used.
+	MaxNgramsInMemory int `json:"maxNgramsInMemory"`
+}
+
+// TEIConf configures conversion of TEI P5 documents into
+// structures and tokens
+type TEIConf struct {
+
+	// TokenElements lists elements representing tokens (each element
+	// produces a single token with the element's text as the word).
+	// If omitted, "w" and "pc" are used. Text outside of token elements
+	// is tokenized by whitespace.
+	TokenElements []string `json:"tokenElements,omitempty"`
+
+	// TokenAttrs lists attributes of token elements used as positional
+	// attributes (i.e. columns 1, 2,... of a corresponding vertical).
+	// If omitted, "lemma" and "pos" are used.
+	TokenAttrs []string `json:"tokenAttrs,omitempty"`
+}
+
+// FreqListExportConf configures export of counted n-grams
+// as a frequency list
+type FreqListExportConf struct {
+
+	// Dir is a directory where the file [corpus].freq.tsv is written
+	Dir string `json:"dir"`
+}
+
+// TagDistribConf configures calculation of frequency distributions
+// of values of a vertical column (typically PoS tags)
+type TagDistribConf struct {
+
+	// Column specifies a vertical column and an optional modifier
+	// function (e.g. for extracting PoS from a positional tag)
+	Column db.VertColumn `json:"column"`
+
+	// TextTypes lists structural attributes (in the struct_attr form,
+	// e.g. doc_genre) the distribution is also calculated for. Tokens
+	// outside a respective structure are not counted for the attribute.
+	TextTypes []string `json:"textTypes,omitempty"`
+}
+
+// ComputedAttrConf defines a structural attribute computed
+// from other structural attributes (see package expr)
+type ComputedAttrConf struct {
+
+	// Name is a name of the attribute in the [struct]_[attr] form
+	// (e.g. doc_period). The structure must be configured in Structures.
+	Name string `json:"name"`
+
+	// Expr is an expression calculating the value (e.g. decade(doc_year))
+	Expr string `json:"expr"`
+}
+
+// DateAttrConf configures normalization of values
+// of a date attribute (see VTEConf.DateAttrs)
+type DateAttrConf struct {
+
+	// Formats lists accepted formats of values which are tried in
+	// the defined order. A format consists of YYYY (year), MM (month
+	// with two digits), M (month with one or two digits), DD, D (day)
+	// and separators (e.g. DD.MM.YYYY, M/YYYY, YYYY).
+	Formats []string `json:"formats"`
+
+	// Invalid specifies handling of values matching none of the formats.
+	// With "error" (default), the value is reported as a processing error,
+	// "null" stores an empty value and "keep" keeps the original value.
+	// In both the latter cases, the derived attributes are empty.
+	Invalid string `json:"invalid,omitempty"`
+}
+
+// BinConf is a labeled interval of values of a binned
+// attribute (see BinnedAttrConf)
+type BinConf struct {
+
+	// Min is an inclusive lower bound of the interval.
+	// If omitted, the interval is not bounded from below.
+	Min *float64 `json:"min,omitempty"`
+
+	// Max is an exclusive upper bound of the interval.
+	// If omitted, the interval is not bounded from above.
+	Max *float64 `json:"max,omitempty"`
+
+	// Label is stored for values within the interval
+	Label string `json:"label"`
+}
+
+// BinnedAttrConf defines a structural attribute storing labeled
+// intervals of values of a numeric attribute (see VTEConf.BinnedAttrs)
+type BinnedAttrConf struct {
+
+	// Name is a name of the attribute in the [struct]_[attr] form
+	// (e.g. doc_size_class). The structure must be configured in Structures.
+	Name string `json:"name"`
+
+	// Source is a numeric structural attribute (in the [struct]_[attr]
+	// form) or "poscount" (number of tokens of an atom)
+	Source string `json:"source"`
+
+	// Bins lists intervals which are tried in the defined order.
+	// It cannot be combined with Width.
+	Bins []BinConf `json:"bins,omitempty"`
+
+	// Width, if positive, splits values into regular intervals
+	// [k * Width, (k + 1) * Width). It cannot be combined with Bins.
+	Width float64 `json:"width,omitempty"`
+
+	// Label is a template of labels of the Width intervals with {min}
+	// and {max} placeholders replaced by the interval bounds. If omitted,
+	// "{min}" is used (i.e. the same values as produced by the bucket
+	// function of ComputedAttrs).
+	Label string `json:"label,omitempty"`
+
+	// Default is stored for values not matching any of the Bins.
+	// If omitted, an empty value is stored.
+	Default string `json:"default,omitempty"`
+}
+
+// InferColumnTypesConf configures inference of column
+// types (see VTEConf.InferColumnTypes)
+type InferColumnTypesConf struct {
+
+	// SampleMB, if positive, limits the scanned part of each vertical
+	// file (in megabytes). By default, the whole files are scanned which
+	// means the vertical is processed twice. With a sample, values not
+	// matching the inferred types are stored as NULL (see ColumnTypes).
+	SampleMB int `json:"sampleMB,omitempty"`
+}
+
+// SAttrExportConf configures export of structural attributes
+// in the format accepted by cwb-s-encode (start, end, value)
+type SAttrExportConf struct {
+
+	// Dir is a directory where the files are written
+	Dir string `json:"dir"`
+}
+
+// ParserConf contains options passed to the vertical parser.
+// Structural attributes are always accumulated by the extractor
+// itself (see StackStructEval) so the parser is not configurable
+// in this respect.
+type ParserConf struct {
+
+	// LogProgressEachNth specifies how often (in lines) the parser
+	// logs its progress. If omitted, a value based on the file
+	// size is used.
+	LogProgressEachNth int `json:"logProgressEachNth,omitempty"`
+
+	// Strict, if true, makes any malformed line a fatal error
+	// (by default, such lines are logged and skipped). It cannot
+	// be combined with MaxParseErrors.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// GetLogProgressEachNth returns configured progress logging step
+// or zero (meaning a step based on the file size)
+func (pc *ParserConf) GetLogProgressEachNth() int {
+	if pc != nil {
+		return pc.LogProgressEachNth
+	}
+	return 0
+}
+
+// IsStrict returns true if malformed lines should be fatal errors
+func (pc *ParserConf) IsStrict() bool {
+	return pc != nil && pc.Strict
+}
+
+// GetTokenElements returns configured token elements or defaults
+// (also for a nil receiver)
+func (tc *TEIConf) GetTokenElements() []string {
+	if tc != nil && len(tc.TokenElements) > 0 {
+		return tc.TokenElements
+	}
+	return []string{"w", "pc"}
+}
+
+// GetTokenAttrs returns configured token attributes or defaults
+// (also for a nil receiver)
+func (tc *TEIConf) GetTokenAttrs() []string {
+	if tc != nil && len(tc.TokenAttrs) > 0 {
+		return tc.TokenAttrs
+	}
+	return []string{"lemma", "pos"}
+}
+
 func (nc *NgramConf) UpgradeLegacy() error {
 	if len(nc.AttrColumns) > 0 {
 		log.Warn().Msg("upgrading legacy n-gram configuration")
@@ -70,9 +480,13 @@ func (nc *NgramConf) UpgradeLegacy() error {
 			cmods = make([]string, len(nc.AttrColumns))
 		}
 		for i, v := range nc.AttrColumns {
+			if v.Idx < 0 {
+				return fmt.Errorf("unresolved column name %s in attrColumns", v.Name)
+			}
 			ans[i] = db.VertColumn{
-				Idx:   v,
+				Idx:   v.Idx,
 				ModFn: cmods[i],
+				Name:  v.Name,
 			}
 		}
 		nc.VertColumns = ans
@@ -80,6 +494,76 @@ func (nc *NgramConf) UpgradeLegacy() error {
 	return nil
 }
 
+// ResolveSize folds Size into NgramSize and PositionColumns (character
+// n-grams are always counted as unigrams). In case both Size and NgramSize
+// are configured, they must be equal.
+func (nc *NgramConf) ResolveSize() error {
+	if nc.CharNgrams != nil && nc.NgramSize == 0 {
+		nc.NgramSize = 1
+	}
+	if nc.Size == 0 {
+		return nil
+	}
+	if nc.Size < 0 {
+		return fmt.Errorf("invalid n-gram size %d", nc.Size)
+	}
+	if nc.NgramSize > 0 && nc.NgramSize != nc.Size {
+		return fmt.Errorf("ngrams.size and ngrams.ngramSize mismatch (%d vs. %d)", nc.Size, nc.NgramSize)
+	}
+	nc.NgramSize = nc.Size
+	nc.PositionColumns = true
+	nc.Size = 0
+	return nil
+}
+
+// CountColumns returns columns of the colcounts table. In case
+// PositionColumns is set for n-grams (NgramSize > 1), each vertical
+// column is expanded into a column per n-gram position. With
+// RoleColumnNames set, the columns are marked as named by role.
+func (nc *NgramConf) CountColumns() db.VertColumns {
+	positional := nc.PositionColumns && nc.NgramSize > 1
+	if !positional && !nc.RoleColumnNames {
+		return nc.VertColumns
+	}
+	ans := make(db.VertColumns, 0, len(nc.VertColumns)*nc.NgramSize)
+	for _, vc := range nc.VertColumns {
+		vc.NamedByRole = nc.RoleColumnNames
+		if !positional {
+			ans = append(ans, vc)
+			continue
+		}
+		for pos := 1; pos <= nc.NgramSize; pos++ {
+			col := vc
+			col.NgramPos = pos
+			ans = append(ans, col)
+		}
+	}
+	return ans
+}
+
+// HasDocFreq tests whether document frequencies
+// of n-grams are counted (see DocFreqStructure)
+func (nc *NgramConf) HasDocFreq() bool {
+	return nc.DocFreqStructure != ""
+}
+
+// HasHapaxTable tests whether n-grams occurring only
+// once are stored in a separate table (see Hapaxes)
+func (nc *NgramConf) HasHapaxTable() bool {
+	return nc.Hapaxes == HapaxesSeparate
+}
+
+// IsBoundary tests whether a structure is configured
+// as an n-gram boundary (see BoundaryStructures)
+func (nc *NgramConf) IsBoundary(structName string) bool {
+	for _, s := range nc.BoundaryStructures {
+		if s == structName {
+			return true
+		}
+	}
+	return false
+}
+
 func (nc *NgramConf) MaxRequiredColumn() int {
 	return nc.VertColumns.MaxColumn()
 }
@@ -88,20 +572,198 @@ func (nc *NgramConf) MaxRequiredColumn() int {
 // respective zero values (CalcARF == 0, len(VertColumns) == 0 etc.)
 // This is used e.g. to reset n-gram configuration in CNC-MASM
 func (nc *NgramConf) IsZero() bool {
-	return !nc.CalcARF && len(nc.VertColumns) == 0 && len(nc.ColumnMods) == 0 &&
-		len(nc.AttrColumns) == 0 && nc.NgramSize == 0
+	return !nc.CalcARF &&
+		!nc.ARFSinglePass &&
+		len(nc.VertColumns) == 0 &&
+		len(nc.ColumnMods) == 0 &&
+		len(nc.AttrColumns) == 0 &&
+		nc.NgramSize == 0 &&
+		nc.Size == 0 &&
+		!nc.PositionColumns &&
+		nc.MaxSkip == 0 &&
+		len(nc.BoundaryStructures) == 0 &&
+		nc.MinFreq == 0 &&
+		nc.DocFreqStructure == "" &&
+		!nc.TFIDF &&
+		!nc.ItemCounts &&
+		!nc.IPM &&
+		!nc.AssocMeasures &&
+		!nc.DictEncoding &&
+		!nc.RoleColumnNames &&
+		nc.Hapaxes == "" &&
+		nc.CharNgrams == nil &&
+		nc.SummaryTopN == 0 &&
+		nc.NumShards == 0 &&
+		nc.Spill == nil &&
+		nc.FlushEveryTokens == 0
 }
 
 // VTEConf holds configuration for a concrete
 // data extraction task.
 type VTEConf struct {
+
+	// Extends specifies a path to a base configuration (absolute or
+	// relative to the configuration file) the configuration inherits
+	// all the items from. Objects are merged recursively, other values
+	// (including arrays) are replaced. The base configuration can also
+	// extend another one.
+	Extends string `json:"extends,omitempty"`
+
+	// Registry specifies a path to a Manatee corpus registry file
+	// (absolute or relative to the configuration file). Vertical file,
+	// encoding, structures and names of n-gram columns not configured
+	// explicitly are taken from the registry.
+	Registry string `json:"registry,omitempty"`
+
+	// PosAttrs contains names of positional attributes in the order
+	// of vertical columns. The names can be used instead of column
+	// indexes in Ngrams.AttrColumns. If omitted, the names are taken
+	// from the registry (if configured).
+	PosAttrs []string `json:"posAttrs,omitempty"`
+
 	Corpus              string `json:"corpus"`
 	ParallelCorpus      string `json:"parallelCorpus,omitempty"`
 	AtomStructure       string `json:"atomStructure"`
 	AtomParentStructure string `json:"atomParentStructure"`
 	StackStructEval     bool   `json:"stackStructEval"`
 
-	// MaxNumErrors if reached then the process stops
+	// NumWorkers specifies number of parallel workers parsing
+	// the vertical file and counting n-grams. Values lower than 2
+	// mean sequential processing. Parallel processing requires
+	// atomStructure to be set and it cannot be combined with a custom
+	// filter, MaxAtoms, single pass ARF, docFreqStructure, itemCounts
+	// and TEI input.
+	NumWorkers int `json:"numWorkers,omitempty"`
+
+	// InternStrings enables interning of structural attribute values
+	// so repeated values (e.g. text types) are stored in memory only once.
+	// This is useful mainly with writers buffering many rows.
+	InternStrings bool `json:"internStrings,omitempty"`
+
+	// InputFormat specifies a format of input files - either "vertical"
+	// (default) or "tei" (TEI P5 XML, see TEI).
+	InputFormat string `json:"inputFormat,omitempty"`
+
+	// ColumnSeparator specifies a string separating positional attributes
+	// of tokens in the vertical (default is the tab character).
+	ColumnSeparator string `json:"columnSeparator,omitempty"`
+
+	// ColumnSeparatorRegexp is an alternative to ColumnSeparator specifying
+	// the separator by a regular expression (e.g. "\\s{2,}" for two or more
+	// whitespace characters)
+	ColumnSeparatorRegexp string `json:"columnSeparatorRegexp,omitempty"`
+
+	// TEI configures processing of TEI documents (only applied
+	// with InputFormat set to "tei")
+	TEI *TEIConf `json:"tei,omitempty"`
+
+	// SAttrExport, if set, enables export of configured structures
+	// and their attributes into CWB/Manatee-style region files
+	SAttrExport *SAttrExportConf `json:"sattrExport,omitempty"`
+
+	// FreqListExport, if set, enables export of counted n-grams
+	// sorted by their frequency (descending) into a TSV file.
+	// The mode cannot be combined with Ngrams.FlushEveryTokens.
+	FreqListExport *FreqListExportConf `json:"freqListExport,omitempty"`
+
+	// TagDistrib, if set, enables writing of frequencies of values
+	// of a vertical column per corpus and per configured text types
+	// into the corpus_tagdistrib and corpus_tagdistrib_tt tables
+	TagDistrib *TagDistribConf `json:"tagDistrib,omitempty"`
+
+	// MultiValues maps structural attributes (in the [struct]_[attr]
+	// form, e.g. doc_keywords) with values containing multiple items
+	// to delimiters of the items (e.g. "|"). Individual items are
+	// written into the liveattrs_multivalue table keyed by item_id
+	// (i.e. SelfJoin must be configured). The liveattrs_entry table
+	// keeps the original values.
+	MultiValues map[string]string `json:"multiValues,omitempty"`
+
+	// EmptyValues maps structural attributes (in the [struct]_[attr]
+	// form) to policies applied to their empty or missing values.
+	// Attributes without a policy are stored as NULL.
+	EmptyValues map[string]db.EmptyValuePolicy `json:"emptyValues,omitempty"`
+
+	// ColumnNames maps structural attributes (in the [struct]_[attr] form)
+	// to names of their output columns in liveattrs_entry (e.g. doc_srclang
+	// -> doc_source_language). All the other configuration items refer
+	// to structural attributes by their original names except for
+	// IndexedCols and BibView which refer to the output columns.
+	ColumnNames map[string]string `json:"columnNames,omitempty"`
+
+	// ColumnTypes maps structural attributes (in the [struct]_[attr] form)
+	// to types of their liveattrs_entry columns (integer, float, date,
+	// boolean). Attributes without a type are stored as strings.
+	// Values which cannot be converted are logged and stored as NULL.
+	ColumnTypes map[string]string `json:"columnTypes,omitempty"`
+
+	// ColumnSizes maps structural attributes (in the [struct]_[attr] form)
+	// to sizes of their VARCHAR columns in liveattrs_entry. Attributes
+	// without a size use db.DfltLAVarcharSize. Databases without
+	// limited text columns (e.g. SQLite) ignore the sizes.
+	ColumnSizes map[string]int `json:"columnSizes,omitempty"`
+
+	// InferColumnTypes, if set, enables scanning of the vertical before
+	// the schema is created to infer types (see ColumnTypes) and sizes
+	// (see ColumnSizes) of columns of structural attributes without
+	// explicitly configured types or sizes.
+	InferColumnTypes *InferColumnTypesConf `json:"inferColumnTypes,omitempty"`
+
+	// InferredColumns contains columns with types resolved
+	// by ResolveInferredColumnTypes
+	InferredColumns map[string]db.InferredColumn `json:"-"`
+
+	// ValueMaps maps structural attributes (in the [struct]_[attr] form)
+	// to tables translating their values (e.g. NOV -> novel). Values
+	// without a mapping are kept unchanged. In case of multi-value
+	// attributes, also the individual items are translated.
+	ValueMaps map[string]map[string]string `json:"valueMaps,omitempty"`
+
+	// DateAttrs maps date structural attributes (in the [struct]_[attr]
+	// form) to their accepted formats. The values are normalized to
+	// the ISO format (YYYY-MM-DD, or YYYY-MM and YYYY in case a format
+	// lacks a day or a month) and the attributes [attr]_year and
+	// [attr]_month (integer columns) are derived from them. The values
+	// are normalized after ValueMaps are applied and before ComputedAttrs
+	// are evaluated.
+	DateAttrs map[string]DateAttrConf `json:"dateAttrs,omitempty"`
+
+	// ComputedAttrs defines structural attributes computed from other
+	// attributes. The attributes are evaluated in the defined order
+	// so an expression can refer to previously defined computed attributes.
+	// Expressions see values already translated via ValueMaps.
+	ComputedAttrs []ComputedAttrConf `json:"computedAttrs,omitempty"`
+
+	// BinnedAttrs defines structural attributes storing labeled intervals
+	// of values of numeric attributes (e.g. doc_wordcount -> short, medium,
+	// long). The attributes are evaluated once an atom is closed so they
+	// can bin also poscount but they cannot be used in ComputedAttrs.
+	BinnedAttrs []BinnedAttrConf `json:"binnedAttrs,omitempty"`
+
+	// Parser contains options passed to the vertical parser
+	Parser *ParserConf `json:"parser,omitempty"`
+
+	// MaxParseErrors, if positive, specifies a number of malformed lines
+	// (unparseable tags, unmatched structures) which are skipped (and
+	// reported once the parsing is done) before the process stops.
+	// Such lines are then counted only here and never in MaxNumErrors
+	// (and vice versa, other processing errors do not consume this budget).
+	MaxParseErrors int `json:"maxParseErrors,omitempty"`
+
+	// Strictness specifies handling of structures and attributes found
+	// in the vertical but not configured in Structures (and of configured
+	// attributes which never appear). With "strict", the process stops
+	// on such a problem. With "lenient" (default), the problems are counted
+	// and summarized once the parsing is done.
+	Strictness string `json:"strictness,omitempty"`
+
+	// MaxAtoms, if positive, limits number of processed atoms
+	// (the rest of the input is skipped). This is useful mainly
+	// for testing of a configuration.
+	MaxAtoms int `json:"maxAtoms,omitempty"`
+
+	// MaxNumErrors if reached then the process stops. With MaxParseErrors
+	// set, malformed lines are not counted here.
 	MaxNumErrors int                 `json:"maxNumErrors"`
 	Structures   map[string][]string `json:"structures"`
 
@@ -122,7 +784,15 @@ type VTEConf struct {
 
 	DB db.Conf `json:"db"`
 
-	Encoding    string          `json:"encoding"`
+	// InputEncoding specifies encoding of input files (e.g. "iso-8859-2",
+	// "windows-1250"). The data are converted to UTF-8 before parsing.
+	// With "auto", the encoding is detected. If omitted, Encoding is used.
+	InputEncoding string `json:"inputEncoding,omitempty"`
+
+	// Encoding
+	//
+	// Deprecated: please use InputEncoding instead
+	Encoding    string          `json:"encoding,omitempty"`
 	SelfJoin    db.SelfJoinConf `json:"selfJoin"`
 	IndexedCols []string        `json:"indexedCols"`
 	BibView     db.BibViewConf  `json:"bibView"`
@@ -136,6 +806,14 @@ func (c *VTEConf) HasConfiguredFilter() bool {
 	return c.Filter.Lib != "" && c.Filter.Fn != ""
 }
 
+// GetInputEncoding returns configured encoding of input files
+func (c *VTEConf) GetInputEncoding() string {
+	if c.InputEncoding != "" {
+		return c.InputEncoding
+	}
+	return c.Encoding
+}
+
 func (c *VTEConf) HasConfiguredVertical() bool {
 	return c.VerticalFile != "" || len(c.VerticalFiles) > 0
 }
@@ -147,6 +825,248 @@ func (c *VTEConf) GetDefinedVerticals() []string {
 	return c.VerticalFiles
 }
 
+// ResolveColumnNames sets indexes of columns referred by names
+// of positional attributes in Ngrams.AttrColumns (see PosAttrs)
+func (c *VTEConf) ResolveColumnNames() error {
+	for i, ref := range c.Ngrams.AttrColumns {
+		if ref.Name == "" {
+			continue
+		}
+		if len(c.PosAttrs) == 0 {
+			return fmt.Errorf(
+				"cannot resolve column %s, no positional attributes configured (see posAttrs and registry)", ref.Name)
+		}
+		idx := -1
+		for j, attr := range c.PosAttrs {
+			if attr == ref.Name {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf(
+				"unknown positional attribute %s (available: %s)", ref.Name, strings.Join(c.PosAttrs, ", "))
+		}
+		c.Ngrams.AttrColumns[i].Idx = idx
+	}
+	return nil
+}
+
+// WildcardStructures returns sorted names of structures configured
+// with the attribute wildcard (see StructAttrWildcard)
+func (c *VTEConf) WildcardStructures() []string {
+	ans := make([]string, 0, len(c.Structures))
+	for st, attrs := range c.Structures {
+		for _, attr := range attrs {
+			if attr == StructAttrWildcard {
+				ans = append(ans, st)
+				break
+			}
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// ResolveWildcardStructures replaces attribute wildcards (see StructAttrWildcard)
+// with attributes found in the vertical. The found argument maps structures
+// to their attributes. Explicitly configured attributes are kept.
+func (c *VTEConf) ResolveWildcardStructures(found map[string][]string) {
+	for _, st := range c.WildcardStructures() {
+		attrs := make([]string, 0, len(c.Structures[st])+len(found[st]))
+		known := make(map[string]bool)
+		for _, attr := range c.Structures[st] {
+			if attr != StructAttrWildcard && !known[attr] {
+				attrs = append(attrs, attr)
+				known[attr] = true
+			}
+		}
+		foundAttrs := append([]string{}, found[st]...)
+		sort.Strings(foundAttrs)
+		for _, attr := range foundAttrs {
+			if !known[attr] {
+				attrs = append(attrs, attr)
+				known[attr] = true
+			}
+		}
+		if len(found[st]) == 0 {
+			log.Warn().Str("structure", st).Msg("No attributes found for a wildcard structure")
+		}
+		c.Structures[st] = attrs
+	}
+}
+
+// ResolveInferredColumnTypes sets types and VARCHAR sizes of columns of
+// structural attributes based on statistics of their values (mapping
+// [struct]_[attr] to db.ColumnValueStats). Explicitly configured types
+// and sizes are kept. Attributes with value maps and date attributes
+// (their values are translated) are not inferred. Multi-value attributes
+// and attributes keeping empty values are inferred as strings.
+func (c *VTEConf) ResolveInferredColumnTypes(stats map[string]*db.ColumnValueStats) {
+	c.InferredColumns = make(map[string]db.InferredColumn)
+	for st, attrs := range c.Structures {
+		for _, attr := range attrs {
+			name := st + "_" + attr
+			valStats, ok := stats[name]
+			if !ok {
+				continue
+			}
+			if _, ok := c.ValueMaps[name]; ok {
+				continue
+			}
+			if _, ok := c.DateAttrs[name]; ok {
+				continue
+			}
+			if policy, ok := c.EmptyValues[name]; ok && policy.Action == db.EmptyValueDefault {
+				withDefault := *valStats
+				withDefault.Add(policy.Default)
+				valStats = &withDefault
+			}
+			inferred := valStats.Infer()
+			_, isMultiValue := c.MultiValues[name]
+			if isMultiValue || c.EmptyValues[name].Action == db.EmptyValueKeep {
+				inferred = valStats.InferString()
+			}
+			if _, ok := c.ColumnTypes[name]; ok {
+				continue
+			}
+			if _, ok := c.ColumnSizes[name]; ok {
+				continue
+			}
+			if inferred.Type != db.ColumnTypeString {
+				if c.ColumnTypes == nil {
+					c.ColumnTypes = make(map[string]string)
+				}
+				c.ColumnTypes[name] = inferred.Type
+
+			} else {
+				if c.ColumnSizes == nil {
+					c.ColumnSizes = make(map[string]int)
+				}
+				c.ColumnSizes[name] = inferred.VarcharSize
+			}
+			c.InferredColumns[name] = inferred
+			log.Info().
+				Str("attr", name).
+				Str("type", inferred.Type).
+				Int("varcharSize", inferred.VarcharSize).
+				Int("maxLength", inferred.MaxLength).
+				Msg("Inferred column type")
+		}
+	}
+}
+
+// ResolveDateAttrs adds the year and month attributes derived from date
+// attributes (see DateAttrs) to their respective structures and sets
+// their column types to integer. The method can be called repeatedly.
+func (c *VTEConf) ResolveDateAttrs() error {
+	for name := range c.DateAttrs {
+		structName := c.attrStructure(name)
+		attr := strings.TrimPrefix(name, structName+"_")
+		if structName == "" || attr == "" {
+			return fmt.Errorf("date attribute %s does not belong to any configured structure", name)
+		}
+		for _, suffix := range []string{DateYearSuffix, DateMonthSuffix} {
+			c.addStructAttr(structName, attr+suffix)
+			if _, ok := c.ColumnTypes[name+suffix]; !ok {
+				if c.ColumnTypes == nil {
+					c.ColumnTypes = make(map[string]string)
+				}
+				c.ColumnTypes[name+suffix] = db.ColumnTypeInteger
+			}
+		}
+	}
+	return nil
+}
+
+// attrStructure returns a configured structure an attribute in the
+// [struct]_[attr] form belongs to. In case more structures match,
+// the longest one is used. An empty string means no match.
+func (c *VTEConf) attrStructure(name string) string {
+	var ans string
+	for st := range c.Structures {
+		if strings.HasPrefix(name, st+"_") && len(st) > len(ans) {
+			ans = st
+		}
+	}
+	return ans
+}
+
+// addStructAttr adds an attribute to a structure
+// in case it is not already present
+func (c *VTEConf) addStructAttr(structName, attr string) {
+	for _, v := range c.Structures[structName] {
+		if v == attr {
+			return
+		}
+	}
+	c.Structures[structName] = append(c.Structures[structName], attr)
+}
+
+// ResolveBinnedAttrs adds all the binned attributes (see BinnedAttrs)
+// to their respective structures. The method can be called repeatedly.
+func (c *VTEConf) ResolveBinnedAttrs() error {
+	for _, ba := range c.BinnedAttrs {
+		structName := c.attrStructure(ba.Name)
+		attr := strings.TrimPrefix(ba.Name, structName+"_")
+		if structName == "" || attr == "" {
+			return fmt.Errorf("binned attribute %s does not belong to any configured structure", ba.Name)
+		}
+		c.addStructAttr(structName, attr)
+	}
+	return nil
+}
+
+// ResolveDerivedAttrs adds all the attributes derived from other
+// attributes (see DateAttrs, BinnedAttrs, ComputedAttrs) to their
+// respective structures. The method can be called repeatedly.
+func (c *VTEConf) ResolveDerivedAttrs() error {
+	if err := c.ResolveDateAttrs(); err != nil {
+		return err
+	}
+	if err := c.ResolveBinnedAttrs(); err != nil {
+		return err
+	}
+	return c.ResolveComputedAttrs()
+}
+
+// WithDerivedAttrs returns a semi-shallow copy of the called config
+// with derived attributes resolved (see ResolveDerivedAttrs). Parts
+// modified by the resolving (structures, column types) are provided
+// as deep copies so the original config is left untouched.
+func (c *VTEConf) WithDerivedAttrs() (*VTEConf, error) {
+	ans := *c
+	ans.Structures = make(map[string][]string, len(c.Structures))
+	for k, v := range c.Structures {
+		ans.Structures[k] = append([]string{}, v...)
+	}
+	if c.ColumnTypes != nil {
+		ans.ColumnTypes = make(map[string]string, len(c.ColumnTypes))
+		for k, v := range c.ColumnTypes {
+			ans.ColumnTypes[k] = v
+		}
+	}
+	if err := ans.ResolveDerivedAttrs(); err != nil {
+		return nil, err
+	}
+	return &ans, nil
+}
+
+// ResolveComputedAttrs adds all the computed attributes (see ComputedAttrs)
+// to their respective structures so they become regular columns of
+// liveattrs_entry. The method can be called repeatedly.
+func (c *VTEConf) ResolveComputedAttrs() error {
+	for _, ca := range c.ComputedAttrs {
+		structName := c.attrStructure(ca.Name)
+		attr := strings.TrimPrefix(ca.Name, structName+"_")
+		if structName == "" || attr == "" {
+			return fmt.Errorf("computed attribute %s does not belong to any configured structure", ca.Name)
+		}
+		c.addStructAttr(structName, attr)
+	}
+	return nil
+}
+
 // WithoutPassword returns a new semi-shallow copy of the called
 // config with sensitive information replaced by `*`. By the
 // "semi-shallownes" we mean that in case a sensitive information
@@ -160,15 +1080,41 @@ func (c *VTEConf) WithoutPasswords() VTEConf {
 	return ans
 }
 
+// LoadConf loads a configuration from a JSON file. In case the
+// configuration extends a base one (see Extends), the configurations
+// are merged. All the ${VAR} occurrences in string values are replaced
+// by values of respective environment variables (an undefined variable
+// is an error).
 func LoadConf(confPath string) (*VTEConf, error) {
-	rawData, err := os.ReadFile(confPath)
+	rawData, err := loadMergedConf(confPath)
 	if err != nil {
 		return nil, err
 	}
+	rawData, err = expandEnvVars(rawData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment variables in %s: %w", confPath, err)
+	}
 	var conf VTEConf
 	err2 := sonic.Unmarshal(rawData, &conf)
 	if err2 != nil {
 		return nil, err2
 	}
+	if conf.Registry != "" {
+		regPath := conf.Registry
+		if !filepath.IsAbs(regPath) {
+			regPath = filepath.Join(filepath.Dir(confPath), regPath)
+		}
+		reg, err := LoadRegistry(regPath)
+		if err != nil {
+			return nil, err
+		}
+		conf.applyRegistry(reg)
+	}
+	if err := conf.ResolveColumnNames(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", confPath, err)
+	}
+	if err := conf.ResolveDerivedAttrs(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", confPath, err)
+	}
 	return &conf, nil
 }
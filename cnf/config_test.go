@@ -17,6 +17,10 @@
 package cnf
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/czcorpus/vert-tagextract/v2/db"
@@ -35,7 +39,7 @@ func TestNgramIsZero(t *testing.T) {
 	assert.False(t, cnf.IsZero())
 
 	cnf.CalcARF = false
-	cnf.AttrColumns = []int{0}
+	cnf.AttrColumns = []ColumnRef{{Idx: 0}}
 	assert.False(t, cnf.IsZero())
 
 	cnf.AttrColumns = nil
@@ -60,3 +64,257 @@ func TestNgramMaxRequiredColumnNoColumns(t *testing.T) {
 	var cnf NgramConf
 	assert.Equal(t, 0, cnf.MaxRequiredColumn())
 }
+
+func TestLoadConfExpandsEnvVars(t *testing.T) {
+	t.Setenv("VTE_TEST_PASSWORD", `se"cr${et}`)
+	t.Setenv("VTE_TEST_DIR", "/data")
+	path := filepath.Join(t.TempDir(), "conf.json")
+	data := `{"corpus": "test", "verticalFile": "${VTE_TEST_DIR}/test.vert", "maxNumErrors": 10,
+		"db": {"type": "mysql", "password": "${VTE_TEST_PASSWORD}"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	conf, err := LoadConf(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/test.vert", conf.VerticalFile)
+	assert.Equal(t, `se"cr${et}`, conf.DB.Password)
+	assert.Equal(t, 10, conf.MaxNumErrors)
+}
+
+func TestLoadConfUndefinedEnvVar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	data := `{"corpus": "test", "db": {"type": "mysql", "password": "${VTE_TEST_UNDEFINED_VAR}"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	_, err := LoadConf(path)
+	assert.ErrorContains(t, err, "VTE_TEST_UNDEFINED_VAR")
+}
+
+func TestLoadConfExtends(t *testing.T) {
+	dir := t.TempDir()
+	base := `{"atomStructure": "p", "structures": {"doc": ["id", "title"], "p": ["id"]},
+		"db": {"type": "mysql", "host": "db.example.org", "name": "liveattrs"}, "indexedCols": ["doc_id"]}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "base.json"), []byte(base), 0644))
+	corp := `{"extends": "base.json", "corpus": "syn2020", "structures": {"doc": ["id"]},
+		"db": {"name": "syn2020"}, "indexedCols": []}`
+	path := filepath.Join(dir, "syn2020.json")
+	assert.NoError(t, os.WriteFile(path, []byte(corp), 0644))
+	conf, err := LoadConf(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "syn2020", conf.Corpus)
+	assert.Equal(t, "p", conf.AtomStructure)
+	assert.Equal(t, map[string][]string{"doc": {"id"}, "p": {"id"}}, conf.Structures)
+	assert.Equal(t, "db.example.org", conf.DB.Host)
+	assert.Equal(t, "syn2020", conf.DB.Name)
+	assert.Equal(t, []string{}, conf.IndexedCols)
+}
+
+func TestLoadConfCyclicExtends(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"extends": "b.json"}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"extends": "a.json"}`), 0644))
+	_, err := LoadConf(filepath.Join(dir, "a.json"))
+	assert.ErrorContains(t, err, "cyclic extends")
+}
+
+func TestNgramCountColumns(t *testing.T) {
+	nc := NgramConf{Size: 3, VertColumns: db.VertColumns{{Idx: 0}, {Idx: 2}}}
+	assert.NoError(t, nc.ResolveSize())
+	assert.Equal(t, 3, nc.NgramSize)
+	assert.True(t, nc.PositionColumns)
+	assert.Equal(t, 0, nc.Size)
+	assert.Equal(
+		t,
+		[]string{"col0_1", "col0_2", "col0_3", "col2_1", "col2_2", "col2_3"},
+		db.GenerateColCountNames(nc.CountColumns()),
+	)
+	assert.NoError(t, nc.ResolveSize())
+	assert.Equal(t, 3, nc.NgramSize)
+	nc = NgramConf{NgramSize: 2, PositionColumns: true, VertColumns: db.VertColumns{{Idx: 0}}}
+	assert.Equal(t, []string{"col0_1", "col0_2"}, db.GenerateColCountNames(nc.CountColumns()))
+	nc = NgramConf{NgramSize: 2, VertColumns: db.VertColumns{{Idx: 0}, {Idx: 2}}}
+	assert.Equal(t, []string{"col0", "col2"}, db.GenerateColCountNames(nc.CountColumns()))
+	nc = NgramConf{NgramSize: 2, Size: 3}
+	assert.ErrorContains(t, nc.ResolveSize(), "mismatch")
+}
+
+func TestNgramRoleColumnNames(t *testing.T) {
+	cols := db.VertColumns{{Idx: 0, Role: db.RoleWord}, {Idx: 2, Role: db.RoleLemma}, {Idx: 3, Role: "custom"}}
+	nc := NgramConf{NgramSize: 1, RoleColumnNames: true, VertColumns: cols}
+	assert.Equal(t, []string{"word", "lemma", "col3"}, db.GenerateColCountNames(nc.CountColumns()))
+	assert.Equal(t, []string{"word", "lemma"}, db.RoleIndexedColCountNames(nc.CountColumns()))
+	assert.False(t, nc.VertColumns[0].NamedByRole)
+	nc = NgramConf{Size: 2, RoleColumnNames: true, VertColumns: cols}
+	assert.NoError(t, nc.ResolveSize())
+	assert.Equal(
+		t,
+		[]string{"word_1", "word_2", "lemma_1", "lemma_2", "col3_1", "col3_2"},
+		db.GenerateColCountNames(nc.CountColumns()),
+	)
+	assert.Equal(t, []string{"word_1", "lemma_1"}, db.RoleIndexedColCountNames(nc.CountColumns()))
+	for _, name := range db.GenerateColCountNames(nc.CountColumns()) {
+		assert.True(t, db.IsNgramColCountName(name))
+	}
+	assert.False(t, db.IsNgramColCountName("corpus_id"))
+	assert.False(t, db.IsNgramColCountName("count"))
+
+	assert.Equal(t, 2, cols.Lemma().Idx)
+	assert.True(t, cols.Tag().IsUndefined())
+	assert.NoError(t, cols.ValidateRoles())
+	cols = append(cols, db.VertColumn{Idx: 4, Role: db.RoleLemma})
+	assert.ErrorContains(t, cols.ValidateRoles(), "multiple columns")
+}
+
+func TestResolveWildcardStructures(t *testing.T) {
+	conf := VTEConf{Structures: map[string][]string{
+		"doc":  {"id", "*"},
+		"text": {"*"},
+		"p":    {"num"},
+	}}
+	assert.Equal(t, []string{"doc", "text"}, conf.WildcardStructures())
+	conf.ResolveWildcardStructures(map[string][]string{
+		"doc": {"year", "id", "author"},
+		"p":   {"num", "style"},
+	})
+	assert.Equal(t, []string{"id", "author", "year"}, conf.Structures["doc"])
+	assert.Equal(t, []string{}, conf.Structures["text"])
+	assert.Equal(t, []string{"num"}, conf.Structures["p"])
+	assert.Empty(t, conf.WildcardStructures())
+}
+
+func TestWithDerivedAttrs(t *testing.T) {
+	conf := VTEConf{
+		Structures:    map[string][]string{"doc": {"id", "pub"}},
+		DateAttrs:     map[string]DateAttrConf{"doc_pub": {Formats: []string{"YYYY"}}},
+		ComputedAttrs: []ComputedAttrConf{{Name: "doc_label", Expr: "doc_id"}},
+	}
+	resolved, err := conf.WithDerivedAttrs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "pub", "pub_year", "pub_month", "label"}, resolved.Structures["doc"])
+	assert.Equal(t, db.ColumnTypeInteger, resolved.ColumnTypes["doc_pub_year"])
+	assert.Equal(t, []string{"id", "pub"}, conf.Structures["doc"])
+	assert.Nil(t, conf.ColumnTypes)
+
+	conf.ComputedAttrs = []ComputedAttrConf{{Name: "page_label", Expr: "doc_id"}}
+	_, err = conf.WithDerivedAttrs()
+	assert.ErrorContains(t, err, "does not belong to any configured structure")
+}
+
+func TestResolveInferredColumnTypes(t *testing.T) {
+	newStats := func(values ...string) *db.ColumnValueStats {
+		ans := &db.ColumnValueStats{}
+		for _, v := range values {
+			ans.Add(v)
+		}
+		return ans
+	}
+	conf := VTEConf{
+		Structures: map[string][]string{
+			"doc": {"id", "year", "score", "pub", "open", "title", "genre", "kw", "extra"},
+		},
+		ColumnTypes: map[string]string{"doc_extra": db.ColumnTypeString},
+		ValueMaps:   map[string]map[string]string{"doc_genre": {"1": "fiction"}},
+		MultiValues: map[string]string{"doc_kw": "|"},
+		EmptyValues: map[string]db.EmptyValuePolicy{
+			"doc_score": {Action: db.EmptyValueDefault, Default: "n/a"},
+		},
+	}
+	conf.ResolveInferredColumnTypes(map[string]*db.ColumnValueStats{
+		"doc_id":    newStats("007", "123"),
+		"doc_year":  newStats("1999", "", "-2001"),
+		"doc_score": newStats("1.5", "2"),
+		"doc_pub":   newStats("2001-02-03"),
+		"doc_open":  newStats("true", "False"),
+		"doc_title": newStats("Krakatit", "R.U.R."),
+		"doc_genre": newStats("1", "2"),
+		"doc_kw":    newStats("1", "2"),
+		"doc_extra": newStats("1"),
+	})
+	assert.Equal(
+		t,
+		map[string]string{
+			"doc_year":  db.ColumnTypeInteger,
+			"doc_pub":   db.ColumnTypeDate,
+			"doc_open":  db.ColumnTypeBoolean,
+			"doc_extra": db.ColumnTypeString,
+		},
+		conf.ColumnTypes,
+	)
+	assert.Equal(t, map[string]int{"doc_id": 16, "doc_score": 16, "doc_title": 16, "doc_kw": 16}, conf.ColumnSizes)
+	assert.Equal(t, db.InferredColumn{Type: db.ColumnTypeInteger, NumValues: 2, MaxLength: 5}, conf.InferredColumns["doc_year"])
+	assert.NotContains(t, conf.InferredColumns, "doc_genre")
+	assert.NotContains(t, conf.InferredColumns, "doc_extra")
+
+	longValues := newStats(strings.Repeat("x", 100))
+	assert.Equal(t, 128, longValues.Infer().VarcharSize)
+	longValues.Add(strings.Repeat("x", 2000))
+	assert.Equal(t, db.DfltLAVarcharSize, longValues.Infer().VarcharSize)
+}
+
+func TestLoadConfAttrColumnNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	data := `{"corpus": "test", "posAttrs": ["word", "lemma", "tag"],
+		"ngrams": {"attrColumns": ["tag", 0], "columnMods": ["firstChar", ""]}, "db": {"type": "sqlite"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	conf, err := LoadConf(path)
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Ngrams.UpgradeLegacy())
+	assert.Equal(
+		t,
+		db.VertColumns{{Idx: 2, ModFn: "firstChar", Name: "tag"}, {Idx: 0}},
+		conf.Ngrams.VertColumns,
+	)
+}
+
+func TestLoadConfModFnList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	data := `{"corpus": "test", "ngrams": {"vertColumns": [
+		{"idx": 0, "modFn": ["toLower", "removeDiacritics", "firstChar"]}, {"idx": 1, "modFn": "toLower"}]},
+		"db": {"type": "sqlite"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	conf, err := LoadConf(path)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		db.VertColumns{{Idx: 0, ModFn: "toLower:removeDiacritics:firstChar"}, {Idx: 1, ModFn: "toLower"}},
+		conf.Ngrams.VertColumns,
+	)
+}
+
+func TestLoadConfUnknownAttrColumnName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	data := `{"corpus": "test", "posAttrs": ["word", "lemma"],
+		"ngrams": {"attrColumns": ["tag"]}, "db": {"type": "sqlite"}}`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	_, err := LoadConf(path)
+	assert.ErrorContains(t, err, "unknown positional attribute tag")
+}
+
+func TestTEIConfDefaults(t *testing.T) {
+	var tc *TEIConf
+	assert.Equal(t, []string{"w", "pc"}, tc.GetTokenElements())
+	assert.Equal(t, []string{"lemma", "pos"}, tc.GetTokenAttrs())
+
+	tc = &TEIConf{TokenElements: []string{"tok"}, TokenAttrs: []string{"lemma"}}
+	assert.Equal(t, []string{"tok"}, tc.GetTokenElements())
+	assert.Equal(t, []string{"lemma"}, tc.GetTokenAttrs())
+}
+
+func TestMarshalOmitsTEIConf(t *testing.T) {
+	data, err := json.Marshal(&VTEConf{})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"tei"`)
+}
+
+func TestParserConfDefaults(t *testing.T) {
+	var pc *ParserConf
+	assert.Equal(t, 0, pc.GetLogProgressEachNth())
+	assert.False(t, pc.IsStrict())
+
+	pc = &ParserConf{LogProgressEachNth: 1000, Strict: true}
+	assert.Equal(t, 1000, pc.GetLogProgressEachNth())
+	assert.True(t, pc.IsStrict())
+}
+
+func TestMarshalOmitsParserConf(t *testing.T) {
+	data, err := json.Marshal(&VTEConf{})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"parser"`)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvString replaces all ${VAR} occurrences in s with
+// values of respective environment variables. Undefined
+// variables are reported as an error.
+func expandEnvString(s string) (string, error) {
+	var err error
+	ans := envVarRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		name := envVarRegexp.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined environment variable %s", name)
+		}
+		return v
+	})
+	return ans, err
+}
+
+func expandEnvValue(v any) (any, error) {
+	switch tv := v.(type) {
+	case string:
+		return expandEnvString(tv)
+	case []any:
+		for i, item := range tv {
+			exp, err := expandEnvValue(item)
+			if err != nil {
+				return nil, err
+			}
+			tv[i] = exp
+		}
+	case map[string]any:
+		for k, item := range tv {
+			exp, err := expandEnvValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			tv[k] = exp
+		}
+	}
+	return v, nil
+}
+
+// expandEnvVars replaces ${VAR} occurrences in all string values
+// of a JSON document with values of respective environment variables.
+// Values are expanded after the document is parsed so the variables
+// may contain any characters (including quotes).
+func expandEnvVars(rawData []byte) ([]byte, error) {
+	if !envVarRegexp.Match(rawData) {
+		return rawData, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(rawData))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc, err := expandEnvValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadConfData loads a JSON configuration as a generic document.
+// In case the configuration extends another one (see VTEConf.Extends),
+// the base configuration is loaded first (recursively) and the current
+// configuration is merged into it. The 'visited' map prevents cycles.
+func loadConfData(confPath string, visited map[string]bool) (map[string]any, error) {
+	absPath, err := filepath.Abs(confPath)
+	if err != nil {
+		return nil, err
+	}
+	if visited[absPath] {
+		return nil, fmt.Errorf("cyclic extends in %s", confPath)
+	}
+	visited[absPath] = true
+	rawData, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(rawData))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", confPath, err)
+	}
+	ext, ok := doc["extends"]
+	if !ok || ext == nil {
+		return doc, nil
+	}
+	basePath, ok := ext.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid extends in %s: expected string", confPath)
+	}
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(absPath), basePath)
+	}
+	base, err := loadConfData(basePath, visited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load base config of %s: %w", confPath, err)
+	}
+	delete(doc, "extends")
+	delete(base, "extends")
+	return mergeConfData(base, doc), nil
+}
+
+// mergeConfData merges the 'override' document into the 'base' one.
+// Objects are merged recursively, all the other values (including
+// arrays) are replaced.
+func mergeConfData(base, override map[string]any) map[string]any {
+	for k, v := range override {
+		baseObj, baseIsObj := base[k].(map[string]any)
+		obj, isObj := v.(map[string]any)
+		if baseIsObj && isObj {
+			base[k] = mergeConfData(baseObj, obj)
+
+		} else {
+			base[k] = v
+		}
+	}
+	return base
+}
+
+// loadMergedConf loads a configuration file with all its
+// base configurations merged in and returns it as JSON
+func loadMergedConf(confPath string) ([]byte, error) {
+	doc, err := loadConfData(confPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
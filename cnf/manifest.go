@@ -0,0 +1,121 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestItem specifies a single corpus to be processed
+// within a batch
+type ManifestItem struct {
+
+	// Config is a path to the corpus configuration (absolute
+	// or relative to the manifest file)
+	Config string `json:"config"`
+
+	// Corpus, if set, overrides the corpus name from the configuration
+	// (this allows using a single configuration for multiple corpora)
+	Corpus string `json:"corpus,omitempty"`
+
+	// VerticalFile, if set, overrides the vertical file(s) from
+	// the configuration. A relative path is resolved against
+	// the manifest directory.
+	VerticalFile string `json:"verticalFile,omitempty"`
+
+	// Append specifies that the data should be added to an existing database
+	Append bool `json:"append,omitempty"`
+}
+
+// Manifest lists multiple corpora to be processed in one run
+type Manifest struct {
+	Corpora []ManifestItem `json:"corpora"`
+
+	// SharedDatabase specifies that all the corpora are written
+	// to a single database - i.e. the first corpus creates the database
+	// and all the others are appended to it (e.g. aligned corpora
+	// of InterCorp)
+	SharedDatabase bool `json:"sharedDatabase,omitempty"`
+
+	// ContinueOnError specifies that a failed corpus does not
+	// stop processing of the remaining ones
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+
+	dir string
+}
+
+// IsAppend returns true if the idx-th corpus should be appended
+// to an existing database
+func (m *Manifest) IsAppend(idx int) bool {
+	return m.Corpora[idx].Append || (m.SharedDatabase && idx > 0)
+}
+
+// LoadItemConf loads a configuration of the idx-th corpus
+// with all the item's overrides applied
+func (m *Manifest) LoadItemConf(idx int) (*VTEConf, error) {
+	item := m.Corpora[idx]
+	confPath := item.Config
+	if !filepath.IsAbs(confPath) {
+		confPath = filepath.Join(m.dir, confPath)
+	}
+	conf, err := LoadConf(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config of manifest item %d: %w", idx, err)
+	}
+	if item.Corpus != "" {
+		conf.Corpus = item.Corpus
+	}
+	if item.VerticalFile != "" {
+		conf.VerticalFile = item.VerticalFile
+		if !filepath.IsAbs(conf.VerticalFile) && !strings.HasPrefix(conf.VerticalFile, "|") &&
+			!strings.Contains(conf.VerticalFile, "://") {
+			conf.VerticalFile = filepath.Join(m.dir, conf.VerticalFile)
+		}
+		conf.VerticalFiles = nil
+	}
+	return conf, nil
+}
+
+// LoadManifest loads a batch manifest from a JSON file
+func LoadManifest(manifestPath string) (*Manifest, error) {
+	rawData, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var ans Manifest
+	if err := json.Unmarshal(rawData, &ans); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", manifestPath, err)
+	}
+	if len(ans.Corpora) == 0 {
+		return nil, fmt.Errorf("no corpora in manifest %s", manifestPath)
+	}
+	for i, item := range ans.Corpora {
+		if item.Config == "" {
+			return nil, fmt.Errorf("missing config in manifest item %d", i)
+		}
+	}
+	absPath, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	ans.dir = filepath.Dir(absPath)
+	return &ans, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	conf := `{"corpus": "base", "atomStructure": "p", "verticalFiles": ["/data/base.vert"], "db": {"type": "sqlite"}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "base.json"), []byte(conf), 0644))
+	manifest := `{"sharedDatabase": true, "corpora": [
+		{"config": "base.json"},
+		{"config": "base.json", "corpus": "ic_en", "verticalFile": "en.vert"}
+	]}`
+	path := filepath.Join(dir, "manifest.json")
+	assert.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+
+	m, err := LoadManifest(path)
+	assert.NoError(t, err)
+	assert.False(t, m.IsAppend(0))
+	assert.True(t, m.IsAppend(1))
+	c0, err := m.LoadItemConf(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "base", c0.Corpus)
+	assert.Equal(t, []string{"/data/base.vert"}, c0.VerticalFiles)
+	c1, err := m.LoadItemConf(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "ic_en", c1.Corpus)
+	assert.Equal(t, filepath.Join(dir, "en.vert"), c1.VerticalFile)
+	assert.Empty(t, c1.VerticalFiles)
+}
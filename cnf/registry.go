@@ -0,0 +1,197 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Registry contains information obtained from a Manatee
+// corpus registry file relevant for the extraction
+type Registry struct {
+
+	// Vertical is a path to the vertical file (VERTICAL)
+	Vertical string
+
+	// Encoding is an encoding of the vertical (ENCODING)
+	Encoding string
+
+	// PosAttrs contains names of positional attributes in the order
+	// of vertical columns (dynamic attributes are not included)
+	PosAttrs []string
+
+	// Structures contains structures and their attributes
+	// (dynamic attributes are not included)
+	Structures map[string][]string
+}
+
+// tokenizeRegistry splits registry data into tokens
+// (keys, values, block braces). Quoted values are unquoted,
+// comments are removed.
+func tokenizeRegistry(confPath string) ([]string, error) {
+	f, err := os.Open(confPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ans := make([]string, 0, 200)
+	sc := bufio.NewScanner(f)
+	lineNum := 0
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '#':
+				i = len(line)
+			case line[i] == ' ' || line[i] == '\t' || line[i] == '\r':
+			case line[i] == '"':
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated string on line %d", lineNum)
+				}
+				ans = append(ans, line[i+1:i+1+end])
+				i += end + 1
+			case line[i] == '{' || line[i] == '}':
+				ans = append(ans, line[i:i+1])
+			default:
+				end := strings.IndexAny(line[i:], " \t\r{}#\"")
+				if end < 0 {
+					end = len(line) - i
+				}
+				ans = append(ans, line[i:i+end])
+				i += end - 1
+			}
+		}
+	}
+	return ans, sc.Err()
+}
+
+type registryItem struct {
+	key      string
+	value    string
+	children []registryItem
+}
+
+func (ri registryItem) isDynamic() bool {
+	for _, ch := range ri.children {
+		if ch.key == "DYNAMIC" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRegistryItems parses "KEY value [{ ... }]" items up to
+// the end of the current block
+func parseRegistryItems(tokens []string, pos int) ([]registryItem, int, error) {
+	ans := make([]registryItem, 0, 20)
+	for pos < len(tokens) {
+		if tokens[pos] == "}" {
+			return ans, pos + 1, nil
+		}
+		if pos+1 >= len(tokens) || tokens[pos+1] == "{" || tokens[pos+1] == "}" {
+			return nil, pos, fmt.Errorf("missing value of %s", tokens[pos])
+		}
+		item := registryItem{key: strings.ToUpper(tokens[pos]), value: tokens[pos+1]}
+		pos += 2
+		if pos < len(tokens) && tokens[pos] == "{" {
+			var err error
+			item.children, pos, err = parseRegistryItems(tokens, pos+1)
+			if err != nil {
+				return nil, pos, err
+			}
+		}
+		ans = append(ans, item)
+	}
+	return ans, pos, nil
+}
+
+// normalizeRegistryEncoding converts encoding names used
+// in registry files (e.g. "iso8859-2") to the ones used
+// by the vertical parser
+func normalizeRegistryEncoding(enc string) string {
+	enc = strings.ToLower(enc)
+	switch {
+	case enc == "utf8":
+		return "utf-8"
+	case strings.HasPrefix(enc, "iso8859"):
+		return "iso-8859" + strings.TrimPrefix(enc, "iso8859")
+	case strings.HasPrefix(enc, "cp12"):
+		return "windows-" + strings.TrimPrefix(enc, "cp")
+	}
+	return enc
+}
+
+// LoadRegistry loads a Manatee corpus registry file
+func LoadRegistry(confPath string) (*Registry, error) {
+	tokens, err := tokenizeRegistry(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry %s: %w", confPath, err)
+	}
+	items, _, err := parseRegistryItems(tokens, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry %s: %w", confPath, err)
+	}
+	ans := &Registry{Structures: make(map[string][]string)}
+	for _, item := range items {
+		switch item.key {
+		case "VERTICAL":
+			ans.Vertical = item.value
+		case "ENCODING":
+			ans.Encoding = normalizeRegistryEncoding(item.value)
+		case "ATTRIBUTE":
+			if !item.isDynamic() {
+				ans.PosAttrs = append(ans.PosAttrs, item.value)
+			}
+		case "STRUCTURE":
+			attrs := make([]string, 0, len(item.children))
+			for _, ch := range item.children {
+				if ch.key == "ATTRIBUTE" && !ch.isDynamic() {
+					attrs = append(attrs, ch.value)
+				}
+			}
+			ans.Structures[item.value] = attrs
+		}
+	}
+	return ans, nil
+}
+
+// applyRegistry fills in items not configured explicitly
+// with values from a corpus registry
+func (c *VTEConf) applyRegistry(reg *Registry) {
+	if !c.HasConfiguredVertical() {
+		c.VerticalFile = reg.Vertical
+	}
+	if c.GetInputEncoding() == "" {
+		c.InputEncoding = reg.Encoding
+	}
+	if len(c.Structures) == 0 {
+		c.Structures = reg.Structures
+	}
+	if len(c.PosAttrs) == 0 {
+		c.PosAttrs = reg.PosAttrs
+	}
+	for i, col := range c.Ngrams.VertColumns {
+		if col.Name == "" && col.Idx >= 0 && col.Idx < len(reg.PosAttrs) {
+			c.Ngrams.VertColumns[i].Name = reg.PosAttrs[col.Idx]
+		}
+	}
+}
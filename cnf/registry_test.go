@@ -0,0 +1,86 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRegistry = `
+NAME "Test corpus"
+PATH /corpora/data/test
+VERTICAL "| zcat /corpora/vert/test.vert.gz"
+ENCODING "iso8859-2"
+
+ATTRIBUTE word
+ATTRIBUTE lemma {
+	LABEL "lemma # not a comment"
+}
+ATTRIBUTE lc {
+	DYNAMIC utf8lowercase
+	DYNLIB internal
+	FROMATTR word
+}
+ATTRIBUTE tag
+STRUCTURE doc {
+	ATTRIBUTE id
+	ATTRIBUTE "title" # a comment
+	ATTRIBUTE year {
+		MULTIVALUE yes
+	}
+}
+STRUCTURE p
+STRUCTURE s
+{
+	ATTRIBUTE id
+}
+`
+
+func TestLoadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test")
+	assert.NoError(t, os.WriteFile(path, []byte(testRegistry), 0644))
+	reg, err := LoadRegistry(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "| zcat /corpora/vert/test.vert.gz", reg.Vertical)
+	assert.Equal(t, "iso-8859-2", reg.Encoding)
+	assert.Equal(t, []string{"word", "lemma", "tag"}, reg.PosAttrs)
+	assert.Equal(
+		t,
+		map[string][]string{"doc": {"id", "title", "year"}, "p": {}, "s": {"id"}},
+		reg.Structures,
+	)
+}
+
+func TestLoadConfWithRegistry(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "test"), []byte(testRegistry), 0644))
+	conf := `{"corpus": "test", "atomStructure": "doc", "registry": "test",
+		"structures": {"doc": ["id"]}, "ngrams": {"vertColumns": [{"idx": 0}, {"idx": 2, "name": "pos"}]}}`
+	path := filepath.Join(dir, "test.json")
+	assert.NoError(t, os.WriteFile(path, []byte(conf), 0644))
+	c, err := LoadConf(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "| zcat /corpora/vert/test.vert.gz", c.VerticalFile)
+	assert.Equal(t, "iso-8859-2", c.InputEncoding)
+	assert.Equal(t, map[string][]string{"doc": {"id"}}, c.Structures)
+	assert.Equal(t, db.VertColumns{{Idx: 0, Name: "word"}, {Idx: 2, Name: "pos"}}, c.Ngrams.VertColumns)
+}
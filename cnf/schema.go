@@ -0,0 +1,254 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// JSONSchema returns a JSON Schema describing VTEConf. The schema
+// is derived from the configuration types so it always matches
+// the configuration items accepted by the current version.
+func JSONSchema() map[string]any {
+	ans := typeSchema(reflect.TypeOf(VTEConf{}))
+	ans["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	ans["title"] = "vert-tagextract configuration"
+	ans["required"] = []string{"corpus", "atomStructure", "db"}
+	props := ans["properties"].(map[string]any)
+	props["corpus"].(map[string]any)["minLength"] = 1
+	props["atomStructure"].(map[string]any)["minLength"] = 1
+	props["inputFormat"].(map[string]any)["enum"] = []string{InputFormatVertical, InputFormatTEI}
+	props["strictness"].(map[string]any)["enum"] = []string{StrictnessLenient, StrictnessStrict}
+	ngramProps := props["ngrams"].(map[string]any)["properties"].(map[string]any)
+	ngramProps["hapaxes"].(map[string]any)["enum"] = []string{HapaxesKeep, HapaxesDrop, HapaxesSeparate}
+	ngramProps["attrColumns"] = map[string]any{
+		"type":  "array",
+		"items": map[string]any{"type": []string{"integer", "string"}},
+	}
+	dbProps := props["db"].(map[string]any)
+	dbProps["required"] = []string{"type"}
+	return ans
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		props := make(map[string]any)
+		if t == reflect.TypeOf(db.VertColumn{}) {
+			props["modFn"] = map[string]any{
+				"type":  []string{"string", "array"},
+				"items": map[string]any{"type": "string"},
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if _, ok := props[name]; !ok {
+				props[name] = typeSchema(field.Type)
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// ValidationError describes a single problem found
+// in a configuration
+type ValidationError struct {
+
+	// Path is a path of the problematic item
+	// (e.g. "db.batchSize", "verticalFiles[1]")
+	Path string
+
+	Message string
+}
+
+func (ve ValidationError) Error() string {
+	if ve.Path == "" {
+		return ve.Message
+	}
+	return fmt.Sprintf("%s: %s", ve.Path, ve.Message)
+}
+
+// ValidateConf checks a JSON configuration against JSONSchema
+// and returns all the problems found (unknown keys, wrong types,
+// missing required items). Environment variables are expanded
+// before the validation (see LoadConf). An error is returned
+// only in case the data cannot be processed at all (e.g. invalid
+// JSON syntax).
+func ValidateConf(rawData []byte) ([]ValidationError, error) {
+	rawData, err := expandEnvVars(rawData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment variables: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(rawData))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	ans := make([]ValidationError, 0, 10)
+	validateValue(JSONSchema(), doc, "", &ans)
+	return ans, nil
+}
+
+// ValidateConfFile is a variant of ValidateConf reading
+// the configuration from a file (including its base configurations,
+// see VTEConf.Extends)
+func ValidateConfFile(confPath string) ([]ValidationError, error) {
+	rawData, err := loadMergedConf(confPath)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateConf(rawData)
+}
+
+func itemPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonTypeName(v any) string {
+	switch tv := v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := tv.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+func validateValue(schema map[string]any, value any, path string, errs *[]ValidationError) {
+	if value == nil {
+		return // null is accepted (i.e. a default value is used)
+	}
+	expected, ok := schema["type"].(string)
+	if !ok {
+		return
+	}
+	actual := jsonTypeName(value)
+	if actual != expected && !(expected == "number" && actual == "integer") {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", expected, actual)})
+		return
+	}
+	switch tv := value.(type) {
+	case string:
+		if minLength, ok := schema["minLength"].(int); ok && len(tv) < minLength {
+			*errs = append(*errs, ValidationError{Path: path, Message: "must not be empty"})
+		}
+		if enum, ok := schema["enum"].([]string); ok && tv != "" {
+			valid := false
+			for _, item := range enum {
+				if item == tv {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				*errs = append(*errs, ValidationError{
+					Path:    path,
+					Message: fmt.Sprintf("invalid value %s (expected one of: %s)", tv, strings.Join(enum, ", ")),
+				})
+			}
+		}
+	case json.Number:
+		if minimum, ok := schema["minimum"].(int); ok {
+			if n, err := tv.Int64(); err == nil && n < int64(minimum) {
+				*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("must be at least %d", minimum)})
+			}
+		}
+	case []any:
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range tv {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case map[string]any:
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, ok := tv[name]; !ok {
+					*errs = append(*errs, ValidationError{Path: itemPath(path, name), Message: "missing required item"})
+				}
+			}
+		}
+		keys := make([]string, 0, len(tv))
+		for k := range tv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		props, _ := schema["properties"].(map[string]any)
+		for _, k := range keys {
+			if propSchema, ok := props[k].(map[string]any); ok {
+				validateValue(propSchema, tv[k], itemPath(path, k), errs)
+
+			} else if addSchema, ok := schema["additionalProperties"].(map[string]any); ok {
+				validateValue(addSchema, tv[k], itemPath(path, k), errs)
+
+			} else if schema["additionalProperties"] == false {
+				*errs = append(*errs, ValidationError{Path: itemPath(path, k), Message: "unknown configuration item"})
+			}
+		}
+	}
+}
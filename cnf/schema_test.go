@@ -0,0 +1,78 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConf(t *testing.T) {
+	data := `{
+		"corpus": "test",
+		"atomStructure": "",
+		"maxNumErrors": "10",
+		"verticalFiles": ["a.vert", 2],
+		"structures": {"doc": ["id", true]},
+		"db": {"type": "sqlite", "batchSize": 1.5, "pasword": "x"},
+		"unknownItem": null
+	}`
+	problems, err := ValidateConf([]byte(data))
+	assert.NoError(t, err)
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.Error()
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"atomStructure: must not be empty",
+			"db.batchSize: expected integer, got number",
+			"db.pasword: unknown configuration item",
+			"maxNumErrors: expected integer, got string",
+			"structures.doc[1]: expected string, got boolean",
+			"unknownItem: unknown configuration item",
+			"verticalFiles[1]: expected string, got integer",
+		},
+		msgs,
+	)
+}
+
+func TestValidateConfMissingItems(t *testing.T) {
+	problems, err := ValidateConf([]byte(`{"corpus": "test", "db": {}}`))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]ValidationError{
+			{Path: "atomStructure", Message: "missing required item"},
+			{Path: "db.type", Message: "missing required item"},
+		},
+		problems,
+	)
+}
+
+func TestValidateConfMarshaledConf(t *testing.T) {
+	conf := VTEConf{Corpus: "test", AtomStructure: "p", SAttrExport: &SAttrExportConf{Dir: "/tmp"}}
+	conf.DB.Type = "sqlite"
+	data, err := json.Marshal(conf)
+	assert.NoError(t, err)
+	problems, err := ValidateConf(data)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
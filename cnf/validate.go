@@ -0,0 +1,334 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+var columnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks the configuration for invalid values and for
+// options which cannot be combined. Derived attributes are resolved
+// on a copy of the configuration, the called one is not modified.
+// Checks depending on the runtime environment (custom modifiers,
+// a database writer, generating of item_id) are performed once
+// an extractor is created.
+func (c *VTEConf) Validate() error {
+	if wst := c.WildcardStructures(); len(wst) > 0 {
+		return fmt.Errorf(
+			"unresolved wildcard structures %s (see cnf.VTEConf.ResolveWildcardStructures)",
+			strings.Join(wst, ", "))
+	}
+	conf, err := c.WithDerivedAttrs()
+	if err != nil {
+		return err
+	}
+	if conf.Parser.IsStrict() && conf.MaxParseErrors > 0 {
+		return fmt.Errorf("strict parsing cannot be combined with maxParseErrors")
+	}
+	switch conf.Strictness {
+	case "", StrictnessLenient, StrictnessStrict:
+	default:
+		return fmt.Errorf("unknown strictness %s", conf.Strictness)
+	}
+	switch conf.InputFormat {
+	case "", InputFormatVertical, InputFormatTEI:
+	default:
+		return fmt.Errorf("unknown input format %s", conf.InputFormat)
+	}
+	if err := conf.validateStructAttrs(); err != nil {
+		return err
+	}
+	if err := conf.validateNgrams(); err != nil {
+		return err
+	}
+	return conf.validateParallel()
+}
+
+// validateStructAttrs checks options referring to structural attributes
+func (c *VTEConf) validateStructAttrs() error {
+	if err := validateMultiValues(c.MultiValues, c.Structures); err != nil {
+		return err
+	}
+	if err := validateEmptyValues(c.EmptyValues, c.Structures); err != nil {
+		return err
+	}
+	if err := validateColumnNames(c.ColumnNames, c.Structures); err != nil {
+		return err
+	}
+	if err := validateColumnTypes(c.ColumnTypes, c.Structures, c.MultiValues, c.EmptyValues); err != nil {
+		return err
+	}
+	if err := validateColumnSizes(c.ColumnSizes, c.Structures); err != nil {
+		return err
+	}
+	return validateValueMaps(c.ValueMaps, c.Structures)
+}
+
+// validateNgrams checks the n-gram configuration and its
+// combinations with other options
+func (c *VTEConf) validateNgrams() error {
+	nc := c.Ngrams
+	if err := nc.ResolveSize(); err != nil {
+		return err
+	}
+	if err := nc.VertColumns.ValidateRoles(); err != nil {
+		return err
+	}
+	switch nc.Hapaxes {
+	case "", HapaxesKeep, HapaxesDrop:
+	case HapaxesSeparate:
+		if nc.MinFreq > 1 {
+			return fmt.Errorf("separate hapaxes cannot be combined with minFreq")
+		}
+	default:
+		return fmt.Errorf("unknown hapaxes mode %s", nc.Hapaxes)
+	}
+	if nc.TFIDF && (!nc.HasDocFreq() || nc.DocFreqStructure != c.AtomStructure) {
+		return fmt.Errorf("tfidf requires docFreqStructure set to the atom structure")
+	}
+	if nc.AssocMeasures && nc.NgramSize != 2 {
+		return fmt.Errorf("association measures require n-grams of size 2")
+	}
+	if nc.MaxSkip < 0 {
+		return fmt.Errorf("invalid n-gram maxSkip %d", nc.MaxSkip)
+	}
+	if nc.MaxSkip > 0 && nc.CalcARF {
+		return fmt.Errorf("skip-grams cannot be combined with ARF calculation")
+	}
+	if cng := nc.CharNgrams; cng != nil {
+		if cng.Size < 1 {
+			return fmt.Errorf("invalid character n-gram size %d", cng.Size)
+		}
+		if len(nc.VertColumns) != 1 {
+			return fmt.Errorf("character n-grams require exactly one vertical column")
+		}
+		if nc.NgramSize > 1 || nc.MaxSkip > 0 || nc.CalcARF {
+			return fmt.Errorf(
+				"character n-grams cannot be combined with size, maxSkip or ARF calculation")
+		}
+	}
+	if nc.Spill != nil {
+		if nc.CalcARF {
+			return fmt.Errorf("n-gram spilling cannot be combined with ARF calculation")
+		}
+		if nc.HasDocFreq() || nc.AssocMeasures {
+			return fmt.Errorf(
+				"n-gram spilling cannot be combined with docFreqStructure or association measures")
+		}
+	}
+	if nc.FlushEveryTokens > 0 {
+		if nc.CalcARF || nc.Spill != nil {
+			return fmt.Errorf(
+				"incremental flush of n-gram counts cannot be combined with ARF calculation or spilling")
+		}
+		if nc.MinFreq > 1 || nc.HasDocFreq() || nc.HasHapaxTable() || nc.AssocMeasures || nc.IPM {
+			return fmt.Errorf(
+				"incremental flush of n-gram counts cannot be combined with minFreq, docFreqStructure, " +
+					"separate hapaxes, association measures or ipm")
+		}
+		if nc.DictEncoding {
+			return fmt.Errorf(
+				"incremental flush of n-gram counts cannot be combined with dictionary encoding")
+		}
+	}
+	if c.FreqListExport != nil {
+		if len(nc.VertColumns) == 0 {
+			return fmt.Errorf("frequency list export requires n-gram columns")
+		}
+		if nc.FlushEveryTokens > 0 {
+			return fmt.Errorf("frequency list export cannot be combined with incremental flush of n-gram counts")
+		}
+	}
+	return nil
+}
+
+// validateParallel checks options which cannot be used
+// with parallel processing (see NumWorkers)
+func (c *VTEConf) validateParallel() error {
+	if c.NumWorkers <= 1 {
+		return nil
+	}
+	if c.HasConfiguredFilter() {
+		return fmt.Errorf("parallel processing (numWorkers) cannot be combined with a custom filter")
+	}
+	if c.AtomStructure == "" {
+		return fmt.Errorf("parallel processing (numWorkers) requires atomStructure")
+	}
+	if c.MaxAtoms > 0 {
+		return fmt.Errorf("parallel processing (numWorkers) cannot be combined with maxAtoms")
+	}
+	if c.Ngrams.CalcARF && c.Ngrams.ARFSinglePass {
+		return fmt.Errorf("parallel processing (numWorkers) cannot be combined with single pass ARF")
+	}
+	if c.Ngrams.HasDocFreq() || c.Ngrams.ItemCounts {
+		return fmt.Errorf(
+			"parallel processing (numWorkers) cannot be combined with docFreqStructure or itemCounts")
+	}
+	if c.InputFormat == InputFormatTEI {
+		return fmt.Errorf("parallel processing (numWorkers) cannot be combined with TEI input")
+	}
+	return nil
+}
+
+// knownStructAttrs returns a set of all the configured structural
+// attributes in the [struct]_[attr] form
+func knownStructAttrs(structures map[string][]string) map[string]bool {
+	known := make(map[string]bool)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			known[st+"_"+attr] = true
+		}
+	}
+	return known
+}
+
+// validateMultiValues checks that all the multi-value attributes
+// are configured structural attributes with non-empty delimiters
+func validateMultiValues(multiValues map[string]string, structures map[string][]string) error {
+	known := knownStructAttrs(structures)
+	for attr, delim := range multiValues {
+		if !known[attr] {
+			return fmt.Errorf("multi-value attribute %s is not configured in structures", attr)
+		}
+		if delim == "" {
+			return fmt.Errorf("missing delimiter of multi-value attribute %s", attr)
+		}
+	}
+	return nil
+}
+
+// validateEmptyValues checks that all the empty value policies
+// are valid and belong to configured structural attributes
+func validateEmptyValues(
+	emptyValues map[string]db.EmptyValuePolicy,
+	structures map[string][]string,
+) error {
+	known := knownStructAttrs(structures)
+	for attr, policy := range emptyValues {
+		if !known[attr] {
+			return fmt.Errorf("empty value policy attribute %s is not configured in structures", attr)
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("invalid empty value policy of %s: %w", attr, err)
+		}
+	}
+	return nil
+}
+
+// validateColumnNames checks that all the renamed attributes are
+// configured structural attributes and that the resulting
+// liveattrs_entry columns are valid and unique
+func validateColumnNames(columnNames map[string]string, structures map[string][]string) error {
+	used := map[string]string{
+		"wordcount": "wordcount",
+		"poscount":  "poscount",
+		"corpus_id": "corpus_id",
+		"item_id":   "item_id",
+	}
+	known := knownStructAttrs(structures)
+	for name := range known {
+		if _, ok := columnNames[name]; !ok {
+			used[name] = name
+		}
+	}
+	for attr, column := range columnNames {
+		if !known[attr] {
+			return fmt.Errorf("renamed attribute %s is not configured in structures", attr)
+		}
+		if !columnNameRegexp.MatchString(column) {
+			return fmt.Errorf("invalid output column name %s of attribute %s", column, attr)
+		}
+		if prev, ok := used[column]; ok {
+			return fmt.Errorf("output column %s of attribute %s already used by %s", column, attr, prev)
+		}
+		used[column] = attr
+	}
+	return nil
+}
+
+// validateColumnTypes checks that all the typed attributes are
+// configured structural attributes with supported types. Multi-value
+// attributes and attributes keeping empty strings cannot be typed.
+func validateColumnTypes(
+	columnTypes map[string]string,
+	structures map[string][]string,
+	multiValues map[string]string,
+	emptyValues map[string]db.EmptyValuePolicy,
+) error {
+	known := knownStructAttrs(structures)
+	for attr, colType := range columnTypes {
+		if !known[attr] {
+			return fmt.Errorf("typed attribute %s is not configured in structures", attr)
+		}
+		if !db.IsKnownColumnType(colType) {
+			return fmt.Errorf("unknown column type %s of attribute %s", colType, attr)
+		}
+		if colType == db.ColumnTypeString {
+			continue
+		}
+		if _, ok := multiValues[attr]; ok {
+			return fmt.Errorf("multi-value attribute %s cannot be of type %s", attr, colType)
+		}
+		policy, ok := emptyValues[attr]
+		if !ok {
+			continue
+		}
+		switch policy.Action {
+		case db.EmptyValueKeep:
+			return fmt.Errorf("attribute %s of type %s cannot keep empty values", attr, colType)
+		case db.EmptyValueDefault:
+			if _, err := db.ConvertTypedValue(colType, policy.Default); err != nil {
+				return fmt.Errorf(
+					"invalid default empty value of attribute %s of type %s: %w", attr, colType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateColumnSizes checks that all the sized attributes
+// are configured structural attributes with positive sizes
+func validateColumnSizes(columnSizes map[string]int, structures map[string][]string) error {
+	known := knownStructAttrs(structures)
+	for attr, size := range columnSizes {
+		if !known[attr] {
+			return fmt.Errorf("sized attribute %s is not configured in structures", attr)
+		}
+		if size <= 0 {
+			return fmt.Errorf("invalid column size %d of attribute %s", size, attr)
+		}
+	}
+	return nil
+}
+
+// validateValueMaps checks that all the remapped attributes
+// are configured structural attributes
+func validateValueMaps(valueMaps map[string]map[string]string, structures map[string][]string) error {
+	known := knownStructAttrs(structures)
+	for attr := range valueMaps {
+		if !known[attr] {
+			return fmt.Errorf("value map attribute %s is not configured in structures", attr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func newValidConf() *VTEConf {
+	return &VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Ngrams: NgramConf{
+			NgramSize:   2,
+			VertColumns: db.VertColumns{{Idx: 0}, {Idx: 1}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, newValidConf().Validate())
+
+	conf := newValidConf()
+	conf.ColumnNames = map[string]string{"doc_id": "p_num"}
+	assert.ErrorContains(t, conf.Validate(), "output column p_num of attribute doc_id already used")
+
+	conf = newValidConf()
+	conf.Ngrams.AssocMeasures = true
+	conf.Ngrams.NgramSize = 3
+	assert.ErrorContains(t, conf.Validate(), "association measures require n-grams of size 2")
+
+	conf = newValidConf()
+	conf.NumWorkers = 4
+	conf.MaxAtoms = 10
+	assert.ErrorContains(t, conf.Validate(), "cannot be combined with maxAtoms")
+}
+
+func TestValidateDoesNotModifyConf(t *testing.T) {
+	conf := newValidConf()
+	conf.Ngrams.NgramSize = 0
+	conf.Ngrams.Size = 2
+	assert.NoError(t, conf.Validate())
+	assert.Equal(t, 2, conf.Ngrams.Size)
+	assert.Equal(t, 0, conf.Ngrams.NgramSize)
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var (
+	tsvEscaper = strings.NewReplacer(
+		"\\", "\\\\",
+		"\t", "\\t",
+		"\n", "\\n",
+		"\r", "\\r",
+	)
+)
+
+// batchInsert buffers rows in the TabSeparated format and sends
+// them to the server once the configured batch size is reached.
+type batchInsert struct {
+	writer  *Writer
+	query   string
+	table   string
+	buff    bytes.Buffer
+	numRows int
+	numSent int
+}
+
+func (ins *batchInsert) Exec(values ...any) error {
+	for i, v := range values {
+		if i > 0 {
+			ins.buff.WriteByte('\t')
+		}
+		switch tv := v.(type) {
+		case string:
+			if tv == "" {
+				ins.buff.WriteString("\\N")
+
+			} else {
+				ins.buff.WriteString(tsvEscaper.Replace(tv))
+			}
+		case nil:
+			ins.buff.WriteString("\\N")
+		default:
+			ins.buff.WriteString(tsvEscaper.Replace(fmt.Sprint(tv)))
+		}
+	}
+	ins.buff.WriteByte('\n')
+	ins.numRows++
+	if ins.numRows >= ins.writer.batchSize {
+		return ins.flush()
+	}
+	return nil
+}
+
+func (ins *batchInsert) flush() error {
+	if ins.numRows == 0 {
+		return nil
+	}
+	if _, err := ins.writer.query(ins.query, &ins.buff); err != nil {
+		return fmt.Errorf("failed to insert batch into %s: %w", ins.table, err)
+	}
+	ins.numSent += ins.numRows
+	ins.reset()
+	return nil
+}
+
+func (ins *batchInsert) reset() {
+	ins.buff.Reset()
+	ins.numRows = 0
+}
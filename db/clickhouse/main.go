@@ -0,0 +1,236 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	// dfltBatchSize specifies number of rows sent to ClickHouse
+	// within a single INSERT request. ClickHouse strongly prefers
+	// large and infrequent inserts.
+	dfltBatchSize = 100000
+
+	dfltHTTPTimeout = 10 * time.Minute
+)
+
+// Writer writes data to a ClickHouse server using its HTTP interface.
+// Please note that ClickHouse does not support transactions so
+// Rollback can only discard rows which have not been sent yet.
+type Writer struct {
+	client            *http.Client
+	serverURL         string
+	dbName            string
+	user              string
+	password          string
+	batchSize         int
+	inserts           []*batchInsert
+	groupedCorpusName string
+
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	IndexedCols      []string
+	SelfJoinConf     db.SelfJoinConf
+	BibViewConf      db.BibViewConf
+	CountColumns     db.VertColumns
+	DocFreq          bool
+	AssocMeasures    bool
+	IPM              bool
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	DictEncoding     bool
+	MultiValueTable  bool
+}
+
+// query sends a query to the server. In case body is not nil,
+// the query is passed as a URL argument and the body is used
+// as data for the query (this is how INSERTs are performed).
+func (w *Writer) query(query string, body io.Reader) (string, error) {
+	args := url.Values{}
+	args.Set("database", w.dbName)
+	if body == nil {
+		body = strings.NewReader(query)
+
+	} else {
+		args.Set("query", query)
+	}
+	req, err := http.NewRequest(http.MethodPost, w.serverURL+"/?"+args.Encode(), body)
+	if err != nil {
+		return "", err
+	}
+	if w.user != "" {
+		req.Header.Set("X-ClickHouse-User", w.user)
+		req.Header.Set("X-ClickHouse-Key", w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(
+			"query failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return strings.TrimSpace(string(respBody)), nil
+}
+
+func (w *Writer) exec(query string) error {
+	_, err := w.query(query, nil)
+	return err
+}
+
+func (w *Writer) DatabaseExists() bool {
+	ans, err := w.query(fmt.Sprintf("EXISTS TABLE `%s%s`", w.groupedCorpusName, laTableSuffix), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to test data storage existence")
+		return false
+	}
+	return ans == "1"
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	dbExisted := w.DatabaseExists()
+	if !appendMode {
+		if dbExisted {
+			log.
+				Warn().
+				Str("storageName", w.dbName+"/"+w.groupedCorpusName+laTableSuffix).
+				Msg("The data storage already exists. Existing data will be deleted.")
+			if err := w.dropExisting(); err != nil {
+				return err
+			}
+		}
+		if err := w.createSchema(); err != nil {
+			return err
+		}
+		if w.BibViewConf.IsConfigured() {
+			if err := w.createBibView(); err != nil {
+				return err
+			}
+		}
+
+	} else if err := w.createCorpusSizesTable(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	ins := &batchInsert{
+		writer: w,
+		query: fmt.Sprintf(
+			"INSERT INTO `%s_%s` (%s) FORMAT TabSeparated",
+			w.groupedCorpusName, table, strings.Join(attrs, ", "),
+		),
+		table: table,
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+// Commit sends all the remaining buffered rows to the server
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		if err := ins.flush(); err != nil {
+			return err
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+// Rollback discards all the buffered rows. Rows already sent
+// to the server cannot be removed this way.
+func (w *Writer) Rollback() error {
+	var numSent int
+	for _, ins := range w.inserts {
+		numSent += ins.numSent
+		ins.reset()
+	}
+	w.inserts = w.inserts[:0]
+	if numSent > 0 {
+		log.Warn().
+			Int("numRows", numSent).
+			Msg("ClickHouse does not support transactions, some rows already written will remain")
+	}
+	return nil
+}
+
+func (w *Writer) Close() {
+	w.client.CloseIdleConnections()
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	serverURL := conf.DB.Host
+	if !strings.HasPrefix(serverURL, "http://") && !strings.HasPrefix(serverURL, "https://") {
+		serverURL = "http://" + serverURL
+	}
+	if _, err := url.Parse(serverURL); err != nil {
+		return nil, fmt.Errorf("invalid ClickHouse server address %s: %w", conf.DB.Host, err)
+	}
+	batchSize := conf.DB.BatchSize
+	if batchSize <= 0 {
+		batchSize = dfltBatchSize
+	}
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	return &Writer{
+		client:            &http.Client{Timeout: dfltHTTPTimeout},
+		serverURL:         strings.TrimSuffix(serverURL, "/"),
+		dbName:            conf.DB.Name,
+		user:              conf.DB.User,
+		password:          conf.DB.Password,
+		batchSize:         batchSize,
+		groupedCorpusName: groupedCorpusName,
+		Structures:        conf.Structures,
+		ColumnNames:       conf.ColumnNames,
+		ColumnTypes:       conf.ColumnTypes,
+		IndexedCols:       conf.IndexedCols,
+		SelfJoinConf:      conf.SelfJoin,
+		BibViewConf:       conf.BibView,
+		CountColumns:      conf.Ngrams.CountColumns(),
+		DocFreq:           conf.Ngrams.HasDocFreq(),
+		AssocMeasures:     conf.Ngrams.AssocMeasures,
+		IPM:               conf.Ngrams.IPM,
+		HapaxTable:        conf.Ngrams.HasHapaxTable(),
+		TFIDFTable:        conf.Ngrams.TFIDF,
+		ItemCountsTable:   conf.Ngrams.ItemCounts,
+		TagDistribTables:  conf.TagDistrib != nil,
+		DictEncoding:      conf.Ngrams.DictEncoding,
+		MultiValueTable:   len(conf.MultiValues) > 0,
+	}, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedQuery struct {
+	query string
+	data  string
+}
+
+// fakeServer records queries sent via the ClickHouse HTTP interface.
+// Responses can be configured by a query prefix. As the writer waits
+// for each response, no locking is needed.
+type fakeServer struct {
+	server    *httptest.Server
+	queries   []receivedQuery
+	responses map[string]string
+}
+
+func (fs *fakeServer) sentQueries() []string {
+	ans := make([]string, len(fs.queries))
+	for i, q := range fs.queries {
+		ans[i] = q.query
+	}
+	return ans
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	fs := &fakeServer{responses: make(map[string]string)}
+	fs.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		rq := receivedQuery{query: r.URL.Query().Get("query"), data: string(body)}
+		if rq.query == "" {
+			rq.query = rq.data
+			rq.data = ""
+		}
+		fs.queries = append(fs.queries, rq)
+		if strings.HasPrefix(rq.query, "FAIL") {
+			http.Error(w, "Code: 62. Syntax error", http.StatusBadRequest)
+			return
+		}
+		for prefix, resp := range fs.responses {
+			if strings.HasPrefix(rq.query, prefix) {
+				io.WriteString(w, resp)
+				return
+			}
+		}
+	}))
+	t.Cleanup(fs.server.Close)
+	return fs
+}
+
+func newTestWriter(t *testing.T, fs *fakeServer, batchSize int) *Writer {
+	w, err := NewWriter(&cnf.VTEConf{
+		Corpus:     "syn",
+		Structures: map[string][]string{"doc": {"year"}},
+		DB: db.Conf{
+			Host:      strings.TrimPrefix(fs.server.URL, "http://"),
+			Name:      "test",
+			BatchSize: batchSize,
+		},
+		ColumnTypes: map[string]string{"doc_year": db.ColumnTypeInteger},
+		Ngrams:      cnf.NgramConf{VertColumns: db.VertColumns{{Idx: 0}}},
+	})
+	assert.NoError(t, err)
+	return w
+}
+
+func TestChColumnType(t *testing.T) {
+	for colType, expected := range map[string]string{
+		db.ColumnTypeInteger: "Int64",
+		db.ColumnTypeFloat:   "Float64",
+		db.ColumnTypeDate:    "Date32",
+		db.ColumnTypeBoolean: "Bool",
+		"":                   "String",
+	} {
+		assert.Equal(t, expected, chColumnType(colType), colType)
+	}
+}
+
+func TestSQLStringEscaper(t *testing.T) {
+	assert.Equal(t, `it\'s a \\ test`, sqlStringEscaper.Replace(`it's a \ test`))
+}
+
+func TestInsertTabSeparatedEscaping(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 10)
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_title", "doc_year", "corpus_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("tab\there", 1999, "syn"))
+	assert.NoError(t, ins.Exec("new\nline\r", nil, "syn"))
+	assert.NoError(t, ins.Exec(`back\slash`, 2001, ""))
+	assert.Empty(t, fs.queries)
+	assert.NoError(t, w.Commit())
+
+	assert.Equal(
+		t,
+		[]receivedQuery{
+			{
+				query: "INSERT INTO `syn_liveattrs_entry` (doc_title, doc_year, corpus_id) FORMAT TabSeparated",
+				data: "tab\\there\t1999\tsyn\n" +
+					"new\\nline\\r\t\\N\tsyn\n" +
+					"back\\\\slash\t2001\t\\N\n",
+			},
+		},
+		fs.queries,
+	)
+}
+
+func TestInsertFlushedOnBatchSize(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 2)
+	ins, err := w.PrepareInsert("colcounts", []string{"col0", "count"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", 1))
+	assert.NoError(t, ins.Exec("bar", 2))
+	assert.NoError(t, ins.Exec("baz", 3))
+	if assert.Len(t, fs.queries, 1) {
+		assert.Equal(t, "foo\t1\nbar\t2\n", fs.queries[0].data)
+	}
+	assert.NoError(t, w.Rollback())
+	assert.NoError(t, w.Commit())
+	assert.Len(t, fs.queries, 1)
+}
+
+func TestInsertServerError(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 10)
+	ins := &batchInsert{writer: w, query: "FAIL", table: "colcounts"}
+	assert.NoError(t, ins.Exec("foo", 1))
+	err := ins.flush()
+	assert.ErrorContains(t, err, "failed to insert batch into colcounts")
+	assert.ErrorContains(t, err, "Syntax error")
+}
+
+func TestInitializeCreatesSchema(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.responses["EXISTS TABLE"] = "0\n"
+	w := newTestWriter(t, fs, 10)
+	assert.NoError(t, w.Initialize(false))
+	assert.Equal(
+		t,
+		[]string{
+			"EXISTS TABLE `syn_liveattrs_entry`",
+			"CREATE TABLE `syn_liveattrs_entry` (doc_year Nullable(Int64), poscount UInt32, wordcount UInt32, " +
+				"corpus_id LowCardinality(String)) ENGINE = MergeTree ORDER BY corpus_id",
+			"CREATE TABLE IF NOT EXISTS `syn_corpus_sizes` (corpus_id String, tokens UInt64, atoms UInt64) " +
+				"ENGINE = MergeTree ORDER BY corpus_id",
+			"CREATE TABLE `syn_colcounts` (col0 String, hash_id FixedString(40), corpus_id LowCardinality(String), " +
+				"count UInt64, arf Float64) ENGINE = MergeTree ORDER BY (corpus_id, col0)",
+		},
+		fs.sentQueries(),
+	)
+}
+
+func TestUpdateCorpusSize(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.responses["SELECT sum(tokens)"] = "100\t10\n"
+	w := newTestWriter(t, fs, 10)
+	tokens, atoms, err := w.UpdateCorpusSize("it's", 50, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, tokens)
+	assert.Equal(t, 15, atoms)
+	assert.NoError(t, w.Commit())
+	if assert.Len(t, fs.queries, 2) {
+		assert.Contains(t, fs.queries[0].query, `WHERE corpus_id = 'it\'s'`)
+		assert.Equal(t, "it's\t50\t5\n", fs.queries[1].data)
+	}
+}
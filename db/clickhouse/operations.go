@@ -0,0 +1,300 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	laTableSuffix = "_liveattrs_entry"
+)
+
+var (
+	sqlStringEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+)
+
+// dropExisting drops existing tables/views.
+// It is safe to call this even if one or more of these does not exist.
+func (w *Writer) dropExisting() error {
+	log.Info().Msg("Attempting to drop possible existing tables and views...")
+	err := w.exec(fmt.Sprintf("DROP VIEW IF EXISTS `%s_bibliography`", w.groupedCorpusName))
+	if err != nil {
+		return fmt.Errorf("failed to drop view `%s_bibliography`: %s", w.groupedCorpusName, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s%s`", w.groupedCorpusName, laTableSuffix))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table '%s%s': %s", w.groupedCorpusName, laTableSuffix, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_colcounts`", w.groupedCorpusName))
+	if err != nil {
+		return fmt.Errorf("failed to drop table `%s_colcounts`: %s", w.groupedCorpusName, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, db.ColcountsHapaxTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", w.groupedCorpusName, db.ColcountsHapaxTable, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, db.CorpusTFIDFTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", w.groupedCorpusName, db.CorpusTFIDFTable, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, db.CorpusItemCountsTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", w.groupedCorpusName, db.CorpusItemCountsTable, err)
+	}
+	err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", w.groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	for _, tbl := range []string{db.CorpusTagDistribTable, db.CorpusTagDistribTTTable, db.LiveattrsMultiValueTable} {
+		err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, tbl))
+		if err != nil {
+			return fmt.Errorf("failed to drop table `%s_%s`: %s", w.groupedCorpusName, tbl, err)
+		}
+	}
+	log.Info().Msg("...DONE")
+	return nil
+}
+
+// chColumnType returns a ClickHouse type of a liveattrs_entry
+// column of the provided type (see db.ColumnTypeInteger etc.)
+func chColumnType(colType string) string {
+	switch colType {
+	case db.ColumnTypeInteger:
+		return "Int64"
+	case db.ColumnTypeFloat:
+		return "Float64"
+	case db.ColumnTypeDate:
+		return "Date32"
+	case db.ColumnTypeBoolean:
+		return "Bool"
+	default:
+		return "String"
+	}
+}
+
+// generateColNames produces a list of structural
+// attribute names as used in database
+// (i.e. [structname]_[attr_name]) out of lists
+// of structural attributes defined in the configuration.
+func generateColNames(structures map[string][]string, columnNames map[string]string) []string {
+	ans := make([]string, 0, len(structures)*4)
+	for k, v := range structures {
+		for _, a := range v {
+			ans = append(ans, db.StructAttrColumn(k, a, columnNames))
+		}
+	}
+	return ans
+}
+
+// createCorpusSizesTable creates the corpus_sizes table in case it
+// does not exist yet (databases created by older versions lack it)
+func (w *Writer) createCorpusSizesTable() error {
+	err := w.exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS `%s_%s` (corpus_id String, tokens UInt64, atoms UInt64) "+
+			"ENGINE = MergeTree ORDER BY corpus_id",
+		w.groupedCorpusName, db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create table '%s_%s': %s", w.groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	return nil
+}
+
+// UpdateCorpusSize inserts the numbers of tokens and atoms as a new
+// row of the corpus_sizes table (ClickHouse does not support in-place
+// updates so the sizes of a corpus appended by multiple runs are meant
+// to be summed) and returns the total numbers including previous runs.
+func (w *Writer) UpdateCorpusSize(corpusID string, tokens, atoms int) (int, int, error) {
+	ans, err := w.query(
+		fmt.Sprintf(
+			"SELECT sum(tokens), sum(atoms) FROM `%s_%s` WHERE corpus_id = '%s' FORMAT TabSeparated",
+			w.groupedCorpusName, db.CorpusSizesTable, sqlStringEscaper.Replace(corpusID)),
+		nil,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read size of corpus %s: %w", corpusID, err)
+	}
+	var prevTokens, prevAtoms int
+	if _, err := fmt.Sscanf(ans, "%d\t%d", &prevTokens, &prevAtoms); err != nil {
+		return 0, 0, fmt.Errorf("failed to read size of corpus %s: %w", corpusID, err)
+	}
+	ins, err := w.PrepareInsert(db.CorpusSizesTable, []string{"corpus_id", "tokens", "atoms"})
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := ins.Exec(corpusID, tokens, atoms); err != nil {
+		return 0, 0, fmt.Errorf("failed to insert size of corpus %s: %w", corpusID, err)
+	}
+	return prevTokens + tokens, prevAtoms + atoms, nil
+}
+
+// createSchema creates the liveattrs and colcounts tables. Custom indexed
+// columns are handled via data skipping indices as ClickHouse does not
+// know secondary indices in the classical sense.
+func (w *Writer) createSchema() error {
+	log.Info().Msg("Attempting to create tables and views")
+
+	cols := generateColNames(w.Structures, w.ColumnNames)
+	colTypes := db.OutputColumnTypes(w.Structures, w.ColumnNames, w.ColumnTypes)
+	colDefs := make([]string, 0, len(cols)+len(w.IndexedCols)+4)
+	for _, col := range cols {
+		colDefs = append(colDefs, fmt.Sprintf("%s Nullable(%s)", col, chColumnType(colTypes[col])))
+	}
+	colDefs = append(
+		colDefs, "poscount UInt32", "wordcount UInt32", "corpus_id LowCardinality(String)")
+	orderBy := "corpus_id"
+	if w.SelfJoinConf.IsConfigured() {
+		colDefs = append(colDefs, "item_id String")
+		orderBy = "(corpus_id, item_id)"
+	}
+	for _, c := range w.IndexedCols {
+		colDefs = append(colDefs, fmt.Sprintf("INDEX %s_idx %s TYPE bloom_filter GRANULARITY 4", c, c))
+	}
+	err := w.exec(fmt.Sprintf(
+		"CREATE TABLE `%s%s` (%s) ENGINE = MergeTree ORDER BY %s",
+		w.groupedCorpusName, laTableSuffix, strings.Join(colDefs, ", "), orderBy))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create table '%s%s': %s", w.groupedCorpusName, laTableSuffix, err)
+	}
+	if err = w.createCorpusSizesTable(); err != nil {
+		return err
+	}
+	if w.MultiValueTable {
+		err = w.exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id LowCardinality(String), item_id String, attr LowCardinality(String), "+
+				"value String) ENGINE = MergeTree ORDER BY (corpus_id, attr, value)",
+			w.groupedCorpusName, db.LiveattrsMultiValueTable))
+		if err != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", w.groupedCorpusName, db.LiveattrsMultiValueTable, err)
+		}
+	}
+	if w.TagDistribTables {
+		err = w.exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id LowCardinality(String), tag String, count UInt64) "+
+				"ENGINE = MergeTree ORDER BY (corpus_id, tag)",
+			w.groupedCorpusName, db.CorpusTagDistribTable))
+		if err != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", w.groupedCorpusName, db.CorpusTagDistribTable, err)
+		}
+		err = w.exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id LowCardinality(String), attr LowCardinality(String), value String, "+
+				"tag String, count UInt64) ENGINE = MergeTree ORDER BY (corpus_id, attr, value, tag)",
+			w.groupedCorpusName, db.CorpusTagDistribTTTable))
+		if err != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", w.groupedCorpusName, db.CorpusTagDistribTTTable, err)
+		}
+	}
+
+	if len(w.CountColumns) > 0 {
+		ccNames := db.GenerateColCountNames(w.CountColumns)
+		ccDefs := make([]string, len(ccNames))
+		ccType := "String"
+		if w.DictEncoding {
+			ccType = "UInt32"
+		}
+		for i, c := range ccNames {
+			ccDefs[i] = c + " " + ccType
+		}
+		// the sorting key allows for fast prefix lookups of n-grams within a corpus
+		err = w.exec(fmt.Sprintf(
+			"CREATE TABLE `%s_colcounts` (%s, hash_id FixedString(40), corpus_id LowCardinality(String), "+
+				"count UInt64, arf Float64%s) ENGINE = MergeTree ORDER BY (corpus_id, %s)",
+			w.groupedCorpusName, strings.Join(ccDefs, ", "), db.DocFreqColDef(w.DocFreq, "UInt64")+db.IPMColDef(w.IPM, "Float64")+db.AssocColDefs(w.AssocMeasures, "Float64"),
+			strings.Join(ccNames, ", ")))
+		if err != nil {
+			return fmt.Errorf("failed to create table '%s_colcounts': %s", w.groupedCorpusName, err)
+		}
+		if w.DictEncoding {
+			for _, tbl := range db.ColValuesTables(w.CountColumns) {
+				err = w.exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", w.groupedCorpusName, tbl))
+				if err != nil {
+					return fmt.Errorf("failed to drop table '%s_%s': %s", w.groupedCorpusName, tbl, err)
+				}
+				err = w.exec(fmt.Sprintf(
+					"CREATE TABLE `%s_%s` (corpus_id LowCardinality(String), id UInt32, value String) "+
+						"ENGINE = MergeTree ORDER BY (corpus_id, id)",
+					w.groupedCorpusName, tbl))
+				if err != nil {
+					return fmt.Errorf("failed to create table '%s_%s': %s", w.groupedCorpusName, tbl, err)
+				}
+			}
+		}
+		if w.HapaxTable {
+			err = w.exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` AS `%s_colcounts`",
+				w.groupedCorpusName, db.ColcountsHapaxTable, w.groupedCorpusName))
+			if err != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", w.groupedCorpusName, db.ColcountsHapaxTable, err)
+			}
+		}
+		if w.TFIDFTable {
+			err = w.exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` (atom_id UInt64, hash_id FixedString(40), corpus_id LowCardinality(String), "+
+					"tfidf Float64) ENGINE = MergeTree ORDER BY (corpus_id, atom_id)",
+				w.groupedCorpusName, db.CorpusTFIDFTable))
+			if err != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", w.groupedCorpusName, db.CorpusTFIDFTable, err)
+			}
+		}
+		if w.ItemCountsTable {
+			err = w.exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` (item_id String, hash_id FixedString(40), corpus_id LowCardinality(String), "+
+					"count UInt64) ENGINE = MergeTree ORDER BY (corpus_id, item_id)",
+				w.groupedCorpusName, db.CorpusItemCountsTable))
+			if err != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", w.groupedCorpusName, db.CorpusItemCountsTable, err)
+			}
+		}
+	}
+	log.Info().Msg("DONE")
+	return nil
+}
+
+// createBibView creates a database view needed
+// by liveattrs to fetch bibliography information.
+func (w *Writer) createBibView() error {
+	colDefs := make([]string, len(w.BibViewConf.Cols))
+	for i, c := range w.BibViewConf.Cols {
+		if c != w.BibViewConf.IDAttr {
+			colDefs[i] = c
+
+		} else {
+			colDefs[i] = fmt.Sprintf("%s AS id", c)
+		}
+	}
+	return w.exec(fmt.Sprintf(
+		"CREATE VIEW `%s_bibliography` AS SELECT %s FROM `%s%s`",
+		w.groupedCorpusName, strings.Join(colDefs, ", "), w.groupedCorpusName, laTableSuffix))
+}
@@ -18,7 +18,12 @@ package db
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -30,6 +35,10 @@ const (
 	// for VARCHARs used for "colcounts" (which is a base
 	// for n-grams)
 	DfltColcountVarcharSize = 255
+
+	// DfltNumPartitions is a default number of partitions
+	// for partitioned tables
+	DfltNumPartitions = 16
 )
 
 type Insert struct {
@@ -46,6 +55,178 @@ func (ins *Insert) Exec(values ...any) error {
 	return err
 }
 
+const (
+	RoleWord     = "word"
+	RoleLemma    = "lemma"
+	RoleSublemma = "sublemma"
+	RoleTag      = "tag"
+)
+
+// IsKnownRole tests whether the role is one of the column roles
+// vte understands (word, lemma, sublemma, tag). Columns with a known
+// role can be named by the role and they are indexed in colcounts.
+func IsKnownRole(role string) bool {
+	switch role {
+	case RoleWord, RoleLemma, RoleSublemma, RoleTag:
+		return true
+	}
+	return false
+}
+
+// EmptyString is a value writers store as an empty string.
+// Plain empty strings passed to Exec are stored as NULL.
+type EmptyString struct{}
+
+// Value implements driver.Valuer
+func (EmptyString) Value() (driver.Value, error) {
+	return "", nil
+}
+
+func (EmptyString) String() string {
+	return ""
+}
+
+func (EmptyString) MarshalJSON() ([]byte, error) {
+	return []byte(`""`), nil
+}
+
+const (
+	// EmptyValueNull stores empty values as NULL (default)
+	EmptyValueNull = "null"
+
+	// EmptyValueKeep stores empty values as empty strings
+	EmptyValueKeep = "keep"
+
+	// EmptyValueDefault replaces empty values with a configured default
+	EmptyValueDefault = "default"
+
+	// EmptyValueSkip skips the whole atom (structural attributes)
+	// or token (counted columns) with an empty value
+	EmptyValueSkip = "skip"
+)
+
+// EmptyValuePolicy specifies how empty values of a column are handled
+type EmptyValuePolicy struct {
+
+	// Action is one of "null" (default), "keep", "default" and "skip"
+	Action string `json:"action"`
+
+	// Default is a value used by the "default" action
+	Default string `json:"default,omitempty"`
+}
+
+func (p *EmptyValuePolicy) Validate() error {
+	switch p.Action {
+	case "", EmptyValueNull, EmptyValueKeep, EmptyValueSkip:
+		return nil
+	case EmptyValueDefault:
+		if p.Default == "" {
+			return fmt.Errorf("empty value action %s requires a non-empty default", p.Action)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown empty value action: %s", p.Action)
+}
+
+// Apply returns a value to be written instead of v and
+// whether the respective atom/token should be skipped.
+// Non-empty values are returned unchanged. A nil policy
+// behaves like the "null" action.
+func (p *EmptyValuePolicy) Apply(v string) (any, bool) {
+	if v != "" || p == nil {
+		return v, false
+	}
+	switch p.Action {
+	case EmptyValueKeep:
+		return EmptyString{}, false
+	case EmptyValueDefault:
+		return p.Default, false
+	case EmptyValueSkip:
+		return v, true
+	}
+	return v, false
+}
+
+const (
+	// ColumnTypeString is the default type of liveattrs_entry columns
+	ColumnTypeString = "string"
+
+	// ColumnTypeInteger stores values as 64-bit integers
+	ColumnTypeInteger = "integer"
+
+	// ColumnTypeFloat stores values as double precision numbers
+	ColumnTypeFloat = "float"
+
+	// ColumnTypeDate stores values as dates in the YYYY-MM-DD format
+	ColumnTypeDate = "date"
+
+	// ColumnTypeBoolean stores values as booleans
+	ColumnTypeBoolean = "boolean"
+
+	// DateFormat is the expected format of values of date columns
+	DateFormat = "2006-01-02"
+)
+
+// IsKnownColumnType tests whether t is a supported type
+// of liveattrs_entry columns
+func IsKnownColumnType(t string) bool {
+	switch t {
+	case ColumnTypeString, ColumnTypeInteger, ColumnTypeFloat, ColumnTypeDate, ColumnTypeBoolean:
+		return true
+	}
+	return false
+}
+
+// StructAttrColumnType returns a type of the liveattrs_entry column
+// storing values of a structural attribute. The columnTypes maps
+// [struct]_[attr] to a type, attributes without a type are strings.
+func StructAttrColumnType(structName, attr string, columnTypes map[string]string) string {
+	if v, ok := columnTypes[structName+"_"+attr]; ok && v != "" {
+		return v
+	}
+	return ColumnTypeString
+}
+
+// OutputColumnTypes maps liveattrs_entry columns (see StructAttrColumn)
+// of typed structural attributes to their types. Columns of string
+// attributes are not included.
+func OutputColumnTypes(
+	structures map[string][]string,
+	columnNames map[string]string,
+	columnTypes map[string]string,
+) map[string]string {
+	ans := make(map[string]string)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			if t := StructAttrColumnType(st, attr, columnTypes); t != ColumnTypeString {
+				ans[StructAttrColumn(st, attr, columnNames)] = t
+			}
+		}
+	}
+	return ans
+}
+
+// ConvertTypedValue converts a (non-empty) raw value of a structural
+// attribute to a value matching the provided column type.
+// Dates are validated and returned as strings in the DateFormat.
+func ConvertTypedValue(colType, v string) (any, error) {
+	switch colType {
+	case ColumnTypeInteger:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	case ColumnTypeFloat:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case ColumnTypeDate:
+		t, err := time.Parse(DateFormat, strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(DateFormat), nil
+	case ColumnTypeBoolean:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	}
+	return v, nil
+}
+
 // SelfJoinConf contains information about aligned
 // structural attributes (e.g. sentences from two
 // languages).
@@ -71,6 +252,58 @@ func (c *BibViewConf) IsConfigured() bool {
 	return c.IDAttr != "" && len(c.Cols) > 0
 }
 
+// PartitioningConf specifies how a table should be partitioned
+// (currently supported for the colcounts table in the mysql backend)
+type PartitioningConf struct {
+
+	// Column is a partitioning column - either corpus_id
+	// or one of the n-gram columns (col0, col1,...)
+	Column string `json:"column"`
+
+	// NumPartitions is the number of (hash) partitions
+	NumPartitions int `json:"numPartitions,omitempty"`
+}
+
+func (c *PartitioningConf) IsConfigured() bool {
+	return c.Column != ""
+}
+
+func (c *PartitioningConf) NumPartitionsOrDefault() int {
+	if c.NumPartitions <= 0 {
+		return DfltNumPartitions
+	}
+	return c.NumPartitions
+}
+
+// SQLiteConf contains sqlite backend specific settings.
+// All the values are optional.
+type SQLiteConf struct {
+
+	// JournalMode specifies the journal_mode pragma (e.g. WAL, MEMORY, DELETE)
+	JournalMode string `json:"journalMode,omitempty"`
+
+	// Synchronous specifies the synchronous pragma (e.g. OFF, NORMAL, FULL)
+	Synchronous string `json:"synchronous,omitempty"`
+
+	// PageSize specifies the page_size pragma in bytes. Please note
+	// that the value is applied only to newly created databases.
+	PageSize int `json:"pageSize,omitempty"`
+
+	// CacheSize specifies the cache_size pragma (a positive value
+	// means number of pages, a negative one means size in KiB)
+	CacheSize int `json:"cacheSize,omitempty"`
+
+	// InMemory specifies that all the data should be written
+	// to an in-memory database which is saved to the target
+	// file only on successful commit
+	InMemory bool `json:"inMemory,omitempty"`
+
+	// ColcountsPath specifies an optional separate database file
+	// for the colcounts table (attached to the main database).
+	// It cannot be combined with InMemory.
+	ColcountsPath string `json:"colcountsPath,omitempty"`
+}
+
 type Conf struct {
 	Type           string   `json:"type"`
 	Name           string   `json:"name"`
@@ -78,24 +311,139 @@ type Conf struct {
 	User           string   `json:"user"`
 	Password       string   `json:"password"`
 	PreconfQueries []string `json:"preconfSettings"`
+
+	// BatchSize specifies number of rows written at once
+	// by backends supporting batched inserts. Zero means
+	// a backend specific default.
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// Compress specifies whether file-based backends
+	// should gzip their output
+	Compress bool `json:"compress,omitempty"`
+
+	// Charset and Collation specify table charset and collation
+	// used by the mysql backend (e.g. utf8mb4 and utf8mb4_czech_ci).
+	// If empty, server defaults are used.
+	Charset   string `json:"charset,omitempty"`
+	Collation string `json:"collation,omitempty"`
+
+	// ColcountsPartitioning specifies an optional partitioning
+	// of the colcounts table (mysql backend only)
+	ColcountsPartitioning PartitioningConf `json:"colcountsPartitioning"`
+
+	// SQLite contains sqlite backend specific settings
+	SQLite *SQLiteConf `json:"sqlite,omitempty"`
+
+	// TLS enables encrypted connections (currently
+	// supported by the mysql backend)
+	TLS *TLSConf `json:"tls,omitempty"`
+
+	// LocalInfile specifies whether the mysql backend should
+	// stage rows into temporary files and load them using
+	// LOAD DATA LOCAL INFILE instead of INSERT statements
+	LocalInfile bool `json:"localInfile,omitempty"`
+
+	// DeferIndexes specifies that indices should be created
+	// only after all the rows are inserted which is usually
+	// faster for large imports (sqlite and mysql backends)
+	DeferIndexes bool `json:"deferIndexes,omitempty"`
+
+	// Dialect specifies a target SQL dialect for
+	// the offline SQL dump writer (sqlite, mysql, postgres)
+	Dialect string `json:"dialect,omitempty"`
 }
 
 type VertColumn struct {
-	Idx   int    `json:"idx"`
+	Idx int `json:"idx"`
+
+	// ModFn specifies value modification function(s) applied
+	// in order, separated by colons (e.g. "toLower:firstChar").
+	// In JSON, a list of functions can be used too.
 	ModFn string `json:"modFn,omitempty"`
 
+	// ModScript is an inline Lua script defining a function
+	// transform(value) applied after ModFn
+	ModScript string `json:"modScript,omitempty"`
+
+	// Name is a name of the positional attribute (informative only,
+	// e.g. filled in from a corpus registry)
+	Name string `json:"name,omitempty"`
+
 	// Role is a general "tag" specifying additional
 	// usage in systems using vert-tagextract.
 	// E.g. when combined with cnc-masm, we use this to
 	// specify whether the column belongs to one of
 	// {word, lemma, sublemma, tag}
 	Role string `json:"role,omitempty"`
+
+	// Stopwords specifies a path to a file with values (one per line)
+	// excluded from counted n-grams. The values are compared after
+	// the column's modFn is applied.
+	Stopwords string `json:"stopwords,omitempty"`
+
+	// Exclude is a regular expression matching values (after modFn
+	// is applied) of tokens skipped when counting n-grams (e.g. "^Z"
+	// for punctuation tags). Excluded tokens are not part of any n-gram.
+	Exclude string `json:"exclude,omitempty"`
+
+	// OnEmpty specifies how empty values (after modFn is applied)
+	// are handled. By default, they are stored as NULL.
+	OnEmpty *EmptyValuePolicy `json:"onEmpty,omitempty"`
+
+	// NgramPos is a position within an n-gram (starting from 1)
+	// in case n-gram positions are stored in separate columns.
+	// Zero means the column contains whole n-grams.
+	NgramPos int `json:"-"`
+
+	// NamedByRole specifies that the colcounts column is named
+	// by the column's Role instead of its index (see ColCountName)
+	NamedByRole bool `json:"-"`
+}
+
+// UnmarshalJSON accepts modFn either as a string or as a list
+// of functions which are joined into a single chain.
+func (vc *VertColumn) UnmarshalJSON(data []byte) error {
+	type vertColumn VertColumn
+	var tmp struct {
+		vertColumn
+		ModFn json.RawMessage `json:"modFn,omitempty"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*vc = VertColumn(tmp.vertColumn)
+	if len(tmp.ModFn) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(tmp.ModFn, &vc.ModFn); err == nil {
+		return nil
+	}
+	var fns []string
+	if err := json.Unmarshal(tmp.ModFn, &fns); err != nil {
+		return fmt.Errorf("modFn must be a string or a list of strings")
+	}
+	vc.ModFn = strings.Join(fns, ":")
+	return nil
 }
 
 func (vc VertColumn) IsUndefined() bool {
 	return vc.Idx == -1
 }
 
+// ColCountName returns a name of the colcounts column storing
+// values of the vertical column (e.g. col0, col0_1 or - in case
+// the column is named by a known role - lemma, lemma_1)
+func (vc VertColumn) ColCountName() string {
+	name := fmt.Sprintf("col%d", vc.Idx)
+	if vc.NamedByRole && IsKnownRole(vc.Role) {
+		name = vc.Role
+	}
+	if vc.NgramPos > 0 {
+		return fmt.Sprintf("%s_%d", name, vc.NgramPos)
+	}
+	return name
+}
+
 type VertColumns []VertColumn
 
 func (vc VertColumns) GetByIdx(idx int) VertColumn {
@@ -107,6 +455,54 @@ func (vc VertColumns) GetByIdx(idx int) VertColumn {
 	return VertColumn{Idx: -1}
 }
 
+// ByRole returns the first column with the specified role.
+// In case there is no such column, an undefined column is returned
+// (see VertColumn.IsUndefined).
+func (vc VertColumns) ByRole(role string) VertColumn {
+	for _, v := range vc {
+		if v.Role == role {
+			return v
+		}
+	}
+	return VertColumn{Idx: -1}
+}
+
+// Word returns a column with the "word" role
+func (vc VertColumns) Word() VertColumn {
+	return vc.ByRole(RoleWord)
+}
+
+// Lemma returns a column with the "lemma" role
+func (vc VertColumns) Lemma() VertColumn {
+	return vc.ByRole(RoleLemma)
+}
+
+// Sublemma returns a column with the "sublemma" role
+func (vc VertColumns) Sublemma() VertColumn {
+	return vc.ByRole(RoleSublemma)
+}
+
+// Tag returns a column with the "tag" role
+func (vc VertColumns) Tag() VertColumn {
+	return vc.ByRole(RoleTag)
+}
+
+// ValidateRoles checks that each known role (see IsKnownRole)
+// is assigned to at most one vertical column
+func (vc VertColumns) ValidateRoles() error {
+	used := make(map[string]int)
+	for _, v := range vc {
+		if !IsKnownRole(v.Role) {
+			continue
+		}
+		if idx, ok := used[v.Role]; ok && idx != v.Idx {
+			return fmt.Errorf("role %s assigned to multiple columns (%d, %d)", v.Role, idx, v.Idx)
+		}
+		used[v.Role] = v.Idx
+	}
+	return nil
+}
+
 // MaxColumn returns max index of a column
 // in VertColumns. E.g. if one defines
 // columns {3, 10, 7}, then 10 will be returned.
@@ -141,13 +537,179 @@ type InsertOperation interface {
 	Exec(values ...any) error
 }
 
+// ColcountsStager is an optional interface of a Writer able to store
+// partial n-gram counts into a staging table and aggregate them into
+// the colcounts table once all the data are processed.
+type ColcountsStager interface {
+
+	// PrepareStagingInsert creates a staging table (if not created yet)
+	// and prepares an insert operation. The attributes are the same
+	// as in case of the colcounts table.
+	PrepareStagingInsert(attrs []string) (InsertOperation, error)
+
+	// AggregateStaged sums staged counts of matching n-grams,
+	// inserts the results into the colcounts table and removes
+	// the staging table.
+	AggregateStaged() error
+}
+
+// CorpusSizesUpdater is an optional interface of a Writer able to
+// accumulate sizes of a corpus processed by multiple runs (i.e. when
+// appending data to an existing database). Writers not implementing
+// the interface get a new corpus_sizes row on each run.
+type CorpusSizesUpdater interface {
+
+	// UpdateCorpusSize adds the numbers of tokens and atoms to the
+	// size of the corpus stored by previous runs and returns the
+	// resulting total numbers.
+	UpdateCorpusSize(corpusID string, tokens, atoms int) (totalTokens, totalAtoms int, err error)
+}
+
+// Executor represents an object able to run an SQL statement.
+// It is implemented e.g. by *sql.DB and *sql.Tx. Schema
+// generating functions of SQL backends accept Executor so
+// the same code can be used to produce offline SQL scripts.
+type Executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+const (
+	// ColcountsHapaxTable is a name of an optional table storing
+	// n-grams occurring only once (see cnf.NgramConf.Hapaxes)
+	ColcountsHapaxTable = "colcounts_hapax"
+
+	// CorpusTFIDFTable is a name of an optional table storing
+	// TF-IDF values of n-grams per atom (see cnf.NgramConf.TFIDF)
+	CorpusTFIDFTable = "corpus_tfidf"
+
+	// CorpusItemCountsTable is a name of an optional table storing
+	// n-gram counts of individual atoms (see cnf.NgramConf.ItemCounts)
+	CorpusItemCountsTable = "corpus_itemcounts"
+
+	// CorpusSizesTable is a name of a table storing total numbers
+	// of tokens and atoms of processed corpora
+	CorpusSizesTable = "corpus_sizes"
+
+	// CorpusTagDistribTable is a name of an optional table storing
+	// frequencies of values of a configured column (e.g. PoS tags)
+	CorpusTagDistribTable = "corpus_tagdistrib"
+
+	// CorpusTagDistribTTTable is a name of an optional table storing
+	// frequencies of values of a configured column per text type
+	CorpusTagDistribTTTable = "corpus_tagdistrib_tt"
+
+	// LiveattrsMultiValueTable stores individual values of multi-value
+	// structural attributes (one row per atom, attribute and value)
+	LiveattrsMultiValueTable = "liveattrs_multivalue"
+)
+
+// StructAttrColumn returns a name of the liveattrs_entry column
+// storing values of a structural attribute. By default, the name
+// is [struct]_[attr] but it can be changed via columnNames
+// (mapping [struct]_[attr] to an output column name).
+func StructAttrColumn(structName, attr string, columnNames map[string]string) string {
+	name := structName + "_" + attr
+	if v, ok := columnNames[name]; ok {
+		return v
+	}
+	return name
+}
+
+// ColValuesTable returns a name of a table storing distinct values
+// of a vertical column in case colcounts is dictionary-encoded
+// (see cnf.NgramConf.DictEncoding). All the n-gram positions of
+// a column (col0_1, col0_2,...) share the same table.
+func ColValuesTable(col VertColumn) string {
+	return fmt.Sprintf("colvalues_col%d", col.Idx)
+}
+
+// ColValuesTables returns names of all the distinct
+// value tables of the provided colcounts columns
+func ColValuesTables(countColumns VertColumns) []string {
+	ans := make([]string, 0, len(countColumns))
+	seen := make(map[string]bool)
+	for _, col := range countColumns {
+		tbl := ColValuesTable(col)
+		if !seen[tbl] {
+			ans = append(ans, tbl)
+			seen[tbl] = true
+		}
+	}
+	return ans
+}
+
+// DocFreqColDef returns an SQL definition (including a leading comma)
+// of the optional colcounts column docfreq with the provided type.
+// In case docFreq is false, an empty string is returned.
+func DocFreqColDef(docFreq bool, sqlType string) string {
+	if !docFreq {
+		return ""
+	}
+	return ", docfreq " + sqlType
+}
+
+// IPMColDef returns an SQL definition (including a leading comma)
+// of the optional colcounts column ipm with the provided type.
+// In case ipm is false, an empty string is returned.
+func IPMColDef(ipm bool, sqlType string) string {
+	if !ipm {
+		return ""
+	}
+	return ", ipm " + sqlType
+}
+
+// AssocColDefs returns SQL definitions (including a leading comma)
+// of the optional colcounts columns with association measures
+// (mi, tscore, logdice) of the provided type. In case assoc is false,
+// an empty string is returned.
+func AssocColDefs(assoc bool, sqlType string) string {
+	if !assoc {
+		return ""
+	}
+	return fmt.Sprintf(", mi %s, tscore %s, logdice %s", sqlType, sqlType, sqlType)
+}
+
 // GenerateColCountNames creates a list of general column names
 // for positional attributes we would like to count. E.g. in
 // case we want [0, 1, 3] (this can be something like 'word', 'lemma' )
+// we get [col0, col1, col3]. Columns with an n-gram position set
+// are named like col0_1, col0_2. Columns named by their role
+// are named like lemma, lemma_1 (see VertColumn.ColCountName).
 func GenerateColCountNames(colCount VertColumns) []string {
 	columns := make([]string, len(colCount))
 	for i, v := range colCount {
-		columns[i] = fmt.Sprintf("col%d", v.Idx)
+		columns[i] = v.ColCountName()
 	}
 	return columns
 }
+
+// IsNgramColCountName tests whether a colcounts column name
+// refers to an n-gram column (col0, col1_2, lemma, lemma_2,...)
+func IsNgramColCountName(name string) bool {
+	base, _, _ := strings.Cut(name, "_")
+	if IsKnownRole(base) {
+		return true
+	}
+	if len(base) < 4 || !strings.HasPrefix(base, "col") {
+		return false
+	}
+	for _, c := range base[3:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RoleIndexedColCountNames returns names of colcounts columns which
+// should be indexed because they have a known role. For n-grams stored
+// in separate position columns, only the first position is indexed.
+func RoleIndexedColCountNames(colCount VertColumns) []string {
+	ans := make([]string, 0, len(colCount))
+	for _, v := range colCount {
+		if IsKnownRole(v.Role) && v.NgramPos <= 1 {
+			ans = append(ans, v.ColCountName())
+		}
+	}
+	return ans
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AccumulateCorpusSize is a common implementation of
+// CorpusSizesUpdater.UpdateCorpusSize for SQL databases. All the
+// rows of the corpus in the corpus_sizes table (provided as
+// a quoted name) are replaced by a single row containing their
+// sums plus the provided numbers. The placeholder function returns
+// a placeholder of the i-th (starting from 1) query argument.
+func AccumulateCorpusSize(
+	tx *sql.Tx,
+	table string,
+	placeholder func(i int) string,
+	corpusID string,
+	tokens, atoms int,
+) (int, int, error) {
+	var prevTokens, prevAtoms int64
+	row := tx.QueryRow(
+		fmt.Sprintf(
+			"SELECT COALESCE(SUM(tokens), 0), COALESCE(SUM(atoms), 0) FROM %s WHERE corpus_id = %s",
+			table, placeholder(1)),
+		corpusID,
+	)
+	if err := row.Scan(&prevTokens, &prevAtoms); err != nil {
+		return 0, 0, fmt.Errorf("failed to read size of corpus %s: %w", corpusID, err)
+	}
+	_, err := tx.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE corpus_id = %s", table, placeholder(1)), corpusID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to remove size of corpus %s: %w", corpusID, err)
+	}
+	totalTokens := int(prevTokens) + tokens
+	totalAtoms := int(prevAtoms) + atoms
+	_, err = tx.Exec(
+		fmt.Sprintf(
+			"INSERT INTO %s (corpus_id, tokens, atoms) VALUES (%s, %s, %s)",
+			table, placeholder(1), placeholder(2), placeholder(3)),
+		corpusID, totalTokens, totalAtoms,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to insert size of corpus %s: %w", corpusID, err)
+	}
+	return totalTokens, totalAtoms, nil
+}
+
+// QuestionMarkPlaceholder is a placeholder function for
+// AccumulateCorpusSize used by databases with "?" placeholders
+func QuestionMarkPlaceholder(i int) string {
+	return "?"
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build duckdb
+
+// Package duckdb provides a DuckDB writer. As the driver requires
+// a large native library, the package is compiled only with
+// the 'duckdb' build tag (go build -tags duckdb ...).
+package duckdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+
+	_ "github.com/marcboeker/go-duckdb" // load the driver
+)
+
+type Writer struct {
+	database         *sql.DB
+	tx               *sql.Tx
+	Path             string
+	PreconfQueries   []string
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	IndexedCols      []string
+	SelfJoinConf     db.SelfJoinConf
+	BibViewConf      db.BibViewConf
+	VertColumns      db.VertColumns
+	DocFreq          bool
+	AssocMeasures    bool
+	IPM              bool
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	DictEncoding     bool
+	MultiValueTable  bool
+}
+
+func (w *Writer) DatabaseExists() bool {
+	return fs.IsFile(w.Path)
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	var err error
+	dbExisted := fs.IsFile(w.Path)
+	w.database, err = sql.Open("duckdb", w.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open DuckDB database: %w", err)
+	}
+	log.Info().Msgf("Opened DuckDB database %s", w.Path)
+
+	for _, q := range w.PreconfQueries {
+		log.Info().Str("value", q).Msg("Applying preconfiguration")
+		if _, err := w.database.Exec(q); err != nil {
+			return fmt.Errorf("failed to apply preconfiguration '%s': %w", q, err)
+		}
+	}
+
+	if !appendMode {
+		if dbExisted {
+			log.
+				Warn().
+				Str("database", w.Path).
+				Msg("The database already exists. Existing data will be deleted.")
+			err := dropExisting(w.database)
+			if err != nil {
+				return err
+			}
+		}
+		err := createSchema(w.database, schemaOptions{
+			structures:       w.Structures,
+			columnNames:      w.ColumnNames,
+			columnTypes:      w.ColumnTypes,
+			indexedCols:      w.IndexedCols,
+			useSelfJoin:      w.SelfJoinConf.IsConfigured(),
+			countColumns:     w.VertColumns,
+			docFreq:          w.DocFreq,
+			assocMeasures:    w.AssocMeasures,
+			ipm:              w.IPM,
+			hapaxTable:       w.HapaxTable,
+			tfidfTable:       w.TFIDFTable,
+			itemCountsTable:  w.ItemCountsTable,
+			tagDistribTables: w.TagDistribTables,
+			dictEncoding:     w.DictEncoding,
+			multiValueTable:  w.MultiValueTable,
+		})
+		if err != nil {
+			return err
+		}
+		if w.BibViewConf.IsConfigured() {
+			err := createBibView(w.database, w.BibViewConf.Cols, w.BibViewConf.IDAttr)
+			if err != nil {
+				return err
+			}
+		}
+
+	} else if err := createCorpusSizesTable(w.database); err != nil {
+		return err
+	}
+	w.tx, err = w.database.Begin()
+	return err
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	if w.tx == nil {
+		return nil, fmt.Errorf("cannot prepare insert - no transaction active")
+	}
+	stmt, err := prepareInsert(w.tx, table, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return &db.Insert{Stmt: stmt}, nil
+}
+
+func (w *Writer) UpdateCorpusSize(corpusID string, tokens, atoms int) (int, int, error) {
+	if w.tx == nil {
+		return 0, 0, fmt.Errorf("cannot update corpus size - no transaction active")
+	}
+	return db.AccumulateCorpusSize(
+		w.tx,
+		db.CorpusSizesTable,
+		db.QuestionMarkPlaceholder,
+		corpusID,
+		tokens,
+		atoms,
+	)
+}
+
+func (w *Writer) Commit() error {
+	return w.tx.Commit()
+}
+
+func (w *Writer) Rollback() error {
+	return w.tx.Rollback()
+}
+
+func (w *Writer) Close() {
+	if w.database == nil {
+		return
+	}
+	err := w.database.Close()
+	if err != nil {
+		log.Warn().Err(err).Msg("Error closing database")
+	}
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	return &Writer{
+		Path:             conf.DB.Name,
+		PreconfQueries:   conf.DB.PreconfQueries,
+		Structures:       conf.Structures,
+		ColumnNames:      conf.ColumnNames,
+		ColumnTypes:      conf.ColumnTypes,
+		IndexedCols:      conf.IndexedCols,
+		SelfJoinConf:     conf.SelfJoin,
+		BibViewConf:      conf.BibView,
+		VertColumns:      conf.Ngrams.CountColumns(),
+		DocFreq:          conf.Ngrams.HasDocFreq(),
+		AssocMeasures:    conf.Ngrams.AssocMeasures,
+		IPM:              conf.Ngrams.IPM,
+		HapaxTable:       conf.Ngrams.HasHapaxTable(),
+		TFIDFTable:       conf.Ngrams.TFIDF,
+		ItemCountsTable:  conf.Ngrams.ItemCounts,
+		TagDistribTables: conf.TagDistrib != nil,
+		DictEncoding:     conf.Ngrams.DictEncoding,
+		MultiValueTable:  len(conf.MultiValues) > 0,
+	}, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build duckdb
+
+package duckdb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLColumnType(t *testing.T) {
+	for colType, expected := range map[string]string{
+		db.ColumnTypeInteger: "BIGINT",
+		db.ColumnTypeFloat:   "DOUBLE",
+		db.ColumnTypeDate:    "DATE",
+		db.ColumnTypeBoolean: "BOOLEAN",
+		"":                   "VARCHAR",
+	} {
+		assert.Equal(t, expected, sqlColumnType(colType), colType)
+	}
+}
+
+func TestGenerateAuxColDefs(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{"poscount INTEGER", "wordcount INTEGER", "corpus_id VARCHAR"},
+		generateAuxColDefs(false),
+	)
+	assert.Equal(
+		t,
+		[]string{"poscount INTEGER", "wordcount INTEGER", "corpus_id VARCHAR", "item_id VARCHAR"},
+		generateAuxColDefs(true),
+	)
+}
+
+func TestWriteAndAppend(t *testing.T) {
+	conf := &cnf.VTEConf{
+		Corpus:      "syn",
+		Structures:  map[string][]string{"doc": {"id", "year"}},
+		ColumnTypes: map[string]string{"doc_year": db.ColumnTypeInteger},
+		DB:          db.Conf{Name: filepath.Join(t.TempDir(), "test.duckdb")},
+	}
+	write := func(appendMode bool, docID string, year int) (int, int) {
+		w, err := NewWriter(conf)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Initialize(appendMode))
+		defer w.Close()
+		ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "doc_year", "corpus_id"})
+		assert.NoError(t, err)
+		assert.NoError(t, ins.Exec(docID, year, "syn"))
+		tokens, atoms, err := w.UpdateCorpusSize("syn", 100, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Commit())
+		return tokens, atoms
+	}
+	tokens, atoms := write(false, "d1", 1999)
+	assert.Equal(t, 100, tokens)
+	assert.Equal(t, 1, atoms)
+	tokens, atoms = write(true, "d2", 2001)
+	assert.Equal(t, 200, tokens)
+	assert.Equal(t, 2, atoms)
+
+	database, err := sql.Open("duckdb", conf.DB.Name)
+	assert.NoError(t, err)
+	defer database.Close()
+	var numRows, sumYears int
+	assert.NoError(t, database.QueryRow(
+		"SELECT COUNT(*), SUM(doc_year) FROM liveattrs_entry").Scan(&numRows, &sumYears))
+	assert.Equal(t, 2, numRows)
+	assert.Equal(t, 4000, sumYears)
+}
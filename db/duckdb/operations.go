@@ -0,0 +1,309 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build duckdb
+
+package duckdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+func joinArgs(args []string) string {
+	return strings.Join(args, ", ")
+}
+
+// prepareInsert creates a prepared statement for an INSERT
+// operation.
+func prepareInsert(database *sql.Tx, table string, cols []string) (*sql.Stmt, error) {
+	valReplac := make([]string, len(cols))
+	for i := range cols {
+		valReplac[i] = "?"
+	}
+	ans, err := database.Prepare(
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, joinArgs(cols), joinArgs(valReplac)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare INSERT: %s", err)
+	}
+	return ans, nil
+}
+
+// sqlColumnType returns an SQL type of a liveattrs_entry
+// column of the provided type (see db.ColumnTypeInteger etc.)
+func sqlColumnType(colType string) string {
+	switch colType {
+	case db.ColumnTypeInteger:
+		return "BIGINT"
+	case db.ColumnTypeFloat:
+		return "DOUBLE"
+	case db.ColumnTypeDate:
+		return "DATE"
+	case db.ColumnTypeBoolean:
+		return "BOOLEAN"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// generateColNames produces a list of structural
+// attribute names as used in database
+// (i.e. [structname]_[attr_name]) out of lists
+// of structural attributes defined in the configuration.
+func generateColNames(structures map[string][]string, columnNames map[string]string) []string {
+	numAttrs := 0
+	for _, v := range structures {
+		numAttrs += len(v)
+	}
+	ans := make([]string, numAttrs)
+	i := 0
+	for k, v := range structures {
+		for _, a := range v {
+			ans[i] = db.StructAttrColumn(k, a, columnNames)
+			i++
+		}
+	}
+	return ans
+}
+
+// generateAuxColDefs creates definitions for
+// auxiliary columns (num of positions, num of words etc.)
+func generateAuxColDefs(hasSelfJoin bool) []string {
+	ans := []string{"poscount INTEGER", "wordcount INTEGER", "corpus_id VARCHAR"}
+	if hasSelfJoin {
+		ans = append(ans, "item_id VARCHAR")
+	}
+	return ans
+}
+
+// createBibView creates a database view needed
+// by liveattrs to fetch bibliography information.
+func createBibView(database *sql.DB, cols []string, idAttr string) error {
+	colDefs := make([]string, len(cols))
+	for i, c := range cols {
+		if c != idAttr {
+			colDefs[i] = c
+
+		} else {
+			colDefs[i] = fmt.Sprintf("%s AS id", c)
+		}
+	}
+	_, err := database.Exec(
+		fmt.Sprintf("CREATE VIEW bibliography AS SELECT %s FROM liveattrs_entry", joinArgs(colDefs)))
+	return err
+}
+
+func createAuxIndices(database *sql.DB, cols []string) error {
+	for _, c := range cols {
+		_, err := database.Exec(fmt.Sprintf("CREATE INDEX %s_idx ON liveattrs_entry(%s)", c, c))
+		if err != nil {
+			return err
+		}
+		log.Info().
+			Str("index", c+"_idx").
+			Str("table", "liveattrs_entry").
+			Str("column", c).
+			Msg("Created custom index")
+	}
+	return nil
+}
+
+// dropExisting drops existing tables/views.
+// It is safe to call this even if one or more
+// of these does not exist.
+func dropExisting(database *sql.DB) error {
+	log.Info().Msg("Attempting to drop possible existing tables and views")
+	queries := []string{
+		"DROP VIEW IF EXISTS bibliography",
+		"DROP TABLE IF EXISTS liveattrs_entry",
+		"DROP SEQUENCE IF EXISTS liveattrs_entry_id_seq",
+		"DROP TABLE IF EXISTS " + db.CorpusSizesTable,
+		"DROP TABLE IF EXISTS " + db.CorpusTagDistribTable,
+		"DROP TABLE IF EXISTS " + db.CorpusTagDistribTTTable,
+		"DROP TABLE IF EXISTS " + db.LiveattrsMultiValueTable,
+		"DROP TABLE IF EXISTS colcounts",
+		"DROP TABLE IF EXISTS " + db.ColcountsHapaxTable,
+		"DROP TABLE IF EXISTS " + db.CorpusTFIDFTable,
+		"DROP TABLE IF EXISTS " + db.CorpusItemCountsTable,
+	}
+	for _, q := range queries {
+		if _, err := database.Exec(q); err != nil {
+			return fmt.Errorf("failed to run '%s': %s", q, err)
+		}
+	}
+	return nil
+}
+
+// createCorpusSizesTable creates the corpus_sizes table in case it
+// does not exist yet (databases created by older versions lack it)
+func createCorpusSizesTable(database db.Executor) error {
+	_, err := database.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (corpus_id VARCHAR, tokens BIGINT, atoms BIGINT)", db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf("failed to create table '%s': %s", db.CorpusSizesTable, err)
+	}
+	return nil
+}
+
+// schemaOptions specifies the tables, views and columns
+// to be created by createSchema.
+type schemaOptions struct {
+	structures       map[string][]string
+	columnNames      map[string]string
+	columnTypes      map[string]string
+	indexedCols      []string
+	useSelfJoin      bool
+	countColumns     db.VertColumns
+	docFreq          bool
+	assocMeasures    bool
+	ipm              bool
+	hapaxTable       bool
+	tfidfTable       bool
+	itemCountsTable  bool
+	tagDistribTables bool
+	dictEncoding     bool
+	multiValueTable  bool
+}
+
+// createSchema creates all the required tables, views and indices
+func createSchema(database *sql.DB, opts schemaOptions) error {
+	log.Info().Msg("Attempting to create tables and views")
+
+	// DuckDB does not support AUTOINCREMENT so we have to use a sequence
+	_, dbErr := database.Exec("CREATE SEQUENCE liveattrs_entry_id_seq")
+	if dbErr != nil {
+		return fmt.Errorf("failed to create sequence 'liveattrs_entry_id_seq': %s", dbErr)
+	}
+	cols := generateColNames(opts.structures, opts.columnNames)
+	colTypes := db.OutputColumnTypes(opts.structures, opts.columnNames, opts.columnTypes)
+	colsDefs := make([]string, len(cols))
+	for i, col := range cols {
+		colsDefs[i] = fmt.Sprintf("%s %s", col, sqlColumnType(colTypes[col]))
+	}
+	allCollsDefs := append(colsDefs, generateAuxColDefs(opts.useSelfJoin)...)
+	_, dbErr = database.Exec(fmt.Sprintf(
+		"CREATE TABLE liveattrs_entry (id INTEGER PRIMARY KEY DEFAULT nextval('liveattrs_entry_id_seq'), %s)",
+		joinArgs(allCollsDefs)))
+	if dbErr != nil {
+		return fmt.Errorf("failed to create table 'liveattrs_entry': %s", dbErr)
+	}
+	if dbErr = createCorpusSizesTable(database); dbErr != nil {
+		return dbErr
+	}
+	if opts.multiValueTable {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id VARCHAR, item_id VARCHAR, attr VARCHAR, value VARCHAR)",
+			db.LiveattrsMultiValueTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.LiveattrsMultiValueTable, dbErr)
+		}
+	}
+	if opts.tagDistribTables {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id VARCHAR, tag VARCHAR, count BIGINT)", db.CorpusTagDistribTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.CorpusTagDistribTable, dbErr)
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id VARCHAR, attr VARCHAR, value VARCHAR, tag VARCHAR, count BIGINT)",
+			db.CorpusTagDistribTTTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.CorpusTagDistribTTTable, dbErr)
+		}
+	}
+
+	if opts.useSelfJoin {
+		_, dbErr = database.Exec(
+			"CREATE UNIQUE INDEX item_id_corpus_id_idx ON liveattrs_entry(item_id, corpus_id)")
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create index item_id_idx on liveattrs_entry(item_id): %s", dbErr)
+		}
+	}
+	dbErr = createAuxIndices(database, opts.indexedCols)
+	if dbErr != nil {
+		return fmt.Errorf("failed to create a custom index: %s", dbErr)
+	}
+
+	if len(opts.countColumns) > 0 {
+		colDefs := db.GenerateColCountNames(opts.countColumns)
+		colType := "VARCHAR"
+		if opts.dictEncoding {
+			colType = "INTEGER"
+		}
+		for i, c := range colDefs {
+			colDefs[i] = c + " " + colType
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE colcounts (hash_id VARCHAR PRIMARY KEY, %s, corpus_id VARCHAR, count INTEGER, arf DOUBLE%s)",
+			joinArgs(colDefs), db.DocFreqColDef(opts.docFreq, "INTEGER")+db.IPMColDef(opts.ipm, "DOUBLE")+db.AssocColDefs(opts.assocMeasures, "DOUBLE")))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table 'colcounts': %s", dbErr)
+		}
+		if opts.dictEncoding {
+			for _, tbl := range db.ColValuesTables(opts.countColumns) {
+				_, dbErr = database.Exec("DROP TABLE IF EXISTS " + tbl)
+				if dbErr != nil {
+					return fmt.Errorf("failed to drop table '%s': %s", tbl, dbErr)
+				}
+				_, dbErr = database.Exec(fmt.Sprintf(
+					"CREATE TABLE %s (corpus_id VARCHAR, id INTEGER, value VARCHAR, PRIMARY KEY(corpus_id, id))", tbl))
+				if dbErr != nil {
+					return fmt.Errorf("failed to create table '%s': %s", tbl, dbErr)
+				}
+			}
+		}
+		if opts.hapaxTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s AS SELECT * FROM colcounts WHERE false", db.ColcountsHapaxTable))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.ColcountsHapaxTable, dbErr)
+			}
+		}
+		if opts.tfidfTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s (atom_id INTEGER, hash_id VARCHAR, corpus_id VARCHAR, tfidf DOUBLE)",
+				db.CorpusTFIDFTable))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.CorpusTFIDFTable, dbErr)
+			}
+		}
+		if opts.itemCountsTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s (item_id VARCHAR, hash_id VARCHAR, corpus_id VARCHAR, count INTEGER)",
+				db.CorpusItemCountsTable))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.CorpusItemCountsTable, dbErr)
+			}
+		}
+		_, dbErr = database.Exec("CREATE INDEX colcounts_corpus_id_idx ON colcounts(corpus_id)")
+		if dbErr != nil {
+			return fmt.Errorf("failed to create index colcounts_corpus_id_idx on colcounts(corpus_id): %s", dbErr)
+		}
+		for _, col := range db.RoleIndexedColCountNames(opts.countColumns) {
+			_, dbErr = database.Exec(fmt.Sprintf("CREATE INDEX colcounts_%s_idx ON colcounts(%s)", col, col))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create index colcounts_%s_idx on colcounts(%s): %s", col, col, dbErr)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// liveattrsMapping creates index mapping for structural attributes.
+// Each attribute is stored as a keyword (for faceted search) with
+// an additional analyzed 'text' subfield for full-text search.
+// Typed attributes (see cnf.VTEConf.ColumnTypes) use respective
+// numeric, date or boolean fields instead.
+func liveattrsMapping(
+	structures map[string][]string,
+	columnNames map[string]string,
+	columnTypes map[string]string,
+	useSelfJoin bool,
+) map[string]any {
+	props := make(map[string]any)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			col := db.StructAttrColumn(st, attr, columnNames)
+			switch db.StructAttrColumnType(st, attr, columnTypes) {
+			case db.ColumnTypeInteger:
+				props[col] = map[string]string{"type": "long"}
+			case db.ColumnTypeFloat:
+				props[col] = map[string]string{"type": "double"}
+			case db.ColumnTypeDate:
+				props[col] = map[string]string{"type": "date", "format": "strict_date"}
+			case db.ColumnTypeBoolean:
+				props[col] = map[string]string{"type": "boolean"}
+			default:
+				props[col] = map[string]any{
+					"type": "keyword",
+					"fields": map[string]any{
+						"text": map[string]string{"type": "text"},
+					},
+				}
+			}
+		}
+	}
+	props["poscount"] = map[string]string{"type": "integer"}
+	props["wordcount"] = map[string]string{"type": "integer"}
+	props["corpus_id"] = map[string]string{"type": "keyword"}
+	if useSelfJoin {
+		props["item_id"] = map[string]string{"type": "keyword"}
+	}
+	return props
+}
+
+func colcountsMapping(countColumns db.VertColumns) map[string]any {
+	props := make(map[string]any)
+	for _, col := range db.GenerateColCountNames(countColumns) {
+		props[col] = map[string]string{"type": "keyword"}
+	}
+	props["hash_id"] = map[string]string{"type": "keyword"}
+	props["corpus_id"] = map[string]string{"type": "keyword"}
+	props["count"] = map[string]string{"type": "long"}
+	props["arf"] = map[string]string{"type": "double"}
+	props["docfreq"] = map[string]string{"type": "long"}
+	props["ipm"] = map[string]string{"type": "double"}
+	props["mi"] = map[string]string{"type": "double"}
+	props["tscore"] = map[string]string{"type": "double"}
+	props["logdice"] = map[string]string{"type": "double"}
+	return props
+}
+
+func corpusSizesMapping() map[string]any {
+	return map[string]any{
+		"corpus_id": map[string]string{"type": "keyword"},
+		"tokens":    map[string]string{"type": "long"},
+		"atoms":     map[string]string{"type": "long"},
+	}
+}
+
+func multiValueMapping() map[string]any {
+	return map[string]any{
+		"corpus_id": map[string]string{"type": "keyword"},
+		"item_id":   map[string]string{"type": "keyword"},
+		"attr":      map[string]string{"type": "keyword"},
+		"value":     map[string]string{"type": "keyword"},
+	}
+}
+
+func tagDistribMapping(textTypes bool) map[string]any {
+	ans := map[string]any{
+		"corpus_id": map[string]string{"type": "keyword"},
+		"tag":       map[string]string{"type": "keyword"},
+		"count":     map[string]string{"type": "long"},
+	}
+	if textTypes {
+		ans["attr"] = map[string]string{"type": "keyword"}
+		ans["value"] = map[string]string{"type": "keyword"}
+	}
+	return ans
+}
+
+func tfidfMapping() map[string]any {
+	return map[string]any{
+		"atom_id":   map[string]string{"type": "long"},
+		"hash_id":   map[string]string{"type": "keyword"},
+		"corpus_id": map[string]string{"type": "keyword"},
+		"tfidf":     map[string]string{"type": "double"},
+	}
+}
+
+func itemCountsMapping() map[string]any {
+	return map[string]any{
+		"item_id":   map[string]string{"type": "keyword"},
+		"hash_id":   map[string]string{"type": "keyword"},
+		"corpus_id": map[string]string{"type": "keyword"},
+		"count":     map[string]string{"type": "long"},
+	}
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  any `json:"error"`
+	} `json:"items"`
+}
+
+// bulkInsert buffers documents and sends them using
+// the _bulk API once batchSize is reached
+type bulkInsert struct {
+	writer  *Writer
+	index   string
+	attrs   []string
+	action  []byte
+	buff    bytes.Buffer
+	numDocs int
+	numSent int
+}
+
+func (ins *bulkInsert) Exec(values ...any) error {
+	doc := make(map[string]any, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+		doc[ins.attrs[i]] = v
+	}
+	data, err := sonic.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode document: %w", err)
+	}
+	ins.buff.Write(ins.action)
+	ins.buff.Write(data)
+	ins.buff.WriteByte('\n')
+	ins.numDocs++
+	if ins.numDocs >= ins.writer.batchSize {
+		return ins.flush()
+	}
+	return nil
+}
+
+func (ins *bulkInsert) flush() error {
+	if ins.numDocs == 0 {
+		return nil
+	}
+	status, body, err := ins.writer.request(
+		http.MethodPost, "/_bulk", "application/x-ndjson", &ins.buff)
+	if err != nil {
+		return fmt.Errorf("failed to index documents into %s: %w", ins.index, err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to index documents into %s: %s", ins.index, body)
+	}
+	var resp bulkResponse
+	if err := sonic.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if resp.Errors {
+		for _, item := range resp.Items {
+			for _, res := range item {
+				if res.Error != nil {
+					return fmt.Errorf(
+						"failed to index documents into %s: %v (status %d)",
+						ins.index, res.Error, res.Status)
+				}
+			}
+		}
+	}
+	ins.numSent += ins.numDocs
+	ins.reset()
+	return nil
+}
+
+func (ins *bulkInsert) reset() {
+	ins.buff.Reset()
+	ins.numDocs = 0
+}
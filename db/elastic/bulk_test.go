@@ -0,0 +1,157 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+// fakeServer records requests sent to the Elasticsearch REST API
+// and answers them with the configured bulk response
+type fakeServer struct {
+	server       *httptest.Server
+	requests     []receivedRequest
+	existing     map[string]bool
+	bulkResponse string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	fs := &fakeServer{existing: make(map[string]bool), bulkResponse: `{"errors":false,"items":[]}`}
+	fs.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		fs.requests = append(fs.requests, receivedRequest{method: r.Method, path: r.URL.Path, body: string(body)})
+		switch {
+		case r.Method == http.MethodHead && !fs.existing[strings.TrimPrefix(r.URL.Path, "/")]:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/_bulk":
+			io.WriteString(w, fs.bulkResponse)
+		}
+	}))
+	t.Cleanup(fs.server.Close)
+	return fs
+}
+
+func newTestWriter(t *testing.T, fs *fakeServer, batchSize int) *Writer {
+	w, err := NewWriter(&cnf.VTEConf{
+		Corpus:     "SYN",
+		Structures: map[string][]string{"doc": {"title"}},
+		DB:         db.Conf{Host: fs.server.URL, BatchSize: batchSize},
+	})
+	assert.NoError(t, err)
+	return w
+}
+
+func TestLiveattrsMapping(t *testing.T) {
+	props := liveattrsMapping(
+		map[string][]string{"doc": {"title", "year", "published", "score", "open"}},
+		map[string]string{"doc_title": "title"},
+		map[string]string{
+			"doc_year":      db.ColumnTypeInteger,
+			"doc_published": db.ColumnTypeDate,
+			"doc_score":     db.ColumnTypeFloat,
+			"doc_open":      db.ColumnTypeBoolean,
+		},
+		true,
+	)
+	assert.Equal(
+		t,
+		map[string]any{
+			"title": map[string]any{
+				"type":   "keyword",
+				"fields": map[string]any{"text": map[string]string{"type": "text"}},
+			},
+			"doc_year":      map[string]string{"type": "long"},
+			"doc_published": map[string]string{"type": "date", "format": "strict_date"},
+			"doc_score":     map[string]string{"type": "double"},
+			"doc_open":      map[string]string{"type": "boolean"},
+			"poscount":      map[string]string{"type": "integer"},
+			"wordcount":     map[string]string{"type": "integer"},
+			"corpus_id":     map[string]string{"type": "keyword"},
+			"item_id":       map[string]string{"type": "keyword"},
+		},
+		props,
+	)
+	assert.NotContains(t, liveattrsMapping(nil, nil, nil, false), "item_id")
+}
+
+func TestColcountsMapping(t *testing.T) {
+	props := colcountsMapping(db.VertColumns{{Idx: 0}, {Idx: 2}})
+	assert.Equal(t, map[string]string{"type": "keyword"}, props["col0"])
+	assert.Equal(t, map[string]string{"type": "keyword"}, props["col2"])
+	assert.Equal(t, map[string]string{"type": "long"}, props["count"])
+	assert.Equal(t, map[string]string{"type": "double"}, props["arf"])
+}
+
+func TestTagDistribMapping(t *testing.T) {
+	assert.NotContains(t, tagDistribMapping(false), "attr")
+	assert.Contains(t, tagDistribMapping(true), "attr")
+	assert.Contains(t, tagDistribMapping(true), "value")
+}
+
+func TestBulkBody(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 2)
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_title", "poscount", "corpus_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("a \"quoted\" title", 10, "syn"))
+	assert.NoError(t, ins.Exec("", 20, "syn"))
+	assert.NoError(t, ins.Exec("third", 30, "syn"))
+	if assert.Len(t, fs.requests, 1) {
+		assert.Equal(t, "/_bulk", fs.requests[0].path)
+		// sonic does not sort map keys so documents are compared as JSON
+		lines := strings.Split(fs.requests[0].body, "\n")
+		if assert.Len(t, lines, 5) {
+			assert.Equal(t, `{"index":{"_index":"syn_liveattrs_entry"}}`, lines[0])
+			assert.JSONEq(t, `{"corpus_id":"syn","doc_title":"a \"quoted\" title","poscount":10}`, lines[1])
+			assert.Equal(t, `{"index":{"_index":"syn_liveattrs_entry"}}`, lines[2])
+			assert.JSONEq(t, `{"corpus_id":"syn","poscount":20}`, lines[3])
+			assert.Empty(t, lines[4])
+		}
+	}
+	assert.NoError(t, w.Commit())
+	if assert.Len(t, fs.requests, 3) {
+		assert.Equal(t, "/_bulk", fs.requests[1].path)
+		assert.Equal(t, "/syn_liveattrs_entry/_refresh", fs.requests[2].path)
+	}
+}
+
+func TestBulkItemError(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.bulkResponse = `{"errors":true,"items":[{"index":{"status":400,"error":"mapper_parsing_exception"}}]}`
+	w := newTestWriter(t, fs, 10)
+	ins, err := w.PrepareInsert("colcounts", []string{"col0"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo"))
+	err = w.Commit()
+	assert.ErrorContains(t, err, "mapper_parsing_exception")
+	assert.ErrorContains(t, err, "status 400")
+}
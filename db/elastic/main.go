@@ -0,0 +1,279 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elastic provides a writer indexing extracted data into
+// Elasticsearch (or OpenSearch) using its REST bulk API.
+package elastic
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	dfltBatchSize   = 5000
+	dfltHTTPTimeout = 5 * time.Minute
+)
+
+// Writer indexes each row as a document. Tables are mapped to indices
+// named [groupedCorpusName]_[table] (e.g. syn_v4_liveattrs_entry).
+// As Elasticsearch does not support transactions, Rollback in the
+// "create" mode removes the whole indices while in the "append" mode
+// it can only discard documents not sent yet.
+type Writer struct {
+	client            *http.Client
+	serverURL         string
+	user              string
+	password          string
+	batchSize         int
+	groupedCorpusName string
+	appendMode        bool
+	inserts           []*bulkInsert
+
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	SelfJoinConf     db.SelfJoinConf
+	CountColumns     db.VertColumns
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	MultiValueTable  bool
+}
+
+func (w *Writer) indexName(table string) string {
+	return strings.ToLower(fmt.Sprintf("%s_%s", w.groupedCorpusName, table))
+}
+
+func (w *Writer) request(method, path, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequest(method, w.serverURL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if w.user != "" {
+		req.SetBasicAuth(w.user, w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+func (w *Writer) indexExists(index string) (bool, error) {
+	status, _, err := w.request(http.MethodHead, "/"+index, "", nil)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusOK, nil
+}
+
+func (w *Writer) deleteIndex(index string) error {
+	status, body, err := w.request(http.MethodDelete, "/"+index, "", nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete index %s: %s", index, body)
+	}
+	return nil
+}
+
+func (w *Writer) createIndex(index string, properties map[string]any) error {
+	mapping, err := sonic.Marshal(map[string]any{
+		"mappings": map[string]any{"properties": properties},
+	})
+	if err != nil {
+		return err
+	}
+	status, body, err := w.request(
+		http.MethodPut, "/"+index, "application/json", strings.NewReader(string(mapping)))
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", index, err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to create index %s: %s", index, body)
+	}
+	log.Info().Str("index", index).Msg("Created Elasticsearch index")
+	return nil
+}
+
+func (w *Writer) DatabaseExists() bool {
+	ans, err := w.indexExists(w.indexName("liveattrs_entry"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to test data storage existence")
+		return false
+	}
+	return ans
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	w.appendMode = appendMode
+	if appendMode {
+		return nil
+	}
+	indices := map[string]map[string]any{
+		w.indexName("liveattrs_entry"):   liveattrsMapping(w.Structures, w.ColumnNames, w.ColumnTypes, w.SelfJoinConf.IsConfigured()),
+		w.indexName(db.CorpusSizesTable): corpusSizesMapping(),
+	}
+	if w.MultiValueTable {
+		indices[w.indexName(db.LiveattrsMultiValueTable)] = multiValueMapping()
+	}
+	if w.TagDistribTables {
+		indices[w.indexName(db.CorpusTagDistribTable)] = tagDistribMapping(false)
+		indices[w.indexName(db.CorpusTagDistribTTTable)] = tagDistribMapping(true)
+	}
+	if len(w.CountColumns) > 0 {
+		indices[w.indexName("colcounts")] = colcountsMapping(w.CountColumns)
+		if w.HapaxTable {
+			indices[w.indexName(db.ColcountsHapaxTable)] = colcountsMapping(w.CountColumns)
+		}
+		if w.TFIDFTable {
+			indices[w.indexName(db.CorpusTFIDFTable)] = tfidfMapping()
+		}
+		if w.ItemCountsTable {
+			indices[w.indexName(db.CorpusItemCountsTable)] = itemCountsMapping()
+		}
+	}
+	for index, properties := range indices {
+		exists, err := w.indexExists(index)
+		if err != nil {
+			return err
+		}
+		if exists {
+			log.Warn().
+				Str("index", index).
+				Msg("The index already exists. Existing data will be deleted.")
+			if err := w.deleteIndex(index); err != nil {
+				return err
+			}
+		}
+		if err := w.createIndex(index, properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	index := w.indexName(table)
+	action, err := sonic.Marshal(map[string]any{"index": map[string]string{"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+	ins := &bulkInsert{
+		writer: w,
+		index:  index,
+		attrs:  attrs,
+		action: append(action, '\n'),
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		if err := ins.flush(); err != nil {
+			return err
+		}
+		status, body, err := w.request(http.MethodPost, "/"+ins.index+"/_refresh", "", nil)
+		if err != nil {
+			return fmt.Errorf("failed to refresh index %s: %w", ins.index, err)
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("failed to refresh index %s: %s", ins.index, body)
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Rollback() error {
+	for _, ins := range w.inserts {
+		ins.reset()
+		if !w.appendMode {
+			if err := w.deleteIndex(ins.index); err != nil {
+				return err
+			}
+
+		} else if ins.numSent > 0 {
+			log.Warn().
+				Str("index", ins.index).
+				Int("numDocs", ins.numSent).
+				Msg("Elasticsearch does not support transactions, some documents already indexed will remain")
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Close() {
+	w.client.CloseIdleConnections()
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	if conf.Ngrams.DictEncoding {
+		return nil, fmt.Errorf("Elasticsearch writer does not support ngrams.dictEncoding")
+	}
+	serverURL := conf.DB.Host
+	if !strings.HasPrefix(serverURL, "http://") && !strings.HasPrefix(serverURL, "https://") {
+		serverURL = "http://" + serverURL
+	}
+	batchSize := conf.DB.BatchSize
+	if batchSize <= 0 {
+		batchSize = dfltBatchSize
+	}
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	if conf.BibView.IsConfigured() {
+		log.Warn().Msg("Elasticsearch writer does not support bibView, the setting will be ignored")
+	}
+	return &Writer{
+		client:            &http.Client{Timeout: dfltHTTPTimeout},
+		serverURL:         strings.TrimSuffix(serverURL, "/"),
+		user:              conf.DB.User,
+		password:          conf.DB.Password,
+		batchSize:         batchSize,
+		groupedCorpusName: groupedCorpusName,
+		Structures:        conf.Structures,
+		ColumnNames:       conf.ColumnNames,
+		ColumnTypes:       conf.ColumnTypes,
+		SelfJoinConf:      conf.SelfJoin,
+		CountColumns:      conf.Ngrams.CountColumns(),
+		HapaxTable:        conf.Ngrams.HasHapaxTable(),
+		TFIDFTable:        conf.Ngrams.TFIDF,
+		ItemCountsTable:   conf.Ngrams.ItemCounts,
+		TagDistribTables:  conf.TagDistrib != nil,
+		MultiValueTable:   len(conf.MultiValues) > 0,
+	}, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWriterRejectsDictEncoding(t *testing.T) {
+	_, err := NewWriter(&cnf.VTEConf{Ngrams: cnf.NgramConf{DictEncoding: true}})
+	assert.Error(t, err)
+}
+
+func TestInitializeReplacesExistingIndices(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.existing["syn_liveattrs_entry"] = true
+	w := newTestWriter(t, fs, 10)
+	assert.NoError(t, w.Initialize(false))
+	var deleted, created []string
+	for _, req := range fs.requests {
+		switch req.method {
+		case http.MethodDelete:
+			deleted = append(deleted, req.path)
+		case http.MethodPut:
+			created = append(created, req.path)
+		}
+	}
+	assert.Equal(t, []string{"/syn_liveattrs_entry"}, deleted)
+	assert.ElementsMatch(t, []string{"/syn_liveattrs_entry", "/syn_corpus_sizes"}, created)
+}
+
+func TestRollbackDeletesIndicesInCreateMode(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 10)
+	assert.NoError(t, w.Initialize(false))
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_title"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo"))
+	fs.requests = nil
+	assert.NoError(t, w.Rollback())
+	if assert.Len(t, fs.requests, 1) {
+		assert.Equal(t, http.MethodDelete, fs.requests[0].method)
+		assert.Equal(t, "/syn_liveattrs_entry", fs.requests[0].path)
+	}
+}
+
+func TestRollbackKeepsIndicesInAppendMode(t *testing.T) {
+	fs := newFakeServer(t)
+	w := newTestWriter(t, fs, 10)
+	assert.NoError(t, w.Initialize(true))
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_title"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo"))
+	assert.NoError(t, w.Rollback())
+	assert.Empty(t, fs.requests)
+}
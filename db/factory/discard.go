@@ -0,0 +1,55 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+type discardInsert struct{}
+
+func (di *discardInsert) Exec(values ...any) error {
+	return nil
+}
+
+// DiscardWriter is a writer accepting all the data without
+// storing them anywhere. It is useful to measure performance
+// of parsing and counting without the database overhead.
+type DiscardWriter struct {
+}
+
+func (dw *DiscardWriter) DatabaseExists() bool {
+	return false
+}
+
+func (dw *DiscardWriter) Initialize(appendMode bool) error {
+	return nil
+}
+
+func (dw *DiscardWriter) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	return &discardInsert{}, nil
+}
+
+func (dw *DiscardWriter) Commit() error {
+	return nil
+}
+
+func (dw *DiscardWriter) Rollback() error {
+	return nil
+}
+
+func (dw *DiscardWriter) Close() {}
@@ -19,10 +19,21 @@ package factory
 import (
 	"fmt"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/czcorpus/vert-tagextract/v2/cnf"
 	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/clickhouse"
+	"github.com/czcorpus/vert-tagextract/v2/db/elastic"
+	"github.com/czcorpus/vert-tagextract/v2/db/jsonl"
+	"github.com/czcorpus/vert-tagextract/v2/db/mssql"
 	"github.com/czcorpus/vert-tagextract/v2/db/mysql"
+	"github.com/czcorpus/vert-tagextract/v2/db/parquet"
+	"github.com/czcorpus/vert-tagextract/v2/db/postgres"
+	"github.com/czcorpus/vert-tagextract/v2/db/redis"
+	"github.com/czcorpus/vert-tagextract/v2/db/sqldump"
 	"github.com/czcorpus/vert-tagextract/v2/db/sqlite"
+	"github.com/czcorpus/vert-tagextract/v2/db/tsv"
 )
 
 type NullWriter struct {
@@ -63,22 +74,68 @@ func (nw *NullWriter) Rollback() error {
 
 func (nw *NullWriter) Close() {}
 
+// NewDatabaseWriter creates a database writer based on the "db.type"
+// configuration. Besides the built-in writers, also writers registered
+// via RegisterWriter are considered.
 func NewDatabaseWriter(conf *cnf.VTEConf) (db.Writer, error) {
 	switch conf.DB.Type {
 	case "sqlite":
+		var sqliteConf db.SQLiteConf
+		if conf.DB.SQLite != nil {
+			sqliteConf = *conf.DB.SQLite
+		}
 		db := &sqlite.Writer{
-			Path:           conf.DB.Name,
-			PreconfQueries: conf.DB.PreconfQueries,
-			Structures:     conf.Structures,
-			IndexedCols:    conf.IndexedCols,
-			SelfJoinConf:   conf.SelfJoin,
-			BibViewConf:    conf.BibView,
-			VertColumns:    conf.Ngrams.VertColumns,
+			Path:             conf.DB.Name,
+			PreconfQueries:   conf.DB.PreconfQueries,
+			SQLiteConf:       sqliteConf,
+			Structures:       conf.Structures,
+			ColumnNames:      conf.ColumnNames,
+			ColumnTypes:      conf.ColumnTypes,
+			IndexedCols:      conf.IndexedCols,
+			SelfJoinConf:     conf.SelfJoin,
+			BibViewConf:      conf.BibView,
+			VertColumns:      conf.Ngrams.CountColumns(),
+			DocFreq:          conf.Ngrams.HasDocFreq(),
+			AssocMeasures:    conf.Ngrams.AssocMeasures,
+			IPM:              conf.Ngrams.IPM,
+			HapaxTable:       conf.Ngrams.HasHapaxTable(),
+			TFIDFTable:       conf.Ngrams.TFIDF,
+			ItemCountsTable:  conf.Ngrams.ItemCounts,
+			TagDistribTables: conf.TagDistrib != nil,
+			DictEncoding:     conf.Ngrams.DictEncoding,
+			MultiValueTable:  len(conf.MultiValues) > 0,
+			DeferIndexes:     conf.DB.DeferIndexes,
 		}
+		db.SetLogger(log.With().Str("corpus", conf.Corpus).Logger())
 		return db, nil
 	case "mysql":
 		return mysql.NewWriter(conf)
+	case "postgres":
+		return postgres.NewWriter(conf)
+	case "mssql":
+		return mssql.NewWriter(conf)
+	case "clickhouse":
+		return clickhouse.NewWriter(conf)
+	case "duckdb":
+		return newDuckDBWriter(conf)
+	case "parquet":
+		return parquet.NewWriter(conf)
+	case "csv", "tsv":
+		return tsv.NewWriter(conf, conf.DB.Type)
+	case "jsonl":
+		return jsonl.NewWriter(conf)
+	case "sqldump":
+		return sqldump.NewWriter(conf)
+	case "elasticsearch":
+		return elastic.NewWriter(conf)
+	case "redis":
+		return redis.NewWriter(conf)
+	case "discard":
+		return &DiscardWriter{}, nil
 	default:
+		if factory, ok := registeredWriter(conf.DB.Type); ok {
+			return factory(conf)
+		}
 		return &NullWriter{}, nil
 	}
 }
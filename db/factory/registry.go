@@ -0,0 +1,83 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// WriterFactory creates a database writer based on
+// the provided configuration
+type WriterFactory func(conf *cnf.VTEConf) (db.Writer, error)
+
+var (
+	builtinWriters = map[string]bool{
+		"sqlite": true, "mysql": true, "postgres": true, "mssql": true,
+		"clickhouse": true, "duckdb": true, "parquet": true, "csv": true,
+		"tsv": true, "jsonl": true, "sqldump": true, "elasticsearch": true,
+		"redis": true, "discard": true,
+	}
+
+	registryMu sync.RWMutex
+	registry   = make(map[string]WriterFactory)
+)
+
+// RegisterWriter makes a third-party database writer available
+// under the specified name (to be used as "db.type" in the configuration).
+// It is intended to be called from an init function of a package
+// providing the writer. Like sql.Register, the function panics
+// in case the name is already used (including names of the
+// built-in writers) or the factory is nil.
+func RegisterWriter(name string, factory WriterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("factory: RegisterWriter factory is nil")
+	}
+	if builtinWriters[name] {
+		panic(fmt.Sprintf("factory: cannot register writer %s - a built-in writer of the same name exists", name))
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("factory: RegisterWriter called twice for writer %s", name))
+	}
+	registry[name] = factory
+}
+
+// RegisteredWriters returns sorted names of all the
+// registered third-party writers
+func RegisteredWriters() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ans := make([]string, 0, len(registry))
+	for name := range registry {
+		ans = append(ans, name)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func registeredWriter(name string) (WriterFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[name]
+	return factory, ok
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterWriter(t *testing.T) {
+	RegisterWriter("test-discard", func(conf *cnf.VTEConf) (db.Writer, error) {
+		return &DiscardWriter{}, nil
+	})
+	conf := &cnf.VTEConf{DB: db.Conf{Type: "test-discard"}}
+	w, err := NewDatabaseWriter(conf)
+	assert.NoError(t, err)
+	assert.IsType(t, &DiscardWriter{}, w)
+	assert.Contains(t, RegisteredWriters(), "test-discard")
+}
+
+func TestRegisterWriterRejectsBuiltin(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterWriter("sqlite", func(conf *cnf.VTEConf) (db.Writer, error) {
+			return &DiscardWriter{}, nil
+		})
+	})
+}
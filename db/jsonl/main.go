@@ -0,0 +1,151 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonl provides a writer producing JSON Lines files
+// where each inserted row becomes a single JSON object.
+package jsonl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+)
+
+type tableInsert struct {
+	file *fs.AtomicFile
+
+	// keys contains already encoded JSON object keys
+	// (including the colon) so we don't have to encode
+	// them over and over again
+	keys [][]byte
+	buff bytes.Buffer
+}
+
+// Exec writes a JSON object with keys matching the attributes
+// passed to PrepareInsert. The order of keys is preserved.
+func (ti *tableInsert) Exec(values ...any) error {
+	ti.buff.Reset()
+	ti.buff.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			ti.buff.WriteByte(',')
+		}
+		ti.buff.Write(ti.keys[i])
+		if s, ok := v.(string); ok && s == "" {
+			ti.buff.WriteString("null")
+			continue
+		}
+		enc, err := sonic.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode value %v: %w", v, err)
+		}
+		ti.buff.Write(enc)
+	}
+	ti.buff.WriteString("}\n")
+	_, err := ti.file.Write(ti.buff.Bytes())
+	return err
+}
+
+// Writer writes each table into a separate file named
+// [corpus]_[table].jsonl located in the output directory.
+type Writer struct {
+	outDir   string
+	corpusID string
+	useGzip  bool
+	inserts  []*tableInsert
+}
+
+func (w *Writer) tablePath(table string) string {
+	return filepath.Join(w.outDir, fmt.Sprintf("%s_%s.jsonl", w.corpusID, table))
+}
+
+// DatabaseExists tests whether the output directory exists.
+func (w *Writer) DatabaseExists() bool {
+	return fs.IsDir(w.outDir)
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	return os.MkdirAll(w.outDir, 0755)
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	path := w.tablePath(table)
+	if fs.IsFile(path) || fs.IsFile(path+".gz") {
+		log.Warn().Str("file", path).Msg("The output file already exists and will be overwritten")
+	}
+	ins := &tableInsert{keys: make([][]byte, len(attrs))}
+	for i, attr := range attrs {
+		enc, err := sonic.Marshal(attr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+		}
+		ins.keys[i] = append(enc, ':')
+	}
+	var err error
+	ins.file, err = fs.NewAtomicFile(path, w.useGzip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		if err := ins.file.Commit(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ins.file.Path(), err)
+		}
+		log.Info().Str("file", ins.file.Path()).Msg("Written output file")
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Rollback() error {
+	for _, ins := range w.inserts {
+		if err := ins.file.Discard(); err != nil {
+			log.Warn().Err(err).Str("file", ins.file.Path()).Msg("failed to discard output file")
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Close() {
+	if len(w.inserts) > 0 {
+		log.Warn().Msg("closing file writer with uncommitted data, discarding them")
+		w.Rollback()
+	}
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	if conf.BibView.IsConfigured() {
+		log.Warn().Msg("JSONL writer does not support bibView, the setting will be ignored")
+	}
+	return &Writer{
+		outDir:   conf.DB.Name,
+		corpusID: conf.Corpus,
+		useGzip:  conf.DB.Compress,
+	}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonl
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWriter(t *testing.T, compress bool) *Writer {
+	w, err := NewWriter(&cnf.VTEConf{Corpus: "syn", DB: db.Conf{Name: t.TempDir(), Compress: compress}})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Initialize(false))
+	return w
+}
+
+func TestEncodeValues(t *testing.T) {
+	w := newTestWriter(t, false)
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_title", "poscount", "ratio", "ok", "doc_note"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("a \"quoted\"\ntitle", 10, 0.5, true, nil))
+	assert.NoError(t, ins.Exec("žluťoučký", 0, 1.0, false, ""))
+	assert.NoError(t, w.Commit())
+
+	data, err := os.ReadFile(filepath.Join(w.outDir, "syn_liveattrs_entry.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`{"doc_title":"a \"quoted\"\ntitle","poscount":10,"ratio":0.5,"ok":true,"doc_note":null}`+"\n"+
+			`{"doc_title":"žluťoučký","poscount":0,"ratio":1,"ok":false,"doc_note":null}`+"\n",
+		string(data),
+	)
+}
+
+func TestEscapedKeys(t *testing.T) {
+	w := newTestWriter(t, false)
+	ins, err := w.PrepareInsert("colcounts", []string{"col\"0"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo"))
+	assert.NoError(t, w.Commit())
+
+	data, err := os.ReadFile(filepath.Join(w.outDir, "syn_colcounts.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"col\"0":"foo"}`+"\n", string(data))
+}
+
+func TestGzipOutput(t *testing.T) {
+	w := newTestWriter(t, true)
+	ins, err := w.PrepareInsert("colcounts", []string{"col0", "count"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", 3))
+	assert.NoError(t, w.Commit())
+
+	f, err := os.Open(filepath.Join(w.outDir, "syn_colcounts.jsonl.gz"))
+	assert.NoError(t, err)
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gzr)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"col0":"foo","count":3}`+"\n", string(data))
+}
+
+func TestRollbackWritesNothing(t *testing.T) {
+	w := newTestWriter(t, false)
+	ins, err := w.PrepareInsert("colcounts", []string{"col0"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo"))
+	assert.NoError(t, w.Rollback())
+	assert.NoFileExists(t, filepath.Join(w.outDir, "syn_colcounts.jsonl"))
+}
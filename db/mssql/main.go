@@ -0,0 +1,286 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mssql
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+
+	_ "github.com/microsoft/go-mssqldb" // load the driver
+)
+
+func joinArgs(args []string) string {
+	return strings.Join(args, ", ")
+}
+
+type Writer struct {
+	database *sql.DB
+	tx       *sql.Tx
+
+	// groupedCorpusName represents a derived corpus name which is able to group multiple
+	// (aligned) corpora together (e.g. intercorp_v13_en, intercorp_v13_cs => intercorp_v13)
+	groupedCorpusName string
+
+	PreconfQueries   []string
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	ColumnSizes      map[string]int
+	IndexedCols      []string
+	SelfJoinConf     db.SelfJoinConf
+	BibViewConf      db.BibViewConf
+	CountColumns     db.VertColumns
+	DocFreq          bool
+	AssocMeasures    bool
+	IPM              bool
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	DictEncoding     bool
+	MultiValueTable  bool
+}
+
+func (w *Writer) DatabaseExists() bool {
+	row := w.database.QueryRow(
+		`SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_NAME = @p1`,
+		w.groupedCorpusName+laTableSuffix,
+	)
+	var ans int
+	err := row.Scan(&ans)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to test data storage existence")
+		return false
+	}
+	return ans > 0
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	var err error
+	for _, q := range w.PreconfQueries {
+		log.Info().Str("value", q).Msg("Applying preconfiguration")
+		if _, err := w.database.Exec(q); err != nil {
+			return fmt.Errorf("failed to apply preconfiguration '%s': %w", q, err)
+		}
+	}
+	dbExisted := w.DatabaseExists()
+	if !appendMode {
+		if dbExisted {
+			log.
+				Warn().
+				Str("storageName", w.groupedCorpusName+laTableSuffix).
+				Msg("The data storage already exists. Existing data will be deleted.")
+			err := dropExisting(w.database, w.groupedCorpusName)
+			if err != nil {
+				return err
+			}
+		}
+		err := createSchema(w.database, schemaOptions{
+			groupedCorpusName: w.groupedCorpusName,
+			structures:        w.Structures,
+			columnNames:       w.ColumnNames,
+			columnTypes:       w.ColumnTypes,
+			columnSizes:       w.ColumnSizes,
+			indexedCols:       w.IndexedCols,
+			useSelfJoin:       w.SelfJoinConf.IsConfigured(),
+			countColumns:      w.CountColumns,
+			docFreq:           w.DocFreq,
+			assocMeasures:     w.AssocMeasures,
+			ipm:               w.IPM,
+			hapaxTable:        w.HapaxTable,
+			tfidfTable:        w.TFIDFTable,
+			itemCountsTable:   w.ItemCountsTable,
+			tagDistribTables:  w.TagDistribTables,
+			dictEncoding:      w.DictEncoding,
+			multiValueTable:   w.MultiValueTable,
+		})
+		if err != nil {
+			return err
+		}
+		if w.BibViewConf.IsConfigured() {
+			err := createBibView(
+				w.database, w.groupedCorpusName, w.BibViewConf.Cols, w.BibViewConf.IDAttr)
+			if err != nil {
+				return err
+			}
+		}
+
+	} else if err := createCorpusSizesTable(w.database, w.groupedCorpusName); err != nil {
+		return err
+	}
+
+	w.tx, err = w.database.Begin()
+	return err
+}
+
+// placeholder returns a query parameter placeholder
+// for the i-th (1-based) argument
+func placeholder(i int) string {
+	return fmt.Sprintf("@p%d", i)
+}
+
+// insertQuery generates a parametrized INSERT query
+// for the provided table and columns
+func insertQuery(groupedCorpusName, table string, attrs []string) string {
+	valReplac := make([]string, len(attrs))
+	for i := range attrs {
+		valReplac[i] = placeholder(i + 1)
+	}
+	return fmt.Sprintf(
+		"INSERT INTO [%s_%s] (%s) VALUES (%s)",
+		groupedCorpusName,
+		table,
+		joinArgs(attrs),
+		joinArgs(valReplac),
+	)
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	if w.tx == nil {
+		return nil, fmt.Errorf("cannot prepare insert into %s - no transaction active", table)
+	}
+	stmt, err := w.tx.Prepare(insertQuery(w.groupedCorpusName, table, attrs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare INSERT into %s: %s", table, err)
+	}
+	return &db.Insert{Stmt: stmt}, nil
+}
+
+func (w *Writer) UpdateCorpusSize(corpusID string, tokens, atoms int) (int, int, error) {
+	if w.tx == nil {
+		return 0, 0, fmt.Errorf("cannot update corpus size - no transaction active")
+	}
+	return db.AccumulateCorpusSize(
+		w.tx,
+		fmt.Sprintf("[%s_%s]", w.groupedCorpusName, db.CorpusSizesTable),
+		placeholder,
+		corpusID,
+		tokens,
+		atoms,
+	)
+}
+
+func (w *Writer) Commit() error {
+	return w.tx.Commit()
+}
+
+func (w *Writer) Rollback() error {
+	return w.tx.Rollback()
+}
+
+func (w *Writer) Close() {
+	err := w.database.Close()
+	if err != nil {
+		log.Warn().Err(err).Msg("error closing database")
+	}
+}
+
+// CreateSchema creates all the tables, indices and views required
+// by the configuration using the provided executor. In case dropFirst
+// is true, possible existing tables and views are dropped first.
+func CreateSchema(ex db.Executor, conf *cnf.VTEConf, dropFirst bool) error {
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	if dropFirst {
+		if err := dropExisting(ex, groupedCorpusName); err != nil {
+			return err
+		}
+	}
+	err := createSchema(ex, schemaOptions{
+		groupedCorpusName: groupedCorpusName,
+		structures:        conf.Structures,
+		columnNames:       conf.ColumnNames,
+		columnTypes:       conf.ColumnTypes,
+		columnSizes:       conf.ColumnSizes,
+		indexedCols:       conf.IndexedCols,
+		useSelfJoin:       conf.SelfJoin.IsConfigured(),
+		countColumns:      conf.Ngrams.CountColumns(),
+		docFreq:           conf.Ngrams.HasDocFreq(),
+		assocMeasures:     conf.Ngrams.AssocMeasures,
+		ipm:               conf.Ngrams.IPM,
+		hapaxTable:        conf.Ngrams.HasHapaxTable(),
+		tfidfTable:        conf.Ngrams.TFIDF,
+		itemCountsTable:   conf.Ngrams.ItemCounts,
+		tagDistribTables:  conf.TagDistrib != nil,
+		dictEncoding:      conf.Ngrams.DictEncoding,
+		multiValueTable:   len(conf.MultiValues) > 0,
+	})
+	if err != nil {
+		return err
+	}
+	if conf.BibView.IsConfigured() {
+		return createBibView(ex, groupedCorpusName, conf.BibView.Cols, conf.BibView.IDAttr)
+	}
+	return nil
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		Host:     conf.DB.Host,
+		RawQuery: url.Values{"database": []string{conf.DB.Name}}.Encode(),
+	}
+	if conf.DB.Password != "" {
+		dsn.User = url.UserPassword(conf.DB.User, conf.DB.Password)
+
+	} else if conf.DB.User != "" {
+		dsn.User = url.User(conf.DB.User)
+	}
+	db, err := sql.Open("sqlserver", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	return &Writer{
+		database:          db,
+		groupedCorpusName: groupedCorpusName,
+		PreconfQueries:    conf.DB.PreconfQueries,
+		Structures:        conf.Structures,
+		ColumnNames:       conf.ColumnNames,
+		ColumnTypes:       conf.ColumnTypes,
+		ColumnSizes:       conf.ColumnSizes,
+		IndexedCols:       conf.IndexedCols,
+		SelfJoinConf:      conf.SelfJoin,
+		BibViewConf:       conf.BibView,
+		CountColumns:      conf.Ngrams.CountColumns(),
+		DocFreq:           conf.Ngrams.HasDocFreq(),
+		AssocMeasures:     conf.Ngrams.AssocMeasures,
+		IPM:               conf.Ngrams.IPM,
+		HapaxTable:        conf.Ngrams.HasHapaxTable(),
+		TFIDFTable:        conf.Ngrams.TFIDF,
+		ItemCountsTable:   conf.Ngrams.ItemCounts,
+		TagDistribTables:  conf.TagDistrib != nil,
+		DictEncoding:      conf.Ngrams.DictEncoding,
+		MultiValueTable:   len(conf.MultiValues) > 0,
+	}, nil
+}
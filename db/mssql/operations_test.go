@@ -0,0 +1,109 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mssql
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExecutor stores executed queries instead of running them
+type recordingExecutor struct {
+	queries []string
+}
+
+func (ex *recordingExecutor) Exec(query string, args ...any) (sql.Result, error) {
+	ex.queries = append(ex.queries, query)
+	return nil, nil
+}
+
+func TestSQLColumnType(t *testing.T) {
+	for colType, expected := range map[string]string{
+		db.ColumnTypeInteger: "BIGINT",
+		db.ColumnTypeFloat:   "FLOAT",
+		db.ColumnTypeDate:    "DATE",
+		db.ColumnTypeBoolean: "BIT",
+		"":                   "NVARCHAR(100)",
+	} {
+		assert.Equal(t, expected, sqlColumnType(colType, 100), colType)
+	}
+}
+
+func TestGenerateAuxColDefs(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{"poscount INT", "wordcount INT", "corpus_id NVARCHAR(63)"},
+		generateAuxColDefs(false),
+	)
+	assert.Equal(
+		t,
+		[]string{"poscount INT", "wordcount INT", "corpus_id NVARCHAR(63)", "item_id NVARCHAR(127)"},
+		generateAuxColDefs(true),
+	)
+}
+
+func TestInsertQuery(t *testing.T) {
+	assert.Equal(
+		t,
+		"INSERT INTO [syn_liveattrs_entry] (doc_id, corpus_id) VALUES (@p1, @p2)",
+		insertQuery("syn", "liveattrs_entry", []string{"doc_id", "corpus_id"}),
+	)
+	assert.Equal(t, "@p3", placeholder(3))
+}
+
+func TestCreateSchema(t *testing.T) {
+	ex := &recordingExecutor{}
+	err := createSchema(ex, schemaOptions{
+		groupedCorpusName: "syn",
+		structures:        map[string][]string{"doc": {"title"}},
+		columnSizes:       map[string]int{"doc_title": 300},
+		countColumns:      db.VertColumns{{Idx: 0}},
+		dictEncoding:      true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"CREATE TABLE [syn_liveattrs_entry] (id INT IDENTITY(1,1) PRIMARY KEY, doc_title NVARCHAR(300), " +
+				"poscount INT, wordcount INT, corpus_id NVARCHAR(63))",
+			fmt.Sprintf(
+				"IF OBJECT_ID(N'syn_%s', N'U') IS NULL "+
+					"CREATE TABLE [syn_%s] (corpus_id NVARCHAR(255), tokens BIGINT, atoms BIGINT)",
+				db.CorpusSizesTable, db.CorpusSizesTable),
+			"CREATE TABLE [syn_colcounts] (col0 INT, hash_id VARCHAR(40), corpus_id NVARCHAR(255), " +
+				"count INT, arf FLOAT, PRIMARY KEY(hash_id))",
+			"DROP TABLE IF EXISTS [syn_colvalues_col0]",
+			"CREATE TABLE [syn_colvalues_col0] (corpus_id NVARCHAR(255), id INT, " +
+				"value NVARCHAR(255) COLLATE Latin1_General_100_BIN2, PRIMARY KEY(corpus_id, id))",
+			"CREATE INDEX [syn_colcounts_corpus_id_idx] ON [syn_colcounts](corpus_id)",
+		},
+		ex.queries,
+	)
+}
+
+func TestDropExisting(t *testing.T) {
+	ex := &recordingExecutor{}
+	assert.NoError(t, dropExisting(ex, "syn"))
+	assert.Equal(t, "DROP VIEW IF EXISTS [syn_bibliography]", ex.queries[0])
+	for _, q := range ex.queries[1:] {
+		assert.Regexp(t, `^DROP TABLE IF EXISTS \[syn_\w+\]$`, q)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+)
+
+var (
+	infileEscaper = strings.NewReplacer(
+		"\\", "\\\\",
+		"\t", "\\t",
+		"\n", "\\n",
+		"\r", "\\r",
+		"\x00", "\\0",
+	)
+)
+
+// infileInsert stages rows into a temporary file (using the default
+// format of LOAD DATA - i.e. tab separated values with backslash escaping)
+// which is then loaded to the server via LOAD DATA LOCAL INFILE
+// once flush() is called.
+type infileInsert struct {
+	tx      *sql.Tx
+	table   string
+	attrs   []string
+	file    *os.File
+	buff    *bufio.Writer
+	numRows int
+	logger  *zerolog.Logger
+}
+
+func (ins *infileInsert) Exec(values ...any) error {
+	for i, v := range values {
+		if i > 0 {
+			ins.buff.WriteByte('\t')
+		}
+		switch tv := v.(type) {
+		case string:
+			if tv == "" {
+				ins.buff.WriteString("\\N")
+
+			} else {
+				ins.buff.WriteString(infileEscaper.Replace(tv))
+			}
+		case nil:
+			ins.buff.WriteString("\\N")
+		default:
+			ins.buff.WriteString(infileEscaper.Replace(fmt.Sprint(tv)))
+		}
+	}
+	ins.numRows++
+	_, err := ins.buff.WriteString("\n")
+	return err
+}
+
+func (ins *infileInsert) flush() error {
+	defer ins.discard()
+	if err := ins.buff.Flush(); err != nil {
+		return fmt.Errorf("failed to write staged rows for %s: %w", ins.table, err)
+	}
+	if ins.numRows == 0 {
+		return nil
+	}
+	mysql.RegisterLocalFile(ins.file.Name())
+	defer mysql.DeregisterLocalFile(ins.file.Name())
+	_, err := ins.tx.Exec(
+		fmt.Sprintf(
+			"LOAD DATA LOCAL INFILE '%s' INTO TABLE `%s` CHARACTER SET utf8mb4 (%s)",
+			ins.file.Name(), ins.table, joinArgs(ins.attrs),
+		),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load staged rows into %s: %w", ins.table, err)
+	}
+	ins.logger.Info().Str("table", ins.table).Int("rows", ins.numRows).Msg("Loaded staged rows")
+	ins.numRows = 0
+	return nil
+}
+
+// discard removes the staging file. It is safe to call
+// the method multiple times.
+func (ins *infileInsert) discard() {
+	if ins.file == nil {
+		return
+	}
+	ins.file.Close()
+	if err := os.Remove(ins.file.Name()); err != nil {
+		ins.logger.Warn().Err(err).Str("file", ins.file.Name()).Msg("failed to remove staging file")
+	}
+	ins.file = nil
+}
+
+func newInfileInsert(
+	tx *sql.Tx,
+	table string,
+	attrs []string,
+	logger *zerolog.Logger,
+) (*infileInsert, error) {
+	file, err := os.CreateTemp("", "vte-"+table+"-*.tsv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file for %s: %w", table, err)
+	}
+	return &infileInsert{
+		tx:     tx,
+		table:  table,
+		attrs:  attrs,
+		file:   file,
+		buff:   bufio.NewWriter(file),
+		logger: logger,
+	}, nil
+}
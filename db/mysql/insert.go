@@ -0,0 +1,133 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	dfltBatchSize = 1000
+
+	// maxPlaceholders is a max. number of placeholders
+	// MySQL accepts in a single prepared statement
+	maxPlaceholders = 65535
+)
+
+// stagedInsert is an insert operation which may keep some
+// rows locally until flush() is called.
+type stagedInsert interface {
+	Exec(values ...any) error
+	flush() error
+	discard()
+}
+
+// batchInsert collects rows and writes them using multi-row
+// INSERT statements (with up to batchSize rows each). A statement
+// for a full batch is prepared just once, the remaining rows are
+// written by flush() (called also by Writer.Commit()).
+type batchInsert struct {
+	tx             *sql.Tx
+	table          string
+	prefix         string
+	rowPlaceholder string
+	batchSize      int
+	fullStmt       *sql.Stmt
+	args           []any
+	numRows        int
+}
+
+func (ins *batchInsert) query(numRows int) string {
+	var buff strings.Builder
+	buff.WriteString(ins.prefix)
+	for i := 0; i < numRows; i++ {
+		if i > 0 {
+			buff.WriteString(", ")
+		}
+		buff.WriteString(ins.rowPlaceholder)
+	}
+	return buff.String()
+}
+
+func (ins *batchInsert) Exec(values ...any) error {
+	for _, v := range values {
+		if tv, ok := v.(string); ok && tv == "" {
+			ins.args = append(ins.args, sql.NullString{String: "", Valid: false})
+
+		} else {
+			ins.args = append(ins.args, v)
+		}
+	}
+	ins.numRows++
+	if ins.numRows >= ins.batchSize {
+		return ins.flush()
+	}
+	return nil
+}
+
+func (ins *batchInsert) flush() error {
+	if ins.numRows == 0 {
+		return nil
+	}
+	var err error
+	if ins.numRows == ins.batchSize {
+		if ins.fullStmt == nil {
+			ins.fullStmt, err = ins.tx.Prepare(ins.query(ins.batchSize))
+			if err != nil {
+				return fmt.Errorf("failed to prepare INSERT into %s: %w", ins.table, err)
+			}
+		}
+		_, err = ins.fullStmt.Exec(ins.args...)
+
+	} else {
+		_, err = ins.tx.Exec(ins.query(ins.numRows), ins.args...)
+	}
+	ins.args = ins.args[:0]
+	ins.numRows = 0
+	if err != nil {
+		return fmt.Errorf("failed to insert rows into %s: %w", ins.table, err)
+	}
+	return nil
+}
+
+func (ins *batchInsert) discard() {
+	ins.args = ins.args[:0]
+	ins.numRows = 0
+}
+
+func newBatchInsert(tx *sql.Tx, table string, attrs []string, batchSize int) *batchInsert {
+	if batchSize <= 0 {
+		batchSize = dfltBatchSize
+	}
+	if len(attrs) > 0 && batchSize*len(attrs) > maxPlaceholders {
+		batchSize = maxPlaceholders / len(attrs)
+	}
+	valReplac := make([]string, len(attrs))
+	for i := range attrs {
+		valReplac[i] = "?"
+	}
+	return &batchInsert{
+		tx:             tx,
+		table:          table,
+		prefix:         fmt.Sprintf("INSERT INTO `%s` (%s) VALUES ", table, joinArgs(attrs)),
+		rowPlaceholder: "(" + joinArgs(valReplac) + ")",
+		batchSize:      batchSize,
+		args:           make([]any, 0, batchSize*len(attrs)),
+	}
+}
@@ -19,9 +19,11 @@ package mysql
 import (
 	"database/sql"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/czcorpus/vert-tagextract/v2/cnf"
@@ -30,6 +32,10 @@ import (
 	"github.com/go-sql-driver/mysql"
 )
 
+const (
+	tlsConfigName = "vte"
+)
+
 func joinArgs(args []string) string {
 	return strings.Join(args, ", ")
 }
@@ -39,15 +45,68 @@ type Writer struct {
 	tx       *sql.Tx
 	dbName   string
 
+	// batchSize specifies max. number of rows
+	// written by a single INSERT statement
+	batchSize int
+
+	// useLocalInfile specifies whether rows should be
+	// loaded via LOAD DATA LOCAL INFILE
+	useLocalInfile bool
+	inserts        []stagedInsert
+
+	// stagingInsert writes partial n-gram counts
+	// (see PrepareStagingInsert)
+	stagingInsert *batchInsert
+
 	// groupedCorpusName represents a derived corpus name which is able to group multiple
 	// (aligned) corpora together (e.g. intercorp_v13_en, intercorp_v13_cs => intercorp_v13)
 	groupedCorpusName string
 
-	Structures   map[string][]string
-	IndexedCols  []string
-	SelfJoinConf db.SelfJoinConf
-	BibViewConf  db.BibViewConf
-	CountColumns db.VertColumns
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	ColumnSizes      map[string]int
+	IndexedCols      []string
+	SelfJoinConf     db.SelfJoinConf
+	BibViewConf      db.BibViewConf
+	CountColumns     db.VertColumns
+	DocFreq          bool
+	AssocMeasures    bool
+	IPM              bool
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	DictEncoding     bool
+	MultiValueTable  bool
+	Charset          string
+	Collation        string
+	Partitioning     db.PartitioningConf
+
+	// DeferIndexes specifies that indices should be created
+	// only after all the data are inserted (see Commit)
+	DeferIndexes bool
+
+	// indicesPending is true if the schema has been created
+	// but the indices are deferred
+	indicesPending bool
+
+	// logger is used instead of the global logger if set
+	// (see SetLogger)
+	logger *zerolog.Logger
+}
+
+// SetLogger sets a logger used by the writer. By default,
+// the global zerolog logger with the corpus field is used.
+func (w *Writer) SetLogger(logger zerolog.Logger) {
+	w.logger = &logger
+}
+
+func (w *Writer) log() *zerolog.Logger {
+	if w.logger != nil {
+		return w.logger
+	}
+	return &log.Logger
 }
 
 func (w *Writer) DatabaseExists() bool {
@@ -61,7 +120,7 @@ func (w *Writer) DatabaseExists() bool {
 		return false
 	}
 	if err != nil {
-		log.Error().Err(err).Msg("failed to test data storage existence")
+		w.log().Error().Err(err).Msg("failed to test data storage existence")
 		return false
 	}
 	return ans
@@ -72,26 +131,48 @@ func (w *Writer) Initialize(appendMode bool) error {
 	dbExisted := w.DatabaseExists()
 	if !appendMode {
 		if dbExisted {
-			log.
+			w.log().
 				Warn().
 				Str("storageName", w.dbName+"/"+w.groupedCorpusName+"_liveattrs_entry").
 				Msg("The data storage already exists. Existing data will be deleted.")
+			w.log().Info().Msg("Attempting to drop possible existing tables and views")
 			err := dropExisting(w.database, w.groupedCorpusName)
 			if err != nil {
 				return err
 			}
 		}
-		err := createSchema(
-			w.database,
-			w.groupedCorpusName,
-			w.Structures,
-			w.IndexedCols,
-			w.SelfJoinConf.IsConfigured(),
-			w.CountColumns,
-		)
+		w.log().Info().Msg("Attempting to create tables and views")
+		err := createSchema(w.database, schemaOptions{
+			groupedCorpusName: w.groupedCorpusName,
+			structures:        w.Structures,
+			columnNames:       w.ColumnNames,
+			columnTypes:       w.ColumnTypes,
+			columnSizes:       w.ColumnSizes,
+			useSelfJoin:       w.SelfJoinConf.IsConfigured(),
+			countColumns:      w.CountColumns,
+			docFreq:           w.DocFreq,
+			assocMeasures:     w.AssocMeasures,
+			ipm:               w.IPM,
+			hapaxTable:        w.HapaxTable,
+			tfidfTable:        w.TFIDFTable,
+			itemCountsTable:   w.ItemCountsTable,
+			tagDistribTables:  w.TagDistribTables,
+			dictEncoding:      w.DictEncoding,
+			multiValueTable:   w.MultiValueTable,
+			charset:           w.Charset,
+			collation:         w.Collation,
+			partitioning:      w.Partitioning,
+		})
 		if err != nil {
 			return err
 		}
+		if w.DeferIndexes {
+			w.log().Info().Msg("Deferring creation of indices until all the data are inserted")
+			w.indicesPending = true
+
+		} else if err := w.createIndices(); err != nil {
+			return err
+		}
 		if w.BibViewConf.IsConfigured() {
 			err := createBibView(
 				w.database, w.groupedCorpusName, w.BibViewConf.Cols, w.BibViewConf.IDAttr)
@@ -99,60 +180,218 @@ func (w *Writer) Initialize(appendMode bool) error {
 				return err
 			}
 		}
+
+	} else if err := createCorpusSizesTable(w.database, w.groupedCorpusName, w.Charset); err != nil {
+		return err
+	}
+
+	if w.useLocalInfile && !w.localInfileEnabled() {
+		w.log().Warn().Msg("LOAD DATA LOCAL INFILE is disabled by the server, falling back to INSERTs")
+		w.useLocalInfile = false
 	}
 
 	w.tx, err = w.database.Begin()
 	return err
 }
 
+// localInfileEnabled tests whether the server accepts
+// LOAD DATA LOCAL INFILE.
+func (w *Writer) localInfileEnabled() bool {
+	var ans bool
+	if err := w.database.QueryRow("SELECT @@GLOBAL.local_infile").Scan(&ans); err != nil {
+		w.log().Error().Err(err).Msg("failed to test server's local_infile setting")
+		return false
+	}
+	return ans
+}
+
 func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
 	if w.tx == nil {
 		return nil, fmt.Errorf("cannot prepare insert into %s - no transaction active", table)
 	}
-	valReplac := make([]string, len(attrs))
-	for i := range attrs {
-		valReplac[i] = "?"
-	}
-	stmt, err := w.tx.Prepare(
-		fmt.Sprintf(
-			"INSERT INTO `%s_%s` (%s) VALUES (%s)",
-			w.groupedCorpusName,
-			table,
-			joinArgs(attrs),
-			joinArgs(valReplac),
-		),
+	fullTable := fmt.Sprintf("%s_%s", w.groupedCorpusName, table)
+	var ins stagedInsert
+	if w.useLocalInfile {
+		var err error
+		ins, err = newInfileInsert(w.tx, fullTable, attrs, w.log())
+		if err != nil {
+			return nil, err
+		}
+
+	} else {
+		ins = newBatchInsert(w.tx, fullTable, attrs, w.batchSize)
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) createIndices() error {
+	w.log().Info().Msg("Attempting to create indices")
+	err := createIndices(
+		w.database,
+		w.groupedCorpusName,
+		w.IndexedCols,
+		w.SelfJoinConf.IsConfigured(),
+		w.CountColumns,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare INSERT into %s: %s", table, err)
+		return err
+	}
+	if len(w.IndexedCols) > 0 {
+		w.log().Info().
+			Str("table", w.groupedCorpusName+laTableSuffix).
+			Strs("columns", w.IndexedCols).
+			Msg("Created custom database indices")
+	}
+	return nil
+}
+
+func (w *Writer) UpdateCorpusSize(corpusID string, tokens, atoms int) (int, int, error) {
+	if w.tx == nil {
+		return 0, 0, fmt.Errorf("cannot update corpus size - no transaction active")
 	}
-	return &db.Insert{Stmt: stmt}, nil
+	return db.AccumulateCorpusSize(
+		w.tx,
+		fmt.Sprintf("`%s_%s`", w.groupedCorpusName, db.CorpusSizesTable),
+		db.QuestionMarkPlaceholder,
+		corpusID,
+		tokens,
+		atoms,
+	)
 }
 
+// Commit writes all the remaining batched (or staged) rows
+// and commits the current transaction. In case the indices
+// are deferred, they are created after the commit (in MySQL,
+// index creation causes an implicit commit anyway).
 func (w *Writer) Commit() error {
-	return w.tx.Commit()
+	for _, ins := range w.inserts {
+		if err := ins.flush(); err != nil {
+			return err
+		}
+	}
+	w.inserts = w.inserts[:0]
+	if err := w.tx.Commit(); err != nil {
+		return err
+	}
+	if w.indicesPending {
+		if err := w.createIndices(); err != nil {
+			return err
+		}
+		w.indicesPending = false
+	}
+	return nil
 }
 
 func (w *Writer) Rollback() error {
+	for _, ins := range w.inserts {
+		ins.discard()
+	}
+	w.inserts = w.inserts[:0]
+	w.stagingInsert = nil
 	return w.tx.Rollback()
 }
 
 func (w *Writer) Close() {
 	err := w.database.Close()
 	if err != nil {
-		log.Warn().Err(err).Msg("error closing database")
+		w.log().Warn().Err(err).Msg("error closing database")
+	}
+}
+
+// CreateSchema creates all the tables, indices and views required
+// by the configuration using the provided executor. In case dropFirst
+// is true, possible existing tables and views are dropped first.
+func CreateSchema(ex db.Executor, conf *cnf.VTEConf, dropFirst bool) error {
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	if dropFirst {
+		if err := dropExisting(ex, groupedCorpusName); err != nil {
+			return err
+		}
+	}
+	err := createSchema(ex, schemaOptions{
+		groupedCorpusName: groupedCorpusName,
+		structures:        conf.Structures,
+		columnNames:       conf.ColumnNames,
+		columnTypes:       conf.ColumnTypes,
+		columnSizes:       conf.ColumnSizes,
+		useSelfJoin:       conf.SelfJoin.IsConfigured(),
+		countColumns:      conf.Ngrams.CountColumns(),
+		docFreq:           conf.Ngrams.HasDocFreq(),
+		assocMeasures:     conf.Ngrams.AssocMeasures,
+		ipm:               conf.Ngrams.IPM,
+		hapaxTable:        conf.Ngrams.HasHapaxTable(),
+		tfidfTable:        conf.Ngrams.TFIDF,
+		itemCountsTable:   conf.Ngrams.ItemCounts,
+		tagDistribTables:  conf.TagDistrib != nil,
+		dictEncoding:      conf.Ngrams.DictEncoding,
+		multiValueTable:   len(conf.MultiValues) > 0,
+		charset:           conf.DB.Charset,
+		collation:         conf.DB.Collation,
+		partitioning:      conf.DB.ColcountsPartitioning,
+	})
+	if err != nil {
+		return err
 	}
+	err = createIndices(
+		ex,
+		groupedCorpusName,
+		conf.IndexedCols,
+		conf.SelfJoin.IsConfigured(),
+		conf.Ngrams.CountColumns(),
+	)
+	if err != nil {
+		return err
+	}
+	if conf.BibView.IsConfigured() {
+		return createBibView(ex, groupedCorpusName, conf.BibView.Cols, conf.BibView.IDAttr)
+	}
+	return nil
+}
+
+// isSocketPath tests whether a configured host represents
+// a unix socket (either an absolute path or a path
+// prefixed by "unix:").
+func isSocketPath(host string) bool {
+	return strings.HasPrefix(host, "/") || strings.HasPrefix(host, "unix:")
 }
 
 func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
 
 	mconf := mysql.NewConfig()
-	mconf.Net = "tcp"
-	mconf.Addr = conf.DB.Host
+	if isSocketPath(conf.DB.Host) {
+		mconf.Net = "unix"
+		mconf.Addr = strings.TrimPrefix(conf.DB.Host, "unix:")
+
+	} else {
+		mconf.Net = "tcp"
+		mconf.Addr = conf.DB.Host
+	}
 	mconf.User = conf.DB.User
 	mconf.Passwd = conf.DB.Password
 	mconf.DBName = conf.DB.Name
 	mconf.ParseTime = true
 	mconf.Loc = time.Local
+	if conf.DB.Collation != "" {
+		mconf.Collation = conf.DB.Collation
+	}
+	if conf.DB.TLS != nil {
+		host, _, err := net.SplitHostPort(conf.DB.Host)
+		if err != nil {
+			host = conf.DB.Host
+		}
+		tlsConf, err := conf.DB.TLS.TLSConfig(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		if err := mysql.RegisterTLSConfig(tlsConfigName, tlsConf); err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		mconf.TLSConfig = tlsConfigName
+	}
 	db, err := sql.Open("mysql", mconf.FormatDSN())
 	if err != nil {
 		return nil, err
@@ -161,14 +400,34 @@ func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
 	if conf.ParallelCorpus != "" {
 		groupedCorpusName = conf.ParallelCorpus
 	}
+	logger := log.With().Str("corpus", conf.Corpus).Logger()
 	return &Writer{
 		database:          db,
 		dbName:            conf.DB.Name,
+		batchSize:         conf.DB.BatchSize,
+		useLocalInfile:    conf.DB.LocalInfile,
 		groupedCorpusName: groupedCorpusName,
 		Structures:        conf.Structures,
+		ColumnNames:       conf.ColumnNames,
+		ColumnTypes:       conf.ColumnTypes,
+		ColumnSizes:       conf.ColumnSizes,
 		IndexedCols:       conf.IndexedCols,
 		SelfJoinConf:      conf.SelfJoin,
 		BibViewConf:       conf.BibView,
-		CountColumns:      conf.Ngrams.VertColumns,
+		CountColumns:      conf.Ngrams.CountColumns(),
+		DocFreq:           conf.Ngrams.HasDocFreq(),
+		AssocMeasures:     conf.Ngrams.AssocMeasures,
+		IPM:               conf.Ngrams.IPM,
+		HapaxTable:        conf.Ngrams.HasHapaxTable(),
+		TFIDFTable:        conf.Ngrams.TFIDF,
+		ItemCountsTable:   conf.Ngrams.ItemCounts,
+		TagDistribTables:  conf.TagDistrib != nil,
+		DictEncoding:      conf.Ngrams.DictEncoding,
+		MultiValueTable:   len(conf.MultiValues) > 0,
+		Charset:           conf.DB.Charset,
+		Collation:         conf.DB.Collation,
+		Partitioning:      conf.DB.ColcountsPartitioning,
+		DeferIndexes:      conf.DB.DeferIndexes,
+		logger:            &logger,
 	}, nil
 }
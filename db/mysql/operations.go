@@ -17,12 +17,9 @@
 package mysql
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 
-	"github.com/rs/zerolog/log"
-
 	"github.com/czcorpus/vert-tagextract/v2/db"
 )
 
@@ -36,8 +33,7 @@ const (
 // which is able to group multipe (aligned) corpora together.E.g. 'intercorp_v13_cs'
 // and 'intercorp_v13_en' will likely groupedName 'intercorp_v13'. For single corpora,
 // the groupedCorpusName is the same as the original one.
-func dropExisting(database *sql.DB, groupedCorpusName string) error {
-	log.Info().Msg("Attempting to drop possible existing tables and views...")
+func dropExisting(database db.Executor, groupedCorpusName string) error {
 	var err error
 	_, err = database.Exec("DROP TABLE IF EXISTS cache")
 	if err != nil {
@@ -56,16 +52,64 @@ func dropExisting(database *sql.DB, groupedCorpusName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to drop table `%s_colcounts`: %s", groupedCorpusName, err)
 	}
-	log.Info().Msg("...DONE")
+	_, err = database.Exec(
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", groupedCorpusName, db.ColcountsHapaxTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", groupedCorpusName, db.ColcountsHapaxTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", groupedCorpusName, db.CorpusTFIDFTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", groupedCorpusName, db.CorpusTFIDFTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", groupedCorpusName, db.CorpusItemCountsTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", groupedCorpusName, db.CorpusItemCountsTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", groupedCorpusName, db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to drop table `%s_%s`: %s", groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	for _, tbl := range []string{db.CorpusTagDistribTable, db.CorpusTagDistribTTTable, db.LiveattrsMultiValueTable} {
+		_, err = database.Exec(
+			fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", groupedCorpusName, tbl))
+		if err != nil {
+			return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, tbl, err)
+		}
+	}
 	return nil
 }
 
+// sqlColumnType returns an SQL type of a liveattrs_entry
+// column of the provided type (see db.ColumnTypeInteger etc.).
+// The varcharSize applies to string columns.
+func sqlColumnType(colType string, varcharSize int) string {
+	switch colType {
+	case db.ColumnTypeInteger:
+		return "BIGINT"
+	case db.ColumnTypeFloat:
+		return "DOUBLE"
+	case db.ColumnTypeDate:
+		return "DATE"
+	case db.ColumnTypeBoolean:
+		return "BOOLEAN"
+	default:
+		return fmt.Sprintf("VARCHAR(%d)", varcharSize)
+	}
+}
+
 // generateColNames produces a list of structural
 // attribute names as used in database
 // (i.e. [structname]_[attr_name]) out of lists
 // of structural attributes defined in the configuration.
 // (see _examples/*.json)
-func generateColNames(structures map[string][]string) []string {
+func generateColNames(structures map[string][]string, columnNames map[string]string) []string {
 	numAttrs := 0
 	for _, v := range structures {
 		numAttrs += len(v)
@@ -74,7 +118,7 @@ func generateColNames(structures map[string][]string) []string {
 	i := 0
 	for k, v := range structures {
 		for _, a := range v {
-			ans[i] = fmt.Sprintf("%s_%s", k, a)
+			ans[i] = db.StructAttrColumn(k, a, columnNames)
 			i++
 		}
 	}
@@ -97,7 +141,7 @@ func generateAuxColDefs(hasSelfJoin bool) []string {
 	return ans
 }
 
-func createAuxIndices(database *sql.DB, groupedCorpusName string, cols []string) error {
+func createAuxIndices(database db.Executor, groupedCorpusName string, cols []string) error {
 	var err error
 	for _, c := range cols {
 		_, err = database.Exec(
@@ -106,11 +150,6 @@ func createAuxIndices(database *sql.DB, groupedCorpusName string, cols []string)
 		if err != nil {
 			return err
 		}
-		log.Info().
-			Str("index", fmt.Sprintf(`%s_%s_idx`, groupedCorpusName, c)).
-			Str("table", groupedCorpusName+laTableSuffix).
-			Str("column", c).
-			Msg("Created custom database index")
 	}
 	return nil
 }
@@ -132,7 +171,7 @@ func generateViewColDefs(cols []string, idAttr string) []string {
 
 // createBibView creates a database view needed
 // by liveattrs to fetch bibliography information.
-func createBibView(database *sql.DB, groupedCorpusName string, cols []string, idAttr string) error {
+func createBibView(database db.Executor, groupedCorpusName string, cols []string, idAttr string) error {
 	colDefs := generateViewColDefs(cols, idAttr)
 	_, err := database.Exec(fmt.Sprintf(
 		"CREATE VIEW %s_bibliography AS SELECT %s FROM `%s%s`",
@@ -143,39 +182,235 @@ func createBibView(database *sql.DB, groupedCorpusName string, cols []string, id
 	return nil
 }
 
-// createSchema creates all the required tables, views and indices
-func createSchema(
-	database *sql.DB,
-	groupedCorpusName string,
-	structures map[string][]string,
-	indexedCols []string,
-	useSelfJoin bool,
-	countColumns db.VertColumns,
-) error {
-	log.Info().Msg("Attempting to create tables and views")
+// tableOptions generates table options specifying charset and collation.
+// In case none of them is set, server defaults are used.
+func tableOptions(charset, collation string) string {
+	var ans strings.Builder
+	if charset != "" {
+		ans.WriteString(" DEFAULT CHARSET=" + charset)
+	}
+	if collation != "" {
+		ans.WriteString(" COLLATE=" + collation)
+	}
+	return ans.String()
+}
+
+// colcountsCollation returns a binary collation
+// for n-gram columns compatible with the charset.
+func colcountsCollation(charset string) string {
+	if charset != "" {
+		return charset + "_bin"
+	}
+	return "utf8_bin"
+}
+
+// colcountsPartitioning generates a primary key definition and a partitioning
+// clause for the colcounts table. As MySQL requires each unique key to contain
+// all the columns used in the partitioning expression, the partitioning column
+// is added to the primary key.
+func colcountsPartitioning(conf db.PartitioningConf, colNames []string) (string, string, error) {
+	if !conf.IsConfigured() {
+		return "hash_id", "", nil
+	}
+	if conf.Column != "corpus_id" {
+		var found bool
+		for _, c := range colNames {
+			if c == conf.Column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", "", fmt.Errorf(
+				"invalid colcounts partitioning column %s (must be corpus_id or one of %s)",
+				conf.Column, joinArgs(colNames))
+		}
+	}
+	return "hash_id, " + conf.Column,
+		fmt.Sprintf(" PARTITION BY KEY(%s) PARTITIONS %d", conf.Column, conf.NumPartitionsOrDefault()),
+		nil
+}
+
+// createCorpusSizesTable creates the corpus_sizes table in case it
+// does not exist yet (databases created by older versions lack it)
+func createCorpusSizesTable(database db.Executor, groupedCorpusName, charset string) error {
+	_, err := database.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS `%s_%s` (corpus_id VARCHAR(%d), tokens BIGINT, atoms BIGINT)%s",
+		groupedCorpusName, db.CorpusSizesTable, db.DfltColcountVarcharSize, tableOptions(charset, "")))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create table '%s_%s': %s", groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	return nil
+}
+
+// schemaOptions specifies the tables, views and columns
+// to be created by createSchema.
+type schemaOptions struct {
+	groupedCorpusName string
+	structures        map[string][]string
+	columnNames       map[string]string
+	columnTypes       map[string]string
+	columnSizes       map[string]int
+	useSelfJoin       bool
+	countColumns      db.VertColumns
+	docFreq           bool
+	assocMeasures     bool
+	ipm               bool
+	hapaxTable        bool
+	tfidfTable        bool
+	itemCountsTable   bool
+	tagDistribTables  bool
+	dictEncoding      bool
+	multiValueTable   bool
+	charset           string
+	collation         string
+	partitioning      db.PartitioningConf
+}
 
-	cols := generateColNames(structures)
+// createSchema creates all the required tables, views and indices.
+// The charset and collation options are optional (empty string means
+// a server default). Please note that n-gram columns in colcounts always
+// use a binary collation.
+func createSchema(database db.Executor, opts schemaOptions) error {
+
+	cols := generateColNames(opts.structures, opts.columnNames)
+	colTypes := db.OutputColumnTypes(opts.structures, opts.columnNames, opts.columnTypes)
+	colSizes := db.OutputColumnSizes(opts.structures, opts.columnNames, opts.columnSizes)
 	colsDefs := make([]string, len(cols))
 	for i, col := range cols {
-		colsDefs[i] = fmt.Sprintf("%s VARCHAR(%d)", col, db.DfltLAVarcharSize)
+		colsDefs[i] = fmt.Sprintf("%s %s", col, sqlColumnType(colTypes[col], colSizes[col]))
 	}
-	auxColDefs := generateAuxColDefs(useSelfJoin)
+	auxColDefs := generateAuxColDefs(opts.useSelfJoin)
 	allCollsDefs := append(colsDefs, auxColDefs...)
 	_, dbErr := database.Exec(
 		fmt.Sprintf(
-			"CREATE TABLE `%s%s` (id INTEGER PRIMARY KEY auto_increment, %s) ENGINE=InnoDB ROW_FORMAT=DYNAMIC",
-			groupedCorpusName,
+			"CREATE TABLE `%s%s` (id INTEGER PRIMARY KEY auto_increment, %s) ENGINE=InnoDB ROW_FORMAT=DYNAMIC%s",
+			opts.groupedCorpusName,
 			laTableSuffix,
 			joinArgs(allCollsDefs),
+			tableOptions(opts.charset, opts.collation),
 		),
 	)
 	if dbErr != nil {
 		return fmt.Errorf(
-			"failed to create table '%s%s': %s", groupedCorpusName, laTableSuffix, dbErr)
+			"failed to create table '%s%s': %s", opts.groupedCorpusName, laTableSuffix, dbErr)
+	}
+	if dbErr = createCorpusSizesTable(database, opts.groupedCorpusName, opts.charset); dbErr != nil {
+		return dbErr
+	}
+	if opts.multiValueTable {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id VARCHAR(%d), item_id VARCHAR(%d), attr VARCHAR(%d), "+
+				"value VARCHAR(%d), INDEX attr_value_idx(attr, value))%s",
+			opts.groupedCorpusName, db.LiveattrsMultiValueTable, db.DfltColcountVarcharSize, db.DfltLAVarcharSize,
+			db.DfltColcountVarcharSize, db.DfltColcountVarcharSize, tableOptions(opts.charset, "")))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.LiveattrsMultiValueTable, dbErr)
+		}
+	}
+	if opts.tagDistribTables {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id VARCHAR(%d), tag VARCHAR(%d), count BIGINT)%s",
+			opts.groupedCorpusName, db.CorpusTagDistribTable, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize,
+			tableOptions(opts.charset, "")))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTagDistribTable, dbErr)
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE `%s_%s` (corpus_id VARCHAR(%d), attr VARCHAR(%d), value VARCHAR(%d), "+
+				"tag VARCHAR(%d), count BIGINT)%s",
+			opts.groupedCorpusName, db.CorpusTagDistribTTTable, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize,
+			db.DfltLAVarcharSize, db.DfltColcountVarcharSize, tableOptions(opts.charset, "")))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTagDistribTTTable, dbErr)
+		}
 	}
 
-	if useSelfJoin {
+	if len(opts.countColumns) > 0 {
+		colNames := db.GenerateColCountNames(opts.countColumns)
+		pkey, partDef, err := colcountsPartitioning(opts.partitioning, colNames)
+		if err != nil {
+			return err
+		}
+		colDefs := make([]string, len(colNames))
+		for i, c := range colNames {
+			if opts.dictEncoding {
+				colDefs[i] = c + " INT"
+
+			} else {
+				colDefs[i] = c + fmt.Sprintf(
+					" VARCHAR(%d) COLLATE %s", db.DfltColcountVarcharSize, colcountsCollation(opts.charset))
+			}
+		}
 		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s_colcounts (%s, hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER, arf INTEGER%s, PRIMARY KEY(%s))%s%s",
+			opts.groupedCorpusName, strings.Join(colDefs, ", "), db.DfltColcountVarcharSize,
+			db.DocFreqColDef(opts.docFreq, "INTEGER")+db.IPMColDef(opts.ipm, "DOUBLE")+db.AssocColDefs(opts.assocMeasures, "DOUBLE"), pkey, tableOptions(opts.charset, ""), partDef))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s_colcounts': %s", opts.groupedCorpusName, dbErr)
+		}
+		if opts.dictEncoding {
+			for _, tbl := range db.ColValuesTables(opts.countColumns) {
+				_, dbErr = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s_%s`", opts.groupedCorpusName, tbl))
+				if dbErr != nil {
+					return fmt.Errorf("failed to drop table '%s_%s': %s", opts.groupedCorpusName, tbl, dbErr)
+				}
+				_, dbErr = database.Exec(fmt.Sprintf(
+					"CREATE TABLE `%s_%s` (corpus_id VARCHAR(%d), id INT, value VARCHAR(%d) COLLATE %s, "+
+						"PRIMARY KEY(corpus_id, id))%s",
+					opts.groupedCorpusName, tbl, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize,
+					colcountsCollation(opts.charset), tableOptions(opts.charset, "")))
+				if dbErr != nil {
+					return fmt.Errorf("failed to create table '%s_%s': %s", opts.groupedCorpusName, tbl, dbErr)
+				}
+			}
+		}
+		if opts.hapaxTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` LIKE `%s_colcounts`",
+				opts.groupedCorpusName, db.ColcountsHapaxTable, opts.groupedCorpusName))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.ColcountsHapaxTable, dbErr)
+			}
+		}
+		if opts.tfidfTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` (atom_id INTEGER, hash_id VARCHAR(40), corpus_id VARCHAR(%d), tfidf DOUBLE)%s",
+				opts.groupedCorpusName, db.CorpusTFIDFTable, db.DfltColcountVarcharSize, tableOptions(opts.charset, "")))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTFIDFTable, dbErr)
+			}
+		}
+		if opts.itemCountsTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE `%s_%s` (item_id VARCHAR(%d), hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER)%s",
+				opts.groupedCorpusName, db.CorpusItemCountsTable, db.DfltLAVarcharSize, db.DfltColcountVarcharSize,
+				tableOptions(opts.charset, "")))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusItemCountsTable, dbErr)
+			}
+		}
+	}
+	return nil
+}
+
+// createIndices creates all the indices of tables created by createSchema
+func createIndices(
+	database db.Executor,
+	groupedCorpusName string,
+	indexedCols []string,
+	useSelfJoin bool,
+	countColumns db.VertColumns,
+) error {
+	if useSelfJoin {
+		_, dbErr := database.Exec(fmt.Sprintf(
 			"CREATE UNIQUE INDEX `%s%s_item_id_corpus_id_idx` ON `%s%s`(item_id, corpus_id)",
 			groupedCorpusName, laTableSuffix, groupedCorpusName, laTableSuffix))
 		if dbErr != nil {
@@ -184,22 +419,11 @@ func createSchema(
 				groupedCorpusName, laTableSuffix, groupedCorpusName, laTableSuffix, dbErr)
 		}
 	}
-	dbErr = createAuxIndices(database, groupedCorpusName, indexedCols)
+	dbErr := createAuxIndices(database, groupedCorpusName, indexedCols)
 	if dbErr != nil {
 		return fmt.Errorf("failed to create a custom index: %s", dbErr)
 	}
-
 	if len(countColumns) > 0 {
-		colDefs := db.GenerateColCountNames(countColumns)
-		for i, c := range colDefs {
-			colDefs[i] = c + fmt.Sprintf(" VARCHAR(%d) COLLATE utf8_bin", db.DfltColcountVarcharSize)
-		}
-		_, dbErr = database.Exec(fmt.Sprintf(
-			"CREATE TABLE %s_colcounts (%s, hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER, arf INTEGER, PRIMARY KEY(hash_id))",
-			groupedCorpusName, strings.Join(colDefs, ", "), db.DfltColcountVarcharSize))
-		if dbErr != nil {
-			return fmt.Errorf("failed to create table '%s_colcounts': %s", groupedCorpusName, dbErr)
-		}
 		_, dbErr = database.Exec(fmt.Sprintf(
 			"CREATE INDEX %s_colcounts_corpus_id_idx ON %s_colcounts(corpus_id)",
 			groupedCorpusName, groupedCorpusName))
@@ -208,7 +432,16 @@ func createSchema(
 				"failed to create index colcounts_corpus_id_idx on %s_colcounts(corpus_id): %s",
 				groupedCorpusName, dbErr)
 		}
+		for _, col := range db.RoleIndexedColCountNames(countColumns) {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE INDEX %s_colcounts_%s_idx ON %s_colcounts(%s)",
+				groupedCorpusName, col, groupedCorpusName, col))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create index colcounts_%s_idx on %s_colcounts(%s): %s",
+					col, groupedCorpusName, col, dbErr)
+			}
+		}
 	}
-	log.Info().Msg("DONE")
 	return nil
 }
@@ -0,0 +1,82 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// colcountsStagingTable returns a name of a temporary table
+// for partial n-gram counts (see db.ColcountsStager)
+func (w *Writer) colcountsStagingTable() string {
+	return w.groupedCorpusName + "_colcounts_staging"
+}
+
+// PrepareStagingInsert creates a temporary staging table. As temporary
+// tables are bound to a connection, the table is visible only within
+// the current transaction (which is also why creating it does not cause
+// an implicit commit). Staged rows are always written using INSERTs.
+func (w *Writer) PrepareStagingInsert(attrs []string) (db.InsertOperation, error) {
+	if w.tx == nil {
+		return nil, fmt.Errorf("cannot prepare staging insert - no transaction active")
+	}
+	if w.stagingInsert != nil {
+		return w.stagingInsert, nil
+	}
+	colNames := db.GenerateColCountNames(w.CountColumns)
+	colDefs := make([]string, len(colNames))
+	for i, c := range colNames {
+		colDefs[i] = c + fmt.Sprintf(
+			" VARCHAR(%d) COLLATE %s", db.DfltColcountVarcharSize, colcountsCollation(w.Charset))
+	}
+	_, err := w.tx.Exec(fmt.Sprintf(
+		"CREATE TEMPORARY TABLE `%s` (%s, hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER, arf INTEGER)%s",
+		w.colcountsStagingTable(), strings.Join(colDefs, ", "), db.DfltColcountVarcharSize,
+		tableOptions(w.Charset, "")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table '%s': %w", w.colcountsStagingTable(), err)
+	}
+	w.stagingInsert = newBatchInsert(w.tx, w.colcountsStagingTable(), attrs, w.batchSize)
+	w.inserts = append(w.inserts, w.stagingInsert)
+	return w.stagingInsert, nil
+}
+
+func (w *Writer) AggregateStaged() error {
+	if w.stagingInsert == nil {
+		return fmt.Errorf("cannot aggregate staged counts - no staging table prepared")
+	}
+	if err := w.stagingInsert.flush(); err != nil {
+		return err
+	}
+	cols := joinArgs(db.GenerateColCountNames(w.CountColumns))
+	_, err := w.tx.Exec(fmt.Sprintf(
+		"INSERT INTO `%s_colcounts` (%s, corpus_id, count, arf, hash_id) "+
+			"SELECT %s, corpus_id, SUM(count), MAX(arf), hash_id FROM `%s` GROUP BY hash_id, corpus_id, %s",
+		w.groupedCorpusName, cols, cols, w.colcountsStagingTable(), cols))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate staged n-gram counts: %w", err)
+	}
+	_, err = w.tx.Exec(fmt.Sprintf("DROP TEMPORARY TABLE `%s`", w.colcountsStagingTable()))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s': %w", w.colcountsStagingTable(), err)
+	}
+	w.stagingInsert = nil
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+)
+
+const (
+	partitionColumn = "corpus_id"
+)
+
+// Writer stores extracted tables as Parquet files. The output directory
+// is organized in the "Hive" style where each table has its own directory
+// partitioned by corpus ID:
+//
+//	[outDir]/liveattrs_entry/corpus_id=[corpus]/part-[timestamp].parquet
+//	[outDir]/colcounts/corpus_id=[corpus]/part-[timestamp].parquet
+//
+// Files are written to temporary locations first and moved to their
+// final paths on Commit.
+type Writer struct {
+	outDir   string
+	corpusID string
+	runID    string
+	tables   []*tableWriter
+}
+
+func (w *Writer) tableDir(table string) string {
+	return filepath.Join(w.outDir, table)
+}
+
+func (w *Writer) partitionDir(table string) string {
+	return filepath.Join(w.tableDir(table), fmt.Sprintf("%s=%s", partitionColumn, w.corpusID))
+}
+
+func (w *Writer) DatabaseExists() bool {
+	return fs.IsDir(w.tableDir("liveattrs_entry"))
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	if !appendMode {
+		if err := w.removeExistingPartitions(); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(w.outDir, 0755)
+}
+
+// removeExistingPartitions removes partitions of the processed corpus
+// from all the table directories found in the output directory.
+// Partitions of other corpora are kept untouched.
+func (w *Writer) removeExistingPartitions() error {
+	entries, err := os.ReadDir(w.outDir)
+	if os.IsNotExist(err) {
+		return nil
+
+	} else if err != nil {
+		return fmt.Errorf("failed to read output directory %s: %w", w.outDir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := w.partitionDir(entry.Name())
+		if !fs.IsDir(dir) {
+			continue
+		}
+		log.
+			Warn().
+			Str("directory", dir).
+			Msg("The output partition already exists. Existing data will be deleted.")
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove existing partition of table %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	dir := w.partitionDir(table)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+	}
+	tw, err := newTableWriter(filepath.Join(dir, fmt.Sprintf("part-%s.parquet", w.runID)), attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+	}
+	w.tables = append(w.tables, tw)
+	return tw, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, tw := range w.tables {
+		if err := tw.finish(); err != nil {
+			return err
+		}
+	}
+	w.tables = w.tables[:0]
+	return nil
+}
+
+func (w *Writer) Rollback() error {
+	for _, tw := range w.tables {
+		tw.discard()
+	}
+	w.tables = w.tables[:0]
+	return nil
+}
+
+func (w *Writer) Close() {
+	if len(w.tables) > 0 {
+		log.Warn().Msg("closing Parquet writer with unfinished tables, discarding them")
+		w.Rollback()
+	}
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	if conf.BibView.IsConfigured() {
+		log.Warn().Msg("Parquet writer does not support bibView, the setting will be ignored")
+	}
+	return &Writer{
+		outDir:   conf.DB.Name,
+		corpusID: conf.Corpus,
+		runID:    time.Now().Format("20060102T150405"),
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestWriteAndCommit(t *testing.T) {
+	w := &Writer{outDir: t.TempDir(), corpusID: "syn", runID: "test"}
+	assert.NoError(t, w.Initialize(false))
+	ins, err := w.PrepareInsert("colcounts", []string{"col0", "corpus_id", "count", "arf"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", "syn", 10, 1.5))
+	assert.NoError(t, ins.Exec("", "syn", 3, -1))
+	assert.NoError(t, w.Commit())
+
+	path := filepath.Join(w.outDir, "colcounts", "corpus_id=syn", "part-test.parquet")
+	pf, err := local.NewLocalFileReader(path)
+	assert.NoError(t, err)
+	defer pf.Close()
+	pr, err := reader.NewParquetColumnReader(pf, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pr.GetNumRows())
+	values, _, _, err := pr.ReadColumnByPath("parquet_go_root\x01col0", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"foo"}, values[:1])
+	counts, _, _, err := pr.ReadColumnByPath("parquet_go_root\x01count", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(10), int64(3)}, counts)
+}
+
+func TestRollbackRemovesFiles(t *testing.T) {
+	w := &Writer{outDir: t.TempDir(), corpusID: "syn", runID: "test"}
+	assert.NoError(t, w.Initialize(false))
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "corpus_id", "poscount"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("d1", "syn", 7))
+	assert.NoError(t, w.Rollback())
+	matches, err := filepath.Glob(filepath.Join(w.partitionDir("liveattrs_entry"), "*"))
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestCreateModeRemovesOnlyCorpusPartitions(t *testing.T) {
+	outDir := t.TempDir()
+	write := func(corpusID, runID string, appendMode bool) {
+		w := &Writer{outDir: outDir, corpusID: corpusID, runID: runID}
+		assert.NoError(t, w.Initialize(appendMode))
+		for _, table := range []string{"liveattrs_entry", "corpus_sizes"} {
+			ins, err := w.PrepareInsert(table, []string{"corpus_id", "poscount"})
+			assert.NoError(t, err)
+			assert.NoError(t, ins.Exec(corpusID, 1))
+		}
+		assert.NoError(t, w.Commit())
+	}
+	partFiles := func(table, corpusID string) []string {
+		entries, err := os.ReadDir(filepath.Join(outDir, table, "corpus_id="+corpusID))
+		if os.IsNotExist(err) {
+			return []string{}
+		}
+		assert.NoError(t, err)
+		ans := make([]string, len(entries))
+		for i, e := range entries {
+			ans[i] = e.Name()
+		}
+		return ans
+	}
+	write("syn1", "run1", false)
+	write("syn2", "run1", true)
+	write("syn1", "run2", false)
+	for _, table := range []string{"liveattrs_entry", "corpus_sizes"} {
+		assert.Equal(t, []string{"part-run2.parquet"}, partFiles(table, "syn1"), table)
+		assert.Equal(t, []string{"part-run1.parquet"}, partFiles(table, "syn2"), table)
+	}
+}
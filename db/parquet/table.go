@@ -0,0 +1,175 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	colTypeString = iota
+	colTypeInt
+	colTypeFloat
+
+	// numParallelMarshalers specifies how many goroutines
+	// the Parquet library uses to marshal data
+	numParallelMarshalers = 4
+)
+
+// columnType returns a storage type for a known column.
+// All the unknown columns are considered strings.
+func columnType(name string) int {
+	switch name {
+	case "poscount", "wordcount", "count", "docfreq", "atom_id", "tokens", "atoms", "id":
+		return colTypeInt
+	case "arf", "tfidf", "mi", "tscore", "logdice", "ipm":
+		return colTypeFloat
+	default:
+		return colTypeString
+	}
+}
+
+func columnMetadata(name string, colType int) string {
+	switch colType {
+	case colTypeInt:
+		return fmt.Sprintf("name=%s, type=INT64, repetitiontype=OPTIONAL", name)
+	case colTypeFloat:
+		return fmt.Sprintf("name=%s, type=DOUBLE, repetitiontype=OPTIONAL", name)
+	default:
+		return fmt.Sprintf(
+			"name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", name)
+	}
+}
+
+// tableWriter writes rows of a single table (partition) into
+// a temporary file which is moved to its final location once
+// finished.
+type tableWriter struct {
+	path     string
+	file     *os.File
+	pw       *writer.CSVWriter
+	colTypes []int
+
+	// colMapping maps positions of inserted values to positions
+	// of written columns (partitioning column is not written)
+	colMapping []int
+	numCols    int
+}
+
+func (tw *tableWriter) tmpPath() string {
+	return tw.path + ".tmp"
+}
+
+func (tw *tableWriter) Exec(values ...any) error {
+	row := make([]any, tw.numCols)
+	for i, v := range values {
+		trgIdx := tw.colMapping[i]
+		if trgIdx < 0 {
+			continue
+		}
+		var err error
+		row[trgIdx], err = convertValue(v, tw.colTypes[trgIdx])
+		if err != nil {
+			return err
+		}
+	}
+	return tw.pw.Write(row)
+}
+
+func (tw *tableWriter) finish() error {
+	if err := tw.pw.WriteStop(); err != nil {
+		return fmt.Errorf("failed to finish Parquet file %s: %w", tw.path, err)
+	}
+	if err := tw.file.Close(); err != nil {
+		return fmt.Errorf("failed to finish Parquet file %s: %w", tw.path, err)
+	}
+	return os.Rename(tw.tmpPath(), tw.path)
+}
+
+func (tw *tableWriter) discard() {
+	tw.file.Close()
+	if err := os.Remove(tw.tmpPath()); err != nil {
+		log.Warn().Err(err).Str("file", tw.tmpPath()).Msg("failed to remove temporary file")
+	}
+}
+
+func convertValue(v any, colType int) (any, error) {
+	switch tv := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if tv == "" {
+			return nil, nil
+		}
+		if colType != colTypeString {
+			return nil, fmt.Errorf("cannot write string value '%s' to a numeric column", tv)
+		}
+		return tv, nil
+	case int:
+		switch colType {
+		case colTypeInt:
+			return int64(tv), nil
+		case colTypeFloat:
+			return float64(tv), nil
+		}
+		return fmt.Sprint(tv), nil
+	case float64:
+		switch colType {
+		case colTypeInt:
+			return int64(tv), nil
+		case colTypeFloat:
+			return tv, nil
+		}
+		return fmt.Sprint(tv), nil
+	default:
+		return fmt.Sprint(tv), nil
+	}
+}
+
+func newTableWriter(path string, attrs []string) (*tableWriter, error) {
+	tw := &tableWriter{
+		path:       path,
+		colMapping: make([]int, len(attrs)),
+	}
+	md := make([]string, 0, len(attrs))
+	for i, attr := range attrs {
+		if attr == partitionColumn {
+			tw.colMapping[i] = -1
+			continue
+		}
+		ct := columnType(attr)
+		tw.colMapping[i] = len(md)
+		tw.colTypes = append(tw.colTypes, ct)
+		md = append(md, columnMetadata(attr, ct))
+	}
+	tw.numCols = len(md)
+	var err error
+	tw.file, err = os.Create(tw.tmpPath())
+	if err != nil {
+		return nil, err
+	}
+	tw.pw, err = writer.NewCSVWriterFromWriter(md, tw.file, numParallelMarshalers)
+	if err != nil {
+		tw.file.Close()
+		return nil, err
+	}
+	return tw, nil
+}
@@ -0,0 +1,370 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	laTableSuffix = "_liveattrs_entry"
+)
+
+// dropExisting drops existing tables/views.
+// It is safe to call this even if one or more of these does not exist.
+// As in case of MySQL, the groupedCorpusName argument represents a derived
+// corpus name which is able to group multiple (aligned) corpora together.
+func dropExisting(database db.Executor, groupedCorpusName string) error {
+	log.Info().Msg("Attempting to drop possible existing tables and views...")
+	var err error
+	_, err = database.Exec(fmt.Sprintf(`DROP VIEW IF EXISTS "%s_bibliography"`, groupedCorpusName))
+	if err != nil {
+		return fmt.Errorf("failed to drop view %s_bibliography: %s", groupedCorpusName, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s%s"`, groupedCorpusName, laTableSuffix))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s%s': %s", groupedCorpusName, laTableSuffix, err)
+	}
+	_, err = database.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s_colcounts"`, groupedCorpusName))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %s_colcounts: %s", groupedCorpusName, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, groupedCorpusName, db.ColcountsHapaxTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, db.ColcountsHapaxTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, groupedCorpusName, db.CorpusTFIDFTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, db.CorpusTFIDFTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, groupedCorpusName, db.CorpusItemCountsTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, db.CorpusItemCountsTable, err)
+	}
+	_, err = database.Exec(
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, groupedCorpusName, db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	for _, tbl := range []string{db.CorpusTagDistribTable, db.CorpusTagDistribTTTable, db.LiveattrsMultiValueTable} {
+		_, err = database.Exec(
+			fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, groupedCorpusName, tbl))
+		if err != nil {
+			return fmt.Errorf("failed to drop table %s_%s: %s", groupedCorpusName, tbl, err)
+		}
+	}
+	log.Info().Msg("...DONE")
+	return nil
+}
+
+// sqlColumnType returns an SQL type of a liveattrs_entry
+// column of the provided type (see db.ColumnTypeInteger etc.).
+// The varcharSize applies to string columns.
+func sqlColumnType(colType string, varcharSize int) string {
+	switch colType {
+	case db.ColumnTypeInteger:
+		return "BIGINT"
+	case db.ColumnTypeFloat:
+		return "DOUBLE PRECISION"
+	case db.ColumnTypeDate:
+		return "DATE"
+	case db.ColumnTypeBoolean:
+		return "BOOLEAN"
+	default:
+		return fmt.Sprintf("VARCHAR(%d)", varcharSize)
+	}
+}
+
+// generateColNames produces a list of structural
+// attribute names as used in database
+// (i.e. [structname]_[attr_name]) out of lists
+// of structural attributes defined in the configuration.
+// (see _examples/*.json)
+func generateColNames(structures map[string][]string, columnNames map[string]string) []string {
+	numAttrs := 0
+	for _, v := range structures {
+		numAttrs += len(v)
+	}
+	ans := make([]string, numAttrs)
+	i := 0
+	for k, v := range structures {
+		for _, a := range v {
+			ans[i] = db.StructAttrColumn(k, a, columnNames)
+			i++
+		}
+	}
+	return ans
+}
+
+// generateAuxColDefs creates definitions for
+// auxiliary columns (num of positions, num of words etc.)
+func generateAuxColDefs(hasSelfJoin bool) []string {
+	ans := make([]string, 4)
+	ans[0] = "poscount INTEGER"
+	ans[1] = "wordcount INTEGER"
+	ans[2] = "corpus_id VARCHAR(63)"
+	if hasSelfJoin {
+		ans[3] = "item_id VARCHAR(127)"
+
+	} else {
+		ans = ans[:3]
+	}
+	return ans
+}
+
+func createAuxIndices(database db.Executor, groupedCorpusName string, cols []string) error {
+	var err error
+	for _, c := range cols {
+		_, err = database.Exec(
+			fmt.Sprintf(`CREATE INDEX "%s_%s_idx" ON "%s%s"(%s)`,
+				groupedCorpusName, c, groupedCorpusName, laTableSuffix, c))
+		if err != nil {
+			return err
+		}
+		log.Info().
+			Str("index", fmt.Sprintf(`%s_%s_idx`, groupedCorpusName, c)).
+			Str("table", groupedCorpusName+laTableSuffix).
+			Str("column", c).
+			Msg("Created custom database index")
+	}
+	return nil
+}
+
+// generateViewColDefs creates definitions for
+// bibliography view
+func generateViewColDefs(cols []string, idAttr string) []string {
+	ans := make([]string, len(cols))
+	for i, c := range cols {
+		if c != idAttr {
+			ans[i] = c
+
+		} else {
+			ans[i] = fmt.Sprintf("%s AS id", c)
+		}
+	}
+	return ans
+}
+
+// createBibView creates a database view needed
+// by liveattrs to fetch bibliography information.
+func createBibView(database db.Executor, groupedCorpusName string, cols []string, idAttr string) error {
+	colDefs := generateViewColDefs(cols, idAttr)
+	_, err := database.Exec(fmt.Sprintf(
+		`CREATE VIEW "%s_bibliography" AS SELECT %s FROM "%s%s"`,
+		groupedCorpusName, joinArgs(colDefs), groupedCorpusName, laTableSuffix))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// createCorpusSizesTable creates the corpus_sizes table in case it
+// does not exist yet (databases created by older versions lack it)
+func createCorpusSizesTable(database db.Executor, groupedCorpusName string) error {
+	_, err := database.Exec(fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS "%s_%s" (corpus_id VARCHAR(%d), tokens BIGINT, atoms BIGINT)`,
+		groupedCorpusName, db.CorpusSizesTable, db.DfltColcountVarcharSize))
+	if err != nil {
+		return fmt.Errorf(
+			"failed to create table '%s_%s': %s", groupedCorpusName, db.CorpusSizesTable, err)
+	}
+	return nil
+}
+
+// schemaOptions specifies the tables, views and columns
+// to be created by createSchema.
+type schemaOptions struct {
+	groupedCorpusName string
+	structures        map[string][]string
+	columnNames       map[string]string
+	columnTypes       map[string]string
+	columnSizes       map[string]int
+	indexedCols       []string
+	useSelfJoin       bool
+	countColumns      db.VertColumns
+	docFreq           bool
+	assocMeasures     bool
+	ipm               bool
+	hapaxTable        bool
+	tfidfTable        bool
+	itemCountsTable   bool
+	tagDistribTables  bool
+	dictEncoding      bool
+	multiValueTable   bool
+}
+
+// createSchema creates all the required tables, views and indices
+func createSchema(database db.Executor, opts schemaOptions) error {
+	log.Info().Msg("Attempting to create tables and views")
+
+	cols := generateColNames(opts.structures, opts.columnNames)
+	colTypes := db.OutputColumnTypes(opts.structures, opts.columnNames, opts.columnTypes)
+	colSizes := db.OutputColumnSizes(opts.structures, opts.columnNames, opts.columnSizes)
+	colsDefs := make([]string, len(cols))
+	for i, col := range cols {
+		colsDefs[i] = fmt.Sprintf("%s %s", col, sqlColumnType(colTypes[col], colSizes[col]))
+	}
+	auxColDefs := generateAuxColDefs(opts.useSelfJoin)
+	allCollsDefs := append(colsDefs, auxColDefs...)
+	_, dbErr := database.Exec(
+		fmt.Sprintf(
+			`CREATE TABLE "%s%s" (id SERIAL PRIMARY KEY, %s)`,
+			opts.groupedCorpusName,
+			laTableSuffix,
+			joinArgs(allCollsDefs),
+		),
+	)
+	if dbErr != nil {
+		return fmt.Errorf(
+			"failed to create table '%s%s': %s", opts.groupedCorpusName, laTableSuffix, dbErr)
+	}
+	if dbErr = createCorpusSizesTable(database, opts.groupedCorpusName); dbErr != nil {
+		return dbErr
+	}
+	if opts.multiValueTable {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE TABLE "%s_%s" (corpus_id VARCHAR(%d), item_id VARCHAR(%d), attr VARCHAR(%d), value VARCHAR(%d))`,
+			opts.groupedCorpusName, db.LiveattrsMultiValueTable, db.DfltColcountVarcharSize, db.DfltLAVarcharSize,
+			db.DfltColcountVarcharSize, db.DfltLAVarcharSize))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.LiveattrsMultiValueTable, dbErr)
+		}
+	}
+	if opts.tagDistribTables {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE TABLE "%s_%s" (corpus_id VARCHAR(%d), tag VARCHAR(%d), count BIGINT)`,
+			opts.groupedCorpusName, db.CorpusTagDistribTable, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTagDistribTable, dbErr)
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE TABLE "%s_%s" (corpus_id VARCHAR(%d), attr VARCHAR(%d), value VARCHAR(%d), tag VARCHAR(%d), count BIGINT)`,
+			opts.groupedCorpusName, db.CorpusTagDistribTTTable, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize,
+			db.DfltLAVarcharSize, db.DfltColcountVarcharSize))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTagDistribTTTable, dbErr)
+		}
+	}
+
+	if opts.useSelfJoin {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE UNIQUE INDEX "%s%s_item_id_corpus_id_idx" ON "%s%s"(item_id, corpus_id)`,
+			opts.groupedCorpusName, laTableSuffix, opts.groupedCorpusName, laTableSuffix))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create index %s%s_item_id_corpus_id_idx on %s%s(item_id, corpus_id): %s",
+				opts.groupedCorpusName, laTableSuffix, opts.groupedCorpusName, laTableSuffix, dbErr)
+		}
+	}
+	dbErr = createAuxIndices(database, opts.groupedCorpusName, opts.indexedCols)
+	if dbErr != nil {
+		return fmt.Errorf("failed to create a custom index: %s", dbErr)
+	}
+
+	if len(opts.countColumns) > 0 {
+		colDefs := db.GenerateColCountNames(opts.countColumns)
+		for i, c := range colDefs {
+			if opts.dictEncoding {
+				colDefs[i] = c + " INTEGER"
+
+			} else {
+				colDefs[i] = c + fmt.Sprintf(` VARCHAR(%d) COLLATE "C"`, db.DfltColcountVarcharSize)
+			}
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE TABLE "%s_colcounts" (%s, hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER, arf REAL%s, PRIMARY KEY(hash_id))`,
+			opts.groupedCorpusName, strings.Join(colDefs, ", "), db.DfltColcountVarcharSize,
+			db.DocFreqColDef(opts.docFreq, "INTEGER")+db.IPMColDef(opts.ipm, "DOUBLE PRECISION")+db.AssocColDefs(opts.assocMeasures, "DOUBLE PRECISION")))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s_colcounts': %s", opts.groupedCorpusName, dbErr)
+		}
+		if opts.dictEncoding {
+			for _, tbl := range db.ColValuesTables(opts.countColumns) {
+				_, dbErr = database.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s_%s"`, opts.groupedCorpusName, tbl))
+				if dbErr != nil {
+					return fmt.Errorf("failed to drop table '%s_%s': %s", opts.groupedCorpusName, tbl, dbErr)
+				}
+				_, dbErr = database.Exec(fmt.Sprintf(
+					`CREATE TABLE "%s_%s" (corpus_id VARCHAR(%d), id INTEGER, value VARCHAR(%d) COLLATE "C", `+
+						`PRIMARY KEY(corpus_id, id))`,
+					opts.groupedCorpusName, tbl, db.DfltColcountVarcharSize, db.DfltColcountVarcharSize))
+				if dbErr != nil {
+					return fmt.Errorf("failed to create table '%s_%s': %s", opts.groupedCorpusName, tbl, dbErr)
+				}
+			}
+		}
+		if opts.hapaxTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				`CREATE TABLE "%s_%s" (LIKE "%s_colcounts" INCLUDING ALL)`,
+				opts.groupedCorpusName, db.ColcountsHapaxTable, opts.groupedCorpusName))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.ColcountsHapaxTable, dbErr)
+			}
+		}
+		if opts.tfidfTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				`CREATE TABLE "%s_%s" (atom_id INTEGER, hash_id VARCHAR(40), corpus_id VARCHAR(%d), tfidf DOUBLE PRECISION)`,
+				opts.groupedCorpusName, db.CorpusTFIDFTable, db.DfltColcountVarcharSize))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusTFIDFTable, dbErr)
+			}
+		}
+		if opts.itemCountsTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				`CREATE TABLE "%s_%s" (item_id VARCHAR(%d), hash_id VARCHAR(40), corpus_id VARCHAR(%d), count INTEGER)`,
+				opts.groupedCorpusName, db.CorpusItemCountsTable, db.DfltLAVarcharSize, db.DfltColcountVarcharSize))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create table '%s_%s': %s", opts.groupedCorpusName, db.CorpusItemCountsTable, dbErr)
+			}
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			`CREATE INDEX "%s_colcounts_corpus_id_idx" ON "%s_colcounts"(corpus_id)`,
+			opts.groupedCorpusName, opts.groupedCorpusName))
+		if dbErr != nil {
+			return fmt.Errorf(
+				"failed to create index colcounts_corpus_id_idx on %s_colcounts(corpus_id): %s",
+				opts.groupedCorpusName, dbErr)
+		}
+		for _, col := range db.RoleIndexedColCountNames(opts.countColumns) {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				`CREATE INDEX "%s_colcounts_%s_idx" ON "%s_colcounts"(%s)`,
+				opts.groupedCorpusName, col, opts.groupedCorpusName, col))
+			if dbErr != nil {
+				return fmt.Errorf(
+					"failed to create index colcounts_%s_idx on %s_colcounts(%s): %s",
+					col, opts.groupedCorpusName, col, dbErr)
+			}
+		}
+	}
+	log.Info().Msg("DONE")
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExecutor stores executed queries instead of running them
+type recordingExecutor struct {
+	queries []string
+}
+
+func (ex *recordingExecutor) Exec(query string, args ...any) (sql.Result, error) {
+	ex.queries = append(ex.queries, query)
+	return nil, nil
+}
+
+func TestSQLColumnType(t *testing.T) {
+	for colType, expected := range map[string]string{
+		db.ColumnTypeInteger: "BIGINT",
+		db.ColumnTypeFloat:   "DOUBLE PRECISION",
+		db.ColumnTypeDate:    "DATE",
+		db.ColumnTypeBoolean: "BOOLEAN",
+		"":                   "VARCHAR(100)",
+	} {
+		assert.Equal(t, expected, sqlColumnType(colType, 100), colType)
+	}
+}
+
+func TestGenerateAuxColDefs(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{"poscount INTEGER", "wordcount INTEGER", "corpus_id VARCHAR(63)"},
+		generateAuxColDefs(false),
+	)
+	assert.Equal(
+		t,
+		[]string{"poscount INTEGER", "wordcount INTEGER", "corpus_id VARCHAR(63)", "item_id VARCHAR(127)"},
+		generateAuxColDefs(true),
+	)
+}
+
+func TestGenerateViewColDefs(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{"doc_id AS id", "doc_author"},
+		generateViewColDefs([]string{"doc_id", "doc_author"}, "doc_id"),
+	)
+}
+
+func TestInsertQuery(t *testing.T) {
+	assert.Equal(
+		t,
+		`INSERT INTO "syn_liveattrs_entry" (doc_id, corpus_id) VALUES ($1, $2)`,
+		insertQuery("syn", "liveattrs_entry", []string{"doc_id", "corpus_id"}),
+	)
+	assert.Equal(t, "$3", placeholder(3))
+}
+
+func TestCreateSchema(t *testing.T) {
+	ex := &recordingExecutor{}
+	err := createSchema(ex, schemaOptions{
+		groupedCorpusName: "syn",
+		structures:        map[string][]string{"doc": {"year"}},
+		columnTypes:       map[string]string{"doc_year": db.ColumnTypeInteger},
+		indexedCols:       []string{"doc_year"},
+		useSelfJoin:       true,
+		countColumns:      db.VertColumns{{Idx: 0}},
+		ipm:               true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			`CREATE TABLE "syn_liveattrs_entry" (id SERIAL PRIMARY KEY, doc_year BIGINT, ` +
+				`poscount INTEGER, wordcount INTEGER, corpus_id VARCHAR(63), item_id VARCHAR(127))`,
+			fmt.Sprintf(
+				`CREATE TABLE IF NOT EXISTS "syn_%s" (corpus_id VARCHAR(255), tokens BIGINT, atoms BIGINT)`,
+				db.CorpusSizesTable),
+			`CREATE UNIQUE INDEX "syn_liveattrs_entry_item_id_corpus_id_idx" ON "syn_liveattrs_entry"(item_id, corpus_id)`,
+			`CREATE INDEX "syn_doc_year_idx" ON "syn_liveattrs_entry"(doc_year)`,
+			`CREATE TABLE "syn_colcounts" (col0 VARCHAR(255) COLLATE "C", hash_id VARCHAR(40), corpus_id VARCHAR(255), ` +
+				`count INTEGER, arf REAL, ipm DOUBLE PRECISION, PRIMARY KEY(hash_id))`,
+			`CREATE INDEX "syn_colcounts_corpus_id_idx" ON "syn_colcounts"(corpus_id)`,
+		},
+		ex.queries,
+	)
+}
+
+func TestDropExisting(t *testing.T) {
+	ex := &recordingExecutor{}
+	assert.NoError(t, dropExisting(ex, "syn"))
+	assert.Equal(t, `DROP VIEW IF EXISTS "syn_bibliography"`, ex.queries[0])
+	for _, q := range ex.queries[1:] {
+		assert.Regexp(t, `^DROP TABLE IF EXISTS "syn_\w+"$`, q)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type nullInsert struct{}
+
+func (ni *nullInsert) Exec(values ...any) error {
+	return nil
+}
+
+// pipelinedInsert writes n-gram counts using a Redis pipeline
+// which is executed each time batchSize commands are collected.
+type pipelinedInsert struct {
+	writer      *Writer
+	pipe        redis.Pipeliner
+	colIdxs     []int
+	countIdx    int
+	arfIdx      int
+	docFreqIdx  int
+	numPending  int
+	keyBuilder  strings.Builder
+	fieldValues []any
+}
+
+func (ins *pipelinedInsert) Exec(values ...any) error {
+	ins.keyBuilder.Reset()
+	ins.keyBuilder.WriteString(ins.writer.keyPrefix())
+	for i, idx := range ins.colIdxs {
+		if i > 0 {
+			ins.keyBuilder.WriteByte('\t')
+		}
+		ins.keyBuilder.WriteString(fmt.Sprint(values[idx]))
+	}
+	ins.fieldValues = ins.fieldValues[:0]
+	ins.fieldValues = append(ins.fieldValues, "count", values[ins.countIdx])
+	if ins.arfIdx >= 0 {
+		ins.fieldValues = append(ins.fieldValues, "arf", values[ins.arfIdx])
+	}
+	if ins.docFreqIdx >= 0 {
+		ins.fieldValues = append(ins.fieldValues, "docfreq", values[ins.docFreqIdx])
+	}
+	ins.pipe.HSet(ins.writer.ctx, ins.keyBuilder.String(), ins.fieldValues...)
+	ins.numPending++
+	if ins.numPending >= ins.writer.batchSize {
+		return ins.flush()
+	}
+	return nil
+}
+
+func (ins *pipelinedInsert) flush() error {
+	if ins.numPending == 0 {
+		return nil
+	}
+	_, err := ins.pipe.Exec(ins.writer.ctx)
+	ins.numPending = 0
+	if err != nil {
+		return fmt.Errorf("failed to write n-gram counts to Redis: %w", err)
+	}
+	return nil
+}
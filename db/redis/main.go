@@ -0,0 +1,210 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis provides a writer storing n-gram counts (colcounts)
+// in Redis for fast single key frequency lookups. Each n-gram is stored
+// as a hash with key [corpus]:colcounts:[col values separated by tab]
+// and fields 'count', 'arf' (and optionally 'docfreq'). Other tables
+// (e.g. liveattrs_entry) are not stored.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	dfltBatchSize = 10000
+	scanBatchSize = 10000
+)
+
+type Writer struct {
+	ctx        context.Context
+	client     *redis.Client
+	corpusID   string
+	batchSize  int
+	appendMode bool
+	inserts    []*pipelinedInsert
+
+	CountColumns db.VertColumns
+}
+
+func (w *Writer) keyPrefix() string {
+	return w.corpusID + ":colcounts:"
+}
+
+func (w *Writer) metaKey() string {
+	return w.corpusID + ":meta"
+}
+
+func (w *Writer) DatabaseExists() bool {
+	n, err := w.client.Exists(w.ctx, w.metaKey()).Result()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to test data storage existence")
+		return false
+	}
+	return n > 0
+}
+
+// deleteExisting removes all the keys belonging to the corpus
+func (w *Writer) deleteExisting() error {
+	iter := w.client.Scan(w.ctx, 0, w.keyPrefix()+"*", scanBatchSize).Iterator()
+	keys := make([]string, 0, scanBatchSize)
+	var numDeleted int
+	for iter.Next(w.ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanBatchSize {
+			if err := w.client.Unlink(w.ctx, keys...).Err(); err != nil {
+				return err
+			}
+			numDeleted += len(keys)
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	keys = append(keys, w.metaKey())
+	if err := w.client.Unlink(w.ctx, keys...).Err(); err != nil {
+		return err
+	}
+	log.Info().Int("numKeys", numDeleted+len(keys)).Msg("Deleted existing Redis keys")
+	return nil
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	w.appendMode = appendMode
+	if err := w.client.Ping(w.ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	if !appendMode {
+		if w.DatabaseExists() {
+			log.Warn().
+				Str("corpus", w.corpusID).
+				Msg("The data storage already exists. Existing data will be deleted.")
+			if err := w.deleteExisting(); err != nil {
+				return fmt.Errorf("failed to delete existing data: %w", err)
+			}
+		}
+	}
+	return w.client.HSet(
+		w.ctx,
+		w.metaKey(),
+		"columns", strings.Join(db.GenerateColCountNames(w.CountColumns), " "),
+		"updated", time.Now().Format(time.RFC3339),
+	).Err()
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	if table != "colcounts" {
+		log.Info().Str("table", table).Msg("Redis writer stores only colcounts, ignoring table")
+		return &nullInsert{}, nil
+	}
+	ins := &pipelinedInsert{
+		writer:     w,
+		pipe:       w.client.Pipeline(),
+		countIdx:   -1,
+		arfIdx:     -1,
+		docFreqIdx: -1,
+	}
+	for i, attr := range attrs {
+		switch {
+		case attr == "count":
+			ins.countIdx = i
+		case attr == "arf":
+			ins.arfIdx = i
+		case attr == "docfreq":
+			ins.docFreqIdx = i
+		case db.IsNgramColCountName(attr):
+			ins.colIdxs = append(ins.colIdxs, i)
+		}
+	}
+	if ins.countIdx < 0 || len(ins.colIdxs) == 0 {
+		return nil, fmt.Errorf("cannot prepare insert into %s - missing count or n-gram columns", table)
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		if err := ins.flush(); err != nil {
+			return err
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+// Rollback discards all the pending commands. In the "create" mode,
+// all the data already written for the corpus are removed too.
+func (w *Writer) Rollback() error {
+	for _, ins := range w.inserts {
+		ins.pipe.Discard()
+	}
+	w.inserts = w.inserts[:0]
+	if !w.appendMode {
+		return w.deleteExisting()
+	}
+	log.Warn().Msg("Redis writer cannot rollback already written data in the append mode")
+	return nil
+}
+
+func (w *Writer) Close() {
+	if err := w.client.Close(); err != nil {
+		log.Warn().Err(err).Msg("error closing Redis connection")
+	}
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	if conf.Ngrams.DictEncoding {
+		return nil, fmt.Errorf("Redis writer does not support ngrams.dictEncoding")
+	}
+	var dbNum int
+	if conf.DB.Name != "" {
+		var err error
+		dbNum, err = strconv.Atoi(conf.DB.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis database number %s", conf.DB.Name)
+		}
+	}
+	batchSize := conf.DB.BatchSize
+	if batchSize <= 0 {
+		batchSize = dfltBatchSize
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     conf.DB.Host,
+		Username: conf.DB.User,
+		Password: conf.DB.Password,
+		DB:       dbNum,
+	})
+	return &Writer{
+		ctx:          context.Background(),
+		client:       client,
+		corpusID:     conf.Corpus,
+		batchSize:    batchSize,
+		CountColumns: conf.Ngrams.CountColumns(),
+	}, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHook stores pipelined commands instead
+// of sending them to a server
+type recordingHook struct {
+	pipelines [][]any
+}
+
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return next(ctx, cmd)
+	}
+}
+
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		args := make([]any, len(cmds))
+		for i, cmd := range cmds {
+			args[i] = cmd.Args()
+		}
+		h.pipelines = append(h.pipelines, args)
+		return nil
+	}
+}
+
+func newTestWriter(t *testing.T, batchSize int) (*Writer, *recordingHook) {
+	w, err := NewWriter(&cnf.VTEConf{
+		Corpus: "syn",
+		DB:     db.Conf{BatchSize: batchSize},
+	})
+	assert.NoError(t, err)
+	hook := &recordingHook{}
+	w.client.AddHook(hook)
+	t.Cleanup(w.Close)
+	return w, hook
+}
+
+func TestNewWriterValidation(t *testing.T) {
+	_, err := NewWriter(&cnf.VTEConf{Ngrams: cnf.NgramConf{DictEncoding: true}})
+	assert.Error(t, err)
+	_, err = NewWriter(&cnf.VTEConf{DB: db.Conf{Name: "first"}})
+	assert.Error(t, err)
+	w, err := NewWriter(&cnf.VTEConf{DB: db.Conf{Name: "3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, w.client.Options().DB)
+	assert.Equal(t, dfltBatchSize, w.batchSize)
+	w.Close()
+}
+
+func TestPrepareInsertColumns(t *testing.T) {
+	w, _ := newTestWriter(t, 10)
+	ins, err := w.PrepareInsert("colcounts", []string{"hash_id", "col0", "col2", "corpus_id", "count", "arf"})
+	assert.NoError(t, err)
+	pins := ins.(*pipelinedInsert)
+	assert.Equal(t, []int{1, 2}, pins.colIdxs)
+	assert.Equal(t, 4, pins.countIdx)
+	assert.Equal(t, 5, pins.arfIdx)
+	assert.Equal(t, -1, pins.docFreqIdx)
+
+	_, err = w.PrepareInsert("colcounts", []string{"col0", "arf"})
+	assert.Error(t, err)
+
+	ins, err = w.PrepareInsert("liveattrs_entry", []string{"doc_id"})
+	assert.NoError(t, err)
+	assert.IsType(t, &nullInsert{}, ins)
+}
+
+func TestPipelinedCommands(t *testing.T) {
+	w, hook := newTestWriter(t, 2)
+	ins, err := w.PrepareInsert("colcounts", []string{"col0", "col1", "count", "arf", "docfreq"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", "bar", 10, 3.5, 2))
+	assert.NoError(t, ins.Exec("foo", "baz", 1, 1.0, 1))
+	assert.NoError(t, ins.Exec("x", "y", 5, 2.0, 1))
+	assert.Equal(
+		t,
+		[][]any{
+			{
+				[]any{"hset", "syn:colcounts:foo\tbar", "count", 10, "arf", 3.5, "docfreq", 2},
+				[]any{"hset", "syn:colcounts:foo\tbaz", "count", 1, "arf", 1.0, "docfreq", 1},
+			},
+		},
+		hook.pipelines,
+	)
+	assert.NoError(t, w.Commit())
+	if assert.Len(t, hook.pipelines, 2) {
+		assert.Equal(
+			t,
+			[]any{[]any{"hset", "syn:colcounts:x\ty", "count", 5, "arf", 2.0, "docfreq", 1}},
+			hook.pipelines[1],
+		)
+	}
+}
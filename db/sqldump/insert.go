@@ -0,0 +1,98 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldump
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+var (
+	mysqlEscaper = strings.NewReplacer("\\", "\\\\", "'", "''")
+	stdEscaper   = strings.NewReplacer("'", "''")
+)
+
+// dumpInsert collects rows and writes them as multi-row
+// INSERT statements (with up to batchSize rows each).
+type dumpInsert struct {
+	writer  *Writer
+	prefix  string
+	buff    strings.Builder
+	numRows int
+}
+
+func (ins *dumpInsert) literal(v any) string {
+	switch tv := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		if tv == "" {
+			return "NULL"
+		}
+		if ins.writer.dialect == DialectMySQL {
+			return "'" + mysqlEscaper.Replace(tv) + "'"
+		}
+		return "'" + stdEscaper.Replace(tv) + "'"
+	case db.EmptyString:
+		return "''"
+	case bool:
+		if ins.writer.dialect == DialectPostgres {
+			return strings.ToUpper(fmt.Sprint(tv))
+		}
+		if tv {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(tv)
+	}
+}
+
+func (ins *dumpInsert) Exec(values ...any) error {
+	if ins.numRows == 0 {
+		ins.buff.WriteString(ins.prefix)
+
+	} else {
+		ins.buff.WriteString(",\n")
+	}
+	ins.buff.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			ins.buff.WriteString(", ")
+		}
+		ins.buff.WriteString(ins.literal(v))
+	}
+	ins.buff.WriteByte(')')
+	ins.numRows++
+	if ins.numRows >= ins.writer.batchSize {
+		return ins.flush()
+	}
+	return nil
+}
+
+func (ins *dumpInsert) flush() error {
+	if ins.numRows == 0 {
+		return nil
+	}
+	ins.buff.WriteString(";\n")
+	_, err := ins.writer.file.Write([]byte(ins.buff.String()))
+	ins.buff.Reset()
+	ins.numRows = 0
+	return err
+}
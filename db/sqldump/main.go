@@ -0,0 +1,193 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqldump provides a writer which does not connect to any
+// database. Instead, it produces an SQL script with all the CREATE
+// and INSERT statements for a chosen SQL dialect.
+package sqldump
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/mysql"
+	"github.com/czcorpus/vert-tagextract/v2/db/postgres"
+	"github.com/czcorpus/vert-tagextract/v2/db/sqlite"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+)
+
+const (
+	DialectSQLite   = "sqlite"
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+
+	dfltBatchSize = 1000
+)
+
+// statementRecorder implements db.Executor by writing
+// all the statements to the output file
+type statementRecorder struct {
+	file *fs.AtomicFile
+}
+
+func (sr *statementRecorder) Exec(query string, args ...any) (sql.Result, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("statement arguments not supported in SQL dumps")
+	}
+	_, err := fmt.Fprintf(sr.file, "%s;\n", query)
+	return nil, err
+}
+
+type Writer struct {
+	conf              *cnf.VTEConf
+	path              string
+	dialect           string
+	groupedCorpusName string
+	batchSize         int
+	file              *fs.AtomicFile
+	inserts           []*dumpInsert
+}
+
+// DatabaseExists always returns true as there is no way how
+// to check the target database. This allows for creating
+// "append" dumps with no DDL statements.
+func (w *Writer) DatabaseExists() bool {
+	return true
+}
+
+func (w *Writer) Initialize(appendMode bool) error {
+	var err error
+	w.file, err = fs.NewAtomicFile(w.path, w.conf.DB.Compress)
+	if err != nil {
+		return fmt.Errorf("failed to create SQL dump file: %w", err)
+	}
+	fmt.Fprintf(
+		w.file, "-- vert-tagextract SQL dump, corpus: %s, dialect: %s, created: %s\n\n",
+		w.conf.Corpus, w.dialect, time.Now().Format(time.RFC3339))
+	if !appendMode {
+		rec := &statementRecorder{file: w.file}
+		switch w.dialect {
+		case DialectSQLite:
+			err = sqlite.CreateSchema(rec, w.conf, true)
+		case DialectMySQL:
+			err = mysql.CreateSchema(rec, w.conf, true)
+		case DialectPostgres:
+			err = postgres.CreateSchema(rec, w.conf, true)
+		}
+		if err != nil {
+			w.file.Discard()
+			return err
+		}
+	}
+	if w.dialect == DialectMySQL {
+		_, err = fmt.Fprint(w.file, "\nSTART TRANSACTION;\n")
+
+	} else {
+		_, err = fmt.Fprint(w.file, "\nBEGIN;\n")
+	}
+	return err
+}
+
+func (w *Writer) tableName(table string) string {
+	switch w.dialect {
+	case DialectMySQL:
+		return fmt.Sprintf("`%s_%s`", w.groupedCorpusName, table)
+	case DialectPostgres:
+		return fmt.Sprintf(`"%s_%s"`, w.groupedCorpusName, table)
+	default:
+		return table
+	}
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	if w.file == nil {
+		return nil, fmt.Errorf("cannot prepare insert into %s - writer not initialized", table)
+	}
+	ins := &dumpInsert{
+		writer: w,
+		prefix: fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES\n", w.tableName(table), strings.Join(attrs, ", ")),
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		if err := ins.flush(); err != nil {
+			return err
+		}
+	}
+	w.inserts = w.inserts[:0]
+	if _, err := fmt.Fprint(w.file, "COMMIT;\n"); err != nil {
+		return err
+	}
+	if err := w.file.Commit(); err != nil {
+		return fmt.Errorf("failed to write SQL dump file: %w", err)
+	}
+	log.Info().Str("file", w.file.Path()).Msg("Written SQL dump")
+	w.file = nil
+	return nil
+}
+
+func (w *Writer) Rollback() error {
+	w.inserts = w.inserts[:0]
+	if w.file != nil {
+		err := w.file.Discard()
+		w.file = nil
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) Close() {
+	if w.file != nil {
+		log.Warn().Msg("closing SQL dump writer with uncommitted data, discarding them")
+		w.Rollback()
+	}
+}
+
+func NewWriter(conf *cnf.VTEConf) (*Writer, error) {
+	dialect := conf.DB.Dialect
+	switch dialect {
+	case DialectSQLite, DialectMySQL, DialectPostgres:
+	case "":
+		return nil, fmt.Errorf("SQL dump writer requires db.dialect to be set")
+	default:
+		return nil, fmt.Errorf("unsupported SQL dialect %s", dialect)
+	}
+	batchSize := conf.DB.BatchSize
+	if batchSize <= 0 {
+		batchSize = dfltBatchSize
+	}
+	groupedCorpusName := conf.Corpus
+	if conf.ParallelCorpus != "" {
+		groupedCorpusName = conf.ParallelCorpus
+	}
+	return &Writer{
+		conf:              conf,
+		path:              conf.DB.Name,
+		dialect:           dialect,
+		groupedCorpusName: groupedCorpusName,
+		batchSize:         batchSize,
+	}, nil
+}
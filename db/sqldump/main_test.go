@@ -0,0 +1,72 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldump
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteDumpIsExecutable(t *testing.T) {
+	conf := &cnf.VTEConf{
+		Corpus:     "susanne",
+		Structures: map[string][]string{"doc": {"id", "title"}},
+		DB: db.Conf{
+			Name:      filepath.Join(t.TempDir(), "dump.sql"),
+			Dialect:   DialectSQLite,
+			BatchSize: 2,
+		},
+		Ngrams: cnf.NgramConf{VertColumns: db.VertColumns{{Idx: 0}}},
+	}
+	w, err := NewWriter(conf)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Initialize(false))
+	ins, err := w.PrepareInsert(
+		"liveattrs_entry", []string{"doc_id", "doc_title", "poscount", "wordcount", "corpus_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("d1", "Rock 'n' roll", 10, 0, "susanne"))
+	assert.NoError(t, ins.Exec("d2", "", 20, 0, "susanne"))
+	assert.NoError(t, ins.Exec("d3", "foo", 30, 0, "susanne"))
+	assert.NoError(t, w.Commit())
+
+	script, err := os.ReadFile(conf.DB.Name)
+	assert.NoError(t, err)
+	database, err := sql.Open(sqlite.DriverName, ":memory:")
+	assert.NoError(t, err)
+	_, err = database.Exec(string(script))
+	assert.NoError(t, err)
+	var numRows, numNulls int
+	var title string
+	database.QueryRow("SELECT COUNT(*) FROM liveattrs_entry").Scan(&numRows)
+	database.QueryRow("SELECT COUNT(*) FROM liveattrs_entry WHERE doc_title IS NULL").Scan(&numNulls)
+	database.QueryRow("SELECT doc_title FROM liveattrs_entry WHERE doc_id = 'd1'").Scan(&title)
+	assert.Equal(t, 3, numRows)
+	assert.Equal(t, 1, numNulls)
+	assert.Equal(t, "Rock 'n' roll", title)
+}
+
+func TestNewWriterRequiresDialect(t *testing.T) {
+	_, err := NewWriter(&cnf.VTEConf{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,28 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !modernc
+
+package sqlite
+
+import (
+	_ "github.com/mattn/go-sqlite3" // load the driver
+)
+
+// DriverName is a name of the registered database/sql driver.
+// By default, the CGO based mattn/go-sqlite3 is used. To use
+// the pure Go modernc.org/sqlite driver, build with the "modernc" tag.
+const DriverName = "sqlite3"
@@ -19,25 +19,72 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
 	"github.com/czcorpus/vert-tagextract/v2/db"
 	"github.com/czcorpus/vert-tagextract/v2/fs"
 )
 
 // -------------------------------
 
+const (
+	// colcountsDBName is a schema name of an attached
+	// database for colcounts
+	colcountsDBName = "colcounts_db"
+)
+
 type Writer struct {
-	database       *sql.DB
-	tx             *sql.Tx
-	Path           string
-	PreconfQueries []string
-	Structures     map[string][]string
-	IndexedCols    []string
-	SelfJoinConf   db.SelfJoinConf
-	BibViewConf    db.BibViewConf
-	VertColumns    db.VertColumns
+	database         *sql.DB
+	tx               *sql.Tx
+	Path             string
+	PreconfQueries   []string
+	SQLiteConf       db.SQLiteConf
+	Structures       map[string][]string
+	ColumnNames      map[string]string
+	ColumnTypes      map[string]string
+	IndexedCols      []string
+	SelfJoinConf     db.SelfJoinConf
+	BibViewConf      db.BibViewConf
+	VertColumns      db.VertColumns
+	DocFreq          bool
+	AssocMeasures    bool
+	IPM              bool
+	HapaxTable       bool
+	TFIDFTable       bool
+	ItemCountsTable  bool
+	TagDistribTables bool
+	DictEncoding     bool
+	MultiValueTable  bool
+
+	// DeferIndexes specifies that indices should be created
+	// only after all the data are inserted (see Commit)
+	DeferIndexes bool
+
+	// indicesPending is true if the schema has been created
+	// but the indices are deferred
+	indicesPending bool
+
+	// logger is used instead of the global logger if set
+	// (see SetLogger)
+	logger *zerolog.Logger
+}
+
+// SetLogger sets a logger used by the writer (e.g. with
+// the corpus field attached). By default, the global
+// zerolog logger is used.
+func (w *Writer) SetLogger(logger zerolog.Logger) {
+	w.logger = &logger
+}
+
+func (w *Writer) log() *zerolog.Logger {
+	if w.logger != nil {
+		return w.logger
+	}
+	return &log.Logger
 }
 
 func (w *Writer) DatabaseExists() bool {
@@ -45,60 +92,167 @@ func (w *Writer) DatabaseExists() bool {
 }
 
 func (w *Writer) Initialize(appendMode bool) error {
+	// the attached colcounts database would be written in place
+	// while the main one is saved only on commit
+	if w.SQLiteConf.InMemory && w.SQLiteConf.ColcountsPath != "" {
+		return fmt.Errorf("failed to initialize sqlite database: colcountsPath cannot be combined with inMemory")
+	}
 	var err error
 	dbExisted := fs.IsFile(w.Path)
-	w.database, err = openDatabase(w.Path)
-	if err != nil {
-		return err
+	if w.SQLiteConf.InMemory {
+		w.database, err = openDatabase(memoryDBPath)
+		if err != nil {
+			return err
+		}
+		w.log().Info().Str("database", w.Path).Msg("Opened in-memory sqlite3 database")
+
+	} else {
+		w.database, err = openDatabase(w.Path)
+		if err != nil {
+			return err
+		}
+		w.log().Info().Str("database", w.Path).Msg("Opened sqlite3 database")
+	}
+	// pragmas must be applied before any table is created
+	// (e.g. page_size has no effect on non-empty databases)
+	for _, q := range w.preconfQueries() {
+		w.log().Info().Str("value", q).Msg("Applying preconfiguration")
+		if _, err := w.database.Exec(q); err != nil {
+			return fmt.Errorf("failed to apply preconfiguration '%s': %w", q, err)
+		}
+	}
+
+	if w.SQLiteConf.ColcountsPath != "" {
+		if _, err := w.database.Exec(
+			"ATTACH DATABASE ? AS "+colcountsDBName, w.SQLiteConf.ColcountsPath); err != nil {
+			return fmt.Errorf(
+				"failed to attach colcounts database %s: %w", w.SQLiteConf.ColcountsPath, err)
+		}
+		w.log().Info().Str("database", w.SQLiteConf.ColcountsPath).Msg("Attached colcounts database")
+	}
+
+	if w.SQLiteConf.InMemory && appendMode && dbExisted {
+		w.log().Info().Str("database", w.Path).Msg("Loading existing database into memory")
+		if err := loadDatabase(w.database, w.Path); err != nil {
+			return err
+		}
 	}
-	log.Info().Msgf("Opened sqlite3 database %s", w.Path)
 
 	if !appendMode {
 		if dbExisted {
-			log.
+			w.log().
 				Warn().
 				Str("database", w.Path).
 				Msg("The database already exists. Existing data will be deleted.")
-			err := dropExisting(w.database)
+			w.log().Info().Msg("Attempting to drop possible existing tables and views")
+			err := dropExisting(w.database, w.colcountsSchema())
 			if err != nil {
 				return err
 			}
 		}
-		err := createSchema(
-			w.database,
-			w.Structures,
-			w.IndexedCols,
-			w.SelfJoinConf.IsConfigured(),
-			w.VertColumns,
-		)
+		w.log().Info().Msg("Attempting to create tables and views")
+		err := createSchema(w.database, schemaOptions{
+			structures:       w.Structures,
+			columnNames:      w.ColumnNames,
+			columnTypes:      w.ColumnTypes,
+			useSelfJoin:      w.SelfJoinConf.IsConfigured(),
+			countColumns:     w.VertColumns,
+			docFreq:          w.DocFreq,
+			assocMeasures:    w.AssocMeasures,
+			ipm:              w.IPM,
+			hapaxTable:       w.HapaxTable,
+			tfidfTable:       w.TFIDFTable,
+			itemCountsTable:  w.ItemCountsTable,
+			tagDistribTables: w.TagDistribTables,
+			dictEncoding:     w.DictEncoding,
+			multiValueTable:  w.MultiValueTable,
+			colcountsSchema:  w.colcountsSchema(),
+		})
 		if err != nil {
 			return err
 		}
+		if w.DeferIndexes {
+			w.log().Info().Msg("Deferring creation of indices until all the data are inserted")
+			w.indicesPending = true
+
+		} else if err := w.createIndices(w.database); err != nil {
+			return err
+		}
 		if w.BibViewConf.IsConfigured() {
 			err := createBibView(w.database, w.BibViewConf.Cols, w.BibViewConf.IDAttr)
 			if err != nil {
 				return err
 			}
 		}
+
+	} else if err := createCorpusSizesTable(w.database); err != nil {
+		return err
 	}
 
-	var dbConf []string
-	if len(w.PreconfQueries) > 0 {
-		dbConf = w.PreconfQueries
+	w.tx, err = w.database.Begin()
+	return err
+}
 
-	} else {
-		log.Warn().Msg("No pre-configuration queries found, using default")
-		dbConf = []string{
-			"PRAGMA synchronous = OFF",
-			"PRAGMA journal_mode = MEMORY",
-		}
+func (w *Writer) createIndices(ex db.Executor) error {
+	w.log().Info().Msg("Attempting to create indices")
+	err := createIndices(
+		ex,
+		w.IndexedCols,
+		w.SelfJoinConf.IsConfigured(),
+		w.VertColumns,
+		w.colcountsSchema(),
+	)
+	if err != nil {
+		return err
 	}
-	for _, cnf := range dbConf {
-		log.Info().Str("value", cnf).Msg("Applying preconfiguration")
-		w.database.Exec(cnf)
+	if len(w.IndexedCols) > 0 {
+		w.log().Info().
+			Str("table", "liveattrs_entry").
+			Strs("columns", w.IndexedCols).
+			Msg("Created custom indices")
 	}
-	w.tx, err = w.database.Begin()
-	return err
+	return nil
+}
+
+// colcountsSchema returns a schema prefix for the colcounts table
+// in case it is stored in a separate database file. Otherwise,
+// an empty string is returned.
+func (w *Writer) colcountsSchema() string {
+	if w.SQLiteConf.ColcountsPath != "" {
+		return colcountsDBName + "."
+	}
+	return ""
+}
+
+// preconfQueries generates PRAGMA queries based on the sqlite
+// configuration followed by custom preconfiguration queries.
+// In case there is neither synchronous nor journal_mode configured
+// and there are no custom queries, the defaults (synchronous = OFF,
+// journal_mode = MEMORY) are used.
+func (w *Writer) preconfQueries() []string {
+	synchronous := w.SQLiteConf.Synchronous
+	journalMode := w.SQLiteConf.JournalMode
+	if len(w.PreconfQueries) == 0 && synchronous == "" && journalMode == "" {
+		w.log().Warn().Msg("No pre-configuration queries found, using default")
+		synchronous = "OFF"
+		journalMode = "MEMORY"
+	}
+	ans := make([]string, 0, 4+len(w.PreconfQueries))
+	// page_size must go first as switching to WAL
+	// makes the page size fixed
+	if w.SQLiteConf.PageSize > 0 {
+		ans = append(ans, fmt.Sprintf("PRAGMA page_size = %d", w.SQLiteConf.PageSize))
+	}
+	if w.SQLiteConf.CacheSize != 0 {
+		ans = append(ans, fmt.Sprintf("PRAGMA cache_size = %d", w.SQLiteConf.CacheSize))
+	}
+	if synchronous != "" {
+		ans = append(ans, "PRAGMA synchronous = "+synchronous)
+	}
+	if journalMode != "" {
+		ans = append(ans, "PRAGMA journal_mode = "+journalMode)
+	}
+	return append(ans, w.PreconfQueries...)
 }
 
 func (w *Writer) CreateBibView(cols []string, idAttr string) error {
@@ -109,6 +263,11 @@ func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation
 	if w.tx == nil {
 		return nil, fmt.Errorf("cannot prepare insert - no transaction active")
 	}
+	switch {
+	case table == "colcounts", table == db.ColcountsHapaxTable, table == db.CorpusTFIDFTable,
+		table == db.CorpusItemCountsTable, strings.HasPrefix(table, "colvalues_"):
+		table = w.colcountsSchema() + table
+	}
 	stmt, err := prepareInsert(w.tx, table, attrs)
 	if err != nil {
 		return nil, err
@@ -116,8 +275,33 @@ func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation
 	return &db.Insert{Stmt: stmt}, nil
 }
 
+func (w *Writer) UpdateCorpusSize(corpusID string, tokens, atoms int) (int, int, error) {
+	if w.tx == nil {
+		return 0, 0, fmt.Errorf("cannot update corpus size - no transaction active")
+	}
+	return db.AccumulateCorpusSize(w.tx, db.CorpusSizesTable, db.QuestionMarkPlaceholder, corpusID, tokens, atoms)
+}
+
+// Commit commits the current transaction. In case the indices
+// are deferred, they are created (within the transaction) first.
+// In the in-memory mode, the database is then saved to the target file.
 func (w *Writer) Commit() error {
-	return w.tx.Commit()
+	if w.indicesPending {
+		if err := w.createIndices(w.tx); err != nil {
+			return err
+		}
+		w.indicesPending = false
+	}
+	if err := w.tx.Commit(); err != nil {
+		return err
+	}
+	if w.SQLiteConf.InMemory {
+		if err := dumpDatabase(w.database, w.Path); err != nil {
+			return err
+		}
+		w.log().Info().Str("database", w.Path).Msg("Saved in-memory database")
+	}
+	return nil
 }
 
 func (w *Writer) Rollback() error {
@@ -127,6 +311,50 @@ func (w *Writer) Rollback() error {
 func (w *Writer) Close() {
 	err := w.database.Close()
 	if err != nil {
-		log.Warn().Err(err).Msg("Error closing database")
+		w.log().Warn().Err(err).Msg("Error closing database")
+	}
+}
+
+// CreateSchema creates all the tables, indices and views required
+// by the configuration using the provided executor. In case dropFirst
+// is true, possible existing tables and views are dropped first.
+func CreateSchema(ex db.Executor, conf *cnf.VTEConf, dropFirst bool) error {
+	if dropFirst {
+		if err := dropExisting(ex, ""); err != nil {
+			return err
+		}
+	}
+	err := createSchema(ex, schemaOptions{
+		structures:       conf.Structures,
+		columnNames:      conf.ColumnNames,
+		columnTypes:      conf.ColumnTypes,
+		useSelfJoin:      conf.SelfJoin.IsConfigured(),
+		countColumns:     conf.Ngrams.CountColumns(),
+		docFreq:          conf.Ngrams.HasDocFreq(),
+		assocMeasures:    conf.Ngrams.AssocMeasures,
+		ipm:              conf.Ngrams.IPM,
+		hapaxTable:       conf.Ngrams.HasHapaxTable(),
+		tfidfTable:       conf.Ngrams.TFIDF,
+		itemCountsTable:  conf.Ngrams.ItemCounts,
+		tagDistribTables: conf.TagDistrib != nil,
+		dictEncoding:     conf.Ngrams.DictEncoding,
+		multiValueTable:  len(conf.MultiValues) > 0,
+	})
+	if err != nil {
+		return err
+	}
+	err = createIndices(
+		ex,
+		conf.IndexedCols,
+		conf.SelfJoin.IsConfigured(),
+		conf.Ngrams.CountColumns(),
+		"",
+	)
+	if err != nil {
+		return err
+	}
+	if conf.BibView.IsConfigured() {
+		return createBibView(ex, conf.BibView.Cols, conf.BibView.IDAttr)
 	}
+	return nil
 }
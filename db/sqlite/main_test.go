@@ -0,0 +1,246 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreconfQueriesDefaults(t *testing.T) {
+	w := &Writer{}
+	assert.Equal(
+		t,
+		[]string{"PRAGMA synchronous = OFF", "PRAGMA journal_mode = MEMORY"},
+		w.preconfQueries(),
+	)
+}
+
+func TestPreconfQueriesCustomOnly(t *testing.T) {
+	w := &Writer{PreconfQueries: []string{"PRAGMA foreign_keys = ON"}}
+	assert.Equal(t, []string{"PRAGMA foreign_keys = ON"}, w.preconfQueries())
+}
+
+func TestPreconfQueriesConfigured(t *testing.T) {
+	w := &Writer{
+		PreconfQueries: []string{"PRAGMA temp_store = MEMORY"},
+		SQLiteConf: db.SQLiteConf{
+			JournalMode: "WAL",
+			PageSize:    8192,
+			CacheSize:   -200000,
+		},
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"PRAGMA page_size = 8192",
+			"PRAGMA cache_size = -200000",
+			"PRAGMA journal_mode = WAL",
+			"PRAGMA temp_store = MEMORY",
+		},
+		w.preconfQueries(),
+	)
+}
+
+func TestInitializeAppliesPageSize(t *testing.T) {
+	w := &Writer{
+		Path:       filepath.Join(t.TempDir(), "test.db"),
+		Structures: createStructures(),
+		SQLiteConf: db.SQLiteConf{JournalMode: "WAL", PageSize: 8192},
+	}
+	assert.NoError(t, w.Initialize(false))
+	defer w.Close()
+	var pageSize int
+	var journalMode string
+	assert.NoError(t, w.tx.QueryRow("PRAGMA page_size").Scan(&pageSize))
+	assert.NoError(t, w.tx.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.Equal(t, 8192, pageSize)
+	assert.Equal(t, "wal", journalMode)
+	assert.NoError(t, w.Commit())
+}
+
+func TestInMemoryDatabaseSavedOnCommit(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	newWriter := func() *Writer {
+		return &Writer{
+			Path:       dbPath,
+			Structures: createStructures(),
+			SQLiteConf: db.SQLiteConf{InMemory: true},
+		}
+	}
+	insertDoc := func(w *Writer, id string) {
+		ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "corpus_id"})
+		assert.NoError(t, err)
+		assert.NoError(t, ins.Exec(id, "test"))
+	}
+
+	w := newWriter()
+	assert.NoError(t, w.Initialize(false))
+	insertDoc(w, "d1")
+	assert.False(t, w.DatabaseExists())
+	assert.NoError(t, w.Commit())
+	w.Close()
+	assert.True(t, w.DatabaseExists())
+
+	w = newWriter()
+	assert.NoError(t, w.Initialize(true))
+	insertDoc(w, "d2")
+	assert.NoError(t, w.Commit())
+	w.Close()
+
+	database, err := sql.Open(DriverName, dbPath)
+	assert.NoError(t, err)
+	defer database.Close()
+	var numRows int
+	assert.NoError(t, database.QueryRow("SELECT COUNT(*) FROM liveattrs_entry").Scan(&numRows))
+	assert.Equal(t, 2, numRows)
+}
+
+func TestColcountsInAttachedDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	w := &Writer{
+		Path:        filepath.Join(tmpDir, "test.db"),
+		Structures:  createStructures(),
+		VertColumns: db.VertColumns{{Idx: 0}},
+		SQLiteConf:  db.SQLiteConf{ColcountsPath: filepath.Join(tmpDir, "colcounts.db")},
+	}
+	assert.NoError(t, w.Initialize(false))
+	ins, err := w.PrepareInsert(
+		"colcounts", []string{"col0", "corpus_id", "count", "arf", "hash_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", "test", 10, 3, "abcd"))
+	assert.NoError(t, w.Commit())
+	w.Close()
+
+	database, err := sql.Open(DriverName, w.SQLiteConf.ColcountsPath)
+	assert.NoError(t, err)
+	defer database.Close()
+	var numRows int
+	assert.NoError(t, database.QueryRow("SELECT COUNT(*) FROM colcounts").Scan(&numRows))
+	assert.Equal(t, 1, numRows)
+	database2, err := sql.Open(DriverName, w.Path)
+	assert.NoError(t, err)
+	defer database2.Close()
+	assert.Error(t, database2.QueryRow("SELECT COUNT(*) FROM colcounts").Scan(&numRows))
+}
+
+func TestColcountsPathRejectedInMemory(t *testing.T) {
+	tmpDir := t.TempDir()
+	w := &Writer{
+		Path:       filepath.Join(tmpDir, "test.db"),
+		Structures: createStructures(),
+		SQLiteConf: db.SQLiteConf{
+			InMemory:      true,
+			ColcountsPath: filepath.Join(tmpDir, "colcounts.db"),
+		},
+	}
+	assert.Error(t, w.Initialize(false))
+	assert.NoFileExists(t, w.SQLiteConf.ColcountsPath)
+}
+
+func TestDeferredIndexesCreatedOnCommit(t *testing.T) {
+	w := &Writer{
+		Path:         filepath.Join(t.TempDir(), "test.db"),
+		Structures:   createStructures(),
+		IndexedCols:  []string{"doc_id"},
+		VertColumns:  db.VertColumns{{Idx: 0}},
+		DeferIndexes: true,
+	}
+	countIndices := func(q interface {
+		QueryRow(query string, args ...any) *sql.Row
+	}) int {
+		var ans int
+		assert.NoError(t, q.QueryRow(
+			"SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name LIKE '%_idx'").Scan(&ans))
+		return ans
+	}
+	assert.NoError(t, w.Initialize(false))
+	defer w.Close()
+	assert.Equal(t, 0, countIndices(w.tx))
+	assert.NoError(t, w.Commit())
+	assert.Equal(t, 2, countIndices(w.database))
+}
+
+func TestAggregateStagedCounts(t *testing.T) {
+	w := &Writer{
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		Structures:  createStructures(),
+		VertColumns: db.VertColumns{{Idx: 0}},
+	}
+	assert.NoError(t, w.Initialize(false))
+	defer w.Close()
+	ins, err := w.PrepareStagingInsert([]string{"col0", "corpus_id", "count", "arf", "hash_id"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("foo", "test", 10, -1, "h1"))
+	assert.NoError(t, ins.Exec("bar", "test", 1, -1, "h2"))
+	assert.NoError(t, ins.Exec("foo", "test", 5, -1, "h1"))
+	assert.NoError(t, w.AggregateStaged())
+	assert.NoError(t, w.Commit())
+
+	var count int
+	assert.NoError(t, w.database.QueryRow(
+		"SELECT count FROM colcounts WHERE col0 = 'foo'").Scan(&count))
+	assert.Equal(t, 15, count)
+	assert.NoError(t, w.database.QueryRow("SELECT COUNT(*) FROM colcounts").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestAppendToDatabaseWithoutCorpusSizes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	w := &Writer{Path: dbPath, Structures: createStructures()}
+	assert.NoError(t, w.Initialize(false))
+	// databases created by older versions have no corpus_sizes table
+	_, err := w.tx.Exec("DROP TABLE " + db.CorpusSizesTable)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Commit())
+	w.Close()
+
+	for i, expected := range []int{100, 150} {
+		w = &Writer{Path: dbPath, Structures: createStructures()}
+		assert.NoError(t, w.Initialize(true))
+		tokens, atoms, err := w.UpdateCorpusSize("test", 100-50*i, 10-5*i)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, tokens)
+		assert.Equal(t, expected/10, atoms)
+		_, _, err = w.UpdateCorpusSize("other", 7, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Commit())
+		w.Close()
+	}
+
+	database, err := sql.Open(DriverName, dbPath)
+	assert.NoError(t, err)
+	defer database.Close()
+	rows, err := database.Query(
+		"SELECT corpus_id, tokens, atoms FROM " + db.CorpusSizesTable + " ORDER BY corpus_id")
+	assert.NoError(t, err)
+	defer rows.Close()
+	sizes := make(map[string][2]int)
+	for rows.Next() {
+		var corpusID string
+		var tokens, atoms int
+		assert.NoError(t, rows.Scan(&corpusID, &tokens, &atoms))
+		_, ok := sizes[corpusID]
+		assert.False(t, ok, "duplicate row of corpus %s", corpusID)
+		sizes[corpusID] = [2]int{tokens, atoms}
+	}
+	assert.Equal(t, map[string][2]int{"test": {150, 15}, "other": {14, 2}}, sizes)
+}
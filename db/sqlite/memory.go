@@ -0,0 +1,100 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+/*
+This file contains functions for the "in-memory" mode
+where all the data are written to an in-memory database
+and saved to the target file only on successful commit.
+*/
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	memoryDBPath = ":memory:"
+)
+
+type schemaItem struct {
+	itemType string
+	name     string
+	sql      string
+}
+
+// loadDatabase copies all the tables (incl. data), indices and views
+// from a database file into the (in-memory) database.
+func loadDatabase(database *sql.DB, path string) error {
+	if _, err := database.Exec("ATTACH DATABASE ? AS src", path); err != nil {
+		return fmt.Errorf("failed to load database %s: %w", path, err)
+	}
+	defer database.Exec("DETACH DATABASE src")
+	rows, err := database.Query(
+		`SELECT type, name, sql FROM src.sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END`)
+	if err != nil {
+		return fmt.Errorf("failed to load database %s: %w", path, err)
+	}
+	// we have to read all the rows first as there
+	// is just a single connection available
+	items := make([]schemaItem, 0, 10)
+	for rows.Next() {
+		var item schemaItem
+		if err := rows.Scan(&item.itemType, &item.name, &item.sql); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to load database %s: %w", path, err)
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	for _, item := range items {
+		if _, err := database.Exec(item.sql); err != nil {
+			return fmt.Errorf("failed to load %s %s: %w", item.itemType, item.name, err)
+		}
+		if item.itemType == "table" {
+			name := strings.ReplaceAll(item.name, `"`, `""`)
+			_, err := database.Exec(
+				fmt.Sprintf(`INSERT INTO main."%s" SELECT * FROM src."%s"`, name, name))
+			if err != nil {
+				return fmt.Errorf("failed to load data of table %s: %w", item.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dumpDatabase saves the (in-memory) database to a file. The data are
+// written to a temporary file first which is then renamed so the target
+// file is never left half-written.
+func dumpDatabase(database *sql.DB, path string) error {
+	tmpPath := path + ".tmp"
+	// VACUUM INTO requires a non-existing (or empty) target
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+	if _, err := database.Exec("VACUUM INTO ?", tmpPath); err != nil {
+		return fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+	return nil
+}
@@ -27,18 +27,17 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/rs/zerolog/log"
-
 	"github.com/czcorpus/vert-tagextract/v2/db"
-
-	_ "github.com/mattn/go-sqlite3" // load the driver
 )
 
 // openDatabase opens a sqlite3 database specified by
 // its filesystem path. In case of an error it panics.
 func openDatabase(dbPath string) (*sql.DB, error) {
 	var err error
-	if db, err := sql.Open("sqlite3", dbPath); err == nil {
+	if db, err := sql.Open(DriverName, dbPath); err == nil {
+		// pragmas are applied per connection so we
+		// must make sure all the queries share one
+		db.SetMaxOpenConns(1)
 		return db, nil
 	}
 	return nil, fmt.Errorf("failed to open text types db: %s", err)
@@ -64,7 +63,7 @@ func prepareInsert(database *sql.Tx, table string, cols []string) (*sql.Stmt, er
 // (i.e. [structname]_[attr_name]) out of lists
 // of structural attributes defined in the configuration.
 // (see _examples/*.json)
-func generateColNames(structures map[string][]string) []string {
+func generateColNames(structures map[string][]string, columnNames map[string]string) []string {
 	numAttrs := 0
 	for _, v := range structures {
 		numAttrs += len(v)
@@ -73,13 +72,30 @@ func generateColNames(structures map[string][]string) []string {
 	i := 0
 	for k, v := range structures {
 		for _, a := range v {
-			ans[i] = fmt.Sprintf("%s_%s", k, a)
+			ans[i] = db.StructAttrColumn(k, a, columnNames)
 			i++
 		}
 	}
 	return ans
 }
 
+// sqlColumnType returns an SQL type of a liveattrs_entry
+// column of the provided type (see db.ColumnTypeInteger etc.)
+func sqlColumnType(colType string) string {
+	switch colType {
+	case db.ColumnTypeInteger:
+		return "INTEGER"
+	case db.ColumnTypeFloat:
+		return "REAL"
+	case db.ColumnTypeDate:
+		return "DATE"
+	case db.ColumnTypeBoolean:
+		return "INTEGER"
+	default:
+		return "TEXT"
+	}
+}
+
 func joinArgs(args []string) string {
 	return strings.Join(args, ", ")
 }
@@ -117,7 +133,7 @@ func generateViewColDefs(cols []string, idAttr string) []string {
 
 // createBibView creates a database view needed
 // by liveattrs to fetch bibliography information.
-func createBibView(database *sql.DB, cols []string, idAttr string) error {
+func createBibView(database db.Executor, cols []string, idAttr string) error {
 	colDefs := generateViewColDefs(cols, idAttr)
 	_, err := database.Exec(fmt.Sprintf("CREATE VIEW bibliography AS SELECT %s FROM liveattrs_entry", joinArgs(colDefs)))
 	if err != nil {
@@ -126,27 +142,22 @@ func createBibView(database *sql.DB, cols []string, idAttr string) error {
 	return nil
 }
 
-func createAuxIndices(database *sql.DB, cols []string) error {
+func createAuxIndices(database db.Executor, cols []string) error {
 	var err error
 	for _, c := range cols {
 		_, err = database.Exec(fmt.Sprintf("CREATE INDEX %s_idx ON liveattrs_entry(%s)", c, c))
 		if err != nil {
 			return err
 		}
-		log.Info().
-			Str("index", c+"_idx").
-			Str("table", "liveattrs_entry").
-			Str("column", c).
-			Msg("Created custom index")
 	}
 	return nil
 }
 
 // dropExisting drops existing tables/views.
 // It is safe to call this even if one or more
-// of these does not exist.
-func dropExisting(database *sql.DB) error {
-	log.Info().Msg("Attempting to drop possible existing tables and views")
+// of these does not exist. For colcountsSchema,
+// see createSchema.
+func dropExisting(database db.Executor, colcountsSchema string) error {
 	var err error
 	_, err = database.Exec("DROP TABLE IF EXISTS cache")
 	if err != nil {
@@ -160,69 +171,212 @@ func dropExisting(database *sql.DB) error {
 	if err != nil {
 		return fmt.Errorf("failed to drop table 'liveattrs_entry': %s", err)
 	}
-	_, err = database.Exec("DROP TABLE IF EXISTS colcounts")
+	_, err = database.Exec("DROP TABLE IF EXISTS " + db.CorpusSizesTable)
 	if err != nil {
-		return fmt.Errorf("failed to drop table 'colcounts': %s", err)
+		return fmt.Errorf("failed to drop table '%s': %s", db.CorpusSizesTable, err)
+	}
+	for _, tbl := range []string{db.CorpusTagDistribTable, db.CorpusTagDistribTTTable, db.LiveattrsMultiValueTable} {
+		_, err = database.Exec("DROP TABLE IF EXISTS " + tbl)
+		if err != nil {
+			return fmt.Errorf("failed to drop table '%s': %s", tbl, err)
+		}
+	}
+	_, err = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %scolcounts", colcountsSchema))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%scolcounts': %s", colcountsSchema, err)
+	}
+	_, err = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s%s", colcountsSchema, db.ColcountsHapaxTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s%s': %s", colcountsSchema, db.ColcountsHapaxTable, err)
+	}
+	_, err = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s%s", colcountsSchema, db.CorpusTFIDFTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s%s': %s", colcountsSchema, db.CorpusTFIDFTable, err)
+	}
+	_, err = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s%s", colcountsSchema, db.CorpusItemCountsTable))
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s%s': %s", colcountsSchema, db.CorpusItemCountsTable, err)
 	}
 	return nil
 }
 
-// createSchema creates all the required tables, views and indices
-func createSchema(
-	database *sql.DB,
-	structures map[string][]string,
-	indexedCols []string,
-	useSelfJoin bool,
-	countColumns db.VertColumns,
-) error {
-	log.Info().Msg("Attempting to create tables and views")
+// createCorpusSizesTable creates the corpus_sizes table in case it
+// does not exist yet (databases created by older versions lack it)
+func createCorpusSizesTable(database db.Executor) error {
+	_, err := database.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (corpus_id TEXT, tokens INTEGER, atoms INTEGER)", db.CorpusSizesTable))
+	if err != nil {
+		return fmt.Errorf("failed to create table '%s': %s", db.CorpusSizesTable, err)
+	}
+	return nil
+}
+
+// schemaOptions specifies the tables, views and columns
+// to be created by createSchema.
+type schemaOptions struct {
+	structures       map[string][]string
+	columnNames      map[string]string
+	columnTypes      map[string]string
+	useSelfJoin      bool
+	countColumns     db.VertColumns
+	docFreq          bool
+	assocMeasures    bool
+	ipm              bool
+	hapaxTable       bool
+	tfidfTable       bool
+	itemCountsTable  bool
+	tagDistribTables bool
+	dictEncoding     bool
+	multiValueTable  bool
+	colcountsSchema  string
+}
 
+// createSchema creates all the required tables and views.
+// Indices are created separately by createIndices.
+// The opts.colcountsSchema specifies a schema prefix (e.g. "colcounts_db.")
+// of an attached database for the colcounts table. An empty string means
+// the main database.
+func createSchema(database db.Executor, opts schemaOptions) error {
 	var dbErr error
 	_, dbErr = database.Exec("CREATE TABLE cache (key TEXT PRIMARY KEY, value TEXT)")
 	if dbErr != nil {
 		return fmt.Errorf("failed to create table 'cache': %s", dbErr)
 	}
 
-	cols := generateColNames(structures)
+	cols := generateColNames(opts.structures, opts.columnNames)
+	colTypes := db.OutputColumnTypes(opts.structures, opts.columnNames, opts.columnTypes)
 	colsDefs := make([]string, len(cols))
 	for i, col := range cols {
-		colsDefs[i] = fmt.Sprintf("%s TEXT", col)
+		colsDefs[i] = fmt.Sprintf("%s %s", col, sqlColumnType(colTypes[col]))
 	}
-	auxColDefs := generateAuxColDefs(useSelfJoin)
+	auxColDefs := generateAuxColDefs(opts.useSelfJoin)
 	allCollsDefs := append(colsDefs, auxColDefs...)
 	_, dbErr = database.Exec(fmt.Sprintf("CREATE TABLE liveattrs_entry (id INTEGER PRIMARY KEY AUTOINCREMENT, %s)", joinArgs(allCollsDefs)))
 	if dbErr != nil {
 		return fmt.Errorf("failed to create table 'liveattrs_entry': %s", dbErr)
 	}
+	if dbErr = createCorpusSizesTable(database); dbErr != nil {
+		return dbErr
+	}
+	if opts.multiValueTable {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id TEXT, item_id TEXT, attr TEXT, value TEXT)", db.LiveattrsMultiValueTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.LiveattrsMultiValueTable, dbErr)
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE INDEX %s_attr_value_idx ON %s(attr, value)",
+			db.LiveattrsMultiValueTable, db.LiveattrsMultiValueTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create index on '%s': %s", db.LiveattrsMultiValueTable, dbErr)
+		}
+	}
+	if opts.tagDistribTables {
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id TEXT, tag TEXT, count INTEGER)", db.CorpusTagDistribTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.CorpusTagDistribTable, dbErr)
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %s (corpus_id TEXT, attr TEXT, value TEXT, tag TEXT, count INTEGER)",
+			db.CorpusTagDistribTTTable))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table '%s': %s", db.CorpusTagDistribTTTable, dbErr)
+		}
+	}
 
+	if len(opts.countColumns) > 0 {
+		colDefs := db.GenerateColCountNames(opts.countColumns)
+		colType := "TEXT"
+		if opts.dictEncoding {
+			colType = "INTEGER"
+		}
+		for i, c := range colDefs {
+			colDefs[i] = c + " " + colType
+		}
+		_, dbErr = database.Exec(fmt.Sprintf(
+			"CREATE TABLE %scolcounts (hash_id varchar(40), %s, corpus_id TEXT, count INTEGER, arf INTEGER%s, PRIMARY KEY(hash_id))",
+			opts.colcountsSchema, strings.Join(colDefs, ", "), db.DocFreqColDef(opts.docFreq, "INTEGER")+db.IPMColDef(opts.ipm, "REAL")+db.AssocColDefs(opts.assocMeasures, "REAL")))
+		if dbErr != nil {
+			return fmt.Errorf("failed to create table 'colcounts': %s", dbErr)
+		}
+		if opts.dictEncoding {
+			for _, tbl := range db.ColValuesTables(opts.countColumns) {
+				_, dbErr = database.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s%s", opts.colcountsSchema, tbl))
+				if dbErr != nil {
+					return fmt.Errorf("failed to drop table '%s': %s", tbl, dbErr)
+				}
+				_, dbErr = database.Exec(fmt.Sprintf(
+					"CREATE TABLE %s%s (corpus_id TEXT, id INTEGER, value TEXT, PRIMARY KEY(corpus_id, id))",
+					opts.colcountsSchema, tbl))
+				if dbErr != nil {
+					return fmt.Errorf("failed to create table '%s': %s", tbl, dbErr)
+				}
+			}
+		}
+		if opts.hapaxTable {
+			// hapaxes are unique so no primary key is needed
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s%s AS SELECT * FROM %scolcounts WHERE 0",
+				opts.colcountsSchema, db.ColcountsHapaxTable, opts.colcountsSchema))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.ColcountsHapaxTable, dbErr)
+			}
+		}
+		if opts.tfidfTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s%s (atom_id INTEGER, hash_id varchar(40), corpus_id TEXT, tfidf REAL)",
+				opts.colcountsSchema, db.CorpusTFIDFTable))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.CorpusTFIDFTable, dbErr)
+			}
+		}
+		if opts.itemCountsTable {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE TABLE %s%s (item_id TEXT, hash_id varchar(40), corpus_id TEXT, count INTEGER)",
+				opts.colcountsSchema, db.CorpusItemCountsTable))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create table '%s': %s", db.CorpusItemCountsTable, dbErr)
+			}
+		}
+	}
+	return nil
+}
+
+// createIndices creates all the indices of tables created by createSchema.
+// For the meaning of colcountsSchema, see createSchema.
+func createIndices(
+	database db.Executor,
+	indexedCols []string,
+	useSelfJoin bool,
+	countColumns db.VertColumns,
+	colcountsSchema string,
+) error {
 	if useSelfJoin {
-		_, dbErr = database.Exec(
+		_, dbErr := database.Exec(
 			"CREATE UNIQUE INDEX item_id_corpus_id_idx ON liveattrs_entry(item_id, corpus_id)")
 		if dbErr != nil {
 			return fmt.Errorf(
 				"failed to create index item_id_idx on liveattrs_entry(item_id): %s", dbErr)
 		}
 	}
-	dbErr = createAuxIndices(database, indexedCols)
+	dbErr := createAuxIndices(database, indexedCols)
 	if dbErr != nil {
 		return fmt.Errorf("failed to create a custom index: %s", dbErr)
 	}
-
 	if len(countColumns) > 0 {
-		colDefs := db.GenerateColCountNames(countColumns)
-		for i, c := range colDefs {
-			colDefs[i] = c + " TEXT"
-		}
 		_, dbErr = database.Exec(fmt.Sprintf(
-			"CREATE TABLE colcounts (hash_id varchar(40), %s, corpus_id TEXT, count INTEGER, arf INTEGER, PRIMARY KEY(hash_id))",
-			strings.Join(colDefs, ", ")))
-		if dbErr != nil {
-			return fmt.Errorf("failed to create table 'colcounts': %s", dbErr)
-		}
-		_, dbErr = database.Exec("CREATE INDEX colcounts_corpus_id_idx ON colcounts(corpus_id)")
+			"CREATE INDEX %scolcounts_corpus_id_idx ON colcounts(corpus_id)", colcountsSchema))
 		if dbErr != nil {
 			return fmt.Errorf("failed to create index colcounts_corpus_id_idx on colcounts(corpus_id): %s", dbErr)
 		}
+		for _, col := range db.RoleIndexedColCountNames(countColumns) {
+			_, dbErr = database.Exec(fmt.Sprintf(
+				"CREATE INDEX %scolcounts_%s_idx ON colcounts(%s)", colcountsSchema, col, col))
+			if dbErr != nil {
+				return fmt.Errorf("failed to create index colcounts_%s_idx on colcounts(%s): %s", col, col, dbErr)
+			}
+		}
 	}
 	return nil
 }
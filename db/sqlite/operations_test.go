@@ -26,7 +26,7 @@ import (
 
 func createDatabase() *sql.DB {
 	var err error
-	if db, err := sql.Open("sqlite3", ":memory:"); err == nil {
+	if db, err := sql.Open(DriverName, ":memory:"); err == nil {
 		return db
 	}
 	panic(err)
@@ -50,7 +50,7 @@ func containsItem(list []string, item string) bool {
 
 func TestGenerateColNames(t *testing.T) {
 	structs := createStructures()
-	cols := generateColNames(structs)
+	cols := generateColNames(structs, nil)
 	assert.True(t, containsItem(cols, "doc_id"))
 	assert.True(t, containsItem(cols, "doc_year"))
 	assert.True(t, containsItem(cols, "doc_author"))
@@ -59,6 +59,15 @@ func TestGenerateColNames(t *testing.T) {
 	assert.Equal(t, 5, len(cols))
 }
 
+func TestGenerateColNamesRenamed(t *testing.T) {
+	structs := createStructures()
+	cols := generateColNames(structs, map[string]string{"doc_year": "publication_year"})
+	assert.True(t, containsItem(cols, "publication_year"))
+	assert.False(t, containsItem(cols, "doc_year"))
+	assert.True(t, containsItem(cols, "doc_author"))
+	assert.Equal(t, 5, len(cols))
+}
+
 func TestGenerateViewColDefs(t *testing.T) {
 	viewCols := generateViewColDefs([]string{"doc_id", "doc_author"}, "doc_id")
 	assert.Contains(t, viewCols, "doc_id AS id")
@@ -69,7 +78,7 @@ func TestGenerateViewColDefs(t *testing.T) {
 func TestCreateSchema(t *testing.T) {
 	database := createDatabase()
 	structs := createStructures()
-	createSchema(database, structs, []string{}, false, db.VertColumns{{Idx: 1}})
+	createSchema(database, schemaOptions{structures: structs, countColumns: db.VertColumns{{Idx: 1}}})
 	// cid name type notnull dflt_value pk
 	res, err := database.Query("PRAGMA table_info(liveattrs_entry)")
 	if err != nil {
@@ -102,12 +111,36 @@ func TestCreateSchema(t *testing.T) {
 	assert.Equal(t, 9, len(colsSrch))
 }
 
+func TestCreateSchemaTypedColumns(t *testing.T) {
+	database := createDatabase()
+	structs := createStructures()
+	err := createSchema(database, schemaOptions{
+		structures:   structs,
+		columnNames:  map[string]string{"doc_year": "publication_year"},
+		columnTypes:  map[string]string{"doc_year": db.ColumnTypeInteger, "p_num": db.ColumnTypeFloat},
+		countColumns: db.VertColumns{{Idx: 1}},
+	})
+	assert.NoError(t, err)
+	res, err := database.Query("SELECT name, type FROM pragma_table_info('liveattrs_entry')")
+	assert.NoError(t, err)
+	defer res.Close()
+	colTypes := make(map[string]string)
+	for res.Next() {
+		var name, tp string
+		assert.NoError(t, res.Scan(&name, &tp))
+		colTypes[name] = tp
+	}
+	assert.Equal(t, "INTEGER", colTypes["publication_year"])
+	assert.Equal(t, "REAL", colTypes["p_num"])
+	assert.Equal(t, "TEXT", colTypes["doc_author"])
+}
+
 func TestDropExisdting(t *testing.T) {
 	db := createDatabase()
 	db.Exec("CREATE TABLE cache (key TEXT PRIMARY KEY, value TEXT")
 	db.Exec("CREATE TABLE liveattrs_entry (id INT PRIMARY KEY, name TEXT")
 	db.Exec("CREATE VIEW bibliography AS SELECT * FROM liveattrs_entry")
-	dropExisting(db)
+	dropExisting(db, "")
 
 	res, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table'")
 	if err != nil {
@@ -0,0 +1,70 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+const (
+	// colcountsStagingTable is a temporary table for partial n-gram
+	// counts (see db.ColcountsStager)
+	colcountsStagingTable = "temp.colcounts_staging"
+)
+
+func (w *Writer) PrepareStagingInsert(attrs []string) (db.InsertOperation, error) {
+	if w.tx == nil {
+		return nil, fmt.Errorf("cannot prepare staging insert - no transaction active")
+	}
+	colDefs := db.GenerateColCountNames(w.VertColumns)
+	for i, c := range colDefs {
+		colDefs[i] = c + " TEXT"
+	}
+	_, err := w.tx.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (hash_id varchar(40), %s, corpus_id TEXT, count INTEGER, arf INTEGER)",
+		colcountsStagingTable, strings.Join(colDefs, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table '%s': %w", colcountsStagingTable, err)
+	}
+	stmt, err := prepareInsert(w.tx, colcountsStagingTable, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return &db.Insert{Stmt: stmt}, nil
+}
+
+func (w *Writer) AggregateStaged() error {
+	if w.tx == nil {
+		return fmt.Errorf("cannot aggregate staged counts - no transaction active")
+	}
+	cols := joinArgs(db.GenerateColCountNames(w.VertColumns))
+	_, err := w.tx.Exec(fmt.Sprintf(
+		"INSERT INTO %scolcounts (%s, corpus_id, count, arf, hash_id) "+
+			"SELECT %s, corpus_id, SUM(count), MAX(arf), hash_id FROM %s GROUP BY hash_id, corpus_id, %s",
+		w.colcountsSchema(), cols, cols, colcountsStagingTable, cols))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate staged n-gram counts: %w", err)
+	}
+	_, err = w.tx.Exec("DROP TABLE " + colcountsStagingTable)
+	if err != nil {
+		return fmt.Errorf("failed to drop table '%s': %w", colcountsStagingTable, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConf configures encrypted connections
+// to a database server.
+type TLSConf struct {
+
+	// CACert is a path to a PEM encoded certificate
+	// of a CA used to verify the server. If empty,
+	// system CAs are used.
+	CACert string `json:"caCert,omitempty"`
+
+	// ClientCert and ClientKey are paths to a PEM encoded
+	// client certificate and key (for servers requiring
+	// client authentication)
+	ClientCert string `json:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty"`
+
+	// SkipVerify disables server certificate verification.
+	// Use for testing only.
+	SkipVerify bool `json:"skipVerify,omitempty"`
+}
+
+// TLSConfig creates a tls.Config for connecting to the
+// specified server.
+func (c *TLSConf) TLSConfig(serverName string) (*tls.Config, error) {
+	ans := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: c.SkipVerify,
+	}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		ans.RootCAs = x509.NewCertPool()
+		if !ans.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", c.CACert)
+		}
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		ans.Certificates = []tls.Certificate{cert}
+	}
+	return ans, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tsv provides a writer producing plain CSV or TSV files
+// (one per table, with a header row).
+package tsv
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+)
+
+type tableInsert struct {
+	file *fs.AtomicFile
+	csvw *csv.Writer
+	row  []string
+}
+
+func (ti *tableInsert) Exec(values ...any) error {
+	for i, v := range values {
+		switch tv := v.(type) {
+		case nil:
+			ti.row[i] = ""
+		case string:
+			ti.row[i] = tv
+		default:
+			ti.row[i] = fmt.Sprint(tv)
+		}
+	}
+	return ti.csvw.Write(ti.row)
+}
+
+// Writer writes each table into a separate file named
+// [corpus]_[table].[tsv|csv] located in the output directory.
+type Writer struct {
+	outDir     string
+	corpusID   string
+	separator  rune
+	suffix     string
+	useGzip    bool
+	appendMode bool
+	inserts    []*tableInsert
+}
+
+func (w *Writer) tablePath(table string) string {
+	return filepath.Join(w.outDir, fmt.Sprintf("%s_%s.%s", w.corpusID, table, w.suffix))
+}
+
+// DatabaseExists tests whether the output directory exists.
+// Please note that files of individual corpora are independent
+// so appending a new corpus creates new files.
+func (w *Writer) DatabaseExists() bool {
+	return fs.IsDir(w.outDir)
+}
+
+// Initialize creates the output directory. In the append mode,
+// rows are appended to existing files (without repeating the header).
+func (w *Writer) Initialize(appendMode bool) error {
+	w.appendMode = appendMode
+	return os.MkdirAll(w.outDir, 0755)
+}
+
+func sameColumns(cols1, cols2 []string) bool {
+	if len(cols1) != len(cols2) {
+		return false
+	}
+	for i := range cols1 {
+		if cols1[i] != cols2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readHeader reads the header row of an existing output file
+func (w *Writer) readHeader(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if w.useGzip {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	csvr := csv.NewReader(r)
+	csvr.Comma = w.separator
+	return csvr.Read()
+}
+
+func (w *Writer) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	path := w.tablePath(table)
+	existingPath := path
+	if w.useGzip {
+		existingPath += ".gz"
+	}
+	appendRows := w.appendMode && fs.IsFile(existingPath)
+	if appendRows {
+		header, err := w.readHeader(existingPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header of %s: %w", existingPath, err)
+		}
+		if !sameColumns(header, attrs) {
+			return nil, fmt.Errorf(
+				"cannot append to %s - columns %s do not match the existing ones %s",
+				existingPath, strings.Join(attrs, ", "), strings.Join(header, ", "))
+		}
+
+	} else if fs.IsFile(path) || fs.IsFile(path+".gz") {
+		log.Warn().Str("file", path).Msg("The output file already exists and will be overwritten")
+	}
+	var file *fs.AtomicFile
+	var err error
+	if appendRows {
+		file, err = fs.NewAppendingAtomicFile(path, w.useGzip)
+
+	} else {
+		file, err = fs.NewAtomicFile(path, w.useGzip)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+	}
+	ins := &tableInsert{
+		file: file,
+		csvw: csv.NewWriter(file),
+		row:  make([]string, len(attrs)),
+	}
+	ins.csvw.Comma = w.separator
+	if !appendRows {
+		if err := ins.csvw.Write(attrs); err != nil {
+			file.Discard()
+			return nil, fmt.Errorf("failed to prepare insert into %s: %w", table, err)
+		}
+	}
+	w.inserts = append(w.inserts, ins)
+	return ins, nil
+}
+
+func (w *Writer) Commit() error {
+	for _, ins := range w.inserts {
+		ins.csvw.Flush()
+		if err := ins.csvw.Error(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ins.file.Path(), err)
+		}
+		if err := ins.file.Commit(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ins.file.Path(), err)
+		}
+		log.Info().Str("file", ins.file.Path()).Msg("Written output file")
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Rollback() error {
+	for _, ins := range w.inserts {
+		if err := ins.file.Discard(); err != nil {
+			log.Warn().Err(err).Str("file", ins.file.Path()).Msg("failed to discard output file")
+		}
+	}
+	w.inserts = w.inserts[:0]
+	return nil
+}
+
+func (w *Writer) Close() {
+	if len(w.inserts) > 0 {
+		log.Warn().Msg("closing file writer with uncommitted data, discarding them")
+		w.Rollback()
+	}
+}
+
+// NewWriter creates a new writer. The dbType argument
+// determines the format ("csv" or "tsv").
+func NewWriter(conf *cnf.VTEConf, dbType string) (*Writer, error) {
+	ans := &Writer{
+		outDir:   conf.DB.Name,
+		corpusID: conf.Corpus,
+		useGzip:  conf.DB.Compress,
+	}
+	switch dbType {
+	case "csv":
+		ans.separator = ','
+		ans.suffix = "csv"
+	case "tsv":
+		ans.separator = '\t'
+		ans.suffix = "tsv"
+	default:
+		return nil, fmt.Errorf("unsupported file format %s", dbType)
+	}
+	if conf.BibView.IsConfigured() {
+		log.Warn().Msg("file writer does not support bibView, the setting will be ignored")
+	}
+	return ans, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tsv
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWriter(t *testing.T, dbType string, compress bool) *Writer {
+	w, err := NewWriter(
+		&cnf.VTEConf{Corpus: "syn", DB: db.Conf{Name: t.TempDir(), Compress: compress}}, dbType)
+	assert.NoError(t, err)
+	return w
+}
+
+func writeRows(t *testing.T, w *Writer, appendMode bool, rows ...[]any) {
+	assert.NoError(t, w.Initialize(appendMode))
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "doc_title", "poscount"})
+	assert.NoError(t, err)
+	for _, row := range rows {
+		assert.NoError(t, ins.Exec(row...))
+	}
+	assert.NoError(t, w.Commit())
+}
+
+func readRows(t *testing.T, w *Writer, path string) [][]string {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var r io.Reader = f
+	if w.useGzip {
+		gzr, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		defer gzr.Close()
+		r = gzr
+	}
+	csvr := csv.NewReader(r)
+	csvr.Comma = w.separator
+	ans, err := csvr.ReadAll()
+	assert.NoError(t, err)
+	return ans
+}
+
+func TestWriteEscapedValues(t *testing.T) {
+	for _, dbType := range []string{"tsv", "csv"} {
+		w := newTestWriter(t, dbType, false)
+		writeRows(
+			t, w, false,
+			[]any{"d1", "a \"quoted\" title, with\ttab", 10},
+			[]any{"d2", "multi\nline", nil},
+		)
+		path := filepath.Join(w.outDir, "syn_liveattrs_entry."+dbType)
+		assert.Equal(
+			t,
+			[][]string{
+				{"doc_id", "doc_title", "poscount"},
+				{"d1", "a \"quoted\" title, with\ttab", "10"},
+				{"d2", "multi\nline", ""},
+			},
+			readRows(t, w, path),
+			dbType,
+		)
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	w := newTestWriter(t, "tsv", false)
+	writeRows(t, w, false, []any{"d1", "a,b", 1})
+	data, err := os.ReadFile(filepath.Join(w.outDir, "syn_liveattrs_entry.tsv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "doc_id\tdoc_title\tposcount\nd1\ta,b\t1\n", string(data))
+
+	w = newTestWriter(t, "csv", false)
+	writeRows(t, w, false, []any{"d1", "a,b", 1})
+	data, err = os.ReadFile(filepath.Join(w.outDir, "syn_liveattrs_entry.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "doc_id,doc_title,poscount\nd1,\"a,b\",1\n", string(data))
+
+	_, err = NewWriter(&cnf.VTEConf{}, "xlsx")
+	assert.Error(t, err)
+}
+
+func TestGzipRoundTrip(t *testing.T) {
+	w := newTestWriter(t, "tsv", true)
+	writeRows(t, w, false, []any{"d1", "first\nline", 1})
+	path := filepath.Join(w.outDir, "syn_liveattrs_entry.tsv.gz")
+	assert.Equal(
+		t,
+		[][]string{{"doc_id", "doc_title", "poscount"}, {"d1", "first\nline", "1"}},
+		readRows(t, w, path),
+	)
+	writeRows(t, w, true, []any{"d2", "second", 2})
+	assert.Equal(
+		t,
+		[][]string{{"doc_id", "doc_title", "poscount"}, {"d1", "first\nline", "1"}, {"d2", "second", "2"}},
+		readRows(t, w, path),
+	)
+}
+
+func TestAppendMode(t *testing.T) {
+	w := newTestWriter(t, "tsv", false)
+	path := filepath.Join(w.outDir, "syn_liveattrs_entry.tsv")
+	writeRows(t, w, false, []any{"d1", "first", 1})
+	writeRows(t, w, true, []any{"d2", "second", 2})
+	assert.Equal(
+		t,
+		[][]string{{"doc_id", "doc_title", "poscount"}, {"d1", "first", "1"}, {"d2", "second", "2"}},
+		readRows(t, w, path),
+	)
+	writeRows(t, w, false, []any{"d3", "third", 3})
+	assert.Equal(t, [][]string{{"doc_id", "doc_title", "poscount"}, {"d3", "third", "3"}}, readRows(t, w, path))
+
+	assert.NoError(t, w.Initialize(true))
+	_, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "poscount"})
+	assert.ErrorContains(t, err, "do not match the existing ones")
+}
+
+func TestRollbackKeepsExistingFile(t *testing.T) {
+	w := newTestWriter(t, "tsv", false)
+	path := filepath.Join(w.outDir, "syn_liveattrs_entry.tsv")
+	writeRows(t, w, false, []any{"d1", "first", 1})
+	assert.NoError(t, w.Initialize(true))
+	ins, err := w.PrepareInsert("liveattrs_entry", []string{"doc_id", "doc_title", "poscount"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Exec("d2", "second", 2))
+	assert.NoError(t, w.Rollback())
+	assert.Equal(t, [][]string{{"doc_id", "doc_title", "poscount"}, {"d1", "first", "1"}}, readRows(t, w, path))
+	assert.NoFileExists(t, path+".tmp")
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// minInferredVarcharSize is the smallest VARCHAR size
+	// used for inferred string columns
+	minInferredVarcharSize = 16
+)
+
+// ColumnValueStats collects information about (non-empty) values
+// of a structural attribute allowing to infer a type of its column
+type ColumnValueStats struct {
+	NumValues   int
+	MaxLength   int
+	numIntegers int
+	numFloats   int
+	numDates    int
+	numBooleans int
+}
+
+// hasLeadingZeros tests whether a numeric value starts with zeros
+// (e.g. 00123). Such values are typically identifiers which would
+// be damaged by a conversion to a number.
+func hasLeadingZeros(v string) bool {
+	digits := strings.TrimLeft(v, "+-")
+	return len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9'
+}
+
+// Add records a value of the attribute. Empty values are ignored.
+func (s *ColumnValueStats) Add(v string) {
+	if v == "" {
+		return
+	}
+	s.NumValues++
+	if n := utf8.RuneCountInString(v); n > s.MaxLength {
+		s.MaxLength = n
+	}
+	if !hasLeadingZeros(v) {
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			s.numIntegers++
+		}
+		if fv, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(fv) && !math.IsInf(fv, 0) {
+			s.numFloats++
+		}
+	}
+	if _, err := time.Parse(DateFormat, v); err == nil {
+		s.numDates++
+	}
+	if lv := strings.ToLower(v); lv == "true" || lv == "false" {
+		s.numBooleans++
+	}
+}
+
+// Merge adds statistics of other values to s
+func (s *ColumnValueStats) Merge(other *ColumnValueStats) {
+	s.NumValues += other.NumValues
+	if other.MaxLength > s.MaxLength {
+		s.MaxLength = other.MaxLength
+	}
+	s.numIntegers += other.numIntegers
+	s.numFloats += other.numFloats
+	s.numDates += other.numDates
+	s.numBooleans += other.numBooleans
+}
+
+// InferredColumn describes a liveattrs_entry column
+// type inferred from values found in a vertical
+type InferredColumn struct {
+
+	// Type is one of ColumnTypeString, ColumnTypeInteger etc.
+	Type string `json:"type"`
+
+	// VarcharSize is a suggested size of a string column
+	// (zero for other types)
+	VarcharSize int `json:"varcharSize,omitempty"`
+
+	// NumValues is number of found non-empty values
+	NumValues int `json:"numValues"`
+
+	// MaxLength is the maximum length (in characters) of found values
+	MaxLength int `json:"maxLength"`
+}
+
+// Infer returns the narrowest column type all the recorded values
+// can be converted to. Attributes without values are strings.
+func (s *ColumnValueStats) Infer() InferredColumn {
+	if s.NumValues == 0 {
+		return s.InferString()
+	}
+	ans := InferredColumn{NumValues: s.NumValues, MaxLength: s.MaxLength}
+	switch {
+	case s.numIntegers == s.NumValues:
+		ans.Type = ColumnTypeInteger
+	case s.numFloats == s.NumValues:
+		ans.Type = ColumnTypeFloat
+	case s.numDates == s.NumValues:
+		ans.Type = ColumnTypeDate
+	case s.numBooleans == s.NumValues:
+		ans.Type = ColumnTypeBoolean
+	default:
+		return s.InferString()
+	}
+	return ans
+}
+
+// InferString returns a string column suitable for the recorded values.
+// Its size is rounded up to a power of two and it is limited
+// by DfltLAVarcharSize.
+func (s *ColumnValueStats) InferString() InferredColumn {
+	ans := InferredColumn{
+		Type:        ColumnTypeString,
+		VarcharSize: minInferredVarcharSize,
+		NumValues:   s.NumValues,
+		MaxLength:   s.MaxLength,
+	}
+	for ans.VarcharSize < s.MaxLength {
+		ans.VarcharSize *= 2
+	}
+	if ans.VarcharSize > DfltLAVarcharSize {
+		ans.VarcharSize = DfltLAVarcharSize
+	}
+	return ans
+}
+
+// VarcharSize returns a size of the VARCHAR column storing values
+// of a structural attribute. The columnSizes maps [struct]_[attr]
+// to a size, attributes without a size use DfltLAVarcharSize.
+func VarcharSize(structName, attr string, columnSizes map[string]int) int {
+	if v, ok := columnSizes[structName+"_"+attr]; ok && v > 0 {
+		return v
+	}
+	return DfltLAVarcharSize
+}
+
+// OutputColumnSizes maps liveattrs_entry columns (see StructAttrColumn)
+// of structural attributes to sizes of their VARCHAR columns
+func OutputColumnSizes(
+	structures map[string][]string,
+	columnNames map[string]string,
+	columnSizes map[string]int,
+) map[string]int {
+	ans := make(map[string]int)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			ans[StructAttrColumn(st, attr, columnNames)] = VarcharSize(st, attr, columnSizes)
+		}
+	}
+	return ans
+}
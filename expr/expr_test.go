@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	attrs := map[string]any{"doc_year": "1987", "doc_title": "Foo Bar", "doc_lang": "cs", "doc_pages": 12}
+	for src, expected := range map[string]string{
+		"decade(doc_year)":                                  "1980",
+		"bucket(doc_year, 25)":                              "1975",
+		"decade(doc_missing)":                               "",
+		"doc_lang + '_' + lower(doc_title)":                 "cs_foo bar",
+		"concat(doc_lang, \"/\", doc_year)":                 "cs/1987",
+		"substr(doc_title, 0, 3)":                           "Foo",
+		"substr(doc_title, -3)":                             "Bar",
+		"if(doc_lang == 'cs', 'Czech', 'other')":            "Czech",
+		"if(doc_year < 1900 || doc_lang == 'en', 'a', 'b')": "b",
+		"doc_pages > 9":                                     "true",
+		"!(doc_pages > 9) && doc_lang != ''":                "false",
+		"coalesce(doc_author, doc_lang)":                    "cs",
+		"len(doc_title)":                                    "7",
+		"startsWith(doc_title, 'Foo')":                      "true",
+		"replace(doc_title, ' ', '_')":                      "Foo_Bar",
+		"'it\\'s'":                                          "it's",
+	} {
+		e, err := Parse(src)
+		if assert.NoError(t, err, src) {
+			v, err := e.Eval(attrs)
+			assert.NoError(t, err, src)
+			assert.Equal(t, expected, v, src)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"decade(",
+		"unknown(doc_year)",
+		"substr(doc_title)",
+		"doc_year +",
+		"'abc",
+		"doc_year ; 1",
+		"doc_year doc_lang",
+	} {
+		_, err := Parse(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	e, err := Parse("decade(doc_title)")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"doc_title"}, e.Attrs())
+	_, err = e.Eval(map[string]any{"doc_title": "abc"})
+	assert.ErrorContains(t, err, "invalid number")
+}
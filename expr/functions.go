@@ -0,0 +1,275 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	valTrue  = "true"
+	valFalse = "false"
+)
+
+type node interface {
+	eval(attrs map[string]any) (string, error)
+}
+
+type literalNode string
+
+func (n literalNode) eval(attrs map[string]any) (string, error) {
+	return string(n), nil
+}
+
+type attrNode string
+
+func (n attrNode) eval(attrs map[string]any) (string, error) {
+	switch tv := attrs[string(n)].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return tv, nil
+	default:
+		return fmt.Sprint(tv), nil
+	}
+}
+
+type notNode struct {
+	arg node
+}
+
+func (n *notNode) eval(attrs map[string]any) (string, error) {
+	v, err := n.arg.eval(attrs)
+	if err != nil {
+		return "", err
+	}
+	return boolValue(!isTrue(v)), nil
+}
+
+type binaryNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *binaryNode) eval(attrs map[string]any) (string, error) {
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return "", err
+	}
+	switch n.op {
+	case "&&":
+		if !isTrue(left) {
+			return valFalse, nil
+		}
+	case "||":
+		if isTrue(left) {
+			return valTrue, nil
+		}
+	}
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return "", err
+	}
+	switch n.op {
+	case "+":
+		return left + right, nil
+	case "&&", "||":
+		return boolValue(isTrue(right)), nil
+	}
+	cmp := strings.Compare(left, right)
+	lf, err1 := strconv.ParseFloat(left, 64)
+	rf, err2 := strconv.ParseFloat(right, 64)
+	if err1 == nil && err2 == nil {
+		cmp = 0
+		if lf < rf {
+			cmp = -1
+
+		} else if lf > rf {
+			cmp = 1
+		}
+	}
+	switch n.op {
+	case "==":
+		return boolValue(cmp == 0), nil
+	case "!=":
+		return boolValue(cmp != 0), nil
+	case "<":
+		return boolValue(cmp < 0), nil
+	case "<=":
+		return boolValue(cmp <= 0), nil
+	case ">":
+		return boolValue(cmp > 0), nil
+	default: // ">="
+		return boolValue(cmp >= 0), nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   func(args []string) (string, error)
+	args []node
+}
+
+func (n *callNode) eval(attrs map[string]any) (string, error) {
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		var err error
+		args[i], err = arg.eval(attrs)
+		if err != nil {
+			return "", err
+		}
+	}
+	ans, err := n.fn(args)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", n.name, err)
+	}
+	return ans, nil
+}
+
+// ---
+
+// isTrue tests whether a value is considered true. Empty
+// strings, "0" and "false" are false, anything else is true.
+func isTrue(v string) bool {
+	return v != "" && v != "0" && v != valFalse
+}
+
+func boolValue(b bool) string {
+	if b {
+		return valTrue
+	}
+	return valFalse
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func parseNumber(v string) (float64, error) {
+	ans, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", v)
+	}
+	return ans, nil
+}
+
+// bucket rounds a number down to a multiple of size.
+// Empty values are kept empty.
+func bucket(v string, size float64) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	num, err := parseNumber(v)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(math.Floor(num/size) * size), nil
+}
+
+type function struct {
+	minArgs int
+	maxArgs int // -1 means unlimited
+	eval    func(args []string) (string, error)
+}
+
+var functions = map[string]function{
+	"concat": {1, -1, func(args []string) (string, error) {
+		return strings.Join(args, ""), nil
+	}},
+	"substr": {2, 3, func(args []string) (string, error) {
+		runes := []rune(args[0])
+		start, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid start '%s'", args[1])
+		}
+		if start < 0 {
+			start += len(runes)
+		}
+		if start < 0 {
+			start = 0
+
+		} else if start > len(runes) {
+			start = len(runes)
+		}
+		end := len(runes)
+		if len(args) == 3 {
+			length, err := strconv.Atoi(args[2])
+			if err != nil || length < 0 {
+				return "", fmt.Errorf("invalid length '%s'", args[2])
+			}
+			if start+length < end {
+				end = start + length
+			}
+		}
+		return string(runes[start:end]), nil
+	}},
+	"lower": {1, 1, func(args []string) (string, error) {
+		return strings.ToLower(args[0]), nil
+	}},
+	"upper": {1, 1, func(args []string) (string, error) {
+		return strings.ToUpper(args[0]), nil
+	}},
+	"trim": {1, 1, func(args []string) (string, error) {
+		return strings.TrimSpace(args[0]), nil
+	}},
+	"len": {1, 1, func(args []string) (string, error) {
+		return strconv.Itoa(len([]rune(args[0]))), nil
+	}},
+	"replace": {3, 3, func(args []string) (string, error) {
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}},
+	"contains": {2, 2, func(args []string) (string, error) {
+		return boolValue(strings.Contains(args[0], args[1])), nil
+	}},
+	"startsWith": {2, 2, func(args []string) (string, error) {
+		return boolValue(strings.HasPrefix(args[0], args[1])), nil
+	}},
+	"endsWith": {2, 2, func(args []string) (string, error) {
+		return boolValue(strings.HasSuffix(args[0], args[1])), nil
+	}},
+	"if": {2, 3, func(args []string) (string, error) {
+		if isTrue(args[0]) {
+			return args[1], nil
+		}
+		if len(args) == 3 {
+			return args[2], nil
+		}
+		return "", nil
+	}},
+	"coalesce": {1, -1, func(args []string) (string, error) {
+		for _, arg := range args {
+			if arg != "" {
+				return arg, nil
+			}
+		}
+		return "", nil
+	}},
+	"decade": {1, 1, func(args []string) (string, error) {
+		return bucket(args[0], 10)
+	}},
+	"bucket": {2, 2, func(args []string) (string, error) {
+		size, err := parseNumber(args[1])
+		if err != nil || size <= 0 {
+			return "", fmt.Errorf("invalid bucket size '%s'", args[1])
+		}
+		return bucket(args[0], size)
+	}},
+}
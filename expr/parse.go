@@ -0,0 +1,343 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expr implements a small expression language used to compute
+// structural attributes from other attributes (e.g. "decade(doc_year)"
+// or "if(doc_lang == 'cs', 'Czech', 'other')").
+//
+// Supported are string and number literals, attribute names, function
+// calls, the concatenation operator +, comparisons (==, !=, <, <=, >, >=;
+// numeric in case both operands are numbers), logical operators (&&, ||, !)
+// and parentheses. All the attribute values are handled as strings,
+// missing attributes are treated as empty strings.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func tokenize(src string) ([]token, error) {
+	ans := make([]token, 0, 16)
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			ans = append(ans, token{typ: tokIdent, val: string(runes[start:i]), pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			ans = append(ans, token{typ: tokNumber, val: string(runes[start:i]), pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			var buff strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != c; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				buff.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			ans = append(ans, token{typ: tokString, val: buff.String(), pos: start})
+		default:
+			op := string(c)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			switch op {
+			case "(", ")", ",", "+", "-", "<", ">", "!", "==", "!=", "<=", ">=", "&&", "||":
+			default:
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+			}
+			ans = append(ans, token{typ: tokOperator, val: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+	return append(ans, token{typ: tokEOF, pos: len(runes)}), nil
+}
+
+// ---
+
+type parser struct {
+	tokens []token
+	pos    int
+	attrs  map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	ans := p.tokens[p.pos]
+	if ans.typ != tokEOF {
+		p.pos++
+	}
+	return ans
+}
+
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	tk := p.peek()
+	if tk.typ != tokOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tk.val == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		return fmt.Errorf("expected '%s' at position %d", op, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseCmp()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseCmp()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseCmp() (node, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOp("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseConcat() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("+"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "+", left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.acceptOp("!"); ok {
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{arg: arg}, nil
+	}
+	if _, ok := p.acceptOp("-"); ok {
+		tk := p.next()
+		if tk.typ != tokNumber {
+			return nil, fmt.Errorf("expected a number at position %d", tk.pos)
+		}
+		tk.val = "-" + tk.val
+		return p.numberLiteral(tk)
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tk := p.next()
+	switch tk.typ {
+	case tokString:
+		return literalNode(tk.val), nil
+	case tokNumber:
+		return p.numberLiteral(tk)
+	case tokIdent:
+		if _, ok := p.acceptOp("("); ok {
+			return p.parseCall(tk)
+		}
+		p.attrs[tk.val] = true
+		return attrNode(tk.val), nil
+	case tokOperator:
+		if tk.val == "(" {
+			ans, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return ans, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d", tk.val, tk.pos)
+}
+
+func (p *parser) numberLiteral(tk token) (node, error) {
+	if _, err := strconv.ParseFloat(tk.val, 64); err != nil {
+		return nil, fmt.Errorf("invalid number '%s' at position %d", tk.val, tk.pos)
+	}
+	return literalNode(tk.val), nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.val]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name.val, name.pos)
+	}
+	args := make([]node, 0, 3)
+	if _, ok := p.acceptOp(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOp(")"); ok {
+				break
+			}
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("invalid number of arguments of %s: %d", name.val, len(args))
+	}
+	return &callNode{name: name.val, fn: fn.eval, args: args}, nil
+}
+
+// ---
+
+// Expr is a parsed expression. It can be used by multiple goroutines.
+type Expr struct {
+	src   string
+	root  node
+	attrs []string
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression '%s': %w", src, err)
+	}
+	p := &parser{tokens: tokens, attrs: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err == nil && p.peek().typ != tokEOF {
+		err = fmt.Errorf("unexpected '%s' at position %d", p.peek().val, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression '%s': %w", src, err)
+	}
+	ans := &Expr{src: src, root: root, attrs: make([]string, 0, len(p.attrs))}
+	for attr := range p.attrs {
+		ans.attrs = append(ans.attrs, attr)
+	}
+	return ans, nil
+}
+
+// Attrs returns names of all the attributes the expression refers to
+func (e *Expr) Attrs() []string {
+	return e.attrs
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression using the provided attribute values
+func (e *Expr) Eval(attrs map[string]any) (string, error) {
+	ans, err := e.root.eval(attrs)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate expression '%s': %w", e.src, err)
+	}
+	return ans, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// AtomicFile is a buffered output file which is written
+// to a temporary location and moved to its final path
+// only once Commit is called. This ensures that consumers
+// never see a half-written file. Optionally, the data
+// can be gzip-compressed.
+type AtomicFile struct {
+	path   string
+	file   *os.File
+	gzw    *gzip.Writer
+	buffer *bufio.Writer
+}
+
+func (af *AtomicFile) tmpPath() string {
+	return af.path + ".tmp"
+}
+
+// Path returns the final path of the file
+func (af *AtomicFile) Path() string {
+	return af.path
+}
+
+func (af *AtomicFile) Write(p []byte) (int, error) {
+	return af.buffer.Write(p)
+}
+
+// Commit flushes all the data and moves the file
+// to its final location.
+func (af *AtomicFile) Commit() error {
+	if err := af.buffer.Flush(); err != nil {
+		return err
+	}
+	if af.gzw != nil {
+		if err := af.gzw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := af.file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(af.tmpPath(), af.path)
+}
+
+// Discard closes and removes the temporary file.
+func (af *AtomicFile) Discard() error {
+	af.file.Close()
+	return os.Remove(af.tmpPath())
+}
+
+// NewAtomicFile creates a new AtomicFile. In case useGzip
+// is true, the data are compressed and the ".gz" suffix
+// is added to the path.
+func NewAtomicFile(path string, useGzip bool) (*AtomicFile, error) {
+	return newAtomicFile(path, useGzip, false)
+}
+
+// NewAppendingAtomicFile creates a new AtomicFile starting with
+// contents of an existing file (if any) so the written data are
+// appended to it once committed. Compressed data are written
+// as a new gzip member (concatenated gzip members form a valid
+// gzip file).
+func NewAppendingAtomicFile(path string, useGzip bool) (*AtomicFile, error) {
+	return newAtomicFile(path, useGzip, true)
+}
+
+func newAtomicFile(path string, useGzip, appendData bool) (*AtomicFile, error) {
+	if useGzip {
+		path += ".gz"
+	}
+	ans := &AtomicFile{path: path}
+	var err error
+	ans.file, err = os.Create(ans.tmpPath())
+	if err != nil {
+		return nil, err
+	}
+	if appendData && IsFile(path) {
+		if err := ans.copyFrom(path); err != nil {
+			ans.Discard()
+			return nil, err
+		}
+	}
+	var w io.Writer = ans.file
+	if useGzip {
+		ans.gzw = gzip.NewWriter(ans.file)
+		w = ans.gzw
+	}
+	ans.buffer = bufio.NewWriter(w)
+	return ans, nil
+}
+
+// copyFrom copies contents of a file to the temporary file
+func (af *AtomicFile) copyFrom(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(af.file, src)
+	return err
+}
@@ -17,9 +17,12 @@
 package fs
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // IsDir tests whether a provided path represents
@@ -88,6 +91,36 @@ func ListFilesInDir(path string) ([]string, error) {
 	return ans, nil
 }
 
+// ExpandGlobs replaces glob patterns (see filepath.Match) in the
+// provided list of paths by matching files (sorted by name). Paths
+// without any special characters are kept as they are. It is an
+// error if a pattern does not match any file.
+func ExpandGlobs(paths []string) ([]string, error) {
+	ans := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !HasGlobMeta(path) {
+			ans = append(ans, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return []string{}, fmt.Errorf("invalid pattern %s: %w", path, err)
+		}
+		if len(matches) == 0 {
+			return []string{}, fmt.Errorf("pattern %s does not match any file", path)
+		}
+		sort.Strings(matches)
+		ans = append(ans, matches...)
+	}
+	return ans, nil
+}
+
+// HasGlobMeta tests whether a path contains any of the characters
+// recognized by filepath.Match
+func HasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
+
 // FileSize returns file size in bytes.
 // In case something is wrong, -1 is returned.
 func FileSize(path string) int64 {
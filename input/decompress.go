@@ -0,0 +1,63 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// decompress detects a compression format (gzip, bzip2, xz) of data
+// based on their first bytes and returns a decompressing reader. For
+// uncompressed data, the returned reader provides the original content.
+// The returned closer (can be nil) must be closed once the reading is done.
+func decompress(rd io.Reader) (io.Reader, io.Closer, error) {
+	brd := bufio.NewReaderSize(rd, 64*1024)
+	head, err := brd.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to detect compression: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gzr, err := gzip.NewReader(brd)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return gzr, gzr, nil
+	case bytes.HasPrefix(head, bzip2Magic):
+		return bzip2.NewReader(brd), nil, nil
+	case bytes.HasPrefix(head, xzMagic):
+		xzr, err := xz.NewReader(brd)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open xz stream: %w", err)
+		}
+		return xzr, nil, nil
+	}
+	return brd, nil, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+const testVertical = "<doc id=\"1\">\nfoo\tNN\nbar\tVB\n</doc>\n"
+
+func readAllDecompressed(t *testing.T, data []byte) string {
+	rd, closer, err := decompress(bytes.NewReader(data))
+	assert.NoError(t, err)
+	if closer != nil {
+		defer closer.Close()
+	}
+	out, err := io.ReadAll(rd)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestDecompressPlain(t *testing.T) {
+	assert.Equal(t, testVertical, readAllDecompressed(t, []byte(testVertical)))
+}
+
+func TestDecompressGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(testVertical))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, testVertical, readAllDecompressed(t, buf.Bytes()))
+}
+
+func TestDecompressXz(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(testVertical))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, testVertical, readAllDecompressed(t, buf.Bytes()))
+}
+
+func TestDecompressShortInput(t *testing.T) {
+	assert.Equal(t, "x\n", readAllDecompressed(t, []byte("x\n")))
+}
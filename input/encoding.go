@@ -0,0 +1,116 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	// EncodingAuto is a special encoding name enabling detection
+	// of the input encoding
+	EncodingAuto = "auto"
+
+	detectSampleSize = 64 * 1024
+)
+
+// DetectEncoding guesses encoding of a data sample. Valid UTF-8 data
+// are reported as "utf-8". Otherwise, a Central European single-byte
+// encoding is assumed: in case the sample contains bytes from the 0x80-0x9F
+// range (control characters in ISO-8859-2 but common letters like š, ž, ť
+// in Windows-1250), "windows-1250" is returned, otherwise "iso-8859-2".
+func DetectEncoding(sample []byte) string {
+	// the sample may end in the middle of a multi-byte character
+	for i := 0; i < utf8.UTFMax && len(sample) > 0; i++ {
+		if utf8.Valid(sample) {
+			return "utf-8"
+		}
+		if utf8.FullRune(sample[len(sample)-i-1:]) {
+			break
+		}
+		sample = sample[:len(sample)-1]
+	}
+	for _, b := range sample {
+		if b >= 0x80 && b <= 0x9f {
+			return "windows-1250"
+		}
+	}
+	return "iso-8859-2"
+}
+
+// detectEncoding reads a sample of data from rd and detects their
+// encoding. The returned reader provides the whole original data.
+func detectEncoding(rd io.Reader) (io.Reader, string) {
+	brd := bufio.NewReaderSize(rd, detectSampleSize)
+	sample, _ := brd.Peek(detectSampleSize) // for a shorter input, we get what is available
+	return brd, DetectEncoding(sample)
+}
+
+// utf8Validator passes through UTF-8 data and returns an error once
+// an invalid byte sequence is encountered. Optionally, it can also
+// reject the replacement character U+FFFD which is produced by decoders
+// of single-byte encodings for undefined bytes.
+type utf8Validator struct {
+	rd                io.Reader
+	rejectReplacement bool
+	pending           []byte
+	lineNum           int
+}
+
+func (v *utf8Validator) Read(p []byte) (int, error) {
+	if len(p) < utf8.UTFMax {
+		return 0, io.ErrShortBuffer
+	}
+	for {
+		np := copy(p, v.pending)
+		v.pending = v.pending[:0]
+		n, err := v.rd.Read(p[np:])
+		data := p[:np+n]
+		valid := 0
+		for valid < len(data) {
+			r, size := utf8.DecodeRune(data[valid:])
+			if r == utf8.RuneError && size <= 1 {
+				if !utf8.FullRune(data[valid:]) && err == nil {
+					break // an incomplete character, wait for more data
+				}
+				return 0, fmt.Errorf("invalid byte sequence at line %d (zero-based)", v.lineNum)
+
+			} else if r == utf8.RuneError && v.rejectReplacement {
+				return 0, fmt.Errorf("undefined character at line %d (zero-based)", v.lineNum)
+
+			} else if r == '\n' {
+				v.lineNum++
+			}
+			valid += size
+		}
+		v.pending = append(v.pending, data[valid:]...)
+		if valid > 0 || err != nil {
+			return valid, err
+		}
+	}
+}
+
+func newUTF8Validator(rd io.Reader, rejectReplacement bool) *utf8Validator {
+	return &utf8Validator{
+		rd:                rd,
+		rejectReplacement: rejectReplacement,
+		pending:           make([]byte, 0, utf8.UTFMax),
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readFile(t *testing.T, data []byte, encoding string) (string, error) {
+	path := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	rd, err := Open(path, encoding)
+	assert.NoError(t, err)
+	defer rd.Close()
+	ans, err := io.ReadAll(rd)
+	return string(ans), err
+}
+
+func TestDetectEncoding(t *testing.T) {
+	assert.Equal(t, "utf-8", DetectEncoding([]byte("žluťoučký kůň")))
+	// a multi-byte character cut at the end of the sample
+	assert.Equal(t, "utf-8", DetectEncoding([]byte("kůň")[:4]))
+	// "žluťoučký" in windows-1250 and iso-8859-2
+	assert.Equal(t, "windows-1250", DetectEncoding([]byte{0x9e, 'l', 'u', 0x9d, 'o', 'u', 0xe8, 'k', 0xfd}))
+	assert.Equal(t, "iso-8859-2", DetectEncoding([]byte{0xbe, 'l', 'u', 0xbb, 'o', 'u', 0xe8, 'k', 0xfd}))
+}
+
+func TestOpenAutoEncoding(t *testing.T) {
+	data, err := readFile(t, []byte{0x9e, 'l', 'u', 0x9d, 'o', 'u', 0xe8, 'k', 0xfd, '\n'}, EncodingAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, "žluťoučký\n", data)
+}
+
+func TestOpenInvalidUTF8(t *testing.T) {
+	_, err := readFile(t, []byte("foo\tbar\nb\xffaz\tx\n"), "utf-8")
+	assert.EqualError(t, err, "invalid byte sequence at line 1 (zero-based)")
+}
+
+func TestOpenUndefinedCharacter(t *testing.T) {
+	// 0x98 is not defined in windows-1250
+	_, err := readFile(t, []byte("foo\n\x98\n"), "windows-1250")
+	assert.Error(t, err)
+}
+
+func TestUTF8ValidatorSplitCharacters(t *testing.T) {
+	src := "žluťoučký kůň\n"
+	data, err := io.ReadAll(newUTF8Validator(iotest.OneByteReader(strings.NewReader(src)), false))
+	assert.NoError(t, err)
+	assert.Equal(t, src, string(data))
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// The parsing rules below follow the ones used by vertigo
+// (with the "nil" structural attribute accumulator) so both
+// produce the same results. Vertigo (v5) exports neither its
+// line parser nor a parser of an io.Reader so the rules cannot
+// be reused directly. The equivalence is checked by
+// TestParseMatchesVertigo - any change of the rules should
+// go to vertigo first.
+
+var (
+	tagSrchRegexp   = regexp.MustCompile(`^<([\w\d\p{Po}]+)(\s+.*?|)>$`)
+	tagSrchRegexpSC = regexp.MustCompile(`^<([\w\d\p{Po}]+)(\s+.*?|)/>$`)
+	attrValRegexp   = regexp.MustCompile(`(\w+)="([^"]+)"`)
+	closeTagRegexp  = regexp.MustCompile(`</([^>]+)\s*>`)
+)
+
+func parseAttrVal(src string) map[string]string {
+	ans := make(map[string]string)
+	srch := attrValRegexp.FindAllStringSubmatch(src, -1)
+	for i := 0; i < len(srch); i++ {
+		ans[srch[i][1]] = srch[i][2]
+	}
+	return ans
+}
+
+const (
+	// DfltColumnSeparator separates positional attributes
+	// in a standard vertical file
+	DfltColumnSeparator = "\t"
+)
+
+var (
+	defaultLineParser = &LineParser{separator: DfltColumnSeparator}
+)
+
+// LineParser parses lines of a vertical file with configurable
+// separator of positional attributes (columns)
+type LineParser struct {
+	separator       string
+	separatorRegexp *regexp.Regexp
+}
+
+func (lp *LineParser) splitColumns(line string) []string {
+	if lp.separatorRegexp != nil {
+		return lp.separatorRegexp.Split(line, -1)
+	}
+	return strings.Split(line, lp.separator)
+}
+
+// NewLineParser creates a parser with columns separated either by
+// a separator string or by a regular expression (only one of them can
+// be set). In case none is set, the tab character is used.
+func NewLineParser(separator, separatorRegexp string) (*LineParser, error) {
+	if separator != "" && separatorRegexp != "" {
+		return nil, fmt.Errorf("column separator and separator regexp cannot be used at the same time")
+	}
+	if separatorRegexp != "" {
+		rx, err := regexp.Compile(separatorRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid column separator regexp: %w", err)
+		}
+		return &LineParser{separatorRegexp: rx}, nil
+	}
+	if separator == "" {
+		separator = DfltColumnSeparator
+	}
+	return &LineParser{separator: separator}, nil
+}
+
+// ParseLine parses a single line of a standard (tab-separated) vertical
+// file. See LineParser.Parse for details.
+func ParseLine(line string) (any, error) {
+	return defaultLineParser.Parse(line)
+}
+
+// Parse parses a single line of a vertical file. The returned value
+// is one of *vertigo.Token, *vertigo.Structure, *vertigo.StructureClose.
+// Please note that token indices (Idx) are not set.
+func (lp *LineParser) Parse(line string) (any, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") {
+		switch {
+		case strings.HasPrefix(line, "</"):
+			srch := closeTagRegexp.FindStringSubmatch(line)
+			if len(srch) < 2 {
+				return nil, fmt.Errorf("cannot parse close element '%s'", line)
+			}
+			return &vertigo.StructureClose{Name: srch[1]}, nil
+		case strings.HasSuffix(line, "/>"):
+			srch := tagSrchRegexpSC.FindStringSubmatch(line)
+			if len(srch) < 3 {
+				return nil, fmt.Errorf("cannot parse self closing element '%s'", line)
+			}
+			return &vertigo.Structure{Name: srch[1], Attrs: parseAttrVal(srch[2]), IsEmpty: true}, nil
+		default:
+			srch := tagSrchRegexp.FindStringSubmatch(line)
+			if len(srch) < 3 {
+				return nil, fmt.Errorf("cannot parse open element '%s'", line)
+			}
+			return &vertigo.Structure{Name: srch[1], Attrs: parseAttrVal(srch[2])}, nil
+		}
+	}
+	items := lp.splitColumns(line)
+	return &vertigo.Token{
+		Word:        items[0],
+		Attrs:       items[1:],
+		StructAttrs: map[string]string{},
+	}, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestLineParserSeparator(t *testing.T) {
+	lp, err := NewLineParser("|", "")
+	assert.NoError(t, err)
+	v, err := lp.Parse("cats|cat|NN")
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", v.(*vertigo.Token).Word)
+	assert.Equal(t, []string{"cat", "NN"}, v.(*vertigo.Token).Attrs)
+}
+
+func TestLineParserSeparatorRegexp(t *testing.T) {
+	lp, err := NewLineParser("", `\s{2,}`)
+	assert.NoError(t, err)
+	v, err := lp.Parse("New York   New York  NNP")
+	assert.NoError(t, err)
+	assert.Equal(t, "New York", v.(*vertigo.Token).Word)
+	assert.Equal(t, []string{"New York", "NNP"}, v.(*vertigo.Token).Attrs)
+	// structures are not affected
+	v, err = lp.Parse("<doc id=\"d1\"  title=\"x\">")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"id": "d1", "title": "x"}, v.(*vertigo.Structure).Attrs)
+}
+
+func TestLineParserBothSeparators(t *testing.T) {
+	_, err := NewLineParser(" ", ` +`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package input provides access to vertical files independent
+// of the vertigo parser so the data can be read and parsed
+// in a custom way (e.g. in parallel).
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tomachalek/vertigo/v5"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+var (
+	cmdSplit = regexp.MustCompile(`\s+`)
+)
+
+// multiCloser closes all the wrapped closers in
+// the reverse order (i.e. the outer reader first)
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (mc *multiCloser) Close() error {
+	var ans error
+	for i := len(mc.closers) - 1; i >= 0; i-- {
+		if err := mc.closers[i].Close(); err != nil && ans == nil {
+			ans = err
+		}
+	}
+	return ans
+}
+
+// cmdReader reads an output of a running command
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (cr *cmdReader) Close() error {
+	cr.ReadCloser.Close()
+	return cr.cmd.Wait()
+}
+
+func openCommand(spec string, progress *ReadProgress) (io.ReadCloser, error) {
+	script := cmdSplit.Split(strings.TrimSpace(spec[1:]), -1)
+	if len(script) < 1 || script[0] == "" {
+		return nil, fmt.Errorf("invalid dynamically generated vertical file specification")
+	}
+	cmd := exec.Command(script[0], script[1:]...)
+	cmd.Env = os.Environ()
+	rd, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return progress.wrap(&cmdReader{ReadCloser: rd, cmd: cmd}, -1), nil
+}
+
+func openFile(path string, progress *ReadProgress) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	finfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !finfo.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("path %s is not a regular file", path)
+	}
+	return withDecompression(progress.wrap(f, finfo.Size()))
+}
+
+// withDecompression wraps a raw data source by a decompressing
+// reader (if needed). In case of an error, the source is closed.
+func withDecompression(src io.ReadCloser) (io.ReadCloser, error) {
+	rd, closer, err := decompress(src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	closers := []io.Closer{src}
+	if closer != nil {
+		closers = append(closers, closer)
+	}
+	return &multiCloser{Reader: rd, closers: closers}, nil
+}
+
+// Open opens a vertical file the same way vertigo does - i.e. the
+// path can be either a path to a file or a command producing the vertical
+// to its standard output ("|command arg1 arg2..."). Remote verticals
+// (http://, https://, s3://) are streamed. Files compressed
+// by gzip, bzip2 or xz are detected (based on their content, not
+// the suffix) and decompressed on the fly. In case the encoding
+// is not utf-8, the data are converted. With EncodingAuto, the encoding
+// is detected (see DetectEncoding). Reading of data with invalid byte
+// sequences (for the encoding) fails with an error.
+func Open(path string, encoding string) (io.ReadCloser, error) {
+	return OpenWithProgress(context.Background(), path, encoding, nil)
+}
+
+// OpenWithProgress is a variant of Open tracking number of read
+// bytes via the progress argument (which can be nil). Fetching
+// of a remote vertical is bound to ctx.
+func OpenWithProgress(
+	ctx context.Context,
+	path string,
+	encoding string,
+	progress *ReadProgress,
+) (io.ReadCloser, error) {
+	var chm *charmap.Charmap
+	var err error
+	if !strings.EqualFold(encoding, EncodingAuto) {
+		chm, err = vertigo.GetCharmapByName(encoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open vertical %s: %w", path, err)
+		}
+	}
+	var rd io.ReadCloser
+	if strings.HasPrefix(path, "|") {
+		rd, err = openCommand(path, progress)
+
+	} else if IsRemote(path) {
+		rd, err = openRemote(ctx, path, progress)
+
+	} else {
+		rd, err = openFile(path, progress)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vertical %s: %w", path, err)
+	}
+	var data io.Reader = rd
+	if strings.EqualFold(encoding, EncodingAuto) {
+		data, encoding = detectEncoding(rd)
+		log.Info().Str("file", path).Str("encoding", encoding).Msg("Detected input encoding")
+		chm, err = vertigo.GetCharmapByName(encoding)
+		if err != nil {
+			rd.Close()
+			return nil, fmt.Errorf("failed to open vertical %s: %w", path, err)
+		}
+	}
+	if chm != nil {
+		data = transform.NewReader(data, chm.NewDecoder())
+	}
+	return &multiCloser{
+		Reader:  newUTF8Validator(data, chm != nil),
+		closers: []io.Closer{rd},
+	}, nil
+}
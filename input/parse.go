@@ -0,0 +1,116 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/tomachalek/vertigo/v5"
+)
+
+const (
+	dfltLogProgressEachNth = 1000000
+)
+
+// MalformedLineProcessor can be implemented by a line processor
+// to handle lines which cannot be parsed. Otherwise, such lines
+// are logged and skipped.
+type MalformedLineProcessor interface {
+	ProcMalformedLine(text string, line int, err error) error
+}
+
+// Parse is an equivalent of vertigo.ParseVerticalFile reading the vertical
+// via Open (i.e. with support for compressed and remote files). Lines are
+// parsed by ParseLine and passed to the line processor along with zero-based
+// line numbers - i.e. the same way vertigo does it. Only the "nil" structural
+// attribute accumulator is supported.
+func Parse(conf *vertigo.ParserConf, lproc vertigo.LineProcessor) error {
+	_, err := ParseFrom(context.Background(), conf, defaultLineParser, lproc, 0, nil)
+	return err
+}
+
+// ParseFrom parses a single vertical using a provided line parser
+// with token indices starting from firstToken. Number of read bytes
+// is tracked by progress (can be nil). The index of the token following the last one
+// in the file is returned so the next file can continue from there. Fetching of
+// a remote vertical is bound to ctx.
+func ParseFrom(
+	ctx context.Context,
+	conf *vertigo.ParserConf,
+	lineParser *LineParser,
+	lproc vertigo.LineProcessor,
+	firstToken int,
+	progress *ReadProgress,
+) (int, error) {
+	if conf.StructAttrAccumulator != "" && conf.StructAttrAccumulator != vertigo.AccumulatorTypeNil {
+		return firstToken, fmt.Errorf(
+			"unsupported structural attribute accumulator %s", conf.StructAttrAccumulator)
+	}
+	rd, err := OpenWithProgress(ctx, conf.InputFilePath, conf.Encoding, progress)
+	if err != nil {
+		return firstToken, err
+	}
+	defer rd.Close()
+	logProgressEachNth := dfltLogProgressEachNth
+	if conf.LogProgressEachNth > 0 {
+		logProgressEachNth = conf.LogProgressEachNth
+	}
+	sc := bufio.NewScanner(rd)
+	lineNum, tokenNum := 0, firstToken
+	for sc.Scan() {
+		value, parseErr := lineParser.Parse(sc.Text())
+		var procErr error
+		switch tv := value.(type) {
+		case *vertigo.Token:
+			tv.Idx = tokenNum
+			tokenNum++
+			if tv.MatchesFilter(conf.FilterArgs) {
+				procErr = lproc.ProcToken(tv, lineNum, parseErr)
+			}
+		case *vertigo.Structure:
+			procErr = lproc.ProcStruct(tv, lineNum, parseErr)
+		case *vertigo.StructureClose:
+			procErr = lproc.ProcStructClose(tv, lineNum, parseErr)
+		default:
+			if parseErr == nil {
+				break
+			}
+			if mlproc, ok := lproc.(MalformedLineProcessor); ok {
+				procErr = mlproc.ProcMalformedLine(sc.Text(), lineNum, parseErr)
+
+			} else {
+				log.Warn().Err(parseErr).Int("lineNumber", lineNum).Msg("skipping invalid line")
+			}
+		}
+		if procErr != nil {
+			return tokenNum, procErr
+		}
+		if lineNum > 0 && lineNum%logProgressEachNth == 0 {
+			log.Info().Int("numProcessed", lineNum).Msg("chunk of lines processed")
+		}
+		lineNum++
+	}
+	if err := sc.Err(); err != nil {
+		return tokenNum, fmt.Errorf("failed to read vertical file: %w", err)
+	}
+	log.Info().Int("numLines", lineNum).Str("file", conf.InputFilePath).Msg("Parsing done")
+	return tokenNum, nil
+}
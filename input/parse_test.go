@@ -0,0 +1,92 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+const parityVertical = "<doc id=\"d1\" title=\"A title\">\n" +
+	"<p>\n" +
+	"The\tthe\tDT\n" +
+	"cats\tcat\tNNS\n" +
+	"<g/>\n" +
+	".\t.\t.\n" +
+	"<>\n" +
+	"</p>\n" +
+	"<p n=\"2\" >\n" +
+	"  dogs\tdog\tNNS  \n" +
+	"\n" +
+	"</p >\n" +
+	"</doc>\n"
+
+// eventRecorder stores all the parsing events (incl. line numbers
+// and errors) as strings
+type eventRecorder struct {
+	events []string
+}
+
+func (rp *eventRecorder) ProcToken(tk *vertigo.Token, line int, err error) error {
+	rp.events = append(rp.events, fmt.Sprintf("%d tok %d %q %q %v", line, tk.Idx, tk.Word, tk.Attrs, err))
+	return nil
+}
+
+func (rp *eventRecorder) ProcStruct(st *vertigo.Structure, line int, err error) error {
+	rp.events = append(rp.events, fmt.Sprintf("%d struct %s %v %t %v", line, st.Name, st.Attrs, st.IsEmpty, err))
+	return nil
+}
+
+func (rp *eventRecorder) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
+	rp.events = append(rp.events, fmt.Sprintf("%d close %s %v", line, st.Name, err))
+	return nil
+}
+
+// TestParseMatchesVertigo checks that Parse (working with any source
+// supported by Open) produces the same events as vertigo.ParseVerticalFile
+func TestParseMatchesVertigo(t *testing.T) {
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "test.vert")
+	assert.NoError(t, os.WriteFile(plainPath, []byte(parityVertical), 0644))
+	gzPath := filepath.Join(dir, "test.vert.gz")
+	f, err := os.Create(gzPath)
+	assert.NoError(t, err)
+	w := gzip.NewWriter(f)
+	_, err = w.Write([]byte(parityVertical))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	for _, path := range []string{plainPath, gzPath} {
+		conf := &vertigo.ParserConf{
+			InputFilePath:         path,
+			Encoding:              vertigo.CharsetUTF_8,
+			StructAttrAccumulator: vertigo.AccumulatorTypeNil,
+		}
+		var expected, actual eventRecorder
+		assert.NoError(t, vertigo.ParseVerticalFile(conf, &expected))
+		assert.NoError(t, Parse(conf, &actual))
+		assert.NotEmpty(t, expected.events)
+		assert.Equal(t, expected.events, actual.events, path)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ReadProgress tracks how many bytes of an input file have been read.
+// For compressed files, the bytes of the compressed data are counted
+// so the values can be compared with the file size.
+type ReadProgress struct {
+	bytesRead  int64
+	totalBytes int64
+}
+
+// BytesRead returns number of bytes read so far
+func (rp *ReadProgress) BytesRead() int64 {
+	return atomic.LoadInt64(&rp.bytesRead)
+}
+
+// TotalBytes returns size of the input or -1 if the size is unknown
+// (e.g. in case of a command output)
+func (rp *ReadProgress) TotalBytes() int64 {
+	return atomic.LoadInt64(&rp.totalBytes)
+}
+
+// wrap starts tracking of a data source of a specified size.
+// It is safe to call the method on a nil ReadProgress.
+func (rp *ReadProgress) wrap(src io.ReadCloser, size int64) io.ReadCloser {
+	if rp == nil {
+		return src
+	}
+	atomic.StoreInt64(&rp.bytesRead, 0)
+	atomic.StoreInt64(&rp.totalBytes, size)
+	return &countingReader{ReadCloser: src, progress: rp}
+}
+
+// NewReadProgress creates a new ReadProgress with an unknown size
+func NewReadProgress() *ReadProgress {
+	return &ReadProgress{totalBytes: -1}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	progress *ReadProgress
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	atomic.AddInt64(&cr.progress.bytesRead, int64(n))
+	return n, err
+}
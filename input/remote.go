@@ -0,0 +1,146 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	dfltS3Region = "us-east-1"
+
+	// emptyPayloadHash is a SHA256 hash of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// remoteClient is used to fetch remote verticals. There is no
+// overall timeout as reading of a large vertical may take hours.
+// Only establishing a connection and waiting for a response
+// are limited (the rest is controlled by the context of the run).
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// IsRemote tests whether a path refers to a remote vertical
+// (http://, https:// or s3://)
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") ||
+		strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "s3://")
+}
+
+func openRemote(ctx context.Context, path string, progress *ReadProgress) (io.ReadCloser, error) {
+	var req *http.Request
+	var err error
+	if strings.HasPrefix(path, "s3://") {
+		req, err = newS3Request(ctx, path, time.Now().UTC())
+
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	// ContentLength is -1 if unknown
+	return withDecompression(progress.wrap(resp.Body, resp.ContentLength))
+}
+
+// newS3Request creates a GET request for an object specified by
+// an "s3://bucket/key" URL. The configuration is taken from the
+// standard AWS environment variables (AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION). For
+// S3-compatible storages, AWS_ENDPOINT_URL can be set (path-style
+// addressing is used then). Without credentials, the request is
+// not signed (i.e. only public objects are accessible).
+func newS3Request(ctx context.Context, path string, now time.Time) (*http.Request, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URL: %w", err)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s, expected s3://bucket/key", path)
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = dfltS3Region
+	}
+	var objURL string
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		objURL = fmt.Sprintf("%s/%s/%s", strings.TrimRight(endpoint, "/"), bucket, key)
+
+	} else {
+		objURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	creds := aws.Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		if err := signS3Request(ctx, req, creds, region, now); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// signS3Request signs a bodyless request using the AWS Signature
+// Version 4 scheme. S3 expects the payload hash to be passed also
+// as a header and the path not to be escaped twice.
+func signS3Request(ctx context.Context, req *http.Request, creds aws.Credentials, region string, now time.Time) error {
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	signer := v4.NewSigner(func(opts *v4.SignerOptions) {
+		opts.DisableURIPathEscaping = true
+	})
+	if err := signer.SignHTTP(ctx, creds, req, emptyPayloadHash, "s3", region, now); err != nil {
+		return fmt.Errorf("failed to sign S3 request: %w", err)
+	}
+	return nil
+}
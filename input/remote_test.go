@@ -0,0 +1,91 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenHTTPCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(testVertical))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	rd, err := Open(srv.URL+"/corpus.vert.gz", "")
+	assert.NoError(t, err)
+	defer rd.Close()
+	data, err := io.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.Equal(t, testVertical, string(data))
+}
+
+func TestOpenHTTPNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := Open(srv.URL+"/missing.vert", "")
+	assert.Error(t, err)
+}
+
+func TestOpenS3CustomEndpoint(t *testing.T) {
+	var reqPath, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		reqPath = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		rw.Write([]byte(testVertical))
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	rd, err := Open("s3://verticals/syn2020/part1.vert", "")
+	assert.NoError(t, err)
+	defer rd.Close()
+	data, err := io.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.Equal(t, testVertical, string(data))
+	assert.Equal(t, "/verticals/syn2020/part1.vert", reqPath)
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, auth, "/eu-central-1/s3/aws4_request")
+}
+
+func TestOpenHTTPCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(testVertical))
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := OpenWithProgress(ctx, srv.URL+"/corpus.vert", "", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
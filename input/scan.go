@@ -0,0 +1,151 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tomachalek/vertigo/v5"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// VerticalSummary describes a vertical file based
+// on its scanned part
+type VerticalSummary struct {
+
+	// Structures contains all the found structures and
+	// their attributes (sorted alphabetically)
+	Structures map[string][]string
+
+	// StructCounts contains numbers of occurrences of structures
+	StructCounts map[string]int
+
+	// AttrValues contains statistics of values of structural
+	// attributes (in the [struct]_[attr] form) allowing inference
+	// of column types (see db.ColumnValueStats)
+	AttrValues map[string]*db.ColumnValueStats
+
+	// RootStructure is the most frequent top-level structure
+	// (typically a document)
+	RootStructure string
+
+	// NumColumns is the maximum number of positional
+	// attributes (including the word) of tokens
+	NumColumns int
+
+	NumTokens int
+
+	// Encoding is the detected encoding of the file
+	Encoding string
+}
+
+func detectFileEncoding(path string) (string, error) {
+	rd, err := openFile(path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open vertical %s: %w", path, err)
+	}
+	defer rd.Close()
+	_, encoding := detectEncoding(rd)
+	return encoding, nil
+}
+
+// ScanVertical reads up to maxBytes (of decoded data) of a local
+// vertical file and collects information about its structures and
+// positional attributes. The encoding of the file is detected.
+// It is intended to help with writing configuration for unknown corpora.
+func ScanVertical(path string, maxBytes int64) (*VerticalSummary, error) {
+	encoding, err := detectFileEncoding(path)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := Open(path, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	ans := &VerticalSummary{
+		Structures:   make(map[string][]string),
+		StructCounts: make(map[string]int),
+		AttrValues:   make(map[string]*db.ColumnValueStats),
+		Encoding:     encoding,
+	}
+	structAttrs := make(map[string]map[string]bool)
+	rootCounts := make(map[string]int)
+	openStructs := make([]string, 0, 10)
+	sc := bufio.NewScanner(io.LimitReader(rd, maxBytes))
+	for sc.Scan() {
+		value, err := defaultLineParser.Parse(sc.Text())
+		if err != nil {
+			continue
+		}
+		switch tv := value.(type) {
+		case *vertigo.Token:
+			ans.NumTokens++
+			if len(tv.Attrs)+1 > ans.NumColumns {
+				ans.NumColumns = len(tv.Attrs) + 1
+			}
+		case *vertigo.Structure:
+			ans.StructCounts[tv.Name]++
+			if _, ok := structAttrs[tv.Name]; !ok {
+				structAttrs[tv.Name] = make(map[string]bool)
+			}
+			for attr, v := range tv.Attrs {
+				structAttrs[tv.Name][attr] = true
+				key := tv.Name + "_" + attr
+				if _, ok := ans.AttrValues[key]; !ok {
+					ans.AttrValues[key] = &db.ColumnValueStats{}
+				}
+				ans.AttrValues[key].Add(v)
+			}
+			if len(openStructs) == 0 {
+				rootCounts[tv.Name]++
+			}
+			if !tv.IsEmpty {
+				openStructs = append(openStructs, tv.Name)
+			}
+		case *vertigo.StructureClose:
+			for i := len(openStructs) - 1; i >= 0; i-- {
+				if openStructs[i] == tv.Name {
+					openStructs = openStructs[:i]
+					break
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan vertical file: %w", err)
+	}
+	for name, attrs := range structAttrs {
+		ans.Structures[name] = make([]string, 0, len(attrs))
+		for attr := range attrs {
+			ans.Structures[name] = append(ans.Structures[name], attr)
+		}
+		sort.Strings(ans.Structures[name])
+	}
+	var maxRootCount int
+	for name, count := range rootCounts {
+		if count > maxRootCount || count == maxRootCount && name < ans.RootStructure {
+			ans.RootStructure = name
+			maxRootCount = count
+		}
+	}
+	return ans, nil
+}
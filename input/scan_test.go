@@ -0,0 +1,47 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanVertical(t *testing.T) {
+	vert := "<doc id=\"d1\" title=\"T\">\n<p>\n<s id=\"s1\">\nA\ta\tN\nb\tb\tV\n</s>\n<g/>\nC\tc\tN\n</p>\n</doc>\n" +
+		"<doc id=\"d2\" author=\"X\">\n<s id=\"s2\">\nD\td\n</s>\n</doc>\n"
+	path := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(path, []byte(vert), 0644))
+	summary, err := ScanVertical(path, 1024*1024)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string][]string{"doc": {"author", "id", "title"}, "p": {}, "s": {"id"}, "g": {}},
+		summary.Structures,
+	)
+	assert.Equal(t, map[string]int{"doc": 2, "p": 1, "s": 2, "g": 1}, summary.StructCounts)
+	assert.Equal(t, "doc", summary.RootStructure)
+	assert.Equal(t, 3, summary.NumColumns)
+	assert.Equal(t, 4, summary.NumTokens)
+	assert.Equal(t, "utf-8", summary.Encoding)
+	assert.Equal(t, 2, summary.AttrValues["doc_id"].NumValues)
+	assert.Equal(t, db.ColumnTypeString, summary.AttrValues["doc_id"].Infer().Type)
+}
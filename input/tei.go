@@ -0,0 +1,189 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/rs/zerolog/log"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+const (
+	teiHeaderElement = "teiHeader"
+)
+
+// teiParser converts a stream of TEI XML tokens into structures and tokens
+// passed to a line processor. As there are no lines in XML, an ordinal
+// number of a produced item is used in place of a line number.
+type teiParser struct {
+	dec           *xml.Decoder
+	lproc         vertigo.LineProcessor
+	filterArgs    [][][]string
+	tokenElements map[string]bool
+	tokenAttrs    []string
+	itemNum       int
+	tokenNum      int
+}
+
+func (tp *teiParser) elementAttrs(elm xml.StartElement) map[string]string {
+	ans := make(map[string]string, len(elm.Attr))
+	for _, attr := range elm.Attr {
+		ans[attr.Name.Local] = attr.Value
+	}
+	return ans
+}
+
+func (tp *teiParser) emitToken(word string, attrs []string) error {
+	tk := &vertigo.Token{
+		Idx:         tp.tokenNum,
+		Word:        word,
+		Attrs:       attrs,
+		StructAttrs: map[string]string{},
+	}
+	tp.tokenNum++
+	tp.itemNum++
+	if !tk.MatchesFilter(tp.filterArgs) {
+		return nil
+	}
+	return tp.lproc.ProcToken(tk, tp.itemNum-1, nil)
+}
+
+// readTokenElement reads the whole content of a token element
+// and produces a single token
+func (tp *teiParser) readTokenElement(elm xml.StartElement) error {
+	var word strings.Builder
+	depth := 1
+	for depth > 0 {
+		xtok, err := tp.dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read element %s: %w", elm.Name.Local, err)
+		}
+		switch tv := xtok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			word.Write(tv)
+		}
+	}
+	elmAttrs := tp.elementAttrs(elm)
+	attrs := make([]string, len(tp.tokenAttrs))
+	for i, name := range tp.tokenAttrs {
+		attrs[i] = elmAttrs[name]
+	}
+	return tp.emitToken(strings.Join(strings.Fields(word.String()), " "), attrs)
+}
+
+func (tp *teiParser) run() error {
+	for {
+		xtok, err := tp.dec.Token()
+		if err == io.EOF {
+			return nil
+
+		} else if err != nil {
+			return fmt.Errorf("failed to parse TEI document: %w", err)
+		}
+		var procErr error
+		switch tv := xtok.(type) {
+		case xml.StartElement:
+			if tv.Name.Local == teiHeaderElement {
+				procErr = tp.dec.Skip()
+
+			} else if tp.tokenElements[tv.Name.Local] {
+				procErr = tp.readTokenElement(tv)
+
+			} else {
+				procErr = tp.lproc.ProcStruct(
+					&vertigo.Structure{Name: tv.Name.Local, Attrs: tp.elementAttrs(tv)}, tp.itemNum, nil)
+				tp.itemNum++
+			}
+		case xml.EndElement:
+			procErr = tp.lproc.ProcStructClose(&vertigo.StructureClose{Name: tv.Name.Local}, tp.itemNum, nil)
+			tp.itemNum++
+		case xml.CharData:
+			for _, word := range strings.Fields(string(tv)) {
+				if procErr = tp.emitToken(word, make([]string, len(tp.tokenAttrs))); procErr != nil {
+					break
+				}
+			}
+		}
+		if procErr != nil {
+			return procErr
+		}
+	}
+}
+
+// ParseTEIFrom parses a TEI P5 document and passes its elements as
+// structures and its tokens (see cnf.TEIConf) as tokens to the line
+// processor. The teiHeader element is skipped. Token indices start
+// from firstToken and the index of the token following the last one
+// is returned. Number of read bytes is tracked by progress (can be nil).
+// Fetching of a remote document is bound to ctx.
+func ParseTEIFrom(
+	ctx context.Context,
+	conf *vertigo.ParserConf,
+	teiConf *cnf.TEIConf,
+	lproc vertigo.LineProcessor,
+	firstToken int,
+	progress *ReadProgress,
+) (int, error) {
+	rd, err := OpenWithProgress(ctx, conf.InputFilePath, conf.Encoding, progress)
+	if err != nil {
+		return firstToken, err
+	}
+	defer rd.Close()
+	dec := xml.NewDecoder(rd)
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if conf.Encoding != "" {
+			return input, nil // already converted by Open
+		}
+		chm, err := vertigo.GetCharmapByName(label)
+		if err != nil {
+			return nil, err
+		}
+		if chm == nil {
+			return input, nil
+		}
+		return chm.NewDecoder().Reader(input), nil
+	}
+	tp := &teiParser{
+		dec:           dec,
+		lproc:         lproc,
+		filterArgs:    conf.FilterArgs,
+		tokenElements: make(map[string]bool),
+		tokenAttrs:    teiConf.GetTokenAttrs(),
+		tokenNum:      firstToken,
+	}
+	for _, elm := range teiConf.GetTokenElements() {
+		tp.tokenElements[elm] = true
+	}
+	if err := tp.run(); err != nil {
+		return tp.tokenNum, err
+	}
+	log.Info().
+		Int("numTokens", tp.tokenNum-firstToken).
+		Str("file", conf.InputFilePath).
+		Msg("Parsing of TEI document done")
+	return tp.tokenNum, nil
+}
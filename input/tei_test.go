@@ -0,0 +1,90 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+const testTEI = `<?xml version="1.0" encoding="UTF-8"?>
+<TEI xmlns="http://www.tei-c.org/ns/1.0">
+  <teiHeader><fileDesc><titleStmt><title>Ignored title</title></titleStmt></fileDesc></teiHeader>
+  <text xml:id="t1" type="fiction">
+    <body>
+      <p n="1">
+        <s><w lemma="cat" pos="NN">Cats</w> <w lemma="sleep" pos="VB">sleep</w><pc pos="Z">.</pc></s>
+      </p>
+      <p n="2">Plain text here</p>
+    </body>
+  </text>
+</TEI>
+`
+
+// recordingProcessor stores all the processed items as strings
+type recordingProcessor struct {
+	items []string
+}
+
+func (rp *recordingProcessor) ProcToken(tk *vertigo.Token, line int, err error) error {
+	rp.items = append(rp.items, fmt.Sprintf("%d:%s/%s", tk.Idx, tk.Word, strings.Join(tk.Attrs, "/")))
+	return nil
+}
+
+func (rp *recordingProcessor) ProcStruct(st *vertigo.Structure, line int, err error) error {
+	var id string
+	if v, ok := st.Attrs["id"]; ok {
+		id = "#" + v
+	}
+	rp.items = append(rp.items, "<"+st.Name+id+">")
+	return nil
+}
+
+func (rp *recordingProcessor) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
+	rp.items = append(rp.items, "</"+st.Name+">")
+	return nil
+}
+
+func TestParseTEI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.xml")
+	assert.NoError(t, os.WriteFile(path, []byte(testTEI), 0644))
+	rp := &recordingProcessor{}
+	progress := NewReadProgress()
+	next, err := ParseTEIFrom(context.Background(), &vertigo.ParserConf{InputFilePath: path}, &cnf.TEIConf{}, rp, 10, progress)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(testTEI)), progress.BytesRead())
+	assert.Equal(t, int64(len(testTEI)), progress.TotalBytes())
+	assert.Equal(t, 16, next)
+	assert.Equal(
+		t,
+		[]string{
+			"<TEI>", "<text#t1>", "<body>", "<p>", "<s>",
+			"10:Cats/cat/NN", "11:sleep/sleep/VB", "12:.//Z", "</s>", "</p>",
+			"<p>", "13:Plain//", "14:text//", "15:here//", "</p>",
+			"</body>", "</text>", "</TEI>",
+		},
+		rp.items,
+	)
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package intern provides string interning used to reduce memory
+// consumed by repeated short strings (attribute values, lemmas, tags).
+package intern
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	DfltMaxItems = 1000000
+)
+
+// Clone returns a copy of s not sharing memory with the original
+// string. This is important for values sliced from larger strings
+// (e.g. vertical file lines) as a retained substring keeps the
+// whole original string in memory.
+func Clone(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s)
+	return b.String()
+}
+
+// Pool stores a single instance of each added string so repeated
+// values share the same memory. To prevent unlimited growth in case
+// of unique values (e.g. document IDs), the pool stops accepting new
+// values once it contains maxItems strings. Pool can be used by
+// multiple goroutines.
+type Pool struct {
+	lock     sync.RWMutex
+	data     map[string]string
+	maxItems int
+}
+
+// Get returns an interned instance of s
+func (p *Pool) Get(s string) string {
+	p.lock.RLock()
+	v, ok := p.data[s]
+	p.lock.RUnlock()
+	if ok {
+		return v
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if v, ok := p.data[s]; ok {
+		return v
+	}
+	if len(p.data) >= p.maxItems {
+		return s
+	}
+	v = Clone(s)
+	p.data[v] = v
+	return v
+}
+
+// Size returns number of interned strings
+func (p *Pool) Size() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return len(p.data)
+}
+
+// NewPool creates a new pool accepting up to maxItems strings
+// (a non-positive value means DfltMaxItems).
+func NewPool(maxItems int) *Pool {
+	if maxItems <= 0 {
+		maxItems = DfltMaxItems
+	}
+	return &Pool{
+		data:     make(map[string]string),
+		maxItems: maxItems,
+	}
+}
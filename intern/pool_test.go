@@ -0,0 +1,49 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intern
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestPoolReturnsSameInstance(t *testing.T) {
+	p := NewPool(10)
+	line := "foo\tbar"
+	v1 := p.Get(line[:3])
+	v2 := p.Get(strings.Repeat("fo", 1) + "o")
+	assert.Equal(t, "foo", v1)
+	assert.Equal(t, stringData(v1), stringData(v2))
+	assert.NotEqual(t, stringData(line), stringData(v1))
+	assert.Equal(t, 1, p.Size())
+}
+
+func TestPoolLimit(t *testing.T) {
+	p := NewPool(1)
+	p.Get("foo")
+	v := "bar"
+	assert.Equal(t, stringData(v), stringData(p.Get(v)))
+	assert.Equal(t, 1, p.Size())
+}
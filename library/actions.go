@@ -17,7 +17,9 @@
 package library
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -26,9 +28,11 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
 	"github.com/czcorpus/vert-tagextract/v2/db/colgen"
 	"github.com/czcorpus/vert-tagextract/v2/db/factory"
 	"github.com/czcorpus/vert-tagextract/v2/fs"
+	"github.com/czcorpus/vert-tagextract/v2/input"
 	"github.com/czcorpus/vert-tagextract/v2/proc"
 
 	"github.com/tomachalek/vertigo/v5"
@@ -42,10 +46,20 @@ func sendErrStatus(statusChan chan proc.Status, file string, err error) {
 	}
 }
 
+func sendPhaseStatus(statusChan chan proc.Status, phase string, t0 time.Time) {
+	statusChan <- proc.Status{
+		Datetime: time.Now(),
+		Phase:    &proc.PhaseTiming{Name: phase, Duration: time.Since(t0)},
+	}
+}
+
 // determineLineReportingStep
 // note: the numbers 0.02, 20 are just rough empirical values to determine
 // number of lines based on "average" CNC corpus
 func determineLineReportingStep(filePath string) int {
+	if input.IsRemote(filePath) {
+		return 0 // size is unknown, the parser's default will be used
+	}
 	size := fs.FileSize(filePath)
 	tmp := float64(size) * 0.02
 	if strings.HasSuffix(filePath, ".gz") || strings.HasSuffix(filePath, ".tgz") {
@@ -60,30 +74,131 @@ func determineLineReportingStep(filePath string) int {
 	return step
 }
 
+// expandVerticalPaths expands glob patterns in local paths.
+// Remote verticals are kept untouched.
+func expandVerticalPaths(paths []string) ([]string, error) {
+	ans := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if input.IsRemote(path) {
+			ans = append(ans, path)
+			continue
+		}
+		expanded, err := fs.ExpandGlobs([]string{path})
+		if err != nil {
+			return []string{}, err
+		}
+		ans = append(ans, expanded...)
+	}
+	return ans, nil
+}
+
 // ExtractData extracts structural and/or positional attributes from a vertical file
 // based on the specification in the 'conf' argument.
 // The 'stopChan' can be used to handle calling service shutdown.
 // The 'statusChan' is for getting extraction status information including possible errors
 func ExtractData(conf *cnf.VTEConf, appendData bool, stopChan <-chan os.Signal) (chan proc.Status, error) {
-	if err := conf.Ngrams.UpgradeLegacy(); err != nil {
-		return nil, fmt.Errorf("failed to process file: %w", err)
+	return ExtractDataContext(context.Background(), conf, appendData, stopChan)
+}
+
+// ExtractDataContext is a variant of ExtractData which can be also stopped
+// by cancelling the 'ctx'. In such case, the database transaction is rolled back.
+func ExtractDataContext(
+	ctx context.Context,
+	conf *cnf.VTEConf,
+	appendData bool,
+	stopChan <-chan os.Signal,
+) (chan proc.Status, error) {
+	return ExtractDataWithHooks(ctx, conf, appendData, stopChan, Hooks{})
+}
+
+// scanStructAttrs reads whole vertical files and collects attributes
+// of all the found structures. It is used to resolve wildcard
+// structures (see cnf.StructAttrWildcard).
+func scanStructAttrs(conf *cnf.VTEConf, files []string) (map[string][]string, error) {
+	if conf.InputFormat == cnf.InputFormatTEI {
+		return nil, fmt.Errorf("wildcard structures are not supported for the %s input format", conf.InputFormat)
 	}
-	statusChan := make(chan proc.Status)
-	dbWriter, err := factory.NewDatabaseWriter(conf)
-	if err != nil {
-		return nil, err
+	ans := make(map[string][]string)
+	for _, file := range files {
+		if !fs.IsFile(file) {
+			return nil, fmt.Errorf("cannot scan %s, wildcard structures require local vertical files", file)
+		}
+		log.Info().Str("vertical", file).Msg("Scanning vertical for attributes of wildcard structures")
+		summary, err := input.ScanVertical(file, math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+		for st, attrs := range summary.Structures {
+			ans[st] = append(ans[st], attrs...)
+		}
 	}
-	dbExisted := dbWriter.DatabaseExists()
-	if !dbExisted && appendData {
-		err := fmt.Errorf("update flag is set but the database %s does not exist", conf.DB.Name)
-		return nil, err
+	return ans, nil
+}
+
+// scanAttrValues scans vertical files (or their configured parts)
+// for statistics of values of structural attributes allowing
+// inference of column types (see cnf.VTEConf.InferColumnTypes)
+func scanAttrValues(conf *cnf.VTEConf, files []string) (map[string]*db.ColumnValueStats, error) {
+	if conf.InputFormat == cnf.InputFormatTEI {
+		return nil, fmt.Errorf("column type inference is not supported for the %s input format", conf.InputFormat)
+	}
+	maxBytes := int64(math.MaxInt64)
+	if conf.InferColumnTypes.SampleMB > 0 {
+		maxBytes = int64(conf.InferColumnTypes.SampleMB) * 1024 * 1024
+	}
+	ans := make(map[string]*db.ColumnValueStats)
+	for _, file := range files {
+		if !fs.IsFile(file) {
+			return nil, fmt.Errorf("cannot scan %s, column type inference requires local vertical files", file)
+		}
+		log.Info().Str("vertical", file).Msg("Scanning vertical for values of structural attributes")
+		summary, err := input.ScanVertical(file, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		for attr, stats := range summary.AttrValues {
+			if _, ok := ans[attr]; !ok {
+				ans[attr] = &db.ColumnValueStats{}
+			}
+			ans[attr].Merge(stats)
+		}
 	}
+	return ans, nil
+}
 
+// Hooks contains optional callbacks allowing embedding applications
+// to customize the extraction
+type Hooks struct {
+
+	// Atom is called for each atom before it is written (see proc.AtomHook)
+	Atom proc.AtomHook
+
+	// LineProcessors receive the same parser events as the extractor
+	// (see proc.TTExtractor.AddLineProcessor)
+	LineProcessors []vertigo.LineProcessor
+}
+
+// ExtractDataWithHooks is a variant of ExtractDataContext with custom
+// hooks applied during the extraction.
+func ExtractDataWithHooks(
+	ctx context.Context,
+	conf *cnf.VTEConf,
+	appendData bool,
+	stopChan <-chan os.Signal,
+	hooks Hooks,
+) (chan proc.Status, error) {
+	if err := conf.ResolveColumnNames(); err != nil {
+		return nil, fmt.Errorf("failed to process file: %w", err)
+	}
+	if err := conf.Ngrams.UpgradeLegacy(); err != nil {
+		return nil, fmt.Errorf("failed to process file: %w", err)
+	}
 	var filesToProc []string
 	if conf.VerticalFile != "" && len(conf.VerticalFiles) > 0 {
 		return nil, fmt.Errorf("cannot use verticalFile and verticalFiles at the same time")
 	}
-	if conf.VerticalFile != "" && (fs.IsFile(conf.VerticalFile) || strings.HasPrefix(conf.VerticalFile, "|")) {
+	if conf.VerticalFile != "" && (fs.IsFile(conf.VerticalFile) || strings.HasPrefix(conf.VerticalFile, "|") ||
+		input.IsRemote(conf.VerticalFile)) {
 		filesToProc = []string{conf.VerticalFile}
 
 	} else if conf.VerticalFile != "" && fs.IsDir(conf.VerticalFile) {
@@ -93,76 +208,149 @@ func ExtractData(conf *cnf.VTEConf, appendData bool, stopChan <-chan os.Signal)
 			return nil, err
 		}
 
-	} else if len(conf.VerticalFiles) > 0 && fs.AllFilesExist(conf.VerticalFiles) {
-		filesToProc = conf.VerticalFiles
+	} else if conf.VerticalFile != "" && fs.HasGlobMeta(conf.VerticalFile) {
+		var err error
+		filesToProc, err = fs.ExpandGlobs([]string{conf.VerticalFile})
+		if err != nil {
+			return nil, fmt.Errorf("failed to process verticalFile: %w", err)
+		}
+
+	} else if len(conf.VerticalFiles) > 0 {
+		var err error
+		filesToProc, err = expandVerticalPaths(conf.VerticalFiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process verticalFiles: %w", err)
+		}
+		for _, file := range filesToProc {
+			if !fs.IsFile(file) && !input.IsRemote(file) {
+				return nil, fmt.Errorf("verticalFiles item %s is not a valid file", file)
+			}
+		}
 
 	} else {
 		return nil, fmt.Errorf("neither verticalFile nor verticalFiles provide a valid data source")
 	}
+	if len(conf.WildcardStructures()) > 0 {
+		found, err := scanStructAttrs(conf, filesToProc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve wildcard structures: %w", err)
+		}
+		conf.ResolveWildcardStructures(found)
+	}
+	if conf.InferColumnTypes != nil && appendData {
+		log.Info().Msg("Column type inference is not applied when appending data to an existing schema")
+
+	} else if conf.InferColumnTypes != nil {
+		stats, err := scanAttrValues(conf, filesToProc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to infer column types: %w", err)
+		}
+		conf.ResolveInferredColumnTypes(stats)
+	}
+	if err := conf.ResolveDerivedAttrs(); err != nil {
+		return nil, fmt.Errorf("failed to process file: %w", err)
+	}
+	statusChan := make(chan proc.Status)
+	dbWriter, err := factory.NewDatabaseWriter(conf)
+	if err != nil {
+		return nil, err
+	}
+	dbExisted := dbWriter.DatabaseExists()
+	if !dbExisted && appendData {
+		err := fmt.Errorf("update flag is set but the database %s does not exist", conf.DB.Name)
+		return nil, err
+	}
 
 	go func() {
 		defer dbWriter.Close()
 		defer close(statusChan)
-		var wg sync.WaitGroup
-		wg.Add(len(filesToProc))
 
+		t0 := time.Now()
 		err := dbWriter.Initialize(appendData)
 		if err != nil {
-			wg.Done()
 			sendErrStatus(statusChan, "", err)
 			return
 		}
-		for _, verticalFile := range filesToProc {
-			log.Info().Str("vertical", verticalFile).Msg("Processing vertical")
-			parserConf := &vertigo.ParserConf{
+		sendPhaseStatus(statusChan, proc.PhaseInitialize, t0)
+		parserConfs := make([]*vertigo.ParserConf, len(filesToProc))
+		for i, verticalFile := range filesToProc {
+			log.Info().Str("vertical", verticalFile).Msg("Adding vertical to process")
+			parserConfs[i] = &vertigo.ParserConf{
 				InputFilePath:         verticalFile,
-				StructAttrAccumulator: "nil",
-				Encoding:              conf.Encoding,
-				LogProgressEachNth:    determineLineReportingStep(verticalFile),
+				StructAttrAccumulator: vertigo.AccumulatorTypeNil,
+				Encoding:              conf.GetInputEncoding(),
+				LogProgressEachNth:    conf.Parser.GetLogProgressEachNth(),
+			}
+			if parserConfs[i].LogProgressEachNth <= 0 {
+				parserConfs[i].LogProgressEachNth = determineLineReportingStep(verticalFile)
 			}
+		}
 
-			var fn colgen.AlignedColGenFn
-			if conf.SelfJoin.IsConfigured() {
-				fn = func(args map[string]interface{}) (ident string, err error) {
-					var colgenFn colgen.AlignedUnboundColGenFn
-					defer func() {
-						if r := recover(); r != nil {
-							ident = ""
-							err = fmt.Errorf("%v", r)
-						}
-					}()
-					colgenFn, err = colgen.GetFuncByName(conf.SelfJoin.GeneratorFn)
-					if err != nil {
-						return
+		var fn colgen.AlignedColGenFn
+		if conf.SelfJoin.IsConfigured() {
+			fn = func(args map[string]interface{}) (ident string, err error) {
+				var colgenFn colgen.AlignedUnboundColGenFn
+				defer func() {
+					if r := recover(); r != nil {
+						ident = ""
+						err = fmt.Errorf("%v", r)
 					}
-					ident, err = colgenFn(args, conf.SelfJoin.ArgColumns)
+				}()
+				colgenFn, err = colgen.GetFuncByName(conf.SelfJoin.GeneratorFn)
+				if err != nil {
 					return
 				}
+				ident, err = colgenFn(args, conf.SelfJoin.ArgColumns)
+				return
 			}
+		}
 
-			subStatusChan := make(chan proc.Status, 10)
-			go func() {
-				defer wg.Done()
-				for upd := range subStatusChan {
-					upd.File = verticalFile
-					statusChan <- upd
-				}
-			}()
-			tte, err := proc.NewTTExtractor(dbWriter, conf, fn, subStatusChan, stopChan)
-			if err != nil {
-				close(subStatusChan)
-				sendErrStatus(statusChan, "", err)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		subStatusChan := make(chan proc.Status, 10)
+		go func() {
+			defer wg.Done()
+			for upd := range subStatusChan {
+				statusChan <- upd
 			}
-			err = tte.Run(parserConf)
+		}()
+		tte, err := proc.NewExtractor(
+			conf,
+			proc.WithWriter(dbWriter),
+			proc.WithColgen(fn),
+			proc.WithStatusChan(subStatusChan),
+			proc.WithStopChan(stopChan),
+			proc.WithAtomHook(hooks.Atom),
+			proc.WithLineProcessors(hooks.LineProcessors...),
+		)
+		if err != nil {
 			close(subStatusChan)
-			if err != nil {
-				sendErrStatus(statusChan, verticalFile, err)
-			}
+			wg.Wait()
+			dbWriter.Rollback()
+			sendErrStatus(statusChan, "", err)
+			return
 		}
+		stats, err := tte.RunFiles(ctx, parserConfs)
+		close(subStatusChan)
 		wg.Wait()
+		if err != nil {
+			// the transaction has been already rolled back by the extractor
+			sendErrStatus(statusChan, "", err)
+			return
+		}
+		t0 = time.Now()
 		err = dbWriter.Commit()
 		if err != nil {
 			sendErrStatus(statusChan, "", err)
+			return
+		}
+		commitPhase := proc.PhaseTiming{Name: proc.PhaseCommit, Duration: time.Since(t0)}
+		stats.Phases = append(stats.Phases, commitPhase)
+		stats.Elapsed += commitPhase.Duration
+		statusChan <- proc.Status{
+			Datetime: time.Now(),
+			Phase:    &commitPhase,
+			Stats:    stats,
 		}
 	}()
 
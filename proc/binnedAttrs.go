@@ -0,0 +1,156 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+)
+
+const (
+	dfltBinLabel = "{min}"
+)
+
+// binnedAttr is a validated configuration of a binned attribute
+type binnedAttr struct {
+	name   string
+	source string
+	bins   []cnf.BinConf
+	width  float64
+	label  string
+	dflt   string
+}
+
+// compileBinnedAttrs checks that binned attributes (see cnf.VTEConf.BinnedAttrs)
+// refer to configured structural attributes and that their intervals are valid
+func compileBinnedAttrs(
+	binnedAttrs []cnf.BinnedAttrConf,
+	structures map[string][]string,
+) ([]binnedAttr, error) {
+	known := map[string]bool{"poscount": true}
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			known[st+"_"+attr] = true
+		}
+	}
+	ans := make([]binnedAttr, len(binnedAttrs))
+	for i, ba := range binnedAttrs {
+		if !known[ba.Source] {
+			return nil, fmt.Errorf("binned attribute %s refers to unknown attribute %s", ba.Name, ba.Source)
+		}
+		if ba.Source == ba.Name {
+			return nil, fmt.Errorf("binned attribute %s cannot refer to itself", ba.Name)
+		}
+		if ba.Width < 0 || math.IsNaN(ba.Width) || math.IsInf(ba.Width, 0) {
+			return nil, fmt.Errorf("invalid bin width of attribute %s", ba.Name)
+		}
+		if (ba.Width > 0) == (len(ba.Bins) > 0) {
+			return nil, fmt.Errorf("binned attribute %s requires either bins or width", ba.Name)
+		}
+		for _, bin := range ba.Bins {
+			if bin.Label == "" {
+				return nil, fmt.Errorf("a bin of attribute %s has no label", ba.Name)
+			}
+			if bin.Min != nil && bin.Max != nil && *bin.Min >= *bin.Max {
+				return nil, fmt.Errorf("invalid bin %s of attribute %s, min must be less than max", bin.Label, ba.Name)
+			}
+		}
+		ans[i] = binnedAttr{
+			name:   ba.Name,
+			source: ba.Source,
+			bins:   ba.Bins,
+			width:  ba.Width,
+			label:  ba.Label,
+			dflt:   ba.Default,
+		}
+		if ans[i].label == "" {
+			ans[i].label = dfltBinLabel
+		}
+	}
+	return ans, nil
+}
+
+// checkBinnedAttrsUsage makes sure computed attributes do not refer
+// to binned attributes which are evaluated later (once an atom is closed)
+func checkBinnedAttrsUsage(binned []binnedAttr, computed []computedAttr) error {
+	names := make(map[string]bool)
+	for _, ba := range binned {
+		names[ba.name] = true
+	}
+	for _, ca := range computed {
+		for _, attr := range ca.expr.Attrs() {
+			if names[attr] {
+				return fmt.Errorf("computed attribute %s cannot refer to binned attribute %s", ca.name, attr)
+			}
+		}
+	}
+	return nil
+}
+
+func formatBinBound(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// apply returns a label of an interval the value belongs to
+func (ba *binnedAttr) apply(v float64) string {
+	if ba.width > 0 {
+		lo := math.Floor(v/ba.width) * ba.width
+		return strings.NewReplacer(
+			"{min}", formatBinBound(lo),
+			"{max}", formatBinBound(lo+ba.width),
+		).Replace(ba.label)
+	}
+	for _, bin := range ba.bins {
+		if (bin.Min == nil || v >= *bin.Min) && (bin.Max == nil || v < *bin.Max) {
+			return bin.Label
+		}
+	}
+	return ba.dflt
+}
+
+// applyBinnedAttrs stores labels of intervals of all the binned
+// attributes into attrs. Empty source values produce empty labels.
+func (tte *TTExtractor) applyBinnedAttrs(attrs map[string]any) error {
+	for _, ba := range tte.binnedAttrs {
+		var num float64
+		switch tv := attrs[ba.source].(type) {
+		case nil:
+			attrs[ba.name] = ""
+			continue
+		case int:
+			num = float64(tv)
+		case string:
+			if tv == "" {
+				attrs[ba.name] = ""
+				continue
+			}
+			var err error
+			num, err = strconv.ParseFloat(strings.TrimSpace(tv), 64)
+			if err != nil {
+				return fmt.Errorf("invalid numeric value '%s' of attribute %s binned to %s", tv, ba.source, ba.name)
+			}
+		default:
+			return fmt.Errorf("unsupported value %v of attribute %s binned to %s", tv, ba.source, ba.name)
+		}
+		attrs[ba.name] = ba.apply(num)
+	}
+	return nil
+}
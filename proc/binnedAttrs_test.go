@@ -0,0 +1,93 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestBinnedAttrs(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte(
+			"<doc id=\"1\" born=\"1893\">\na\n</doc>\n"+
+				"<doc id=\"2\" born=\"\">\nb\nc\nd\n</doc>\n"+
+				"<doc id=\"3\" born=\"1900\">\ne\nf\n</doc>\n",
+		),
+		0644,
+	))
+	two, three := 2.0, 3.0
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {"id", "born"}},
+		BinnedAttrs: []cnf.BinnedAttrConf{
+			{
+				Name:   "doc_size",
+				Source: "poscount",
+				Bins: []cnf.BinConf{
+					{Max: &two, Label: "short"},
+					{Min: &two, Max: &three, Label: "medium"},
+				},
+				Default: "long",
+			},
+			{Name: "doc_born_decade", Source: "doc_born", Width: 10, Label: "{min}-{max}"},
+		},
+		Strictness: cnf.StrictnessStrict,
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"corpus_id=test, doc_born=, doc_born_decade=, doc_id=2, doc_size=long, poscount=3, wordcount=0",
+			"corpus_id=test, doc_born=1893, doc_born_decade=1890-1900, doc_id=1, doc_size=short, poscount=1, wordcount=0",
+			"corpus_id=test, doc_born=1900, doc_born_decade=1900-1910, doc_id=3, doc_size=medium, poscount=2, wordcount=0",
+		},
+		writer.sortedRows("liveattrs_entry"),
+	)
+
+	for _, item := range []struct {
+		conf        cnf.BinnedAttrConf
+		expectedErr string
+	}{
+		{cnf.BinnedAttrConf{Name: "doc_x", Source: "doc_year", Width: 10}, "unknown attribute doc_year"},
+		{cnf.BinnedAttrConf{Name: "doc_x", Source: "doc_born"}, "requires either bins or width"},
+		{cnf.BinnedAttrConf{Name: "doc_x", Source: "doc_born", Bins: []cnf.BinConf{{Min: &three, Max: &two, Label: "x"}}},
+			"min must be less than max"},
+		{cnf.BinnedAttrConf{Name: "doc_x", Source: "doc_born", Bins: []cnf.BinConf{{Min: &two}}}, "has no label"},
+	} {
+		conf.BinnedAttrs = []cnf.BinnedAttrConf{item.conf}
+		_, err = NewExtractor(conf, WithWriter(writer))
+		assert.ErrorContains(t, err, item.expectedErr)
+	}
+	conf.BinnedAttrs = []cnf.BinnedAttrConf{{Name: "doc_x", Source: "doc_born", Width: 10}}
+	conf.ComputedAttrs = []cnf.ComputedAttrConf{{Name: "doc_y", Expr: "doc_x + 's'"}}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "cannot refer to binned attribute")
+}
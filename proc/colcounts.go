@@ -0,0 +1,296 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount"
+)
+
+func (tte *TTExtractor) colCountsAttrs() []string {
+	ans := append(
+		db.GenerateColCountNames(tte.countColumns),
+		"corpus_id", "count", "arf", "hash_id")
+	if tte.ngramConf.HasDocFreq() {
+		ans = append(ans, "docfreq")
+	}
+	if tte.ngramConf.IPM {
+		ans = append(ans, "ipm")
+	}
+	if tte.ngramConf.AssocMeasures {
+		ans = append(ans, "mi", "tscore", "logdice")
+	}
+	return ans
+}
+
+func (tte *TTExtractor) colCountsRow(count *ptcount.NgramCounter) []any {
+	numArgs := len(tte.countColumns) + 4
+	if tte.ngramConf.HasDocFreq() {
+		numArgs++
+	}
+	if tte.ngramConf.IPM {
+		numArgs++
+	}
+	if tte.ngramConf.AssocMeasures {
+		numArgs += 3
+	}
+	args := make([]any, numArgs)
+	for i, vc := range tte.countColumns {
+		if vc.NgramPos > 0 {
+			args[i] = count.PositionValue(vc.NgramPos-1, vc.Idx, tte.WordDict())
+
+		} else {
+			args[i] = count.ColumnNgram(vc.Idx, tte.WordDict())
+		}
+	}
+	numCol := len(tte.countColumns)
+	args[numCol] = tte.corpusID
+	args[numCol+1] = count.Count()
+	if count.HasARF() {
+		args[numCol+2] = count.ARF().ARF
+
+	} else {
+		args[numCol+2] = -1
+	}
+	args[numCol+3] = tte.generateHashID(count)
+	if tte.ngramConf.HasDocFreq() {
+		args[numCol+4] = count.DocFreq()
+	}
+	if tte.ngramConf.IPM {
+		ipmIdx := numCol + 4
+		if tte.ngramConf.HasDocFreq() {
+			ipmIdx++
+		}
+		args[ipmIdx] = float64(count.Count()) / float64(tte.corpusTokens) * 1e6
+	}
+	if tte.ngramConf.AssocMeasures {
+		assocIdx := numArgs - 3
+		if assoc := count.Assoc(); assoc != nil {
+			args[assocIdx] = assoc.MI
+			args[assocIdx+1] = assoc.TScore
+			args[assocIdx+2] = assoc.LogDice
+		}
+	}
+	return args
+}
+
+func (tte *TTExtractor) generateHashID(ng *ptcount.NgramCounter) string {
+	hasher := sha1.New()
+	for _, vc := range tte.ngramConf.VertColumns {
+		hasher.Write([]byte(ng.ColumnNgram(vc.Idx, tte.WordDict())))
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+// belowMinFreq tests whether an n-gram should be dropped
+// due to ngrams.minFreq (and counts such n-grams)
+func (tte *TTExtractor) belowMinFreq(count *ptcount.NgramCounter) bool {
+	if count.Count() < tte.minFreq {
+		atomic.AddInt64(&tte.droppedNgrams, 1)
+		return true
+	}
+	return false
+}
+
+// prepareColCountsRows iterates over shards of the n-gram map in parallel
+// and sends prepared rows (decoded n-grams, hashes) via the returned channel.
+// Closing the done channel stops the process.
+func (tte *TTExtractor) prepareColCountsRows(done <-chan struct{}) <-chan []any {
+	counts := tte.GetColCounts()
+	rows := make(chan []any, 1000)
+	shards := make(chan int)
+	numWorkers := tte.numWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shardIdx := range shards {
+				counts.ForEachInShard(shardIdx, func(key ptcount.NgramKey, count *ptcount.NgramCounter) bool {
+					if tte.belowMinFreq(count) {
+						return true
+					}
+					select {
+					case rows <- tte.colCountsRow(count):
+						return true
+					case <-done:
+						return false
+					}
+				})
+			}
+		}()
+	}
+	go func() {
+		defer close(rows)
+	loop:
+		for i := 0; i < counts.NumShards(); i++ {
+			select {
+			case shards <- i:
+			case <-done:
+				break loop
+			}
+		}
+		close(shards)
+		wg.Wait()
+	}()
+	return rows
+}
+
+// mergeSpilledColCountsRows is an alternative to prepareColCountsRows
+// used when n-grams are spilled to disk. Once the returned channel
+// is closed, the value of err is available.
+func (tte *TTExtractor) mergeSpilledColCountsRows(done <-chan struct{}, err *error) <-chan []any {
+	rows := make(chan []any, 1000)
+	go func() {
+		defer close(rows)
+		*err = tte.ngramSpiller.Merge(tte.GetColCounts(), func(count *ptcount.NgramCounter) bool {
+			if tte.belowMinFreq(count) {
+				return true
+			}
+			select {
+			case rows <- tte.colCountsRow(count):
+				return true
+			case <-done:
+				return false
+			}
+		})
+	}()
+	return rows
+}
+
+// spillNgramsIfNeeded writes counted n-grams to disk in case
+// spilling is enabled and the memory limit has been reached
+func (tte *TTExtractor) spillNgramsIfNeeded() error {
+	if tte.ngramSpiller == nil {
+		return nil
+	}
+	return tte.ngramSpiller.SpillIfNeeded(tte.GetColCounts())
+}
+
+func (tte *TTExtractor) insertCounts() error {
+	if tte.colcountsStager != nil {
+		if err := tte.flushStagedCounts(); err != nil {
+			return err
+		}
+		tte.logger.Info().Str("phase", PhaseColcounts).Msg("Aggregating staged n-gram counts")
+		return tte.colcountsStager.AggregateStaged()
+	}
+	ins, err := tte.database.PrepareInsert("colcounts", tte.colCountsAttrs())
+	if err != nil {
+		return fmt.Errorf("failed to prepare colcounts insert: %w", err)
+	}
+	tte.addTableColumns("colcounts", tte.colCountsAttrs())
+	var hapaxIns db.InsertOperation
+	if tte.ngramConf.HasHapaxTable() {
+		hapaxIns, err = tte.database.PrepareInsert(db.ColcountsHapaxTable, tte.colCountsAttrs())
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s insert: %w", db.ColcountsHapaxTable, err)
+		}
+		tte.addTableColumns(db.ColcountsHapaxTable, tte.colCountsAttrs())
+	}
+	if tte.freqList != nil {
+		tte.freqList.setColumns(tte.colCountsAttrs(), tte.ngramConf.CalcARF)
+	}
+	summarizer := newNgramSummarizer(tte.ngramConf.SummaryTopN, len(tte.countColumns))
+	var dict *valueDict
+	if tte.ngramConf.DictEncoding {
+		dict = newValueDict(
+			tte.database,
+			tte.corpusID,
+			tte.countColumns,
+			func(table string, attrs []string) {
+				tte.addTableColumns(table, attrs)
+				tte.addWrittenRows(table, 1)
+			},
+		)
+	}
+	countIdx := len(tte.countColumns) + 1
+	done := make(chan struct{})
+	defer close(done)
+	var rows <-chan []any
+	var mergeErr error
+	if tte.ngramSpiller != nil {
+		rows = tte.mergeSpilledColCountsRows(done, &mergeErr)
+
+	} else {
+		rows = tte.prepareColCountsRows(done)
+	}
+	i := 0
+	for args := range rows {
+		if err := tte.checkStop(); err != nil {
+			return err
+		}
+		summarizer.add(args)
+		if tte.freqList != nil {
+			tte.freqList.add(args)
+		}
+		if dict != nil {
+			if err := dict.encode(args); err != nil {
+				return err
+			}
+		}
+		tte.applyKeepEmpty(args)
+		if hapaxIns != nil && args[countIdx] == 1 {
+			if err := hapaxIns.Exec(args...); err != nil {
+				return err
+			}
+			tte.addWrittenRows(db.ColcountsHapaxTable, 1)
+
+		} else {
+			if err := ins.Exec(args...); err != nil {
+				return err
+			}
+			tte.addWrittenRows("colcounts", 1)
+		}
+
+		if i > 0 && i%1000 == 0 {
+			tte.sendStatus(tte.status(tte.lineCounter))
+			if i%100000 == 0 {
+				tte.logger.Info().
+					Str("phase", PhaseColcounts).
+					Int("rows", i).
+					Msg("next chunk of records processed")
+			}
+		}
+		i++
+	}
+	if mergeErr != nil {
+		return fmt.Errorf("failed to merge spilled n-gram counts: %w", mergeErr)
+	}
+	tte.ngramSummary = summarizer.result()
+	tte.logNgramSummary()
+	if tte.freqList != nil {
+		if err := tte.freqList.write(); err != nil {
+			return err
+		}
+	}
+	if tte.minFreq > 1 {
+		tte.logger.Info().
+			Int("minFreq", tte.minFreq).
+			Int64("numDropped", atomic.LoadInt64(&tte.droppedNgrams)).
+			Msg("Dropped infrequent n-grams")
+	}
+	return nil
+}
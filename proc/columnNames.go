@@ -0,0 +1,34 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+// outputColumnName returns a liveattrs_entry column name
+// of an attribute (see cnf.VTEConf.ColumnNames)
+func (tte *TTExtractor) outputColumnName(attr string) string {
+	if v, ok := tte.columnNames[attr]; ok {
+		return v
+	}
+	return attr
+}
+
+func (tte *TTExtractor) outputColumnNames(attrs []string) []string {
+	ans := make([]string, len(attrs))
+	for i, attr := range attrs {
+		ans[i] = tte.outputColumnName(attr)
+	}
+	return ans
+}
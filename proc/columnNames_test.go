@@ -0,0 +1,63 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestColumnNames(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<doc id=\"1\" srclang=\"cs\">\na\n</doc>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {"id", "srclang"}},
+		ColumnNames:   map[string]string{"doc_srclang": "doc_source_language"},
+		ValueMaps:     map[string]map[string]string{"doc_srclang": {"cs": "Czech"}},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"corpus_id=test, doc_id=1, doc_source_language=Czech, poscount=1, wordcount=0"},
+		writer.sortedRows("liveattrs_entry"),
+	)
+
+	for column, expectedErr := range map[string]string{
+		"doc_id":    "already used",
+		"corpus_id": "already used",
+		"doc-lang":  "invalid output column name",
+	} {
+		conf.ColumnNames = map[string]string{"doc_srclang": column}
+		_, err = NewExtractor(conf, WithWriter(writer))
+		assert.ErrorContains(t, err, expectedErr)
+	}
+	conf.ColumnNames = map[string]string{"doc_lang": "language"}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "not configured in structures")
+}
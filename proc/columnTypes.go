@@ -0,0 +1,47 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// typedValue converts a value of an attribute to the type of its
+// liveattrs_entry column (see cnf.VTEConf.ColumnTypes). Empty
+// and non-string values are returned unchanged. Values which
+// cannot be converted are logged and stored as NULL.
+func (tte *TTExtractor) typedValue(attr string, v any) any {
+	colType, ok := tte.columnTypes[attr]
+	if !ok {
+		return v
+	}
+	sv, isStr := v.(string)
+	if !isStr || sv == "" {
+		return v
+	}
+	ans, err := db.ConvertTypedValue(colType, sv)
+	if err != nil {
+		tte.logger.Warn().
+			Str("attr", attr).
+			Str("value", sv).
+			Str("type", colType).
+			Int("lineNumber", tte.lineCounter).
+			Msg("value does not match the column type, storing NULL")
+		return nil
+	}
+	return ans
+}
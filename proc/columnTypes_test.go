@@ -0,0 +1,85 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestColumnTypes(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte(
+			"<doc id=\"1\" year=\"01999\" score=\"2.50\" pub=\"2001-02-03\" open=\"1\">\na\n</doc>\n"+
+				"<doc id=\"2\" year=\"unknown\" score=\"1\" pub=\"\" open=\"false\">\nb\n</doc>\n"+
+				"<doc id=\"3\" year=\"\" score=\"\" pub=\"\" open=\"\">\nc\n</doc>\n",
+		),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {"id", "year", "score", "pub", "open"}},
+		ColumnTypes: map[string]string{
+			"doc_year":  db.ColumnTypeInteger,
+			"doc_score": db.ColumnTypeFloat,
+			"doc_pub":   db.ColumnTypeDate,
+			"doc_open":  db.ColumnTypeBoolean,
+		},
+		EmptyValues: map[string]db.EmptyValuePolicy{"doc_year": {Action: db.EmptyValueDefault, Default: "0"}},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"corpus_id=test, doc_id=1, doc_open=true, doc_pub=2001-02-03, doc_score=2.5, " +
+				"doc_year=1999, poscount=1, wordcount=0",
+			"corpus_id=test, doc_id=2, doc_open=false, doc_pub=, doc_score=1, doc_year=<nil>, poscount=1, wordcount=0",
+			"corpus_id=test, doc_id=3, doc_open=, doc_pub=, doc_score=, doc_year=0, poscount=1, wordcount=0",
+		},
+		writer.sortedRows("liveattrs_entry"),
+	)
+	assert.Equal(t, 0, tte.errorCounter)
+
+	conf.EmptyValues = map[string]db.EmptyValuePolicy{"doc_id": {Action: db.EmptyValueDefault, Default: "x1"}}
+	for typ, expectedErr := range map[string]string{
+		"decimal":            "unknown column type",
+		db.ColumnTypeInteger: "invalid default empty value",
+		db.ColumnTypeDate:    "invalid default empty value",
+	} {
+		conf.ColumnTypes = map[string]string{"doc_id": typ}
+		_, err = NewExtractor(conf, WithWriter(writer))
+		assert.ErrorContains(t, err, expectedErr)
+	}
+	conf.ColumnTypes = map[string]string{"doc_id": db.ColumnTypeInteger}
+	conf.EmptyValues = map[string]db.EmptyValuePolicy{"doc_id": {Action: db.EmptyValueKeep}}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "cannot keep empty values")
+}
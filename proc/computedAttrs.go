@@ -0,0 +1,82 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/expr"
+)
+
+// computedAttr is a compiled structural attribute
+// computed from other attributes
+type computedAttr struct {
+	name string
+	expr *expr.Expr
+}
+
+// compileComputedAttrs parses expressions of computed attributes and checks
+// that they refer only to configured structural attributes or to previously
+// defined computed attributes
+func compileComputedAttrs(
+	computedAttrs []cnf.ComputedAttrConf,
+	structures map[string][]string,
+) ([]computedAttr, error) {
+	known := make(map[string]bool)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			known[st+"_"+attr] = true
+		}
+	}
+	pending := make(map[string]bool)
+	for _, ca := range computedAttrs {
+		pending[ca.Name] = true
+	}
+	ans := make([]computedAttr, len(computedAttrs))
+	for i, ca := range computedAttrs {
+		e, err := expr.Parse(ca.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid computed attribute %s: %w", ca.Name, err)
+		}
+		for _, attr := range e.Attrs() {
+			if !known[attr] {
+				return nil, fmt.Errorf("computed attribute %s refers to unknown attribute %s", ca.Name, attr)
+			}
+			if pending[attr] {
+				return nil, fmt.Errorf(
+					"computed attribute %s refers to attribute %s which is not computed yet", ca.Name, attr)
+			}
+		}
+		delete(pending, ca.Name)
+		ans[i] = computedAttr{name: ca.Name, expr: e}
+	}
+	return ans, nil
+}
+
+// applyComputedAttrs evaluates all the computed attributes
+// and stores their values into attrs
+func (tte *TTExtractor) applyComputedAttrs(attrs map[string]any) error {
+	for _, ca := range tte.computedAttrs {
+		v, err := ca.expr.Eval(attrs)
+		if err != nil {
+			return fmt.Errorf("failed to compute attribute %s: %w", ca.name, err)
+		}
+		attrs[ca.name] = v
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestComputedAttrs(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte("<doc year=\"1987\" lang=\"cs\">\n<p>\na\n</p>\n</doc>\n<doc year=\"2003\" lang=\"en\">\n<p>\nb\n</p>\n</doc>\n"),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Strictness:    cnf.StrictnessStrict,
+		Structures:    map[string][]string{"doc": {"year", "lang"}, "p": {}},
+		ComputedAttrs: []cnf.ComputedAttrConf{
+			{Name: "doc_period", Expr: "decade(doc_year) + 's'"},
+			{Name: "doc_label", Expr: "if(doc_lang == 'cs', 'Czech', upper(doc_lang)) + ' ' + doc_period"},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"corpus_id=test, doc_label=Czech 1980s, doc_lang=cs, doc_period=1980s, doc_year=1987, poscount=1, wordcount=0",
+			"corpus_id=test, doc_label=EN 2000s, doc_lang=en, doc_period=2000s, doc_year=2003, poscount=1, wordcount=0",
+		},
+		writer.sortedRows("liveattrs_entry"),
+	)
+	// the provided configuration must not be modified
+	assert.Equal(t, []string{"year", "lang"}, conf.Structures["doc"])
+
+	conf.ComputedAttrs = []cnf.ComputedAttrConf{
+		{Name: "doc_label", Expr: "doc_period"},
+		{Name: "doc_period", Expr: "decade(doc_year)"},
+	}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "not computed yet")
+	conf.ComputedAttrs = []cnf.ComputedAttrConf{{Name: "doc_period", Expr: "decade(doc_date)"}}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "unknown attribute doc_date")
+}
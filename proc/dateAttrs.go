@@ -0,0 +1,209 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// dateFormatTokens maps tokens of date formats (see cnf.DateAttrConf)
+// to Go time layout elements. Longer tokens must go first.
+var dateFormatTokens = []struct {
+	token  string
+	layout string
+}{
+	{"YYYY", "2006"},
+	{"MM", "01"},
+	{"M", "1"},
+	{"DD", "02"},
+	{"D", "2"},
+}
+
+// dateFormat is a compiled format of values of a date attribute
+type dateFormat struct {
+	layout   string
+	hasMonth bool
+	hasDay   bool
+}
+
+// compileDateFormat translates a date format (e.g. DD.MM.YYYY)
+// to a Go time layout. Besides the tokens, only separators
+// (i.e. no letters, digits or underscores) are accepted.
+func compileDateFormat(src string) (dateFormat, error) {
+	var ans dateFormat
+	var layout strings.Builder
+	var hasYear bool
+	for rest := src; rest != ""; {
+		matched := false
+		for _, tk := range dateFormatTokens {
+			if !strings.HasPrefix(rest, tk.token) {
+				continue
+			}
+			var seen *bool
+			switch tk.token[0] {
+			case 'Y':
+				seen = &hasYear
+			case 'M':
+				seen = &ans.hasMonth
+			case 'D':
+				seen = &ans.hasDay
+			}
+			if *seen {
+				return dateFormat{}, fmt.Errorf("duplicate %s in date format %s", tk.token, src)
+			}
+			*seen = true
+			layout.WriteString(tk.layout)
+			rest = rest[len(tk.token):]
+			matched = true
+			break
+		}
+		if matched {
+			continue
+		}
+		r := []rune(rest)[0]
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return dateFormat{}, fmt.Errorf("unsupported character %c in date format %s", r, src)
+		}
+		layout.WriteRune(r)
+		rest = rest[len(string(r)):]
+	}
+	if !hasYear {
+		return dateFormat{}, fmt.Errorf("date format %s does not contain YYYY", src)
+	}
+	if ans.hasDay && !ans.hasMonth {
+		return dateFormat{}, fmt.Errorf("date format %s contains a day but no month", src)
+	}
+	ans.layout = layout.String()
+	return ans, nil
+}
+
+// dateAttr is a compiled configuration of a date attribute
+type dateAttr struct {
+	name    string
+	formats []dateFormat
+	invalid string
+}
+
+// normalize parses a value using the first matching format and returns
+// its ISO form along with the year and month (empty if unknown).
+// The last returned value is false if no format matches.
+func (da *dateAttr) normalize(v string) (string, string, string, bool) {
+	v = strings.TrimSpace(v)
+	for _, f := range da.formats {
+		t, err := time.Parse(f.layout, v)
+		if err != nil {
+			continue
+		}
+		year := strconv.Itoa(t.Year())
+		switch {
+		case f.hasDay:
+			return t.Format(db.DateFormat), year, strconv.Itoa(int(t.Month())), true
+		case f.hasMonth:
+			return t.Format("2006-01"), year, strconv.Itoa(int(t.Month())), true
+		default:
+			return t.Format("2006"), year, "", true
+		}
+	}
+	return "", "", "", false
+}
+
+// compileDateAttrs validates configuration of date attributes
+// (see cnf.VTEConf.DateAttrs) and compiles their formats
+func compileDateAttrs(
+	dateAttrs map[string]cnf.DateAttrConf,
+	structures map[string][]string,
+	multiValues map[string]string,
+	columnTypes map[string]string,
+) ([]dateAttr, error) {
+	known := make(map[string]bool)
+	for st, attrs := range structures {
+		for _, attr := range attrs {
+			known[st+"_"+attr] = true
+		}
+	}
+	ans := make([]dateAttr, 0, len(dateAttrs))
+	for name, conf := range dateAttrs {
+		if !known[name] {
+			return nil, fmt.Errorf("date attribute %s is not configured in structures", name)
+		}
+		if _, ok := multiValues[name]; ok {
+			return nil, fmt.Errorf("multi-value attribute %s cannot be a date attribute", name)
+		}
+		if len(conf.Formats) == 0 {
+			return nil, fmt.Errorf("date attribute %s has no formats", name)
+		}
+		da := dateAttr{name: name, invalid: conf.Invalid}
+		switch conf.Invalid {
+		case "":
+			da.invalid = cnf.DateInvalidError
+		case cnf.DateInvalidError, cnf.DateInvalidNull, cnf.DateInvalidKeep:
+		default:
+			return nil, fmt.Errorf("unknown invalid value handling %s of date attribute %s", conf.Invalid, name)
+		}
+		colType := columnTypes[name]
+		if colType != "" && colType != db.ColumnTypeString && colType != db.ColumnTypeDate {
+			return nil, fmt.Errorf("date attribute %s cannot be of type %s", name, colType)
+		}
+		for _, src := range conf.Formats {
+			f, err := compileDateFormat(src)
+			if err != nil {
+				return nil, fmt.Errorf("invalid format of date attribute %s: %w", name, err)
+			}
+			if colType == db.ColumnTypeDate && !f.hasDay {
+				return nil, fmt.Errorf(
+					"date attribute %s of type %s requires formats with a day, found %s", name, colType, src)
+			}
+			da.formats = append(da.formats, f)
+		}
+		ans = append(ans, da)
+	}
+	sort.Slice(ans, func(i, j int) bool { return ans[i].name < ans[j].name })
+	return ans, nil
+}
+
+// applyDateAttrs normalizes values of date attributes
+// and stores their derived year and month attributes
+func (tte *TTExtractor) applyDateAttrs(attrs map[string]any) error {
+	for _, da := range tte.dateAttrs {
+		v, ok := attrs[da.name].(string)
+		if !ok || v == "" {
+			continue
+		}
+		normalized, year, month, ok := da.normalize(v)
+		if ok {
+			attrs[da.name] = normalized
+			attrs[da.name+cnf.DateYearSuffix] = year
+			attrs[da.name+cnf.DateMonthSuffix] = month
+			continue
+		}
+		switch da.invalid {
+		case cnf.DateInvalidNull:
+			attrs[da.name] = ""
+		case cnf.DateInvalidError:
+			return fmt.Errorf("invalid date value '%s' of attribute %s", v, da.name)
+		}
+	}
+	return nil
+}
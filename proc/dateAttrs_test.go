@@ -0,0 +1,85 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestDateAttrs(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte(
+			"<doc id=\"1\" pub=\"3. 2. 2001\" born=\"1890\">\na\n</doc>\n"+
+				"<doc id=\"2\" pub=\"2001-12-24\" born=\"n/a\">\nb\n</doc>\n"+
+				"<doc id=\"3\" pub=\"05/1999\" born=\"\">\nc\n</doc>\n",
+		),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {"id", "pub", "born"}},
+		DateAttrs: map[string]cnf.DateAttrConf{
+			"doc_pub":  {Formats: []string{"YYYY-MM-DD", "D. M. YYYY", "MM/YYYY"}},
+			"doc_born": {Formats: []string{"YYYY"}, Invalid: cnf.DateInvalidKeep},
+		},
+		ComputedAttrs: []cnf.ComputedAttrConf{{Name: "doc_period", Expr: "decade(doc_pub_year)"}},
+		Strictness:    cnf.StrictnessStrict,
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"corpus_id=test, doc_born=, doc_born_month=, doc_born_year=, doc_id=3, doc_period=1990, " +
+				"doc_pub=1999-05, doc_pub_month=5, doc_pub_year=1999, poscount=1, wordcount=0",
+			"corpus_id=test, doc_born=1890, doc_born_month=, doc_born_year=1890, doc_id=1, doc_period=2000, " +
+				"doc_pub=2001-02-03, doc_pub_month=2, doc_pub_year=2001, poscount=1, wordcount=0",
+			"corpus_id=test, doc_born=n/a, doc_born_month=, doc_born_year=, doc_id=2, doc_period=2000, " +
+				"doc_pub=2001-12-24, doc_pub_month=12, doc_pub_year=2001, poscount=1, wordcount=0",
+		},
+		writer.sortedRows("liveattrs_entry"),
+	)
+
+	for format, expectedErr := range map[string]string{
+		"DD.MM.":      "does not contain YYYY",
+		"DD.YYYY":     "contains a day but no month",
+		"YYYY-MM-DDx": "unsupported character x",
+		"YYYY-YYYY":   "duplicate YYYY",
+	} {
+		conf.DateAttrs = map[string]cnf.DateAttrConf{"doc_pub": {Formats: []string{format}}}
+		_, err = NewExtractor(conf, WithWriter(writer))
+		assert.ErrorContains(t, err, expectedErr)
+	}
+	conf.DateAttrs = map[string]cnf.DateAttrConf{"doc_pub": {Formats: []string{"YYYY"}}}
+	conf.ColumnTypes = map[string]string{"doc_pub": db.ColumnTypeDate}
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "requires formats with a day")
+}
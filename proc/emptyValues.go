@@ -0,0 +1,57 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// atomValues prepares values of the current atom for the liveattrs_entry
+// insert with empty value policies applied and typed values converted.
+// The second returned value is false in case the atom should be skipped.
+func (tte *TTExtractor) atomValues() ([]any, bool) {
+	values := make([]any, len(tte.attrNames))
+	for i, n := range tte.attrNames {
+		if tte.currAtomAttrs[n] != nil {
+			values[i] = tte.currAtomAttrs[n]
+
+		} else {
+			values[i] = "" // liveattrs plug-in does not like NULLs
+		}
+		if policy, ok := tte.emptyValues[n]; ok {
+			if sv, isStr := values[i].(string); isStr {
+				var skip bool
+				values[i], skip = policy.Apply(sv)
+				if skip {
+					return nil, false
+				}
+			}
+		}
+		values[i] = tte.typedValue(n, values[i])
+	}
+	return values, true
+}
+
+// applyKeepEmpty replaces empty values of counted columns configured
+// with the "keep" empty value policy so they are not stored as NULL
+func (tte *TTExtractor) applyKeepEmpty(args []any) {
+	for _, i := range tte.keepEmptyCols {
+		if args[i] == "" {
+			args[i] = db.EmptyString{}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestEmptyValues(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte("<p id=\"1\" genre=\"\" lang=\"cs\">\na\n-\n</p>\n<p id=\"2\" genre=\"x\" author=\"y\" lang=\"\">\nb\n</p>\n"),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {"id", "genre", "author", "lang"}},
+		EmptyValues: map[string]db.EmptyValuePolicy{
+			"p_genre":  {Action: db.EmptyValueKeep},
+			"p_author": {Action: db.EmptyValueDefault, Default: "unknown"},
+			"p_lang":   {Action: db.EmptyValueSkip},
+		},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			VertColumns: db.VertColumns{{Idx: 0, ModFn: "regexp:^-$:"}},
+		},
+	}
+	run := func(onEmpty *db.EmptyValuePolicy) *recordingWriter {
+		conf.Ngrams.VertColumns[0].OnEmpty = onEmpty
+		writer := &recordingWriter{rows: make(map[string]*[]string)}
+		tte, err := NewExtractor(conf, WithWriter(writer))
+		assert.NoError(t, err)
+		_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+		assert.NoError(t, err)
+		return writer
+	}
+	colValues := func(writer *recordingWriter) []string {
+		ans := make([]string, 0, 3)
+		for _, row := range writer.sortedRows("colcounts") {
+			ans = append(ans, strings.Split(row, ", ")[1])
+		}
+		return ans
+	}
+
+	writer := run(nil)
+	rows := writer.sortedRows("liveattrs_entry")
+	assert.Len(t, rows, 1)
+	assert.Contains(t, rows[0], `p_genre=""`)
+	assert.Contains(t, rows[0], "p_author=unknown")
+	assert.Contains(t, rows[0], "p_lang=cs")
+	assert.Equal(t, []string{"col0=", "col0=a", "col0=b"}, colValues(writer))
+	assert.Equal(
+		t,
+		[]string{"col0=\"\"", "col0=a", "col0=b"},
+		colValues(run(&db.EmptyValuePolicy{Action: db.EmptyValueKeep})),
+	)
+	assert.Equal(
+		t,
+		[]string{"col0=a", "col0=b", "col0=punct"},
+		colValues(run(&db.EmptyValuePolicy{Action: db.EmptyValueDefault, Default: "punct"})),
+	)
+	assert.Equal(
+		t,
+		[]string{"col0=a", "col0=b"},
+		colValues(run(&db.EmptyValuePolicy{Action: db.EmptyValueSkip})),
+	)
+
+	conf.EmptyValues = map[string]db.EmptyValuePolicy{"p_lang": {Action: "drop"}}
+	_, err := NewExtractor(conf, WithWriter(&recordingWriter{rows: make(map[string]*[]string)}))
+	assert.ErrorContains(t, err, "unknown empty value action")
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+	"github.com/rs/zerolog"
+)
+
+// freqListExporter collects rows of the colcounts table and writes
+// them sorted by frequency (descending) into a TSV file with a header
+// row. Columns corpus_id and hash_id are omitted and so is arf
+// in case it has not been calculated.
+type freqListExporter struct {
+	file     *fs.AtomicFile
+	colIdxs  []int
+	header   []string
+	countCol int
+	rows     [][]any
+	logger   *zerolog.Logger
+}
+
+// setColumns selects exported columns from the colcounts attributes
+func (fe *freqListExporter) setColumns(attrs []string, withARF bool) {
+	fe.colIdxs = make([]int, 0, len(attrs))
+	fe.header = make([]string, 0, len(attrs))
+	for i, attr := range attrs {
+		if attr == "corpus_id" || attr == "hash_id" || attr == "arf" && !withARF {
+			continue
+		}
+		if attr == "count" {
+			fe.countCol = len(fe.colIdxs)
+		}
+		fe.colIdxs = append(fe.colIdxs, i)
+		fe.header = append(fe.header, attr)
+	}
+}
+
+// add adds a colcounts row (with values matching the attributes
+// passed to setColumns)
+func (fe *freqListExporter) add(row []any) {
+	item := make([]any, len(fe.colIdxs))
+	for i, idx := range fe.colIdxs {
+		item[i] = row[idx]
+	}
+	fe.rows = append(fe.rows, item)
+}
+
+// write sorts collected rows and writes them to the (uncommitted) file.
+// Rows with the same frequency are sorted by their values.
+func (fe *freqListExporter) write() error {
+	lines := make([]string, len(fe.rows))
+	counts := make([]int, len(fe.rows))
+	for i, row := range fe.rows {
+		items := make([]string, len(row))
+		for j, v := range row {
+			items[j] = fmt.Sprint(v)
+		}
+		lines[i] = strings.Join(items, "\t")
+		counts[i], _ = row[fe.countCol].(int)
+	}
+	fe.rows = nil
+	idxs := make([]int, len(lines))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.Slice(idxs, func(i, j int) bool {
+		if counts[idxs[i]] != counts[idxs[j]] {
+			return counts[idxs[i]] > counts[idxs[j]]
+		}
+		return lines[idxs[i]] < lines[idxs[j]]
+	})
+	if _, err := fe.file.Write([]byte(strings.Join(fe.header, "\t") + "\n")); err != nil {
+		return fmt.Errorf("failed to write frequency list: %w", err)
+	}
+	for _, idx := range idxs {
+		if _, err := fe.file.Write([]byte(lines[idx] + "\n")); err != nil {
+			return fmt.Errorf("failed to write frequency list: %w", err)
+		}
+	}
+	return nil
+}
+
+func (fe *freqListExporter) commit() error {
+	if err := fe.file.Commit(); err != nil {
+		return fmt.Errorf("failed to commit frequency list: %w", err)
+	}
+	fe.logger.Info().Str("path", fe.file.Path()).Msg("Frequency list written")
+	return nil
+}
+
+// discard removes the written file
+func (fe *freqListExporter) discard() {
+	if err := fe.file.Discard(); err != nil {
+		fe.logger.Error().Err(err).Str("file", fe.file.Path()).Msg("failed to remove unfinished frequency list")
+	}
+}
+
+func newFreqListExporter(dir string, corpusID string, logger *zerolog.Logger) (*freqListExporter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create frequency list directory: %w", err)
+	}
+	f, err := fs.NewAtomicFile(filepath.Join(dir, corpusID+".freq.tsv"), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frequency list file: %w", err)
+	}
+	return &freqListExporter{file: f, logger: logger}, nil
+}
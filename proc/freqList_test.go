@@ -0,0 +1,53 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestFreqListExport(t *testing.T) {
+	tmpDir := t.TempDir()
+	vertPath := filepath.Join(tmpDir, "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nb\na\nc\n</p>\n<p>\na\nc\na\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:         "test",
+		AtomStructure:  "p",
+		Structures:     map[string][]string{"p": {}},
+		FreqListExport: &cnf.FreqListExportConf{Dir: filepath.Join(tmpDir, "freq")},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(tmpDir, "freq", "test.freq.tsv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "col0\tcount\na\t3\nc\t2\nb\t1\n", string(data))
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/input"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// AtomHook allows embedding applications to enrich, transform or veto
+// atoms (i.e. rows of the liveattrs_entry table). OnAtom is called
+// with accumulated attributes of an atom (keys are in the [struct]_[attr]
+// form plus special ones like "poscount", "corpus_id", "item_id") just
+// before the atom is written. The map can be modified in place. Please note
+// that only keys matching existing columns are written - so to inject
+// a computed attribute, the attribute must be configured in "structures".
+// By returning false, the atom is skipped. An error is handled the same
+// way as other processing errors (see maxNumErrors).
+type AtomHook interface {
+	OnAtom(attrs map[string]any) (bool, error)
+}
+
+// AtomHookFunc is an adapter allowing use of ordinary functions
+// as AtomHook
+type AtomHookFunc func(attrs map[string]any) (bool, error)
+
+func (fn AtomHookFunc) OnAtom(attrs map[string]any) (bool, error) {
+	return fn(attrs)
+}
+
+// SetAtomHook registers a hook called for each atom before it is
+// written. It must be called before Run.
+func (tte *TTExtractor) SetAtomHook(hook AtomHook) {
+	tte.atomHook = hook
+}
+
+// teeProcessor forwards all the parser events to the extractor
+// and then to additional (user-registered) line processors.
+type teeProcessor struct {
+	tte    *TTExtractor
+	extras []vertigo.LineProcessor
+}
+
+func (tp *teeProcessor) ProcToken(tk *vertigo.Token, line int, err error) error {
+	if procErr := tp.tte.ProcToken(tk, line, err); procErr != nil {
+		return procErr
+	}
+	for _, lproc := range tp.extras {
+		if procErr := lproc.ProcToken(tk, line, err); procErr != nil {
+			return fmt.Errorf("additional line processor failed: %w", procErr)
+		}
+	}
+	return nil
+}
+
+func (tp *teeProcessor) ProcStruct(st *vertigo.Structure, line int, err error) error {
+	if procErr := tp.tte.ProcStruct(st, line, err); procErr != nil {
+		return procErr
+	}
+	for _, lproc := range tp.extras {
+		if procErr := lproc.ProcStruct(st, line, err); procErr != nil {
+			return fmt.Errorf("additional line processor failed: %w", procErr)
+		}
+	}
+	return nil
+}
+
+func (tp *teeProcessor) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
+	if procErr := tp.tte.ProcStructClose(st, line, err); procErr != nil {
+		return procErr
+	}
+	for _, lproc := range tp.extras {
+		if procErr := lproc.ProcStructClose(st, line, err); procErr != nil {
+			return fmt.Errorf("additional line processor failed: %w", procErr)
+		}
+	}
+	return nil
+}
+
+func (tp *teeProcessor) ProcMalformedLine(text string, line int, err error) error {
+	if procErr := tp.tte.ProcMalformedLine(text, line, err); procErr != nil {
+		return procErr
+	}
+	for _, lproc := range tp.extras {
+		mlproc, ok := lproc.(input.MalformedLineProcessor)
+		if !ok {
+			continue
+		}
+		if procErr := mlproc.ProcMalformedLine(text, line, err); procErr != nil {
+			return fmt.Errorf("additional line processor failed: %w", procErr)
+		}
+	}
+	return nil
+}
+
+// AddLineProcessor registers an additional line processor receiving
+// the same parser events (tokens, structures, closing tags and - if
+// input.MalformedLineProcessor is implemented - malformed lines) as
+// the extractor itself during the main parsing pass. This allows
+// embedding applications to perform their own analysis without
+// parsing the vertical again. Each event is passed to additional
+// processors only after the extractor processed it. An error returned
+// by an additional processor stops the whole run. The method must be
+// called before Run.
+func (tte *TTExtractor) AddLineProcessor(lproc vertigo.LineProcessor) {
+	tte.extraProcs = append(tte.extraProcs, lproc)
+}
+
+// fullLineProcessor is a line processor able to handle also
+// malformed lines
+type fullLineProcessor interface {
+	vertigo.LineProcessor
+	input.MalformedLineProcessor
+}
+
+// lineProcessor returns a processor for the main parsing pass
+func (tte *TTExtractor) lineProcessor() fullLineProcessor {
+	if len(tte.extraProcs) == 0 {
+		return tte
+	}
+	return &teeProcessor{tte: tte, extras: tte.extraProcs}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestAtomHook(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num", "parity"}},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	go func() {
+		for range statusChan {
+		}
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	tte.SetAtomHook(AtomHookFunc(func(attrs map[string]any) (bool, error) {
+		if attrs["doc_id"] != "d0" {
+			return false, nil
+		}
+		num, _ := strconv.Atoi(attrs["p_num"].(string))
+		if num%2 == 0 {
+			attrs["p_parity"] = "even"
+
+		} else {
+			attrs["p_parity"] = "odd"
+		}
+		return true, nil
+	}))
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	close(statusChan)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, stats.InsertedAtoms)
+	rows := writer.sortedRows("liveattrs_entry")
+	assert.Contains(t, rows[0], "doc_id=d0")
+	assert.Contains(t, rows[0], "p_num=0")
+	assert.Contains(t, rows[0], "p_parity=even")
+}
+
+type countingProcessor struct {
+	tokens    int
+	structs   int
+	closings  int
+	lastToken int
+}
+
+func (cp *countingProcessor) ProcToken(tk *vertigo.Token, line int, err error) error {
+	cp.tokens++
+	cp.lastToken = tk.Idx
+	return nil
+}
+
+func (cp *countingProcessor) ProcStruct(st *vertigo.Structure, line int, err error) error {
+	cp.structs++
+	return nil
+}
+
+func (cp *countingProcessor) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
+	cp.closings++
+	return nil
+}
+
+func TestAdditionalLineProcessor(t *testing.T) {
+	vertPath := createTestVertical(t)
+	for _, numWorkers := range []int{1, 4} {
+		conf := &cnf.VTEConf{
+			Corpus:        "test",
+			AtomStructure: "p",
+			Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+			NumWorkers:    numWorkers,
+		}
+		writer := &recordingWriter{rows: make(map[string]*[]string)}
+		cp := &countingProcessor{}
+		tte, err := NewExtractor(conf, WithWriter(writer), WithLineProcessors(cp))
+		assert.NoError(t, err)
+		_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+		assert.NoError(t, err)
+		assert.Equal(t, 450, cp.tokens)
+		assert.Equal(t, 449, cp.lastToken)
+		assert.Equal(t, 105, cp.structs)
+		assert.Equal(t, 105, cp.closings)
+	}
+}
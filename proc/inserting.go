@@ -17,27 +17,33 @@
 package proc
 
 import (
-	"crypto/sha1"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"time"
 	"unicode/utf8"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/czcorpus/vert-tagextract/v2/cnf"
 	"github.com/czcorpus/vert-tagextract/v2/db"
 	"github.com/czcorpus/vert-tagextract/v2/db/colgen"
+	"github.com/czcorpus/vert-tagextract/v2/input"
+	"github.com/czcorpus/vert-tagextract/v2/intern"
 	"github.com/czcorpus/vert-tagextract/v2/ptcount"
 	"github.com/czcorpus/vert-tagextract/v2/ptcount/modders"
 
-	_ "github.com/mattn/go-sqlite3" // sqlite3 driver load
 	"github.com/tomachalek/vertigo/v5"
 )
 
 var (
 	ErrorTooManyParsingErrors = errors.New("too many parsing errors")
+
+	// errAtomLimitReached stops parsing once the configured
+	// number of atoms (maxAtoms) is processed
+	errAtomLimitReached = errors.New("atom limit reached")
 )
 
 func trimString(s string) string {
@@ -48,13 +54,62 @@ func trimString(s string) string {
 	return string([]rune(s)[:limit])
 }
 
+const (
+	PhaseInitialize = "initialize"
+	PhaseParsing    = "parsing"
+	PhaseARF        = "arf"
+	PhaseAssoc      = "assoc"
+	PhaseColcounts  = "colcounts"
+	PhaseTFIDF      = "tfidf"
+	PhaseCommit     = "commit"
+)
+
+// PhaseTiming describes a finished processing phase
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
 // Status stores some basic information about vertical file processing
 type Status struct {
-	Datetime       time.Time
-	File           string
-	ProcessedAtoms int
-	ProcessedLines int
-	Error          error
+	Datetime        time.Time
+	File            string
+	ProcessedAtoms  int
+	ProcessedLines  int
+	ProcessedTokens int
+	Error           error
+
+	// Phase is set in case the status reports
+	// a finished processing phase
+	Phase *PhaseTiming
+
+	// ParseErrors contains a report of skipped malformed lines
+	// (sent once the parsing is finished)
+	ParseErrors []ParseError
+
+	// ProcessedBytes is number of bytes of the current file read
+	// so far (for compressed files, compressed bytes are counted)
+	ProcessedBytes int64
+
+	// TotalBytes is size of the current file (-1 if unknown)
+	TotalBytes int64
+
+	// Stats is set in the final status of a successful run
+	Stats *RunStats
+}
+
+// Progress returns an estimated progress of the current file
+// processing in percents. In case the size of the file is unknown,
+// -1 is returned.
+func (s Status) Progress() float64 {
+	if s.TotalBytes <= 0 {
+		return -1
+	}
+	ans := float64(s.ProcessedBytes) / float64(s.TotalBytes) * 100
+	if ans > 100 {
+		ans = 100
+	}
+	return ans
 }
 
 // TTExtractor handles writing parsed data
@@ -67,9 +122,11 @@ type TTExtractor struct {
 	maxNumErrors       int
 	tokenInAtomCounter int
 	tokenCounter       int
+	processedTokens    int
 	corpusID           string
 	database           db.Writer
 	docInsert          db.InsertOperation
+	itemCountsInsert   db.InsertOperation
 	dbConf             *db.Conf
 	attrAccum          AttrAccumulator
 	atomStruct         string
@@ -80,17 +137,144 @@ type TTExtractor struct {
 	colgenFn           colgen.AlignedColGenFn
 	currAtomAttrs      map[string]interface{}
 	ngramConf          *cnf.NgramConf
-	currSentence       [][]int
-	valueDict          *ptcount.WordDict
+	countColumns       db.VertColumns
+	tokenFilter        *ptcount.TokenFilter
+	ngrams             *ptcount.NgramCollector
+	ngramSpiller       *ptcount.NgramSpiller
+	strPool            *intern.Pool
 	columnModders      []*modders.StringTransformerChain
-	colCounts          map[string]*ptcount.NgramCounter
 	filter             LineFilter
 	stopChan           <-chan os.Signal
 	statusChan         chan<- Status
+	ctx                context.Context
+
+	// numWorkers specifies number of parallel parsing workers.
+	// Values lower than 2 mean sequential processing.
+	numWorkers int
+
+	// countNgrams specifies whether n-grams are counted
+	// by ProcToken (in the parallel mode, it is done by workers)
+	countNgrams bool
+
+	// minFreq is an effective minimum frequency of written n-grams
+	// (see cnf.NgramConf.MinFreq and cnf.NgramConf.Hapaxes)
+	minFreq int
+
+	// droppedNgrams counts n-grams not written due to ngrams.minFreq
+	// (it is updated atomically)
+	droppedNgrams int64
+
+	// multiValues maps multi-value attributes to their delimiters
+	// (see cnf.VTEConf.MultiValues)
+	multiValues      map[string]string
+	multiValueInsert db.InsertOperation
+
+	// columnNames maps structural attributes to output columns
+	// (see cnf.VTEConf.ColumnNames)
+	columnNames map[string]string
+
+	// columnTypes maps structural attributes to types
+	// of their output columns (see cnf.VTEConf.ColumnTypes)
+	columnTypes map[string]string
+
+	// inferredColumns contains column types inferred from
+	// the vertical (see cnf.VTEConf.InferColumnTypes)
+	inferredColumns map[string]db.InferredColumn
+
+	// valueMaps translates values of structural attributes
+	// (see cnf.VTEConf.ValueMaps)
+	valueMaps map[string]map[string]string
+
+	// dateAttrs are normalized date attributes
+	// (see cnf.VTEConf.DateAttrs)
+	dateAttrs []dateAttr
+
+	// binnedAttrs are evaluated once an atom is closed
+	// (see cnf.VTEConf.BinnedAttrs)
+	binnedAttrs []binnedAttr
+
+	// computedAttrs are evaluated in the defined order
+	// for each atom (see cnf.VTEConf.ComputedAttrs)
+	computedAttrs []computedAttr
+
+	// emptyValues contains empty value policies of structural
+	// attributes (see cnf.VTEConf.EmptyValues)
+	emptyValues map[string]db.EmptyValuePolicy
+
+	// keepEmptyCols contains indices of counted columns with
+	// the "keep" empty value policy
+	keepEmptyCols []int
+
+	// colcountsStager is set in case partial n-gram counts
+	// are flushed to a staging table during parsing
+	colcountsStager db.ColcountsStager
+	stagingInsert   db.InsertOperation
+	lastFlushToken  int
+
+	// currentFile is the vertical file currently being processed
+	currentFile string
+
+	inputFormat  string
+	teiConf      *cnf.TEIConf
+	lineParser   *input.LineParser
+	readProgress *input.ReadProgress
+
+	// sattrs (if set) exports structures as CWB s-attribute files
+	sattrs *sattrExporter
+
+	// ngramSummary summarizes written n-grams (nil if no n-grams
+	// have been written or the counts have been staged)
+	ngramSummary *NgramSummary
+
+	// freqList (if set) exports counted n-grams as a frequency list
+	freqList *freqListExporter
+
+	// tagDistrib (if set) counts frequencies of tags per corpus and text types
+	tagDistrib *tagDistribCounter
+
+	// nextTokenPos is a corpus position of the next token
+	nextTokenPos int
+
+	// corpusTokens is a total size of the corpus including data
+	// written by previous runs (see db.CorpusSizesUpdater)
+	corpusTokens int
+
+	// maxParseErrors is a number of malformed lines which
+	// can be skipped (and reported in the end)
+	maxParseErrors int
+	parseErrors    []ParseError
+	skippedLines   int
+
+	// strictParsing makes any malformed line a fatal error
+	strictParsing bool
+
+	structCheck *structChecker
+
+	// maxAtoms, if positive, limits number of processed atoms.
+	// Once reached, tokenLimit contains the position of the first
+	// token which is not processed.
+	maxAtoms   int
+	tokenLimit int
+
+	phases       []PhaseTiming
+	rowsWritten  map[string]int
+	tableColumns map[string][]string
+
+	// phaseRows is a number of rows written since
+	// the last finished processing phase
+	phaseRows int
+
+	atomHook   AtomHook
+	extraProcs []vertigo.LineProcessor
+
+	logger zerolog.Logger
 }
 
 // NewTTExtractor is a factory function to
 // instantiate proper TTExtractor.
+//
+// Deprecated: use NewExtractor with WithWriter, WithColgen,
+// WithStatusChan and WithStopChan options instead.
 func NewTTExtractor(
 	database db.Writer,
 	conf *cnf.VTEConf,
@@ -98,32 +282,186 @@ func NewTTExtractor(
 	statusChan chan Status,
 	stopChan <-chan os.Signal,
 ) (*TTExtractor, error) {
+	return NewExtractor(
+		conf,
+		WithWriter(database),
+		WithColgen(colgenFn),
+		WithStatusChan(statusChan),
+		WithStopChan(stopChan),
+	)
+}
+
+// NewExtractor creates a new TTExtractor based on the provided
+// configuration (which is validated first, see cnf.VTEConf.Validate).
+// A database writer (see WithWriter) is required, all the other
+// options are optional. Derived attributes are resolved on a copy
+// of the configuration, the provided one is not modified.
+func NewExtractor(conf *cnf.VTEConf, opts ...Option) (*TTExtractor, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
 	filter, err := LoadCustomFilter(conf.Filter.Lib, conf.Filter.Fn)
 	if err != nil {
 		return nil, err
 	}
+	conf, err = conf.WithDerivedAttrs()
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.Ngrams.ResolveSize(); err != nil {
+		return nil, err
+	}
 	ans := &TTExtractor{
-		database:         database,
 		dbConf:           &conf.DB,
 		corpusID:         conf.Corpus,
 		atomStruct:       conf.AtomStructure,
 		atomParentStruct: conf.AtomParentStructure,
 		lastAtomOpenLine: -1,
 		structures:       conf.Structures,
-		colgenFn:         colgenFn,
 		ngramConf:        &conf.Ngrams,
-		colCounts:        make(map[string]*ptcount.NgramCounter),
 		columnModders:    make([]*modders.StringTransformerChain, conf.Ngrams.VertColumns.MaxColumn()+1),
 		filter:           filter,
 		maxNumErrors:     conf.MaxNumErrors,
-		currSentence:     make([][]int, 0, 20),
-		valueDict:        ptcount.NewWordDict(),
-		statusChan:       statusChan,
-		stopChan:         stopChan,
+		maxParseErrors:   conf.MaxParseErrors,
+		strictParsing:    conf.Parser.IsStrict(),
+		maxAtoms:         conf.MaxAtoms,
+		minFreq:          conf.Ngrams.MinFreq,
+		numWorkers:       conf.NumWorkers,
+		countNgrams:      len(conf.Ngrams.VertColumns) > 0,
+		ctx:              context.Background(),
+		readProgress:     input.NewReadProgress(),
+		rowsWritten:      make(map[string]int),
+		tableColumns:     make(map[string][]string),
+		inputFormat:      conf.InputFormat,
+		teiConf:          conf.TEI,
+		logger:           log.With().Str("corpus", conf.Corpus).Logger(),
+	}
+	for _, opt := range opts {
+		opt(ans)
+	}
+	if ans.database == nil {
+		return nil, fmt.Errorf("no database writer specified")
+	}
+	ans.structCheck = newStructChecker(
+		conf.Structures,
+		conf.Strictness == cnf.StrictnessStrict,
+		conf.AtomStructure,
+		conf.AtomParentStructure,
+	)
+	if ans.inputFormat == "" {
+		ans.inputFormat = cnf.InputFormatVertical
 	}
 
 	for _, m := range conf.Ngrams.VertColumns {
-		ans.columnModders[m.Idx] = modders.NewStringTransformerChain(m.ModFn)
+		ans.columnModders[m.Idx], err = newColumnModder(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modifier of column %d: %w", m.Idx, err)
+		}
+	}
+	ans.countColumns = conf.Ngrams.CountColumns()
+	for i, col := range ans.countColumns {
+		if col.OnEmpty != nil && col.OnEmpty.Action == db.EmptyValueKeep {
+			ans.keepEmptyCols = append(ans.keepEmptyCols, i)
+		}
+	}
+	if err := ptcount.CheckNgramKeySize(ans.ngramConf); err != nil {
+		return nil, err
+	}
+	if conf.Ngrams.Hapaxes == cnf.HapaxesDrop && ans.minFreq < 2 {
+		ans.minFreq = 2
+	}
+	if conf.Ngrams.ItemCounts && ans.colgenFn == nil {
+		return nil, fmt.Errorf("itemCounts requires selfJoin to generate item_id")
+	}
+	if len(conf.MultiValues) > 0 {
+		if ans.colgenFn == nil {
+			return nil, fmt.Errorf("multiValues requires selfJoin to generate item_id")
+		}
+		ans.multiValues = conf.MultiValues
+	}
+	ans.columnNames = conf.ColumnNames
+	ans.columnTypes = conf.ColumnTypes
+	ans.inferredColumns = conf.InferredColumns
+	ans.valueMaps = conf.ValueMaps
+	ans.dateAttrs, err = compileDateAttrs(conf.DateAttrs, conf.Structures, conf.MultiValues, conf.ColumnTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, da := range ans.dateAttrs {
+		ans.structCheck.computed[da.name+cnf.DateYearSuffix] = true
+		ans.structCheck.computed[da.name+cnf.DateMonthSuffix] = true
+	}
+	ans.binnedAttrs, err = compileBinnedAttrs(conf.BinnedAttrs, conf.Structures)
+	if err != nil {
+		return nil, err
+	}
+	for _, ba := range ans.binnedAttrs {
+		ans.structCheck.computed[ba.name] = true
+	}
+	ans.computedAttrs, err = compileComputedAttrs(conf.ComputedAttrs, conf.Structures)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBinnedAttrsUsage(ans.binnedAttrs, ans.computedAttrs); err != nil {
+		return nil, err
+	}
+	for _, ca := range ans.computedAttrs {
+		ans.structCheck.computed[ca.name] = true
+	}
+	ans.emptyValues = conf.EmptyValues
+	ans.ngrams = ptcount.NewNgramCollector(ans.ngramConf, ans.columnModders)
+	ans.tokenFilter, err = ptcount.NewTokenFilter(conf.Ngrams.VertColumns)
+	if err != nil {
+		return nil, err
+	}
+	ans.ngrams.SetTokenFilter(ans.tokenFilter)
+	if conf.Ngrams.CalcARF && conf.Ngrams.ARFSinglePass {
+		ans.ngrams.RecordPositions()
+	}
+	if conf.Ngrams.TFIDF {
+		ans.ngrams.RecordDocCounts()
+	}
+	if conf.Ngrams.ItemCounts {
+		ans.ngrams.RecordItemCounts()
+	}
+	if conf.Ngrams.Spill != nil {
+		ans.ngramSpiller = ptcount.NewNgramSpiller(
+			conf.Ngrams.Spill.Dir, conf.Ngrams.Spill.MaxNgramsInMemory, &conf.Ngrams)
+	}
+	if conf.Ngrams.FlushEveryTokens > 0 {
+		stager, ok := ans.database.(db.ColcountsStager)
+		if ok {
+			ans.colcountsStager = stager
+
+		} else {
+			ans.logger.Warn().Msg(
+				"database writer does not support staging of n-gram counts, counts will be written at the end")
+		}
+	}
+	ans.lineParser, err = input.NewLineParser(conf.ColumnSeparator, conf.ColumnSeparatorRegexp)
+	if err != nil {
+		return nil, err
+	}
+	if conf.InternStrings {
+		ans.strPool = intern.NewPool(0)
+	}
+	if conf.SAttrExport != nil {
+		ans.sattrs, err = newSAttrExporter(conf.SAttrExport.Dir, conf.Structures, &ans.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.FreqListExport != nil {
+		ans.freqList, err = newFreqListExporter(conf.FreqListExport.Dir, conf.Corpus, &ans.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.TagDistrib != nil {
+		ans.tagDistrib, err = newTagDistribCounter(conf.TagDistrib)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if conf.StackStructEval {
 		ans.attrAccum = newStructStack()
@@ -140,11 +478,46 @@ func (tte *TTExtractor) GetNumTokens() int {
 }
 
 func (tte *TTExtractor) WordDict() *ptcount.WordDict {
-	return tte.valueDict
+	return tte.ngrams.WordDict()
+}
+
+func (tte *TTExtractor) GetColCounts() *ptcount.NgramMap {
+	return tte.ngrams.Counts()
+}
+
+// status creates a processing status based on the current
+// state of the extractor
+func (tte *TTExtractor) status(lineNum int) Status {
+	return Status{
+		Datetime:        time.Now(),
+		File:            tte.currentFile,
+		ProcessedAtoms:  tte.atomCounter,
+		ProcessedLines:  lineNum,
+		ProcessedTokens: tte.processedTokens,
+		ProcessedBytes:  tte.readProgress.BytesRead(),
+		TotalBytes:      tte.readProgress.TotalBytes(),
+	}
 }
 
-func (tte *TTExtractor) GetColCounts() map[string]*ptcount.NgramCounter {
-	return tte.colCounts
+// sendStatus sends a processing status in case
+// a status channel is configured
+func (tte *TTExtractor) sendStatus(st Status) {
+	if tte.statusChan != nil {
+		tte.statusChan <- st
+	}
+}
+
+// checkStop returns an error in case the processing should stop
+// (either because of a stop signal or a cancelled context)
+func (tte *TTExtractor) checkStop() error {
+	select {
+	case s := <-tte.stopChan:
+		return fmt.Errorf("received stop signal: %s", s)
+	case <-tte.ctx.Done():
+		return fmt.Errorf("extraction cancelled: %w", tte.ctx.Err())
+	default:
+		return nil
+	}
 }
 
 // handleProcError reports a provided error err by sending it via
@@ -153,13 +526,10 @@ func (tte *TTExtractor) GetColCounts() map[string]*ptcount.NgramCounter {
 // it returns ErrorTooManyParsingErrors which should be considered a processing
 // stop signal (but it's still up to the consumer).
 func (tte *TTExtractor) handleProcError(lineNum int, err error) error {
-	tte.statusChan <- Status{
-		Datetime:       time.Now(),
-		ProcessedAtoms: tte.atomCounter,
-		ProcessedLines: lineNum,
-		Error:          err,
-	}
-	log.Error().Err(err).Int("lineNumber", lineNum).Msg("parsing error")
+	st := tte.status(lineNum)
+	st.Error = err
+	tte.sendStatus(st)
+	tte.logger.Error().Err(err).Int("lineNumber", lineNum).Msg("parsing error")
 	tte.errorCounter++
 	if tte.errorCounter > tte.maxNumErrors {
 		return ErrorTooManyParsingErrors
@@ -170,47 +540,27 @@ func (tte *TTExtractor) handleProcError(lineNum int, err error) error {
 // ProcToken is a part of vertigo.LineProcessor implementation.
 // It is called by Vertigo parser when a token line is encountered.
 func (tte *TTExtractor) ProcToken(tk *vertigo.Token, line int, err error) error {
-	select {
-	case s := <-tte.stopChan:
-		return fmt.Errorf("received stop signal: %s", s)
-	default:
+	if err := tte.checkStop(); err != nil {
+		return err
 	}
 	if err != nil {
 		return tte.handleProcError(line, err)
 	}
 	tte.lineCounter = line
+	tte.processedTokens++
+	tte.nextTokenPos = tk.Idx + 1
 	if tte.filter.Apply(tk, tte.attrAccum) {
 		tte.tokenInAtomCounter++
 		tte.tokenCounter = tk.Idx
-		attributes := make([]int, tte.ngramConf.MaxRequiredColumn()+1)
-		for _, vertCol := range tte.ngramConf.VertColumns {
-			v := tk.PosAttrByIndex(vertCol.Idx)
-			attributes[vertCol.Idx] = tte.valueDict.Add(tte.columnModders[vertCol.Idx].Transform(v))
-		}
-
-		tte.currSentence = append(tte.currSentence, attributes)
-		if len(tte.currSentence) >= tte.ngramConf.NgramSize {
-			ngram := ptcount.NewNgramCounter(tte.ngramConf.NgramSize)
-			startPos := len(tte.currSentence) - tte.ngramConf.NgramSize
-			for i := startPos; i < len(tte.currSentence); i++ {
-				ngram.AddToken(tte.currSentence[i])
-			}
-			key := ngram.UniqueID()
-			cnt, ok := tte.colCounts[key]
-			if !ok {
-				tte.colCounts[key] = ngram
-
-			} else {
-				cnt.IncCount()
-			}
+		if tte.countNgrams {
+			tte.ngrams.AddToken(tk)
+		}
+		if tte.tagDistrib != nil {
+			tte.tagDistrib.addToken(tk)
 		}
 	}
 	if line%1000 == 0 {
-		tte.statusChan <- Status{
-			Datetime:       time.Now(),
-			ProcessedAtoms: tte.atomCounter,
-			ProcessedLines: line,
-		}
+		tte.sendStatus(tte.status(line))
 	}
 	return nil
 }
@@ -219,6 +569,9 @@ func (tte *TTExtractor) getCurrentAccumAttrs() map[string]interface{} {
 	attrs := make(map[string]interface{})
 	tte.attrAccum.ForEachAttr(func(s string, k string, v string) bool {
 		if tte.acceptAttr(s, k) {
+			if tte.strPool != nil {
+				v = tte.strPool.Get(v)
+			}
 			attrs[fmt.Sprintf("%s_%s", s, k)] = v
 		}
 		return true
@@ -230,24 +583,37 @@ func (tte *TTExtractor) getCurrentAccumAttrs() map[string]interface{} {
 // It si called by Vertigo parser when an opening structure tag
 // is encountered.
 func (tte *TTExtractor) ProcStruct(st *vertigo.Structure, line int, err error) error {
-	select {
-	case s := <-tte.stopChan:
-		return fmt.Errorf("received stop signal: %s", s)
-	default:
+	if err := tte.checkStop(); err != nil {
+		return err
 	}
 	if err != nil { // error from the Vertigo parser
 		return tte.handleProcError(line, err)
 	}
 	tte.lineCounter = line
+	if err := tte.structCheck.check(st, line); err != nil {
+		return err
+	}
+	if tte.countNgrams && tte.ngramConf.IsBoundary(st.Name) {
+		tte.ngrams.ResetSentence()
+	}
+	if tte.countNgrams && st.Name == tte.ngramConf.DocFreqStructure {
+		tte.ngrams.StartDocument()
+	}
 	err2 := tte.attrAccum.begin(line, st)
 	if err2 != nil {
-		return tte.handleProcError(line, err2)
+		return tte.handleStructError("<"+st.Name+">", line, err2)
+	}
+	if tte.sattrs != nil {
+		tte.sattrs.structOpen(st, tte.nextTokenPos)
 	}
 	if st.IsEmpty {
 		_, err3 := tte.attrAccum.end(line, st.Name)
 		if err3 != nil {
-			return tte.handleProcError(line, err3)
+			return tte.handleStructError("<"+st.Name+"/>", line, err3)
 		}
+
+	} else if tte.tagDistrib != nil {
+		tte.tagDistrib.updateTextTypes(tte.attrAccum)
 	}
 
 	if st != nil {
@@ -260,6 +626,13 @@ func (tte *TTExtractor) ProcStruct(st *vertigo.Structure, line int, err error) e
 			attrs["corpus_id"] = tte.corpusID
 			tte.currAtomAttrs = attrs
 			tte.atomCounter++
+			tte.applyValueMaps(attrs)
+			if err := tte.applyDateAttrs(attrs); err != nil {
+				return tte.handleProcError(line, err)
+			}
+			if err := tte.applyComputedAttrs(attrs); err != nil {
+				return tte.handleProcError(line, err)
+			}
 			if tte.colgenFn != nil {
 				var err4 error
 				attrs["item_id"], err4 = tte.colgenFn(attrs)
@@ -273,6 +646,13 @@ func (tte *TTExtractor) ProcStruct(st *vertigo.Structure, line int, err error) e
 			attrs["wordcount"] = 0 // This value is currently unused
 			attrs["poscount"] = 0  // This value is updated once we hit the closing tag
 			attrs["corpus_id"] = tte.corpusID
+			tte.applyValueMaps(attrs)
+			if err := tte.applyDateAttrs(attrs); err != nil {
+				return tte.handleProcError(line, err)
+			}
+			if err := tte.applyComputedAttrs(attrs); err != nil {
+				return tte.handleProcError(line, err)
+			}
 			if tte.colgenFn != nil {
 				var err5 error
 				attrs["item_id"], err5 = tte.colgenFn(attrs)
@@ -284,11 +664,7 @@ func (tte *TTExtractor) ProcStruct(st *vertigo.Structure, line int, err error) e
 		}
 	}
 	if line%1000 == 0 {
-		tte.statusChan <- Status{
-			Datetime:       time.Now(),
-			ProcessedAtoms: tte.atomCounter,
-			ProcessedLines: line,
-		}
+		tte.sendStatus(tte.status(line))
 	}
 	return nil
 }
@@ -297,19 +673,28 @@ func (tte *TTExtractor) ProcStruct(st *vertigo.Structure, line int, err error) e
 // It is called by Vertigo parser when a closing structure tag is
 // encountered.
 func (tte *TTExtractor) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
-	select {
-	case s := <-tte.stopChan:
-		return fmt.Errorf("received stop signal: %s", s)
-	default:
+	if err := tte.checkStop(); err != nil {
+		return err
 	}
 	if err != nil { // error from the Vertigo parser
 		return tte.handleProcError(line, err)
 	}
+	if tte.countNgrams && tte.ngramConf.IsBoundary(st.Name) {
+		tte.ngrams.ResetSentence()
+	}
 	accumItem, err2 := tte.attrAccum.end(line, st.Name)
 	if err2 != nil {
-		return tte.handleProcError(line, err2)
+		return tte.handleStructError("</"+st.Name+">", line, err2)
+	}
+	if tte.tagDistrib != nil {
+		tte.tagDistrib.updateTextTypes(tte.attrAccum)
 	}
 	tte.lineCounter = line
+	if tte.sattrs != nil {
+		if err := tte.sattrs.structClose(st.Name, tte.nextTokenPos); err != nil {
+			return err
+		}
+	}
 	if accumItem.elm.Name == tte.atomStruct ||
 		accumItem.elm.Name == tte.atomParentStruct && tte.lastAtomOpenLine < accumItem.lineOpen {
 		if tte.currAtomAttrs == nil {
@@ -318,31 +703,55 @@ func (tte *TTExtractor) ProcStructClose(st *vertigo.StructureClose, line int, er
 				st.Name, accumItem.elm.Name, line)
 		}
 		tte.currAtomAttrs["poscount"] = tte.tokenInAtomCounter
-		values := make([]any, len(tte.attrNames))
-		for i, n := range tte.attrNames {
-			if tte.currAtomAttrs[n] != nil {
-				values[i] = tte.currAtomAttrs[n]
+		writeAtom := true
+		if err := tte.applyBinnedAttrs(tte.currAtomAttrs); err != nil {
+			if err := tte.handleProcError(line, err); err != nil {
+				return err
+			}
+			writeAtom = false
+		}
+		if writeAtom && tte.atomHook != nil {
+			var hookErr error
+			writeAtom, hookErr = tte.atomHook.OnAtom(tte.currAtomAttrs)
+			if hookErr != nil {
+				return tte.handleProcError(line, fmt.Errorf("atom hook failed: %w", hookErr))
+			}
+		}
+		var values []any
+		if writeAtom {
+			values, writeAtom = tte.atomValues()
+		}
+		if writeAtom {
+			err := tte.docInsert.Exec(values...)
+			if err != nil {
+				return tte.handleProcError(line, err)
 
-			} else {
-				values[i] = "" // liveattrs plug-in does not like NULLs
+			}
+			tte.addWrittenRows("liveattrs_entry", 1)
+			if err := tte.insertMultiValues(); err != nil {
+				return tte.handleProcError(line, err)
 			}
 		}
-		err := tte.docInsert.Exec(values...)
-		if err != nil {
+		if err := tte.insertItemCounts(writeAtom); err != nil {
 			return tte.handleProcError(line, err)
-
 		}
 		tte.currAtomAttrs = make(map[string]interface{})
 
 		// also reset the current sentence
-		tte.currSentence = tte.currSentence[:0]
+		tte.ngrams.ResetSentence()
+		if err := tte.spillNgramsIfNeeded(); err != nil {
+			return err
+		}
+		if err := tte.flushStagedCountsIfNeeded(); err != nil {
+			return err
+		}
+		if tte.maxAtoms > 0 && tte.atomCounter >= tte.maxAtoms {
+			tte.tokenLimit = tte.nextTokenPos
+			return errAtomLimitReached
+		}
 	}
 	if line%1000 == 0 {
-		tte.statusChan <- Status{
-			Datetime:       time.Now(),
-			ProcessedAtoms: tte.atomCounter,
-			ProcessedLines: line,
-		}
+		tte.sendStatus(tte.status(line))
 	}
 	return nil
 }
@@ -368,7 +777,7 @@ func (tte *TTExtractor) calcNumAttrs() int {
 }
 
 func (tte *TTExtractor) generateAttrList() []string {
-	attrNames := make([]string, tte.calcNumAttrs()+3)
+	attrNames := make([]string, tte.calcNumAttrs()+4)
 	i := 0
 	for s, items := range tte.structures {
 		for _, item := range items {
@@ -388,97 +797,237 @@ func (tte *TTExtractor) generateAttrList() []string {
 	return attrNames
 }
 
-func (tte *TTExtractor) generateHashID(ng *ptcount.NgramCounter) string {
-	hasher := sha1.New()
-	for _, vc := range tte.ngramConf.VertColumns {
-		hasher.Write([]byte(ng.ColumnNgram(vc.Idx, tte.valueDict)))
+// insertCorpusSize writes total numbers of tokens and atoms
+// of the processed corpus into the corpus_sizes table. In case
+// the writer is able to accumulate the sizes (see db.CorpusSizesUpdater),
+// the numbers are added to the ones written by previous runs.
+func (tte *TTExtractor) insertCorpusSize() error {
+	tte.corpusTokens = tte.processedTokens
+	attrs := []string{"corpus_id", "tokens", "atoms"}
+	tte.addTableColumns(db.CorpusSizesTable, attrs)
+	if updater, ok := tte.database.(db.CorpusSizesUpdater); ok {
+		totalTokens, _, err := updater.UpdateCorpusSize(tte.corpusID, tte.processedTokens, tte.atomCounter)
+		if err != nil {
+			return fmt.Errorf("failed to update corpus size: %w", err)
+		}
+		tte.corpusTokens = totalTokens
+		tte.addWrittenRows(db.CorpusSizesTable, 1)
+		return nil
+	}
+	ins, err := tte.database.PrepareInsert(db.CorpusSizesTable, attrs)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s insert: %w", db.CorpusSizesTable, err)
 	}
-	return fmt.Sprintf("%x", hasher.Sum(nil))
+	if err := ins.Exec(tte.corpusID, tte.processedTokens, tte.atomCounter); err != nil {
+		return fmt.Errorf("failed to insert corpus size: %w", err)
+	}
+	tte.addWrittenRows(db.CorpusSizesTable, 1)
+	return nil
 }
 
-func (tte *TTExtractor) insertCounts() error {
-	colItems := append(
-		db.GenerateColCountNames(tte.ngramConf.VertColumns),
-		"corpus_id", "count", "arf", "hash_id")
-	ins, err := tte.database.PrepareInsert("colcounts", colItems)
+// newColumnModder creates a chain of value modifiers
+// specified by column's modFn and modScript
+func newColumnModder(col db.VertColumn) (*modders.StringTransformerChain, error) {
+	ans, err := modders.ParseStringTransformerChain(col.ModFn)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	i := 0
-	for _, count := range tte.colCounts {
-		select {
-		case s := <-tte.stopChan:
-			return fmt.Errorf("received stop signal: %s", s)
-		default:
-		}
-
-		args := make([]interface{}, len(tte.ngramConf.VertColumns)+4)
-		for i, vc := range tte.ngramConf.VertColumns {
-			args[i] = count.ColumnNgram(vc.Idx, tte.valueDict)
-		}
-
-		numCol := len(tte.ngramConf.VertColumns)
-		args[numCol] = tte.corpusID
-		args[numCol+1] = count.Count()
-		if count.HasARF() {
-			args[numCol+2] = count.ARF().ARF
-
-		} else {
-			args[numCol+2] = -1
-		}
-		args[numCol+3] = tte.generateHashID(count)
-		err = ins.Exec(args...)
+	if col.ModScript != "" {
+		script, err := modders.NewLuaScript(fmt.Sprintf("modScript of column %d", col.Idx), col.ModScript)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		ans.Append(script)
+	}
+	return ans, nil
+}
 
-		if i > 0 && i%1000 == 0 {
-			tte.statusChan <- Status{
-				Datetime:       time.Now(),
-				ProcessedAtoms: tte.atomCounter,
-				ProcessedLines: tte.lineCounter,
-			}
-			if i%100000 == 0 {
-				log.Info().
-					Int("numProcessed", i).
-					Msg("next chunk of records processed")
-			}
+// checkModders returns the first error recorded by column
+// modders during parsing (e.g. a value missing in a lookup table)
+func (tte *TTExtractor) checkModders() error {
+	for idx, m := range tte.columnModders {
+		if err := m.Err(); err != nil {
+			return fmt.Errorf("failed to apply modFn of column %d: %w", idx, err)
+		}
+	}
+	if tte.tagDistrib != nil {
+		if err := tte.tagDistrib.modder.Err(); err != nil {
+			return fmt.Errorf("failed to apply modFn of tagDistrib column: %w", err)
 		}
-		i++
 	}
 	return nil
 }
 
+// reportPhase logs and sends (via statusChan) information
+// about a finished processing phase started at t0
+func (tte *TTExtractor) reportPhase(name string, t0 time.Time) {
+	dur := time.Since(t0)
+	tte.logger.Info().
+		Str("phase", name).
+		Int("rows", tte.phaseRows).
+		Dur("duration", dur).
+		Msg("Finished processing phase")
+	tte.phaseRows = 0
+	phase := PhaseTiming{Name: name, Duration: dur}
+	tte.phases = append(tte.phases, phase)
+	st := tte.status(tte.lineCounter)
+	st.Phase = &phase
+	tte.sendStatus(st)
+}
+
+// parseFrom parses a single input file of the configured format
+// with token indices starting from firstToken
+func (tte *TTExtractor) parseFrom(
+	conf *vertigo.ParserConf,
+	lproc vertigo.LineProcessor,
+	firstToken int,
+) (int, error) {
+	if tte.inputFormat == cnf.InputFormatTEI {
+		return input.ParseTEIFrom(tte.ctx, conf, tte.teiConf, lproc, firstToken, tte.readProgress)
+	}
+	return input.ParseFrom(tte.ctx, conf, tte.lineParser, lproc, firstToken, tte.readProgress)
+}
+
 // Run starts the parsing and metadata extraction
 // process. The method expects a proper database
 // schema to be ready (see database.go for details).
 // The whole process runs within a transaction which
 // makes sqlite3 inserts a few orders of magnitude
 // faster.
-func (tte *TTExtractor) Run(conf *vertigo.ParserConf) error {
-	log.Info().Msg("using zero-based indexing when reporting line errors")
-	log.Info().Str("file", conf.InputFilePath).Msg("Starting to process vertical file")
+// In case of an error, the transaction is rolled back
+// and the error is returned (i.e. the method never
+// terminates the process). The same applies in case
+// the ctx is cancelled. On success, statistics of the run
+// are returned.
+func (tte *TTExtractor) Run(ctx context.Context, conf *vertigo.ParserConf) (*RunStats, error) {
+	return tte.RunFiles(ctx, []*vertigo.ParserConf{conf})
+}
+
+// RunFiles works like Run but it processes multiple vertical
+// files one after another as a single corpus (i.e. token positions
+// and n-gram counts are shared) within a single transaction.
+func (tte *TTExtractor) RunFiles(ctx context.Context, confs []*vertigo.ParserConf) (*RunStats, error) {
+	t0 := time.Now()
+	tte.ctx = ctx
+	err := tte.run(confs)
+	if err == nil && tte.sattrs != nil {
+		err = tte.sattrs.commit()
+	}
+	if err == nil && tte.freqList != nil {
+		err = tte.freqList.commit()
+	}
+	if err != nil {
+		if tte.sattrs != nil {
+			tte.sattrs.discard()
+		}
+		if tte.freqList != nil {
+			tte.freqList.discard()
+		}
+		if rbErr := tte.database.Rollback(); rbErr != nil {
+			tte.logger.Error().Err(rbErr).Msg("failed to rollback transaction")
+		}
+		return nil, err
+	}
+	return tte.runStats(t0), nil
+}
+
+func (tte *TTExtractor) run(confs []*vertigo.ParserConf) error {
+	if len(confs) == 0 {
+		return fmt.Errorf("no vertical file to process")
+	}
+	tte.logger.Debug().Msg("using zero-based indexing when reporting line errors")
 	tte.attrNames = tte.generateAttrList()
+	outAttrNames := tte.outputColumnNames(tte.attrNames)
 	var err error
-	tte.docInsert, err = tte.database.PrepareInsert("liveattrs_entry", tte.attrNames)
+	tte.docInsert, err = tte.database.PrepareInsert("liveattrs_entry", outAttrNames)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to prepare liveattrs_entry insert: %w", err)
+	}
+	tte.addTableColumns("liveattrs_entry", outAttrNames)
+	if tte.ngramConf.ItemCounts {
+		attrs := []string{"item_id", "hash_id", "corpus_id", "count"}
+		tte.itemCountsInsert, err = tte.database.PrepareInsert(db.CorpusItemCountsTable, attrs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s insert: %w", db.CorpusItemCountsTable, err)
+		}
+		tte.addTableColumns(db.CorpusItemCountsTable, attrs)
 	}
-	parserErr := vertigo.ParseVerticalFile(conf, tte)
-	if parserErr != nil {
-		tte.database.Rollback()
-		tte.statusChan <- Status{
-			Datetime:       time.Now(),
-			Error:          parserErr,
-			ProcessedAtoms: tte.atomCounter,
-			ProcessedLines: -1,
+	if len(tte.multiValues) > 0 {
+		attrs := []string{"corpus_id", "item_id", "attr", "value"}
+		tte.multiValueInsert, err = tte.database.PrepareInsert(db.LiveattrsMultiValueTable, attrs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s insert: %w", db.LiveattrsMultiValueTable, err)
 		}
-		return fmt.Errorf("failed to parse vertical file: %s", parserErr)
+		tte.addTableColumns(db.LiveattrsMultiValueTable, attrs)
+	}
+	if tte.ngramSpiller != nil {
+		defer func() {
+			if err := tte.ngramSpiller.Close(); err != nil {
+				tte.logger.Error().Err(err).Msg("failed to clean up spilled n-gram counts")
+			}
+		}()
+	}
+	t0 := time.Now()
+	var nextToken int
+	for _, conf := range confs {
+		tte.logger.Info().
+			Str("phase", PhaseParsing).
+			Str("file", conf.InputFilePath).
+			Msg("Starting to process vertical file")
+		tte.currentFile = conf.InputFilePath
+		var parserErr error
+		if tte.numWorkers > 1 {
+			tte.logger.Info().
+				Str("phase", PhaseParsing).
+				Int("numWorkers", tte.numWorkers).
+				Msg("Using parallel processing")
+			nextToken, parserErr = tte.runParallel(conf, nextToken)
+
+		} else {
+			nextToken, parserErr = tte.parseFrom(conf, tte.lineProcessor(), nextToken)
+		}
+		if errors.Is(parserErr, errAtomLimitReached) {
+			tte.logger.Info().
+				Int("maxAtoms", tte.maxAtoms).
+				Msg("Reached the maximum number of atoms, skipping the rest of the input")
+			break
+		}
+		if parserErr != nil {
+			st := tte.status(-1)
+			st.Error = parserErr
+			tte.sendStatus(st)
+			return fmt.Errorf("failed to parse vertical file %s: %w", conf.InputFilePath, parserErr)
+		}
+	}
+	tte.reportPhase(PhaseParsing, t0)
+	tte.reportParseErrors()
+	if err := tte.checkModders(); err != nil {
+		return err
+	}
+	if err := tte.structCheck.finish(&tte.logger); err != nil {
+		return err
+	}
+	if err := tte.insertCorpusSize(); err != nil {
+		return err
+	}
+	if err := tte.insertTagDistrib(); err != nil {
+		return err
+	}
+	if tte.strPool != nil {
+		tte.logger.Info().Int("numStrings", tte.strPool.Size()).Msg("Interned structural attribute values")
 	}
 	if len(tte.ngramConf.VertColumns) > 0 {
-		if tte.ngramConf.CalcARF {
-			log.Info().
+		if tte.ngramConf.CalcARF && tte.ngramConf.ARFSinglePass {
+			tte.logger.Info().Str("phase", PhaseARF).Msg("calculating ARF from recorded positions")
+			t0 = time.Now()
+			ptcount.CalcSinglePassARF(tte.GetColCounts(), tte.GetNumTokens())
+			tte.reportPhase(PhaseARF, t0)
+
+		} else if tte.ngramConf.CalcARF {
+			tte.logger.Info().
+				Str("phase", PhaseARF).
 				Msg("calculating ARF (processing the vertical again)")
+			t0 = time.Now()
 			arfCalc := ptcount.NewARFCalculator(
 				tte.GetColCounts(),
 				tte.ngramConf,
@@ -487,16 +1036,46 @@ func (tte *TTExtractor) Run(conf *vertigo.ParserConf) error {
 				tte.WordDict(),
 				tte.atomStruct,
 			)
-			parserErr := vertigo.ParseVerticalFile(conf, arfCalc)
-			if parserErr != nil {
-				return fmt.Errorf("ERROR: %s", parserErr)
+			arfCalc.SetTokenFilter(tte.tokenFilter)
+			var arfToken int
+			for _, conf := range confs {
+				var parserErr error
+				arfToken, parserErr = tte.parseFrom(conf, &stoppableProcessor{arfCalc, tte}, arfToken)
+				if errors.Is(parserErr, errAtomLimitReached) {
+					break
+				}
+				if parserErr != nil {
+					return fmt.Errorf("failed to calculate ARF: %w", parserErr)
+				}
 			}
 			arfCalc.Finalize()
+			tte.reportPhase(PhaseARF, t0)
 		}
-		log.Info().Msg("Saving defined positional attributes counts into the database")
+		if tte.ngramConf.AssocMeasures {
+			tte.logger.Info().Str("phase", PhaseAssoc).Msg("calculating association measures of 2-grams")
+			t0 = time.Now()
+			ptcount.CalcAssocScores(tte.GetColCounts(), len(tte.ngramConf.VertColumns))
+			tte.reportPhase(PhaseAssoc, t0)
+		}
+		tte.logger.Info().
+			Str("phase", PhaseColcounts).
+			Msg("Saving defined positional attributes counts into the database")
+		t0 = time.Now()
 		err = tte.insertCounts()
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to insert n-gram counts: %w", err)
+		}
+		tte.reportPhase(PhaseColcounts, t0)
+
+		if tte.ngramConf.TFIDF {
+			tte.logger.Info().
+				Str("phase", PhaseTFIDF).
+				Msg("Saving TF-IDF values of n-grams per atom into the database")
+			t0 = time.Now()
+			if err := tte.insertTFIDF(); err != nil {
+				return fmt.Errorf("failed to insert TF-IDF values: %w", err)
+			}
+			tte.reportPhase(PhaseTFIDF, t0)
 		}
 	}
 	return nil
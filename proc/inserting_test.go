@@ -0,0 +1,582 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestNewExtractorRequiresWriter(t *testing.T) {
+	_, err := NewExtractor(&cnf.VTEConf{Corpus: "test"})
+	assert.Error(t, err)
+}
+
+// colcountsFailingWriter fails to prepare inserts into the colcounts table
+type colcountsFailingWriter struct {
+	recordingWriter
+}
+
+func (w *colcountsFailingWriter) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	if table == "colcounts" {
+		return nil, fmt.Errorf("colcounts table not available")
+	}
+	return w.recordingWriter.PrepareInsert(table, attrs)
+}
+
+func TestFailedColcountsInsertStopsRun(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &colcountsFailingWriter{recordingWriter{rows: make(map[string]*[]string)}}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.ErrorContains(t, err, "colcounts table not available")
+	assert.True(t, writer.rolledBack)
+}
+
+func TestMaxAtoms(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		MaxAtoms:      10,
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			CalcARF:     true,
+			VertColumns: db.VertColumns{{Idx: 0, ModFn: "toLower"}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, stats.InsertedAtoms)
+	// the first 10 atoms (p_num 0..9 of d0) contain 3+4+5+6+3+4+5+6+3+4 tokens
+	assert.Equal(t, 43, stats.ProcessedTokens)
+	assert.ElementsMatch(t, []string{"doc_id", "p_num", "wordcount", "poscount", "corpus_id"}, stats.TableColumns["liveattrs_entry"])
+	assert.Contains(t, stats.TableColumns, "colcounts")
+}
+
+func TestNgramPositionColumns(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nthe\tN\ncat\tN\nsat\tV\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			Size:        2,
+			VertColumns: db.VertColumns{{Idx: 0}, {Idx: 1}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 2)
+	assert.Contains(t, rows[0], "col0_1=cat, col0_2=sat, col1_1=N, col1_2=V")
+	assert.Contains(t, rows[1], "col0_1=the, col0_2=cat, col1_1=N, col1_2=N")
+}
+
+func TestSkipGrams(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\nc\nd\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			Size:        2,
+			MaxSkip:     1,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	ngrams := make([]string, 0, 5)
+	for _, row := range writer.sortedRows("colcounts") {
+		items := strings.Split(row, ", ")
+		ngrams = append(ngrams, items[1]+" "+items[2])
+	}
+	assert.Equal(t, []string{"col0_1=a col0_2=b", "col0_1=a col0_2=c", "col0_1=b col0_2=c",
+		"col0_1=b col0_2=d", "col0_1=c col0_2=d"}, ngrams)
+}
+
+func TestNgramBoundaryStructures(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	vert := "<p>\n<s>\na\nb\n</s>\n<s>\nc\nd\n</s>\n</p>\n"
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	for _, numWorkers := range []int{1, 2} {
+		conf := &cnf.VTEConf{
+			Corpus:        "test",
+			AtomStructure: "p",
+			Structures:    map[string][]string{"p": {}},
+			NumWorkers:    numWorkers,
+			Ngrams: cnf.NgramConf{
+				NgramSize:          2,
+				BoundaryStructures: []string{"s"},
+				VertColumns:        db.VertColumns{{Idx: 0}},
+			},
+		}
+		writer := &recordingWriter{rows: make(map[string]*[]string)}
+		tte, err := NewExtractor(conf, WithWriter(writer))
+		assert.NoError(t, err)
+		_, err = tte.Run(
+			context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+		assert.NoError(t, err)
+		rows := writer.sortedRows("colcounts")
+		assert.Len(t, rows, 2)
+		assert.Contains(t, rows[0], "col0=a b")
+		assert.Contains(t, rows[1], "col0=c d")
+	}
+}
+
+func TestNgramMinFreq(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\na\nb\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   2,
+			MinFreq:     2,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.DistinctNgrams)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 1)
+	assert.Contains(t, rows[0], "col0=a b")
+}
+
+func TestNgramStopwords(t *testing.T) {
+	dir := t.TempDir()
+	vertPath := filepath.Join(dir, "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nA\nb\nThe\nc\n</p>\n"), 0644))
+	swPath := filepath.Join(dir, "stopwords.txt")
+	assert.NoError(t, os.WriteFile(swPath, []byte("the\n\nof\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   2,
+			VertColumns: db.VertColumns{{Idx: 0, ModFn: "toLower", Stopwords: swPath}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 1)
+	assert.Contains(t, rows[0], "col0=a b")
+}
+
+func TestNgramExcludedTokens(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\tN\n,\tZ:\nb\tN\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   2,
+			CalcARF:     true,
+			VertColumns: db.VertColumns{{Idx: 0}, {Idx: 1, Exclude: "^Z"}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 1)
+	assert.Contains(t, rows[0], "col0=a b, col1=N N")
+}
+
+func TestSinglePassARF(t *testing.T) {
+	vertPath := createTestVertical(t)
+	results := make([][]string, 2)
+	for i, singlePass := range []bool{false, true} {
+		conf := &cnf.VTEConf{
+			Corpus:        "test",
+			AtomStructure: "p",
+			Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+			Ngrams: cnf.NgramConf{
+				NgramSize:     2,
+				CalcARF:       true,
+				ARFSinglePass: singlePass,
+				VertColumns:   db.VertColumns{{Idx: 1}},
+			},
+		}
+		writer := &recordingWriter{rows: make(map[string]*[]string)}
+		tte, err := NewExtractor(conf, WithWriter(writer))
+		assert.NoError(t, err)
+		stats, err := tte.Run(
+			context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+		assert.NoError(t, err)
+		assert.Equal(t, PhaseARF, stats.Phases[1].Name)
+		results[i] = writer.sortedRows("colcounts")
+	}
+	assert.NotContains(t, results[1][0], "arf=-1")
+	assert.Equal(t, results[0], results[1])
+}
+
+func TestNgramDocFreq(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:        1,
+			DocFreqStructure: "doc",
+			VertColumns:      db.VertColumns{{Idx: 1}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 5)
+	for _, row := range rows {
+		// all the words occur in all the 5 documents
+		assert.Contains(t, row, "docfreq=5")
+	}
+}
+
+func TestSeparateHapaxes(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\na\nc\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			Hapaxes:     cnf.HapaxesSeparate,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.RowsWritten["colcounts"])
+	assert.Equal(t, 2, stats.RowsWritten[db.ColcountsHapaxTable])
+	assert.Contains(t, writer.sortedRows("colcounts")[0], "col0=a")
+
+	conf.Ngrams.Hapaxes = cnf.HapaxesDrop
+	writer = &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err = NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	stats, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.RowsWritten["colcounts"])
+	assert.NotContains(t, writer.rows, db.ColcountsHapaxTable)
+}
+
+func TestCharNgrams(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nab\nab\nb\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			CharNgrams:  &cnf.CharNgramConf{Size: 2},
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 4)
+	for _, row := range rows {
+		if strings.Contains(row, "col0=b_") {
+			assert.Contains(t, row, "count=3")
+		}
+	}
+
+	conf.Ngrams.CharNgrams.Size = 0
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.Error(t, err)
+}
+
+func TestTFIDF(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\n</p>\n<p>\na\na\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:        1,
+			DocFreqStructure: "p",
+			TFIDF:            true,
+			VertColumns:      db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows(db.CorpusTFIDFTable)
+	assert.Len(t, rows, 3)
+	// "a" occurs in both the atoms so only "b" has a non-zero value
+	assert.Contains(t, rows[1], "atom_id=1")
+	assert.Contains(t, rows[1], "tfidf=0.693")
+
+	conf.Ngrams.DocFreqStructure = ""
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.Error(t, err)
+}
+
+func TestAssocMeasures(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\na\nb\na\nc\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:     2,
+			AssocMeasures: true,
+			VertColumns:   db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 3)
+	// f(a b) = 2, f(a, *) = 3, f(*, b) = 2, N = 5
+	assert.Contains(t, rows[0], "col0=a b")
+	assert.Contains(t, rows[0], "mi=0.736")
+	assert.Contains(t, rows[0], "logdice=13.678")
+
+	conf.Ngrams.NgramSize = 3
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.Error(t, err)
+}
+
+func TestItemCounts(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath, []byte("<p id=\"x\">\na\nb\na\n</p>\n<p id=\"y\">\nb\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {"id"}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			ItemCounts:  true,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	_, err := NewExtractor(conf, WithWriter(writer))
+	assert.Error(t, err)
+
+	itemID := func(attrs map[string]any) (string, error) {
+		return fmt.Sprint(attrs["p_id"]), nil
+	}
+	tte, err := NewExtractor(conf, WithWriter(writer), WithColgen(itemID))
+	assert.NoError(t, err)
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.RowsWritten[db.CorpusItemCountsTable])
+	rows := writer.sortedRows(db.CorpusItemCountsTable)
+	// "b" occurs once in both the atoms, "a" twice in the first one
+	assert.Contains(t, rows[0], "count=1, hash_id=e9d71f5ee7c92d6dc9e92ffdad17b8bd49418f98, item_id=x")
+	assert.Contains(t, rows[1], "count=1, hash_id=e9d71f5ee7c92d6dc9e92ffdad17b8bd49418f98, item_id=y")
+	assert.Contains(t, rows[2], "count=2, hash_id=86f7e437faa5a7fce15d1ddcb9eaeaea377667b8, item_id=x")
+}
+
+func TestCorpusSizesAndIPM(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\n</p>\n<p>\na\na\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			IPM:         true,
+			VertColumns: db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"atoms=2, corpus_id=test, tokens=4"}, writer.sortedRows(db.CorpusSizesTable))
+	rows := writer.sortedRows("colcounts")
+	assert.Len(t, rows, 2)
+	assert.Contains(t, rows[0], "col0=a, corpus_id=test, count=3")
+	assert.Contains(t, rows[0], "ipm=750000")
+	assert.Contains(t, rows[1], "ipm=250000")
+}
+
+func TestAppendedCorpusSizesAndIPM(t *testing.T) {
+	tmpDir := t.TempDir()
+	vertPath := filepath.Join(tmpDir, "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\na\nb\n</p>\n<p>\na\na\n</p>\n"), 0644))
+	dbPath := filepath.Join(tmpDir, "test.db")
+	runExtraction := func(corpusID string, appendMode bool, ngrams cnf.NgramConf) {
+		conf := &cnf.VTEConf{
+			Corpus:        corpusID,
+			AtomStructure: "p",
+			Structures:    map[string][]string{"p": {}},
+			Ngrams:        ngrams,
+		}
+		writer := &sqlite.Writer{
+			Path:        dbPath,
+			Structures:  conf.Structures,
+			VertColumns: db.VertColumns{{Idx: 0}},
+			IPM:         true,
+		}
+		defer writer.Close()
+		assert.NoError(t, writer.Initialize(appendMode))
+		tte, err := NewExtractor(conf, WithWriter(writer))
+		assert.NoError(t, err)
+		_, err = tte.Run(
+			context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Commit())
+	}
+	runExtraction("test1", false, cnf.NgramConf{})
+	database, err := sql.Open(sqlite.DriverName, dbPath)
+	assert.NoError(t, err)
+	defer database.Close()
+	// databases created by older versions have no corpus_sizes table
+	_, err = database.Exec("DROP TABLE " + db.CorpusSizesTable)
+	assert.NoError(t, err)
+
+	runExtraction("test2", true, cnf.NgramConf{})
+	runExtraction("test2", true, cnf.NgramConf{NgramSize: 1, IPM: true, VertColumns: db.VertColumns{{Idx: 0}}})
+	var numRows, tokens, atoms int
+	assert.NoError(t, database.QueryRow(
+		"SELECT COUNT(*), SUM(tokens), SUM(atoms) FROM "+db.CorpusSizesTable+" WHERE corpus_id = 'test2'").
+		Scan(&numRows, &tokens, &atoms))
+	assert.Equal(t, []int{1, 8, 4}, []int{numRows, tokens, atoms})
+	// n-gram frequencies are relative to the total size of the corpus
+	var ipm float64
+	assert.NoError(t, database.QueryRow(
+		"SELECT ipm FROM colcounts WHERE corpus_id = 'test2' AND col0 = 'a'").Scan(&ipm))
+	assert.Equal(t, 375000.0, ipm)
+}
+
+func TestModFnPipeline(t *testing.T) {
+	tmpDir := t.TempDir()
+	vertPath := filepath.Join(tmpDir, "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nČárka\tNN1\ncesta\tNN22\nŘeka\tVB\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:         "test",
+		AtomStructure:  "p",
+		Structures:     map[string][]string{"p": {}},
+		FreqListExport: &cnf.FreqListExportConf{Dir: tmpDir},
+		Ngrams: cnf.NgramConf{
+			NgramSize: 1,
+			VertColumns: db.VertColumns{
+				{Idx: 0, ModFn: "toLower:removeDiacritics:firstChar"},
+				{
+					Idx:       1,
+					ModFn:     "toLower",
+					ModScript: "function transform(v)\n  return (string.gsub(v, \"%d+$\", \"\"))\nend",
+				},
+			},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(tmpDir, "test.freq.tsv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "col0\tcol1\tcount\nc\tnn\t2\nr\tvb\t1\n", string(data))
+
+	// errors recorded by modders must fail the run
+	mapPath := filepath.Join(tmpDir, "lemmas.tsv")
+	assert.NoError(t, os.WriteFile(mapPath, []byte("c\tx\n"), 0644))
+	conf.Ngrams.VertColumns[0].ModFn = "toLower:removeDiacritics:firstChar:lookup:" + mapPath + ":error"
+	tte, err = NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.ErrorContains(t, err, "value 'r' not found in the lookup table")
+
+	conf.Ngrams.VertColumns[1].ModScript = "x = 1"
+	_, err = NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "does not define function transform(value)")
+}
+
+func TestUnresolvedWildcardStructures(t *testing.T) {
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {cnf.StructAttrWildcard}},
+	}
+	_, err := NewExtractor(conf, WithWriter(&recordingWriter{rows: make(map[string]*[]string)}))
+	assert.ErrorContains(t, err, "unresolved wildcard structures doc")
+}
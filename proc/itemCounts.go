@@ -0,0 +1,51 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// insertItemCounts writes n-gram counts of the current atom into
+// the corpus_itemcounts table (if configured). In case write is false
+// (e.g. the atom has been rejected by a hook), the counts are
+// only discarded.
+func (tte *TTExtractor) insertItemCounts(write bool) error {
+	if tte.itemCountsInsert == nil {
+		return nil
+	}
+	itemCounts := tte.ngrams.TakeItemCounts()
+	if !write {
+		return nil
+	}
+	counts := tte.GetColCounts()
+	for key, count := range itemCounts {
+		ngram, ok := counts.Get(key)
+		if !ok {
+			continue
+		}
+		err := tte.itemCountsInsert.Exec(
+			tte.currAtomAttrs["item_id"], tte.generateHashID(ngram), tte.corpusID, count)
+		if err != nil {
+			return fmt.Errorf("failed to insert item counts: %w", err)
+		}
+		tte.addWrittenRows(db.CorpusItemCountsTable, 1)
+	}
+	return nil
+}
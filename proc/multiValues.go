@@ -0,0 +1,69 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// splitMultiValue splits a value of a multi-value attribute into
+// individual (trimmed, unique and non-empty) items
+func splitMultiValue(value, delim string) []string {
+	ans := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, delim) {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			ans = append(ans, item)
+			seen[item] = true
+		}
+	}
+	return ans
+}
+
+// insertMultiValues writes items of the current atom's multi-value
+// attributes into the liveattrs_multivalue table
+func (tte *TTExtractor) insertMultiValues() error {
+	if tte.multiValueInsert == nil {
+		return nil
+	}
+	attrs := make([]string, 0, len(tte.multiValues))
+	for attr := range tte.multiValues {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for _, attr := range attrs {
+		value, ok := tte.currAtomAttrs[attr]
+		if !ok || value == nil {
+			continue
+		}
+		for _, item := range splitMultiValue(fmt.Sprint(value), tte.multiValues[attr]) {
+			item = tte.mapValue(attr, item)
+			err := tte.multiValueInsert.Exec(
+				tte.corpusID, tte.currAtomAttrs["item_id"], tte.outputColumnName(attr), item)
+			if err != nil {
+				return fmt.Errorf("failed to insert multi-value attribute item: %w", err)
+			}
+			tte.addWrittenRows(db.LiveattrsMultiValueTable, 1)
+		}
+	}
+	return nil
+}
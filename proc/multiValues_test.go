@@ -0,0 +1,70 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestMultiValues(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte("<p id=\"x\" keywords=\"a|b | a\">\na\n</p>\n<p id=\"y\" keywords=\"\">\nb\n</p>\n<p id=\"z\" keywords=\"c\">\nb\n</p>\n"),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {"id", "keywords"}},
+		MultiValues:   map[string]string{"p_keywords": "|"},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	_, err := NewExtractor(conf, WithWriter(writer))
+	assert.ErrorContains(t, err, "requires selfJoin")
+
+	itemID := func(attrs map[string]any) (string, error) {
+		return fmt.Sprint(attrs["p_id"]), nil
+	}
+	conf.MultiValues = map[string]string{"p_foo": "|"}
+	_, err = NewExtractor(conf, WithWriter(writer), WithColgen(itemID))
+	assert.ErrorContains(t, err, "not configured in structures")
+
+	conf.MultiValues = map[string]string{"p_keywords": "|"}
+	tte, err := NewExtractor(conf, WithWriter(writer), WithColgen(itemID))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"attr=p_keywords, corpus_id=test, item_id=x, value=a",
+			"attr=p_keywords, corpus_id=test, item_id=x, value=b",
+			"attr=p_keywords, corpus_id=test, item_id=z, value=c",
+		},
+		writer.sortedRows(db.LiveattrsMultiValueTable),
+	)
+}
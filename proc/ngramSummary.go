@@ -0,0 +1,161 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	dfltSummaryTopN = 10
+)
+
+// NgramFreq is an n-gram (i.e. values of its colcounts
+// columns) along with its frequency
+type NgramFreq struct {
+	Values []string
+	Count  int
+}
+
+func (nf NgramFreq) String() string {
+	return fmt.Sprintf("%s: %d", strings.Join(nf.Values, " | "), nf.Count)
+}
+
+// NgramSummary summarizes written n-grams. It is intended
+// mainly as a quick check of the n-gram configuration.
+type NgramSummary struct {
+
+	// Top contains the most frequent n-grams in descending order
+	Top []NgramFreq
+
+	// Distinct is number of distinct written n-grams (including
+	// hapaxes written to a separate table)
+	Distinct int
+
+	// Hapaxes is number of written n-grams occurring only once
+	Hapaxes int
+}
+
+// HapaxPercent returns percentage of hapaxes
+// within all the distinct n-grams
+func (ns *NgramSummary) HapaxPercent() float64 {
+	if ns.Distinct == 0 {
+		return 0
+	}
+	return float64(ns.Hapaxes) / float64(ns.Distinct) * 100
+}
+
+// ngramFreqHeap is a min-heap of n-grams (the least frequent first)
+type ngramFreqHeap []NgramFreq
+
+func (h ngramFreqHeap) Len() int { return len(h) }
+
+func (h ngramFreqHeap) Less(i, j int) bool {
+	return ngramFreqLess(h[i], h[j])
+}
+
+func (h ngramFreqHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *ngramFreqHeap) Push(x any) { *h = append(*h, x.(NgramFreq)) }
+
+func (h *ngramFreqHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// ngramFreqLess tests whether a is less frequent than b. N-grams
+// with the same frequency are ordered by their values (descending)
+// so the final order of the most frequent n-grams is deterministic.
+func ngramFreqLess(a, b NgramFreq) bool {
+	if a.Count != b.Count {
+		return a.Count < b.Count
+	}
+	return strings.Join(a.Values, "\t") > strings.Join(b.Values, "\t")
+}
+
+// ngramSummarizer creates NgramSummary from colcounts rows
+type ngramSummarizer struct {
+	topN     int
+	numCols  int
+	countIdx int
+	top      ngramFreqHeap
+	summary  NgramSummary
+}
+
+// add processes a colcounts row (n-gram columns must come first,
+// followed by corpus_id and count)
+func (ns *ngramSummarizer) add(row []any) {
+	count, _ := row[ns.countIdx].(int)
+	ns.summary.Distinct++
+	if count == 1 {
+		ns.summary.Hapaxes++
+	}
+	if ns.topN <= 0 || len(ns.top) == ns.topN && count < ns.top[0].Count {
+		return
+	}
+	item := NgramFreq{Values: make([]string, ns.numCols), Count: count}
+	for i := 0; i < ns.numCols; i++ {
+		item.Values[i] = fmt.Sprint(row[i])
+	}
+	if len(ns.top) < ns.topN {
+		heap.Push(&ns.top, item)
+
+	} else if ngramFreqLess(ns.top[0], item) {
+		ns.top[0] = item
+		heap.Fix(&ns.top, 0)
+	}
+}
+
+// result returns the final summary
+func (ns *ngramSummarizer) result() *NgramSummary {
+	ans := ns.summary
+	ans.Top = make([]NgramFreq, len(ns.top))
+	copy(ans.Top, ns.top)
+	sort.Slice(ans.Top, func(i, j int) bool {
+		return ngramFreqLess(ans.Top[j], ans.Top[i])
+	})
+	return &ans
+}
+
+func (tte *TTExtractor) logNgramSummary() {
+	top := make([]string, len(tte.ngramSummary.Top))
+	for i, item := range tte.ngramSummary.Top {
+		top[i] = item.String()
+	}
+	tte.logger.Info().
+		Int("distinctNgrams", tte.ngramSummary.Distinct).
+		Int("hapaxes", tte.ngramSummary.Hapaxes).
+		Float64("hapaxPercent", tte.ngramSummary.HapaxPercent()).
+		Strs("topNgrams", top).
+		Msg("N-gram summary")
+}
+
+func newNgramSummarizer(topN int, numCols int) *ngramSummarizer {
+	if topN == 0 {
+		topN = dfltSummaryTopN
+	}
+	return &ngramSummarizer{
+		topN:     topN,
+		numCols:  numCols,
+		countIdx: numCols + 1,
+	}
+}
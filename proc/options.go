@@ -0,0 +1,86 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"os"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/db/colgen"
+	"github.com/rs/zerolog"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// Option configures a TTExtractor created via NewExtractor
+type Option func(tte *TTExtractor)
+
+// WithWriter sets a database writer the extracted data
+// are written to. The option is required.
+func WithWriter(database db.Writer) Option {
+	return func(tte *TTExtractor) {
+		tte.database = database
+	}
+}
+
+// WithColgen sets a function generating values of the
+// "item_id" column (by default, no such column is generated)
+func WithColgen(colgenFn colgen.AlignedColGenFn) Option {
+	return func(tte *TTExtractor) {
+		tte.colgenFn = colgenFn
+	}
+}
+
+// WithStatusChan sets a channel processing statuses are sent to.
+// The channel must be read continuously during the processing.
+// By default, no statuses are sent.
+func WithStatusChan(statusChan chan<- Status) Option {
+	return func(tte *TTExtractor) {
+		tte.statusChan = statusChan
+	}
+}
+
+// WithStopChan sets a channel allowing to stop the processing
+// via a signal (see also the context passed to Run)
+func WithStopChan(stopChan <-chan os.Signal) Option {
+	return func(tte *TTExtractor) {
+		tte.stopChan = stopChan
+	}
+}
+
+// WithAtomHook sets a hook called for each atom (see AtomHook)
+func WithAtomHook(hook AtomHook) Option {
+	return func(tte *TTExtractor) {
+		tte.atomHook = hook
+	}
+}
+
+// WithLineProcessors adds line processors receiving the same
+// parser events as the extractor (see AddLineProcessor)
+func WithLineProcessors(lprocs ...vertigo.LineProcessor) Option {
+	return func(tte *TTExtractor) {
+		tte.extraProcs = append(tte.extraProcs, lprocs...)
+	}
+}
+
+// WithLogger sets a logger used by the extractor
+// (by default, the global zerolog logger with the "corpus"
+// field is used)
+func WithLogger(logger zerolog.Logger) Option {
+	return func(tte *TTExtractor) {
+		tte.logger = logger
+	}
+}
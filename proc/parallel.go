@@ -0,0 +1,231 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/czcorpus/vert-tagextract/v2/input"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount"
+
+	"github.com/tomachalek/vertigo/v5"
+)
+
+var (
+	// parallelChunkMinLines specifies a minimum size of a chunk
+	// of lines processed by a worker. The actual chunk is always
+	// larger as it must end with a closing tag of an atom structure.
+	parallelChunkMinLines = 50000
+)
+
+type parsedLine struct {
+	lineNum int
+	value   any
+	err     error
+
+	// text is kept only for malformed lines
+	text string
+}
+
+// lineChunk is a chunk of vertical lines ending with
+// a closing tag of an atom structure. As n-grams never
+// cross atom boundaries, chunks can be processed independently.
+type lineChunk struct {
+	firstLine int
+	lines     []string
+	result    chan []parsedLine
+}
+
+// isAtomClose tests whether a line contains a closing
+// tag of the atom structure
+func (tte *TTExtractor) isAtomClose(line string) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "</") || !strings.HasSuffix(line, ">") {
+		return false
+	}
+	return strings.TrimSpace(line[2:len(line)-1]) == tte.atomStruct
+}
+
+// readChunks reads the vertical file and splits it into chunks
+// which are sent both to workers (via chunks) and to the consumer
+// (via ordered) which ensures the original order is preserved.
+func (tte *TTExtractor) readChunks(
+	conf *vertigo.ParserConf,
+	chunks chan<- *lineChunk,
+	ordered chan<- *lineChunk,
+	stop <-chan struct{},
+) error {
+	defer close(chunks)
+	defer close(ordered)
+	rd, err := input.OpenWithProgress(tte.ctx, conf.InputFilePath, conf.Encoding, tte.readProgress)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	sc := bufio.NewScanner(rd)
+	curr := &lineChunk{lines: make([]string, 0, parallelChunkMinLines)}
+	send := func() bool {
+		curr.result = make(chan []parsedLine, 1)
+		select {
+		case ordered <- curr:
+		case <-stop:
+			return false
+		}
+		select {
+		case chunks <- curr:
+		case <-stop:
+			return false
+		}
+		return true
+	}
+	var lineNum int
+	for sc.Scan() {
+		curr.lines = append(curr.lines, sc.Text())
+		lineNum++
+		if len(curr.lines) >= parallelChunkMinLines && tte.isAtomClose(sc.Text()) {
+			if !send() {
+				return nil
+			}
+			curr = &lineChunk{firstLine: lineNum, lines: make([]string, 0, parallelChunkMinLines)}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read vertical file: %w", err)
+	}
+	if len(curr.lines) > 0 {
+		send()
+	}
+	return nil
+}
+
+// parseChunk parses all the lines of a chunk and (if configured)
+// counts n-grams using a worker's own collector. Please note that
+// the parallel mode resets a "sentence" on each closing tag of
+// the atom (and atom parent) structure and on tags of configured
+// boundary structures.
+func (tte *TTExtractor) parseChunk(chunk *lineChunk, collector *ptcount.NgramCollector) {
+	ans := make([]parsedLine, len(chunk.lines))
+	for i, line := range chunk.lines {
+		v, err := tte.lineParser.Parse(line)
+		ans[i] = parsedLine{lineNum: chunk.firstLine + i, value: v, err: err}
+		if err != nil {
+			ans[i].text = line
+		}
+		if collector == nil {
+			continue
+		}
+		switch tv := v.(type) {
+		case *vertigo.Token:
+			collector.AddToken(tv)
+		case *vertigo.Structure:
+			if tte.ngramConf.IsBoundary(tv.Name) {
+				collector.ResetSentence()
+			}
+		case *vertigo.StructureClose:
+			if tv.Name == tte.atomStruct || tv.Name == tte.atomParentStruct ||
+				tte.ngramConf.IsBoundary(tv.Name) {
+				collector.ResetSentence()
+			}
+		}
+	}
+	chunk.result <- ans
+}
+
+// consumeChunks processes parsed lines in their original order
+// using the standard LineProcessor methods. Token indices start
+// from firstToken, the index following the last token is returned.
+func (tte *TTExtractor) consumeChunks(ordered <-chan *lineChunk, firstToken int) (int, error) {
+	tokenIdx := firstToken
+	lproc := tte.lineProcessor()
+	for chunk := range ordered {
+		for _, pl := range <-chunk.result {
+			var procErr error
+			switch tv := pl.value.(type) {
+			case *vertigo.Token:
+				tv.Idx = tokenIdx
+				tokenIdx++
+				procErr = lproc.ProcToken(tv, pl.lineNum, pl.err)
+			case *vertigo.Structure:
+				procErr = lproc.ProcStruct(tv, pl.lineNum, pl.err)
+			case *vertigo.StructureClose:
+				procErr = lproc.ProcStructClose(tv, pl.lineNum, pl.err)
+			default:
+				if pl.err != nil {
+					procErr = lproc.ProcMalformedLine(pl.text, pl.lineNum, pl.err)
+				}
+			}
+			if procErr != nil {
+				return tokenIdx, procErr
+			}
+		}
+	}
+	return tokenIdx, nil
+}
+
+// runParallel is an alternative to input.Parse where
+// lines are parsed (and n-grams counted) by multiple workers. The workers
+// share the word dictionary and the (sharded) n-gram map of the extractor.
+// Structural attributes are still processed sequentially.
+// Like input.ParseFrom, the function returns index of the token
+// following the last one in the file.
+func (tte *TTExtractor) runParallel(conf *vertigo.ParserConf, firstToken int) (int, error) {
+	chunks := make(chan *lineChunk)
+	ordered := make(chan *lineChunk, tte.numWorkers*2)
+	stop := make(chan struct{})
+	var readErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		readErr = tte.readChunks(conf, chunks, ordered, stop)
+	}()
+
+	for i := 0; i < tte.numWorkers; i++ {
+		var collector *ptcount.NgramCollector
+		if tte.countNgrams {
+			collector = tte.ngrams.Fork()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				tte.parseChunk(chunk, collector)
+			}
+		}()
+	}
+	// n-grams are counted by workers
+	countNgrams := tte.countNgrams
+	tte.countNgrams = false
+	defer func() { tte.countNgrams = countNgrams }()
+
+	nextToken, procErr := tte.consumeChunks(ordered, firstToken)
+	close(stop)
+	wg.Wait()
+	if procErr != nil {
+		return nextToken, procErr
+	}
+	if readErr != nil {
+		return nextToken, readErr
+	}
+	if countNgrams {
+		tte.logger.Info().Int("numNgrams", tte.GetColCounts().Len()).Msg("Counted n-grams using parallel workers")
+	}
+	return nextToken, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+type recordingInsert struct {
+	attrs []string
+	rows  *[]string
+}
+
+func (ri *recordingInsert) Exec(values ...any) error {
+	items := make([]string, len(values))
+	for i, v := range values {
+		if _, ok := v.(db.EmptyString); ok {
+			items[i] = ri.attrs[i] + `=""`
+			continue
+		}
+		items[i] = fmt.Sprintf("%s=%v", ri.attrs[i], v)
+	}
+	sort.Strings(items)
+	*ri.rows = append(*ri.rows, strings.Join(items, ", "))
+	return nil
+}
+
+// recordingWriter stores all the inserted rows as strings
+type recordingWriter struct {
+	rows       map[string]*[]string
+	rolledBack bool
+}
+
+func (rw *recordingWriter) DatabaseExists() bool             { return false }
+func (rw *recordingWriter) Initialize(appendMode bool) error { return nil }
+func (rw *recordingWriter) Commit() error                    { return nil }
+func (rw *recordingWriter) Rollback() error                  { rw.rolledBack = true; return nil }
+func (rw *recordingWriter) Close()                           {}
+
+func (rw *recordingWriter) PrepareInsert(table string, attrs []string) (db.InsertOperation, error) {
+	rows := make([]string, 0, 10)
+	rw.rows[table] = &rows
+	return &recordingInsert{attrs: attrs, rows: &rows}, nil
+}
+
+func (rw *recordingWriter) sortedRows(table string) []string {
+	ans := append([]string{}, *rw.rows[table]...)
+	sort.Strings(ans)
+	return ans
+}
+
+func createTestVertical(t *testing.T) string {
+	var vert strings.Builder
+	words := []string{"the", "cat", "sat", "on", "the", "mat"}
+	for d := 0; d < 5; d++ {
+		vert.WriteString(fmt.Sprintf("<doc id=\"d%d\">\n", d))
+		for p := 0; p < 20; p++ {
+			vert.WriteString(fmt.Sprintf("<p num=\"%d\">\n", p))
+			for i := 0; i < 3+p%4; i++ {
+				w := words[(d+p+i)%len(words)]
+				vert.WriteString(fmt.Sprintf("%s\t%s\tN\n", strings.ToUpper(w[:1])+w[1:], w))
+			}
+			vert.WriteString("</p>\n")
+		}
+		vert.WriteString("</doc>\n")
+	}
+	path := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(path, []byte(vert.String()), 0644))
+	return path
+}
+
+func runExtraction(t *testing.T, vertPaths []string, numWorkers int, spill *cnf.SpillConf) *recordingWriter {
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		NumWorkers:    numWorkers,
+		Ngrams: cnf.NgramConf{
+			NgramSize:   2,
+			CalcARF:     spill == nil,
+			Spill:       spill,
+			VertColumns: db.VertColumns{{Idx: 0, ModFn: "toLower"}, {Idx: 1}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	go func() {
+		for range statusChan {
+		}
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	confs := make([]*vertigo.ParserConf, len(vertPaths))
+	for i, vertPath := range vertPaths {
+		confs[i] = &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"}
+	}
+	_, err = tte.RunFiles(context.Background(), confs)
+	close(statusChan)
+	assert.NoError(t, err)
+	return writer
+}
+
+func TestParallelProcessingMatchesSequential(t *testing.T) {
+	parallelChunkMinLines = 10
+	defer func() { parallelChunkMinLines = 50000 }()
+	vertPath := createTestVertical(t)
+	seq := runExtraction(t, []string{vertPath}, 1, nil)
+	par := runExtraction(t, []string{vertPath}, 4, nil)
+	assert.Equal(t, 100, len(*seq.rows["liveattrs_entry"]))
+	assert.Equal(t, seq.sortedRows("liveattrs_entry"), par.sortedRows("liveattrs_entry"))
+	assert.Greater(t, len(*seq.rows["colcounts"]), 0)
+	assert.Equal(t, seq.sortedRows("colcounts"), par.sortedRows("colcounts"))
+}
+
+func TestParallelIncompatibleOptions(t *testing.T) {
+	for name, modify := range map[string]func(conf *cnf.VTEConf){
+		"atomStructure":  func(conf *cnf.VTEConf) { conf.AtomStructure = "" },
+		"maxAtoms":       func(conf *cnf.VTEConf) { conf.MaxAtoms = 10 },
+		"arfSinglePass":  func(conf *cnf.VTEConf) { conf.Ngrams.CalcARF, conf.Ngrams.ARFSinglePass = true, true },
+		"docFreq":        func(conf *cnf.VTEConf) { conf.Ngrams.DocFreqStructure = "p" },
+		"itemCounts":     func(conf *cnf.VTEConf) { conf.Ngrams.ItemCounts = true },
+		"teiInputFormat": func(conf *cnf.VTEConf) { conf.InputFormat = cnf.InputFormatTEI },
+	} {
+		conf := &cnf.VTEConf{
+			Corpus:        "test",
+			AtomStructure: "p",
+			Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+			NumWorkers:    4,
+			Ngrams: cnf.NgramConf{
+				NgramSize:   2,
+				VertColumns: db.VertColumns{{Idx: 0}, {Idx: 1}},
+			},
+		}
+		modify(conf)
+		itemID := func(attrs map[string]any) (string, error) {
+			return fmt.Sprint(attrs["p_num"]), nil
+		}
+		_, err := NewExtractor(
+			conf, WithWriter(&recordingWriter{rows: make(map[string]*[]string)}), WithColgen(itemID))
+		assert.ErrorContains(t, err, "numWorkers", name)
+	}
+}
+
+func TestSpilledCountingMatchesInMemory(t *testing.T) {
+	vertPath := createTestVertical(t)
+	// ARF is not available with spilling so we compare with
+	// a run where the limit is never reached
+	mem := runExtraction(t, []string{vertPath}, 1, &cnf.SpillConf{Dir: t.TempDir(), MaxNgramsInMemory: 1000})
+	spillDir := t.TempDir()
+	spilled := runExtraction(t, []string{vertPath}, 1, &cnf.SpillConf{Dir: spillDir, MaxNgramsInMemory: 3})
+	assert.Equal(t, mem.sortedRows("colcounts"), spilled.sortedRows("colcounts"))
+	files, err := os.ReadDir(spillDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestMultipleFilesMatchSingleFile(t *testing.T) {
+	parallelChunkMinLines = 10
+	defer func() { parallelChunkMinLines = 50000 }()
+	vertPath := createTestVertical(t)
+	data, err := os.ReadFile(vertPath)
+	assert.NoError(t, err)
+	docs := strings.SplitAfter(string(data), "</doc>\n")
+	part1 := filepath.Join(t.TempDir(), "part1.vert")
+	part2 := filepath.Join(t.TempDir(), "part2.vert")
+	assert.NoError(t, os.WriteFile(part1, []byte(strings.Join(docs[:2], "")), 0644))
+	assert.NoError(t, os.WriteFile(part2, []byte(strings.Join(docs[2:], "")), 0644))
+
+	single := runExtraction(t, []string{vertPath}, 1, nil)
+	for _, numWorkers := range []int{1, 4} {
+		multi := runExtraction(t, []string{part1, part2}, numWorkers, nil)
+		assert.Equal(t, single.sortedRows("liveattrs_entry"), multi.sortedRows("liveattrs_entry"))
+		assert.Equal(t, single.sortedRows("colcounts"), multi.sortedRows("colcounts"))
+	}
+}
+
+func TestCancelledRunRollsBack(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	go func() {
+		for range statusChan {
+		}
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tte.Run(ctx, &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	close(statusChan)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, writer.rolledBack)
+	assert.Empty(t, *writer.rows["liveattrs_entry"])
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+)
+
+const (
+	parseErrorMaxTextLength = 200
+)
+
+// ParseError describes a malformed line of a vertical file
+// skipped during processing
+type ParseError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (pe ParseError) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", pe.File, pe.Line, pe.Err, pe.Text)
+}
+
+func shortenLineText(text string) string {
+	runes := []rune(text)
+	if len(runes) > parseErrorMaxTextLength {
+		return string(runes[:parseErrorMaxTextLength]) + "..."
+	}
+	return text
+}
+
+// addParseError records a malformed line. In case the number of
+// malformed lines exceeds the configured limit, ErrorTooManyParsingErrors
+// is returned.
+func (tte *TTExtractor) addParseError(text string, line int, err error) error {
+	tte.skippedLines++
+	if len(tte.parseErrors) >= tte.maxParseErrors {
+		return fmt.Errorf(
+			"%w: more than %d malformed lines (last one at %s:%d: %s)",
+			ErrorTooManyParsingErrors, tte.maxParseErrors, tte.currentFile, line, err)
+	}
+	tte.parseErrors = append(
+		tte.parseErrors,
+		ParseError{File: tte.currentFile, Line: line, Text: shortenLineText(text), Err: err},
+	)
+	return nil
+}
+
+// handleStructError handles an error caused by an inconsistent structure
+// (e.g. a closing tag without a matching opening one). With an error budget
+// (maxParseErrors) configured, the line is recorded and skipped. Otherwise
+// the standard error handling is applied.
+func (tte *TTExtractor) handleStructError(text string, line int, err error) error {
+	if tte.maxParseErrors > 0 {
+		return tte.addParseError(text, line, err)
+	}
+	return tte.handleProcError(line, err)
+}
+
+// ProcMalformedLine is a part of input.MalformedLineProcessor implementation.
+// It is called for lines which cannot be parsed at all.
+func (tte *TTExtractor) ProcMalformedLine(text string, line int, err error) error {
+	if tte.strictParsing {
+		return fmt.Errorf("malformed line %d (%s): %w", line, shortenLineText(text), err)
+	}
+	if tte.maxParseErrors > 0 {
+		return tte.addParseError(text, line, err)
+	}
+	tte.logger.Warn().Err(err).Str("file", tte.currentFile).Int("lineNumber", line).Msg("skipping invalid line")
+	tte.skippedLines++
+	return nil
+}
+
+// reportParseErrors logs all the recorded malformed lines and sends
+// them via statusChan
+func (tte *TTExtractor) reportParseErrors() {
+	if len(tte.parseErrors) == 0 {
+		return
+	}
+	for _, pe := range tte.parseErrors {
+		tte.logger.Warn().
+			Str("file", pe.File).
+			Int("lineNumber", pe.Line).
+			Str("text", pe.Text).
+			Err(pe.Err).
+			Msg("skipped malformed line")
+	}
+	tte.logger.Warn().
+		Int("numSkipped", len(tte.parseErrors)).
+		Int("maxParseErrors", tte.maxParseErrors).
+		Msg("Some malformed lines have been skipped")
+	st := tte.status(tte.lineCounter)
+	st.ParseErrors = tte.parseErrors
+	tte.sendStatus(st)
+}
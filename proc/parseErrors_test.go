@@ -0,0 +1,159 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func runWithParseErrors(t *testing.T, maxParseErrors int) ([]ParseError, *recordingWriter, error) {
+	vert := "<doc id=\"d1\">\n<p num=\"1\">\nA\ta\n<>\n</p>\n</q>\n<p num=\"2\">\nB\tb\n</p>\n</doc>\n"
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:         "test",
+		AtomStructure:  "p",
+		Structures:     map[string][]string{"doc": {"id"}, "p": {"num"}},
+		MaxParseErrors: maxParseErrors,
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	var report []ParseError
+	done := make(chan struct{})
+	go func() {
+		for st := range statusChan {
+			if st.ParseErrors != nil {
+				report = st.ParseErrors
+			}
+		}
+		close(done)
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	close(statusChan)
+	<-done
+	return report, writer, err
+}
+
+func TestMalformedLinesWithinBudget(t *testing.T) {
+	report, writer, err := runWithParseErrors(t, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*writer.rows["liveattrs_entry"]))
+	if assert.Equal(t, 2, len(report)) {
+		assert.Equal(t, 3, report[0].Line)
+		assert.Equal(t, "<>", report[0].Text)
+		assert.Equal(t, 5, report[1].Line)
+		assert.Equal(t, "</q>", report[1].Text)
+	}
+}
+
+func TestMalformedLinesOverBudget(t *testing.T) {
+	_, writer, err := runWithParseErrors(t, 1)
+	assert.True(t, errors.Is(err, ErrorTooManyParsingErrors))
+	assert.True(t, writer.rolledBack)
+}
+
+func TestStrictParsing(t *testing.T) {
+	vert := "<doc id=\"d1\">\n<p num=\"1\">\nA\ta\n<>\n</p>\n</doc>\n"
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Parser:        &cnf.ParserConf{Strict: true},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.ErrorContains(t, err, "malformed line 3")
+	assert.True(t, writer.rolledBack)
+}
+
+func runWithStrictness(t *testing.T, strictness string) (*RunStats, error) {
+	vert := "<doc id=\"d1\" lang=\"cs\">\n<p num=\"1\">\nA\ta\n<g/>\n</p>\n</doc>\n"
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id", "title"}, "p": {"num"}},
+		Strictness:    strictness,
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	return tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+}
+
+func TestLenientStrictness(t *testing.T) {
+	stats, err := runWithStrictness(t, cnf.StrictnessLenient)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"g": 1}, stats.UnknownStructures)
+	assert.Equal(t, map[string]int{"doc.lang": 1}, stats.UnknownAttrs)
+	assert.Equal(t, []string{"doc.title"}, stats.MissingAttrs)
+}
+
+func TestStrictStrictness(t *testing.T) {
+	_, err := runWithStrictness(t, cnf.StrictnessStrict)
+	assert.ErrorContains(t, err, "attribute doc.lang on line 0 is not configured")
+}
+
+func TestParseErrorsAndNumErrorsBudgets(t *testing.T) {
+	vert := "<doc id=\"d1\">\n<p num=\"1\">\nA\ta\n<>\n</p>\n</q>\n<p num=\"2\">\nB\tb\n</p>\n</doc>\n"
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	run := func(maxNumErrors int) (*RunStats, error) {
+		conf := &cnf.VTEConf{
+			Corpus:         "test",
+			AtomStructure:  "p",
+			Structures:     map[string][]string{"doc": {"id"}, "p": {"num"}},
+			MaxParseErrors: 2,
+			MaxNumErrors:   maxNumErrors,
+		}
+		hook := AtomHookFunc(func(attrs map[string]any) (bool, error) {
+			if attrs["p_num"] == "2" {
+				return false, errors.New("rejected")
+			}
+			return true, nil
+		})
+		writer := &recordingWriter{rows: make(map[string]*[]string)}
+		tte, err := NewExtractor(conf, WithWriter(writer), WithAtomHook(hook))
+		assert.NoError(t, err)
+		return tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	}
+
+	// the two malformed lines use up maxParseErrors only
+	stats, err := run(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.SkippedLines)
+	assert.Equal(t, 1, stats.NumErrors)
+
+	// the hook error alone exceeds maxNumErrors
+	_, err = run(0)
+	assert.True(t, errors.Is(err, ErrorTooManyParsingErrors))
+}
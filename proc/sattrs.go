@@ -0,0 +1,160 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/fs"
+	"github.com/rs/zerolog"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+var (
+	sattrValueReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+// openRegion is a structure which has been opened but
+// not closed yet
+type openRegion struct {
+	start int
+	attrs map[string]string
+}
+
+// sattrExporter writes regions of configured structures (and values
+// of their attributes) in the format used by cwb-s-encode, i.e.
+// one "start<TAB>end[<TAB>value]" line per region with start and end
+// being (inclusive) token positions. For each structure, a file
+// [struct].s is created and for each of its attributes a file
+// [struct]_[attr].s is created.
+type sattrExporter struct {
+	structures map[string][]string
+	files      map[string]*fs.AtomicFile
+	open       map[string][]openRegion
+	warnedNest map[string]bool
+	logger     *zerolog.Logger
+}
+
+func (se *sattrExporter) writeLine(file string, values ...any) error {
+	f, ok := se.files[file]
+	if !ok {
+		return nil
+	}
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = fmt.Sprint(v)
+	}
+	_, err := f.Write([]byte(strings.Join(items, "\t") + "\n"))
+	return err
+}
+
+// structOpen registers an opened structure with its first token
+// being at the position nextPos
+func (se *sattrExporter) structOpen(st *vertigo.Structure, nextPos int) {
+	if _, ok := se.structures[st.Name]; !ok || st.IsEmpty {
+		return
+	}
+	se.open[st.Name] = append(se.open[st.Name], openRegion{start: nextPos, attrs: st.Attrs})
+}
+
+// structClose writes a closed structure region with its last token
+// being at the position nextPos - 1. Empty regions are ignored as
+// well as nested structures of the same name (which are not supported
+// by CWB).
+func (se *sattrExporter) structClose(name string, nextPos int) error {
+	stack := se.open[name]
+	if len(stack) == 0 {
+		return nil
+	}
+	region := stack[len(stack)-1]
+	se.open[name] = stack[:len(stack)-1]
+	if len(stack) > 1 {
+		if !se.warnedNest[name] {
+			se.logger.Warn().Str("structure", name).Msg("nested structures cannot be exported as s-attributes, skipping")
+			se.warnedNest[name] = true
+		}
+		return nil
+	}
+	end := nextPos - 1
+	if end < region.start {
+		return nil
+	}
+	if err := se.writeLine(name, region.start, end); err != nil {
+		return fmt.Errorf("failed to export structure %s: %w", name, err)
+	}
+	for _, attr := range se.structures[name] {
+		value := sattrValueReplacer.Replace(region.attrs[attr])
+		if err := se.writeLine(name+"_"+attr, region.start, end, value); err != nil {
+			return fmt.Errorf("failed to export attribute %s.%s: %w", name, attr, err)
+		}
+	}
+	return nil
+}
+
+// commit moves all the written files to their final location
+func (se *sattrExporter) commit() error {
+	for name, f := range se.files {
+		if err := f.Commit(); err != nil {
+			return fmt.Errorf("failed to write s-attribute file for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// discard removes all the written files
+func (se *sattrExporter) discard() {
+	for name, f := range se.files {
+		if err := f.Discard(); err != nil {
+			se.logger.Error().Err(err).Str("file", name).Msg("failed to remove unfinished s-attribute file")
+		}
+	}
+}
+
+func newSAttrExporter(
+	dir string,
+	structures map[string][]string,
+	logger *zerolog.Logger,
+) (*sattrExporter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create s-attribute export directory: %w", err)
+	}
+	ans := &sattrExporter{
+		structures: structures,
+		files:      make(map[string]*fs.AtomicFile),
+		open:       make(map[string][]openRegion),
+		warnedNest: make(map[string]bool),
+		logger:     logger,
+	}
+	for name, attrs := range structures {
+		files := append([]string{name}, make([]string, len(attrs))...)
+		for i, attr := range attrs {
+			files[i+1] = name + "_" + attr
+		}
+		for _, file := range files {
+			f, err := fs.NewAtomicFile(filepath.Join(dir, file+".s"), false)
+			if err != nil {
+				ans.discard()
+				return nil, fmt.Errorf("failed to create s-attribute file: %w", err)
+			}
+			ans.files[file] = f
+		}
+	}
+	return ans, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestSAttrExport(t *testing.T) {
+	vert := "<doc id=\"d1\">\n<p num=\"1\">\nA\ta\nB\tb\n</p>\n<p num=\"x\ty\">\nC\tc\n</p>\n<p num=\"3\">\n</p>\n</doc>\n" +
+		"<doc id=\"d2\">\n<p num=\"4\">\nD\td\n</p>\n</doc>\n"
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte(vert), 0644))
+	exportDir := t.TempDir()
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		SAttrExport:   &cnf.SAttrExportConf{Dir: exportDir},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	go func() {
+		for range statusChan {
+		}
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	close(statusChan)
+	assert.NoError(t, err)
+
+	expected := map[string]string{
+		"doc.s":    "0\t2\n3\t3\n",
+		"doc_id.s": "0\t2\td1\n3\t3\td2\n",
+		"p.s":      "0\t1\n2\t2\n3\t3\n",
+		"p_num.s":  "0\t1\t1\n2\t2\tx y\n3\t3\t4\n",
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(filepath.Join(exportDir, file))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data), file)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import "fmt"
+
+// flushStagedCountsIfNeeded writes counted n-grams to the staging
+// table in case the incremental flush is enabled and enough tokens
+// have been processed since the last flush
+func (tte *TTExtractor) flushStagedCountsIfNeeded() error {
+	if tte.colcountsStager == nil ||
+		tte.tokenCounter-tte.lastFlushToken < tte.ngramConf.FlushEveryTokens {
+		return nil
+	}
+	return tte.flushStagedCounts()
+}
+
+// flushStagedCounts writes all the n-grams counted so far
+// to the staging table and removes them from memory
+func (tte *TTExtractor) flushStagedCounts() error {
+	if tte.stagingInsert == nil {
+		var err error
+		tte.stagingInsert, err = tte.colcountsStager.PrepareStagingInsert(tte.colCountsAttrs())
+		if err != nil {
+			return err
+		}
+		tte.addTableColumns("colcounts_staging", tte.colCountsAttrs())
+	}
+	counts := tte.GetColCounts()
+	var numRows int
+	for i := 0; i < counts.NumShards(); i++ {
+		for _, count := range counts.DrainShard(i) {
+			args := tte.colCountsRow(count)
+			tte.applyKeepEmpty(args)
+			if err := tte.stagingInsert.Exec(args...); err != nil {
+				return fmt.Errorf("failed to write staged n-gram counts: %w", err)
+			}
+			tte.addWrittenRows("colcounts_staging", 1)
+			numRows++
+		}
+	}
+	tte.lastFlushToken = tte.tokenCounter
+	tte.logger.Info().
+		Str("phase", PhaseParsing).
+		Int("rows", numRows).
+		Int("numTokens", tte.tokenCounter).
+		Msg("Flushed partial n-gram counts to the staging table")
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"time"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// RunStats summarizes a finished extraction run
+type RunStats struct {
+
+	// ProcessedTokens is number of all the processed tokens
+	ProcessedTokens int
+
+	// InsertedAtoms is number of atom structures written
+	// to the liveattrs_entry table
+	InsertedAtoms int
+
+	// DistinctNgrams is number of distinct n-grams (tuples of
+	// configured positional attributes) written to the colcounts
+	// table. In case the counts have been staged (see flushEveryTokens),
+	// the final number is not known and -1 is used.
+	DistinctNgrams int
+
+	// NgramSummary contains the most frequent n-grams and number
+	// of hapaxes. In case no n-grams have been written or the counts
+	// have been staged (see flushEveryTokens), nil is used.
+	NgramSummary *NgramSummary
+
+	// SkippedLines is number of malformed lines which have been skipped
+	SkippedLines int
+
+	// NumErrors is number of processing errors (see maxNumErrors)
+	NumErrors int
+
+	// Phases contains durations of individual processing phases
+	Phases []PhaseTiming
+
+	// RowsWritten contains number of written rows per table
+	RowsWritten map[string]int
+
+	// UnknownStructures contains numbers of occurrences of structures
+	// found in the vertical but not configured (lenient strictness only)
+	UnknownStructures map[string]int
+
+	// UnknownAttrs contains numbers of occurrences of structural
+	// attributes (in the [struct].[attr] form) found in the vertical
+	// but not configured (lenient strictness only)
+	UnknownAttrs map[string]int
+
+	// MissingAttrs contains configured attributes which have not
+	// been found in the vertical
+	MissingAttrs []string
+
+	// TableColumns contains columns of all the tables
+	// the extractor has written to
+	TableColumns map[string][]string
+
+	// InferredColumns contains types of liveattrs_entry columns
+	// (keyed by [struct]_[attr]) inferred from the vertical
+	// (see cnf.VTEConf.InferColumnTypes)
+	InferredColumns map[string]db.InferredColumn
+
+	// Elapsed is the total duration of the run
+	Elapsed time.Duration
+}
+
+func (tte *TTExtractor) addWrittenRows(table string, num int) {
+	tte.rowsWritten[table] += num
+	tte.phaseRows += num
+}
+
+func (tte *TTExtractor) addTableColumns(table string, cols []string) {
+	tte.tableColumns[table] = cols
+}
+
+func (tte *TTExtractor) runStats(t0 time.Time) *RunStats {
+	ans := &RunStats{
+		ProcessedTokens: tte.processedTokens,
+		InsertedAtoms:   tte.rowsWritten["liveattrs_entry"],
+		DistinctNgrams:  tte.rowsWritten["colcounts"],
+		NgramSummary:    tte.ngramSummary,
+		SkippedLines:    tte.skippedLines,
+		NumErrors:       tte.errorCounter,
+		Phases:          tte.phases,
+		RowsWritten:     make(map[string]int, len(tte.rowsWritten)),
+		TableColumns:    make(map[string][]string, len(tte.tableColumns)),
+		InferredColumns: tte.inferredColumns,
+		Elapsed:         time.Since(t0),
+	}
+	if tte.colcountsStager != nil {
+		ans.DistinctNgrams = -1
+	}
+	if tte.structCheck != nil {
+		ans.UnknownStructures = tte.structCheck.unknownStructs
+		ans.UnknownAttrs = tte.structCheck.unknownAttrs
+		ans.MissingAttrs = tte.structCheck.missingAttrs()
+	}
+	for k, v := range tte.rowsWritten {
+		ans.RowsWritten[k] = v
+	}
+	for k, v := range tte.tableColumns {
+		ans.TableColumns[k] = v
+	}
+	return ans
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestRunStats(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			VertColumns: db.VertColumns{{Idx: 1}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	statusChan := make(chan Status)
+	go func() {
+		for range statusChan {
+		}
+	}()
+	tte, err := NewTTExtractor(writer, conf, nil, statusChan, make(chan os.Signal))
+	assert.NoError(t, err)
+	stats, err := tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	close(statusChan)
+	assert.NoError(t, err)
+	assert.Equal(t, 450, stats.ProcessedTokens)
+	assert.Equal(t, 100, stats.InsertedAtoms)
+	assert.Equal(t, 5, stats.DistinctNgrams)
+	assert.Equal(t, map[string]int{"liveattrs_entry": 100, "colcounts": 5, db.CorpusSizesTable: 1}, stats.RowsWritten)
+	assert.Equal(t, []string{PhaseParsing, PhaseColcounts}, []string{stats.Phases[0].Name, stats.Phases[1].Name})
+}
+
+func TestPhaseLogFields(t *testing.T) {
+	vertPath := createTestVertical(t)
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"id"}, "p": {"num"}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:   1,
+			VertColumns: db.VertColumns{{Idx: 1}},
+		},
+	}
+	var buff bytes.Buffer
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(
+		conf,
+		WithWriter(writer),
+		WithLogger(zerolog.New(&buff).With().Str("corpus", conf.Corpus).Logger()),
+	)
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+
+	phaseRows := make(map[string]int)
+	for _, line := range bytes.Split(bytes.TrimSpace(buff.Bytes()), []byte("\n")) {
+		var rec map[string]any
+		assert.NoError(t, json.Unmarshal(line, &rec))
+		assert.Equal(t, "test", rec["corpus"])
+		if rec["message"] == "Finished processing phase" {
+			phaseRows[rec["phase"].(string)] = int(rec["rows"].(float64))
+		}
+	}
+	// the corpus_sizes row is written between the two phases
+	assert.Equal(t, map[string]int{PhaseParsing: 100, PhaseColcounts: 6}, phaseRows)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"github.com/czcorpus/vert-tagextract/v2/input"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// stoppableProcessor wraps a line processor not aware of
+// the extractor's stop conditions (e.g. the ARF calculator)
+// so the processing can be stopped or cancelled. It also stops
+// at the same token as the main pass in case maxAtoms is set.
+type stoppableProcessor struct {
+	lproc vertigo.LineProcessor
+	tte   *TTExtractor
+}
+
+func (sp *stoppableProcessor) ProcToken(tk *vertigo.Token, line int, err error) error {
+	if stopErr := sp.tte.checkStop(); stopErr != nil {
+		return stopErr
+	}
+	if sp.tte.tokenLimit > 0 && tk.Idx >= sp.tte.tokenLimit {
+		return errAtomLimitReached
+	}
+	return sp.lproc.ProcToken(tk, line, err)
+}
+
+func (sp *stoppableProcessor) ProcStruct(st *vertigo.Structure, line int, err error) error {
+	if stopErr := sp.tte.checkStop(); stopErr != nil {
+		return stopErr
+	}
+	return sp.lproc.ProcStruct(st, line, err)
+}
+
+func (sp *stoppableProcessor) ProcStructClose(st *vertigo.StructureClose, line int, err error) error {
+	return sp.lproc.ProcStructClose(st, line, err)
+}
+
+func (sp *stoppableProcessor) ProcMalformedLine(text string, line int, err error) error {
+	if mlproc, ok := sp.lproc.(input.MalformedLineProcessor); ok {
+		return mlproc.ProcMalformedLine(text, line, err)
+	}
+	return nil
+}
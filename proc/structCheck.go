@@ -0,0 +1,143 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// structChecker compares structures and attributes found in the vertical
+// with the configured ones. In the strict mode, any difference
+// is reported as an error. Otherwise, the differences are just
+// counted and summarized in the end.
+type structChecker struct {
+	structures map[string][]string
+
+	// implicit contains structures accepted even if not configured
+	// (atom and atom parent structures)
+	implicit map[string]bool
+
+	// computed contains computed attributes (in the [struct]_[attr]
+	// form) which are not expected to be found in the vertical
+	computed map[string]bool
+
+	strict         bool
+	unknownStructs map[string]int
+	unknownAttrs   map[string]int
+	seenAttrs      map[string]bool
+}
+
+func newStructChecker(structures map[string][]string, strict bool, implicit ...string) *structChecker {
+	ans := &structChecker{
+		structures:     structures,
+		implicit:       make(map[string]bool),
+		computed:       make(map[string]bool),
+		strict:         strict,
+		unknownStructs: make(map[string]int),
+		unknownAttrs:   make(map[string]int),
+		seenAttrs:      make(map[string]bool),
+	}
+	for _, s := range implicit {
+		if s != "" {
+			ans.implicit[s] = true
+		}
+	}
+	return ans
+}
+
+func (sc *structChecker) isConfigured(structName, attrName string) bool {
+	for _, v := range sc.structures[structName] {
+		if v == attrName {
+			return true
+		}
+	}
+	return false
+}
+
+// check tests a structure found in the vertical on the line 'line'
+func (sc *structChecker) check(st *vertigo.Structure, line int) error {
+	if st == nil {
+		return nil
+	}
+	if _, ok := sc.structures[st.Name]; !ok && !sc.implicit[st.Name] {
+		if sc.strict {
+			return fmt.Errorf("structure %s on line %d is not configured", st.Name, line)
+		}
+		sc.unknownStructs[st.Name]++
+		return nil
+	}
+	for attr := range st.Attrs {
+		fullName := st.Name + "." + attr
+		if sc.isConfigured(st.Name, attr) {
+			sc.seenAttrs[fullName] = true
+
+		} else if sc.strict {
+			return fmt.Errorf("attribute %s on line %d is not configured", fullName, line)
+
+		} else {
+			sc.unknownAttrs[fullName]++
+		}
+	}
+	return nil
+}
+
+// missingAttrs returns sorted configured attributes
+// which have not been found in the vertical
+func (sc *structChecker) missingAttrs() []string {
+	ans := make([]string, 0, 10)
+	for structName, attrs := range sc.structures {
+		for _, attr := range attrs {
+			if !sc.seenAttrs[structName+"."+attr] && !sc.computed[structName+"_"+attr] {
+				ans = append(ans, structName+"."+attr)
+			}
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// finish checks for configured attributes which have not been
+// found in the vertical and summarizes all the found problems
+func (sc *structChecker) finish(logger *zerolog.Logger) error {
+	missing := sc.missingAttrs()
+	if sc.strict && len(missing) > 0 {
+		return fmt.Errorf("configured attributes not found in the vertical: %s", strings.Join(missing, ", "))
+	}
+	if len(sc.unknownStructs) > 0 {
+		evt := logger.Warn()
+		for name, count := range sc.unknownStructs {
+			evt = evt.Int(name, count)
+		}
+		evt.Msg("Found structures not configured in structures (numbers of occurrences)")
+	}
+	if len(sc.unknownAttrs) > 0 {
+		evt := logger.Warn()
+		for name, count := range sc.unknownAttrs {
+			evt = evt.Int(name, count)
+		}
+		evt.Msg("Found attributes not configured in structures (numbers of occurrences)")
+	}
+	if len(missing) > 0 {
+		logger.Warn().Strs("attrs", missing).Msg("Configured attributes not found in the vertical")
+	}
+	return nil
+}
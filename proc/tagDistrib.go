@@ -0,0 +1,133 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount/modders"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// tagDistribKey identifies a tag within a value of a text type
+type tagDistribKey struct {
+	attr  string
+	value string
+	tag   string
+}
+
+// tagDistribCounter counts values of a vertical column (typically
+// PoS tags) in the whole corpus and per configured text types
+type tagDistribCounter struct {
+	colIdx    int
+	modder    *modders.StringTransformerChain
+	textTypes []string
+
+	// currValues contains values of textTypes for the current
+	// position in the vertical (nil = the structure is not open)
+	currValues []*string
+
+	counts   map[string]int
+	ttCounts map[tagDistribKey]int
+}
+
+// updateTextTypes reads current values of configured
+// text types from the accumulator of structural attributes
+func (tdc *tagDistribCounter) updateTextTypes(accum AttrAccumulator) {
+	if len(tdc.textTypes) == 0 {
+		return
+	}
+	for i := range tdc.currValues {
+		tdc.currValues[i] = nil
+	}
+	accum.ForEachAttr(func(s string, k string, v string) bool {
+		name := s + "_" + k
+		for i, tt := range tdc.textTypes {
+			if tt == name {
+				val := v
+				tdc.currValues[i] = &val
+			}
+		}
+		return true
+	})
+}
+
+func (tdc *tagDistribCounter) addToken(tk *vertigo.Token) {
+	tag := tdc.modder.Transform(tk.PosAttrByIndex(tdc.colIdx))
+	tdc.counts[tag]++
+	for i, tt := range tdc.textTypes {
+		if tdc.currValues[i] != nil {
+			tdc.ttCounts[tagDistribKey{attr: tt, value: *tdc.currValues[i], tag: tag}]++
+		}
+	}
+}
+
+func newTagDistribCounter(conf *cnf.TagDistribConf) (*tagDistribCounter, error) {
+	if conf.Column.Idx < 0 {
+		return nil, fmt.Errorf("invalid tagDistrib column %d", conf.Column.Idx)
+	}
+	modder, err := newColumnModder(conf.Column)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tagDistrib column modifier: %w", err)
+	}
+	return &tagDistribCounter{
+		colIdx:     conf.Column.Idx,
+		modder:     modder,
+		textTypes:  conf.TextTypes,
+		currValues: make([]*string, len(conf.TextTypes)),
+		counts:     make(map[string]int),
+		ttCounts:   make(map[tagDistribKey]int),
+	}, nil
+}
+
+// insertTagDistrib writes counted tag frequencies into the
+// corpus_tagdistrib and corpus_tagdistrib_tt tables
+func (tte *TTExtractor) insertTagDistrib() error {
+	if tte.tagDistrib == nil {
+		return nil
+	}
+	attrs := []string{"corpus_id", "tag", "count"}
+	ins, err := tte.database.PrepareInsert(db.CorpusTagDistribTable, attrs)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s insert: %w", db.CorpusTagDistribTable, err)
+	}
+	tte.addTableColumns(db.CorpusTagDistribTable, attrs)
+	for tag, count := range tte.tagDistrib.counts {
+		if err := ins.Exec(tte.corpusID, tag, count); err != nil {
+			return fmt.Errorf("failed to insert tag distribution: %w", err)
+		}
+	}
+	tte.addWrittenRows(db.CorpusTagDistribTable, len(tte.tagDistrib.counts))
+	if len(tte.tagDistrib.textTypes) == 0 {
+		return nil
+	}
+	attrs = []string{"corpus_id", "attr", "value", "tag", "count"}
+	ins, err = tte.database.PrepareInsert(db.CorpusTagDistribTTTable, attrs)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s insert: %w", db.CorpusTagDistribTTTable, err)
+	}
+	tte.addTableColumns(db.CorpusTagDistribTTTable, attrs)
+	for k, count := range tte.tagDistrib.ttCounts {
+		if err := ins.Exec(tte.corpusID, k.attr, k.value, k.tag, count); err != nil {
+			return fmt.Errorf("failed to insert tag distribution: %w", err)
+		}
+	}
+	tte.addWrittenRows(db.CorpusTagDistribTTTable, len(tte.tagDistrib.ttCounts))
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestTagDistrib(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte("<doc genre=\"fic\">\n<p>\na\tNN\nb\tVB\n</p>\n</doc>\n"+
+			"<doc genre=\"news\">\n<p>\nc\tNN\nd\tNN\n</p>\n</doc>\n"),
+		0644,
+	))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"doc": {"genre"}, "p": {}},
+		TagDistrib: &cnf.TagDistribConf{
+			Column:    db.VertColumn{Idx: 1},
+			TextTypes: []string{"doc_genre"},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"corpus_id=test, count=1, tag=VB", "corpus_id=test, count=3, tag=NN"},
+		writer.sortedRows(db.CorpusTagDistribTable),
+	)
+	assert.Equal(
+		t,
+		[]string{
+			"attr=doc_genre, corpus_id=test, count=1, tag=NN, value=fic",
+			"attr=doc_genre, corpus_id=test, count=1, tag=VB, value=fic",
+			"attr=doc_genre, corpus_id=test, count=2, tag=NN, value=news",
+		},
+		writer.sortedRows(db.CorpusTagDistribTTTable),
+	)
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount"
+)
+
+// insertTFIDF writes TF-IDF values of n-grams of individual atoms
+// into the corpus_tfidf table. The value is calculated as
+// count_in_atom * ln(num_atoms / docfreq). N-grams not written to
+// colcounts due to minFreq are skipped.
+func (tte *TTExtractor) insertTFIDF() error {
+	attrs := []string{"atom_id", "hash_id", "corpus_id", "tfidf"}
+	ins, err := tte.database.PrepareInsert(db.CorpusTFIDFTable, attrs)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s insert: %w", db.CorpusTFIDFTable, err)
+	}
+	tte.addTableColumns(db.CorpusTFIDFTable, attrs)
+	numDocs := float64(tte.ngrams.NumDocuments())
+	counts := tte.GetColCounts()
+	hashes := make(map[ptcount.NgramKey]string)
+	return tte.ngrams.ForEachDocCount(func(doc int, key ptcount.NgramKey, count int) error {
+		if err := tte.checkStop(); err != nil {
+			return err
+		}
+		ngram, ok := counts.Get(key)
+		if !ok || ngram.Count() < tte.minFreq {
+			return nil
+		}
+		hash, ok := hashes[key]
+		if !ok {
+			hash = tte.generateHashID(ngram)
+			hashes[key] = hash
+		}
+		tfidf := float64(count) * math.Log(numDocs/float64(ngram.DocFreq()))
+		if err := ins.Exec(doc, hash, tte.corpusID, tfidf); err != nil {
+			return err
+		}
+		tte.addWrittenRows(db.CorpusTFIDFTable, 1)
+		return nil
+	})
+}
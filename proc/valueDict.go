@@ -0,0 +1,98 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/db"
+)
+
+// valueDict assigns numeric IDs to values of n-gram columns
+// (see cnf.NgramConf.DictEncoding). All the n-gram positions
+// of a single vertical column share the same dictionary and
+// the same value table.
+type valueDict struct {
+	database db.Writer
+	corpusID string
+	columns  db.VertColumns
+
+	// ids maps vertical column index to value => ID mapping
+	ids map[int]map[string]int
+
+	inserts map[string]db.InsertOperation
+
+	// onInsert is called for each written value row
+	onInsert func(table string, attrs []string)
+}
+
+// encode replaces values of n-gram columns in row with their IDs.
+// Values not seen before are written to respective value tables.
+func (vd *valueDict) encode(row []any) error {
+	for i, col := range vd.columns {
+		value := fmt.Sprint(row[i])
+		colIDs, ok := vd.ids[col.Idx]
+		if !ok {
+			colIDs = make(map[string]int)
+			vd.ids[col.Idx] = colIDs
+		}
+		id, ok := colIDs[value]
+		if !ok {
+			id = len(colIDs) + 1
+			colIDs[value] = id
+			if err := vd.insertValue(db.ColValuesTable(col), id, value); err != nil {
+				return err
+			}
+		}
+		row[i] = id
+	}
+	return nil
+}
+
+func (vd *valueDict) insertValue(table string, id int, value string) error {
+	attrs := []string{"corpus_id", "id", "value"}
+	ins, ok := vd.inserts[table]
+	if !ok {
+		var err error
+		ins, err = vd.database.PrepareInsert(table, attrs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s insert: %w", table, err)
+		}
+		vd.inserts[table] = ins
+	}
+	if err := ins.Exec(vd.corpusID, id, value); err != nil {
+		return fmt.Errorf("failed to insert into %s: %w", table, err)
+	}
+	vd.onInsert(table, attrs)
+	return nil
+}
+
+func newValueDict(
+	database db.Writer,
+	corpusID string,
+	columns db.VertColumns,
+	onInsert func(table string, attrs []string),
+) *valueDict {
+	return &valueDict{
+		database: database,
+		corpusID: corpusID,
+		columns:  columns,
+		ids:      make(map[int]map[string]int),
+		inserts:  make(map[string]db.InsertOperation),
+		onInsert: onInsert,
+	}
+}
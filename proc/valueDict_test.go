@@ -0,0 +1,72 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestDictEncoding(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(vertPath, []byte("<p>\nb\na\nc\n</p>\n<p>\na\nc\na\n</p>\n"), 0644))
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "p",
+		Structures:    map[string][]string{"p": {}},
+		Ngrams: cnf.NgramConf{
+			NgramSize:    1,
+			DictEncoding: true,
+			VertColumns:  db.VertColumns{{Idx: 0}},
+		},
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+
+	rowAttrs := func(row string) map[string]string {
+		ans := make(map[string]string)
+		for _, item := range strings.Split(row, ", ") {
+			kv := strings.SplitN(item, "=", 2)
+			ans[kv[0]] = kv[1]
+		}
+		return ans
+	}
+	values := make(map[string]string)
+	for _, row := range writer.sortedRows(db.ColValuesTable(db.VertColumn{Idx: 0})) {
+		attrs := rowAttrs(row)
+		assert.Equal(t, "test", attrs["corpus_id"])
+		values[attrs["id"]] = attrs["value"]
+	}
+	assert.Len(t, values, 3)
+	counts := make(map[string]string)
+	for _, row := range writer.sortedRows("colcounts") {
+		attrs := rowAttrs(row)
+		counts[values[attrs["col0"]]] = attrs["count"]
+	}
+	assert.Equal(t, map[string]string{"a": "3", "b": "1", "c": "2"}, counts)
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+// mapValue translates a value of an attribute using a respective
+// value map. Values without a mapping are returned unchanged.
+func (tte *TTExtractor) mapValue(attr, value string) string {
+	if v, ok := tte.valueMaps[attr][value]; ok {
+		return v
+	}
+	return value
+}
+
+// applyValueMaps translates values of all the remapped attributes
+func (tte *TTExtractor) applyValueMaps(attrs map[string]any) {
+	for attr := range tte.valueMaps {
+		if v, ok := attrs[attr].(string); ok {
+			attrs[attr] = tte.mapValue(attr, v)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+func TestValueMaps(t *testing.T) {
+	vertPath := filepath.Join(t.TempDir(), "test.vert")
+	assert.NoError(t, os.WriteFile(
+		vertPath,
+		[]byte("<doc id=\"1\" genre=\"NOV\" kw=\"NOV|X\">\na\n</doc>\n<doc id=\"2\" genre=\"OTHER\" kw=\"PUB\">\nb\n</doc>\n"),
+		0644,
+	))
+	genres := map[string]string{"NOV": "novel", "PUB": "journalism"}
+	conf := &cnf.VTEConf{
+		Corpus:        "test",
+		AtomStructure: "doc",
+		Structures:    map[string][]string{"doc": {"id", "genre", "kw"}},
+		ValueMaps:     map[string]map[string]string{"doc_genre": genres, "doc_kw": genres},
+		MultiValues:   map[string]string{"doc_kw": "|"},
+		ComputedAttrs: []cnf.ComputedAttrConf{{Name: "doc_label", Expr: "upper(doc_genre)"}},
+	}
+	itemID := func(attrs map[string]any) (string, error) {
+		return fmt.Sprint(attrs["doc_id"]), nil
+	}
+	writer := &recordingWriter{rows: make(map[string]*[]string)}
+	tte, err := NewExtractor(conf, WithWriter(writer), WithColgen(itemID))
+	assert.NoError(t, err)
+	_, err = tte.Run(context.Background(), &vertigo.ParserConf{InputFilePath: vertPath, StructAttrAccumulator: "nil"})
+	assert.NoError(t, err)
+	rows := writer.sortedRows("liveattrs_entry")
+	assert.Len(t, rows, 2)
+	assert.Contains(t, rows[0], "doc_genre=OTHER")
+	assert.Contains(t, rows[1], "doc_genre=novel")
+	assert.Contains(t, rows[1], "doc_label=NOVEL")
+	assert.Equal(
+		t,
+		[]string{
+			"attr=doc_kw, corpus_id=test, item_id=1, value=X",
+			"attr=doc_kw, corpus_id=test, item_id=1, value=novel",
+			"attr=doc_kw, corpus_id=test, item_id=2, value=journalism",
+		},
+		writer.sortedRows(db.LiveattrsMultiValueTable),
+	)
+
+	conf.ValueMaps = map[string]map[string]string{"doc_type": genres}
+	_, err = NewExtractor(conf, WithWriter(writer), WithColgen(itemID))
+	assert.ErrorContains(t, err, "not configured in structures")
+}
@@ -72,16 +72,25 @@ func (ws WordARF) String() string {
 // obtain in the 1st pass.
 type ARFCalculator struct {
 	ngramConf     *cnf.NgramConf
-	counts        map[string]*NgramCounter
+	counts        *NgramMap
 	currSentence  [][]int
 	numTokens     int
 	columnModders []*modders.StringTransformerChain
 	wordDict      *WordDict
 	atomStruct    string
+	keyCols       []int
+	tokenFilter   *TokenFilter
+	currStops     []bool
+}
+
+// SetTokenFilter sets a filter of tokens taking part in counted
+// n-grams. It must be the same filter as the one used by NgramCollector.
+func (arfc *ARFCalculator) SetTokenFilter(filter *TokenFilter) {
+	arfc.tokenFilter = filter
 }
 
 // NewARFCalculator is the recommended factory to create an instance of the type
-func NewARFCalculator(counts map[string]*NgramCounter, ngramConf *cnf.NgramConf, numTokens int,
+func NewARFCalculator(counts *NgramMap, ngramConf *cnf.NgramConf, numTokens int,
 	columnModders []*modders.StringTransformerChain, wordDict *WordDict, atomStruct string) *ARFCalculator {
 	return &ARFCalculator{
 		numTokens:     numTokens,
@@ -91,28 +100,31 @@ func NewARFCalculator(counts map[string]*NgramCounter, ngramConf *cnf.NgramConf,
 		columnModders: columnModders,
 		atomStruct:    atomStruct,
 		wordDict:      wordDict,
+		keyCols:       keyColumns(ngramConf),
 	}
 }
 
 // ProcToken is called by vertigo parser when a token is encountered
 func (arfc *ARFCalculator) ProcToken(tk *vertigo.Token, line int, err error) error {
-	attributes := make([]int, arfc.ngramConf.VertColumns.MaxColumn()+1)
-	for _, vertCol := range arfc.ngramConf.VertColumns {
-		v := tk.PosAttrByIndex(vertCol.Idx)
-		attributes[vertCol.Idx] = arfc.wordDict.Add(arfc.columnModders[vertCol.Idx].Transform(v))
+	attributes, isStop, excluded := encodeToken(
+		tk, arfc.ngramConf, arfc.columnModders, arfc.wordDict, arfc.tokenFilter)
+	if excluded {
+		return nil
 	}
 
 	arfc.currSentence = append(arfc.currSentence, attributes)
+	arfc.currStops = append(arfc.currStops, isStop)
 	if len(arfc.currSentence) >= arfc.ngramConf.NgramSize {
-		ngram := NewNgramCounter(arfc.ngramConf.NgramSize)
 		startPos := len(arfc.currSentence) - arfc.ngramConf.NgramSize
 		for i := startPos; i < len(arfc.currSentence); i++ {
-			ngram.AddToken(arfc.currSentence[i])
+			if arfc.currStops[i] {
+				return nil
+			}
 		}
-		key := ngram.UniqueID()
-		cnt, ok := arfc.counts[key]
+		key := sentenceNgramKey(arfc.currSentence, startPos, arfc.ngramConf.NgramSize, arfc.keyCols)
+		cnt, ok := arfc.counts.Get(key)
 		if !ok {
-			log.Warn().Str("token", key).Msg("token not found in previously processed data")
+			log.Warn().Ints32("token", key[:]).Msg("token not found in previously processed data")
 			return nil
 		}
 		if !cnt.HasARF() {
@@ -126,29 +138,69 @@ func (arfc *ARFCalculator) ProcToken(tk *vertigo.Token, line int, err error) err
 	return nil
 }
 
-// ProcStruct is used by Vertigo parser but we don't need it here
+// ProcStruct resets the current sentence on configured
+// n-gram boundary structures
 func (arfc *ARFCalculator) ProcStruct(strc *vertigo.Structure, line int, err error) error {
+	if arfc.ngramConf.IsBoundary(strc.Name) {
+		arfc.currSentence = arfc.currSentence[:0]
+		arfc.currStops = arfc.currStops[:0]
+	}
 	return err
 }
 
-// ProcStructClose is used by Vertigo parser but we don't need it here
+// ProcMalformedLine ignores malformed lines as they have been
+// already reported during the first pass
+func (arfc *ARFCalculator) ProcMalformedLine(text string, line int, err error) error {
+	return nil
+}
+
+// ProcStructClose resets the current sentence on atom
+// and n-gram boundary structures
 func (arfc *ARFCalculator) ProcStructClose(strc *vertigo.StructureClose, line int, err error) error {
-	if strc.Name == arfc.atomStruct {
+	if strc.Name == arfc.atomStruct || arfc.ngramConf.IsBoundary(strc.Name) {
 		arfc.currSentence = arfc.currSentence[:0]
+		arfc.currStops = arfc.currStops[:0]
 	}
 	return err
 }
 
+// CalcSinglePassARF calculates ARF of all the n-grams using token
+// positions recorded by NgramCollector (see RecordPositions). The
+// results are the same as in case of ARFCalculator. Recorded positions
+// are released once the calculation is done.
+func CalcSinglePassARF(counts *NgramMap, numTokens int) {
+	counts.ForEach(func(k NgramKey, val *NgramCounter) bool {
+		if len(val.positions) == 0 {
+			return true
+		}
+		avgDist := float64(numTokens) / float64(val.Count())
+		first := val.positions[0]
+		last := val.positions[len(val.positions)-1]
+		var arf float64
+		for i := 1; i < len(val.positions); i++ {
+			arf += min(avgDist, val.positions[i]-val.positions[i-1])
+		}
+		arf += min(avgDist, first+numTokens-last)
+		val.arf = &WordARF{
+			ARF:        math.Round(arf/avgDist*1000) / 1000.0,
+			FirstIdx:   first,
+			PrevTokIdx: last,
+		}
+		val.positions = nil
+		return true
+	})
+}
+
 // Finalize performs some final calculations on obtained
 // (and continuouslz calculated) data. It is required to
 // to obtain correct ARF results.
 func (arfc *ARFCalculator) Finalize() {
-	for k, val := range arfc.counts {
+	arfc.counts.ForEach(func(k NgramKey, val *NgramCounter) bool {
 		if val.HasARF() {
-			cnt := arfc.counts[k]
-			avgDist := float64(arfc.numTokens) / float64(cnt.Count())
+			avgDist := float64(arfc.numTokens) / float64(val.Count())
 			val.ARF().ARF += min(avgDist, val.ARF().FirstIdx+arfc.numTokens-val.ARF().PrevTokIdx)
 			val.ARF().ARF = math.Round(val.ARF().ARF/avgDist*1000) / 1000.0
 		}
-	}
+		return true
+	})
 }
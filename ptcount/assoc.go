@@ -0,0 +1,73 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"math"
+)
+
+// AssocScores contains association measures of a 2-gram
+// (see CalcAssocScores)
+type AssocScores struct {
+	MI      float64
+	TScore  float64
+	LogDice float64
+}
+
+// newAssocScores calculates association measures of a 2-gram
+// with frequency fxy and marginal frequencies fx, fy in a sample
+// of n 2-grams
+func newAssocScores(fxy, fx, fy, n int) *AssocScores {
+	expected := float64(fx) * float64(fy) / float64(n)
+	return &AssocScores{
+		MI:      math.Log2(float64(fxy) / expected),
+		TScore:  (float64(fxy) - expected) / math.Sqrt(float64(fxy)),
+		LogDice: 14 + math.Log2(2*float64(fxy)/float64(fx+fy)),
+	}
+}
+
+// splitBigramKey splits a key of a 2-gram into keys of its positions.
+// The width argument specifies number of columns stored per position.
+func splitBigramKey(key NgramKey, width int) (NgramKey, NgramKey) {
+	var x, y NgramKey
+	copy(x[:width], key[:width])
+	copy(y[:width], key[width:2*width])
+	return x, y
+}
+
+// CalcAssocScores calculates association measures (MI, t-score, logDice)
+// of all the counted 2-grams. Marginal frequencies are obtained from
+// the 2-gram counts (i.e. f(x) is frequency of x at the first position
+// of any 2-gram and f(y) is frequency of y at the second position).
+// The width argument specifies number of columns stored per position.
+func CalcAssocScores(counts *NgramMap, width int) {
+	left := make(map[NgramKey]int)
+	right := make(map[NgramKey]int)
+	var total int
+	counts.ForEach(func(k NgramKey, val *NgramCounter) bool {
+		x, y := splitBigramKey(k, width)
+		left[x] += val.Count()
+		right[y] += val.Count()
+		total += val.Count()
+		return true
+	})
+	counts.ForEach(func(k NgramKey, val *NgramCounter) bool {
+		x, y := splitBigramKey(k, width)
+		val.assoc = newAssocScores(val.Count(), left[x], right[y], total)
+		return true
+	})
+}
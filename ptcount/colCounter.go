@@ -37,6 +37,21 @@ type NgramCounter struct {
 	count  int
 	tokens []Position
 	arf    *WordARF // can be nil
+
+	// positions contains token indices of the n-gram occurrences
+	// (used only for single pass ARF calculation)
+	positions []int
+
+	// docFreq is number of distinct documents the n-gram occurs in
+	docFreq int
+
+	// lastDoc is an index of the last document (starting from 1)
+	// the n-gram has been found in
+	lastDoc int
+
+	// assoc contains association measures of a 2-gram
+	// (see CalcAssocScores)
+	assoc *AssocScores
 }
 
 // Length returns n-gram length (1 = unigram, 2 = bigram,...)
@@ -70,6 +85,33 @@ func (c *NgramCounter) AddARF(tk *vertigo.Token) {
 	}
 }
 
+// addPosition records a token index of an occurrence
+// of the n-gram (see CalcSinglePassARF)
+func (c *NgramCounter) addPosition(idx int) {
+	c.positions = append(c.positions, idx)
+}
+
+// addDoc updates document frequency based on an index
+// (starting from 1) of a document containing the n-gram
+func (c *NgramCounter) addDoc(docIdx int) {
+	if c.lastDoc != docIdx {
+		c.docFreq++
+		c.lastDoc = docIdx
+	}
+}
+
+// DocFreq returns number of distinct documents the n-gram
+// occurs in (see cnf.NgramConf.DocFreqStructure)
+func (c *NgramCounter) DocFreq() int {
+	return c.docFreq
+}
+
+// Assoc returns association measures of the n-gram. In case
+// they have not been calculated, nil is returned.
+func (c *NgramCounter) Assoc() *AssocScores {
+	return c.assoc
+}
+
 // ARF returns ARF helper record
 func (c *NgramCounter) ARF() *WordARF {
 	return c.arf
@@ -83,6 +125,16 @@ func (c *NgramCounter) ColumnNgram(colIdx int, wd *WordDict) string {
 	return strings.Join(tmp, " ")
 }
 
+// PositionValue returns a value of column colIdx at n-gram
+// position pos (starting from 0). For positions not filled-in,
+// an empty string is returned.
+func (c *NgramCounter) PositionValue(pos, colIdx int, wd *WordDict) string {
+	if pos >= len(c.tokens) {
+		return ""
+	}
+	return wd.Get(c.tokens[pos].Columns[colIdx])
+}
+
 // columnNgramNumeric produces an n-gram out of values in column
 // colIdx using values' numeric representation encoded as string.
 func (c *NgramCounter) columnNgramNumeric(colIdx int) string {
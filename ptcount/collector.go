@@ -0,0 +1,320 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount/modders"
+
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// NgramCollector creates n-grams out of a sequence
+// of tokens and counts them. N-grams are created only
+// within a "sentence" (see ResetSentence).
+type NgramCollector struct {
+	ngramConf     *cnf.NgramConf
+	columnModders []*modders.StringTransformerChain
+	wordDict      *WordDict
+	counts        *NgramMap
+	currSentence  [][]int
+	keyCols       []int
+	tokenFilter   *TokenFilter
+
+	// recordPositions enables recording of token positions
+	// of counted n-grams (see CalcSinglePassARF)
+	recordPositions bool
+
+	// currDoc is an index of the current document
+	// (see StartDocument)
+	currDoc int
+
+	// docCounts contains per-document counts of n-grams
+	// (see RecordDocCounts)
+	docCounts []map[NgramKey]int
+
+	// itemCounts contains n-gram counts of the current item
+	// (see RecordItemCounts)
+	itemCounts map[NgramKey]int
+
+	// currTokenIdx is an index of the last added token
+	currTokenIdx int
+
+	// currStops marks stopword positions of the current sentence
+	currStops []bool
+}
+
+// SetTokenFilter sets a filter of tokens taking part
+// in counted n-grams (see TokenFilter)
+func (nc *NgramCollector) SetTokenFilter(filter *TokenFilter) {
+	nc.tokenFilter = filter
+}
+
+// RecordPositions makes the collector record token positions
+// of counted n-grams so ARF can be calculated without processing
+// the vertical again (see CalcSinglePassARF). The collector (including
+// its forks) must not be used concurrently and token indices must grow.
+func (nc *NgramCollector) RecordPositions() {
+	nc.recordPositions = true
+}
+
+// StartDocument starts a new document for counting of document
+// frequencies (see cnf.NgramConf.DocFreqStructure). The collector
+// (including its forks) must not be used concurrently.
+func (nc *NgramCollector) StartDocument() {
+	nc.currDoc++
+	if nc.docCounts != nil {
+		nc.docCounts = append(nc.docCounts, make(map[NgramKey]int))
+	}
+}
+
+// RecordDocCounts makes the collector keep n-gram counts
+// of individual documents (see StartDocument and ForEachDocCount).
+// This requires memory proportional to the sum of numbers of distinct
+// n-grams of all the documents.
+func (nc *NgramCollector) RecordDocCounts() {
+	nc.docCounts = make([]map[NgramKey]int, 0, 1000)
+}
+
+// RecordItemCounts makes the collector keep n-gram counts
+// of the current item (typically an atom) which can be obtained
+// (and reset) using TakeItemCounts. The collector (including
+// its forks) must not be used concurrently.
+func (nc *NgramCollector) RecordItemCounts() {
+	nc.itemCounts = make(map[NgramKey]int)
+}
+
+// TakeItemCounts returns n-gram counts of the current item
+// and starts a new one
+func (nc *NgramCollector) TakeItemCounts() map[NgramKey]int {
+	ans := nc.itemCounts
+	nc.itemCounts = make(map[NgramKey]int)
+	return ans
+}
+
+// NumDocuments returns number of started documents
+func (nc *NgramCollector) NumDocuments() int {
+	return nc.currDoc
+}
+
+// ForEachDocCount calls fn for each n-gram of each recorded
+// document. Documents are numbered from 1.
+func (nc *NgramCollector) ForEachDocCount(fn func(doc int, key NgramKey, count int) error) error {
+	for i, counts := range nc.docCounts {
+		for key, count := range counts {
+			if err := fn(i+1, key, count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// trackOccurrence updates per-occurrence data (positions,
+// document frequency) of an already counted n-gram
+func (nc *NgramCollector) trackOccurrence(key NgramKey) {
+	if len(nc.docCounts) > 0 {
+		nc.docCounts[len(nc.docCounts)-1][key]++
+	}
+	if nc.itemCounts != nil {
+		nc.itemCounts[key]++
+	}
+	if !nc.recordPositions && !nc.ngramConf.HasDocFreq() {
+		return
+	}
+	ngram, _ := nc.counts.Get(key)
+	if nc.recordPositions {
+		ngram.addPosition(nc.currTokenIdx)
+	}
+	if nc.ngramConf.HasDocFreq() {
+		ngram.addDoc(nc.currDoc)
+	}
+}
+
+// hasStopword tests whether any of the positions of the current
+// sentence contains a stopword
+func (nc *NgramCollector) hasStopword(positions []int) bool {
+	for _, p := range positions {
+		if nc.currStops[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// AddToken adds a token to the current sentence and counts
+// an n-gram ending with the token (if the sentence is long enough).
+func (nc *NgramCollector) AddToken(tk *vertigo.Token) {
+	attributes, isStop, excluded := encodeToken(
+		tk, nc.ngramConf, nc.columnModders, nc.wordDict, nc.tokenFilter)
+	if excluded {
+		return
+	}
+	nc.currTokenIdx = tk.Idx
+	if nc.ngramConf.CharNgrams != nil {
+		if !isStop {
+			nc.countCharNgrams(attributes)
+		}
+		return
+	}
+
+	nc.currSentence = append(nc.currSentence, attributes)
+	nc.currStops = append(nc.currStops, isStop)
+	if nc.ngramConf.MaxSkip > 0 {
+		nc.countSkipGrams()
+
+	} else if len(nc.currSentence) >= nc.ngramConf.NgramSize {
+		startPos := len(nc.currSentence) - nc.ngramConf.NgramSize
+		for i := startPos; i < len(nc.currSentence); i++ {
+			if nc.currStops[i] {
+				return
+			}
+		}
+		key := sentenceNgramKey(nc.currSentence, startPos, nc.ngramConf.NgramSize, nc.keyCols)
+		if !nc.counts.Inc(key, 1) {
+			ngram := NewNgramCounter(nc.ngramConf.NgramSize)
+			for i := startPos; i < len(nc.currSentence); i++ {
+				ngram.AddToken(nc.currSentence[i])
+			}
+			nc.counts.Add(key, ngram)
+		}
+		nc.trackOccurrence(key)
+	}
+}
+
+// countCharNgrams counts character n-grams of the value of the (only)
+// counted column wrapped in boundary markers. Each character n-gram
+// is encoded as a unigram using the word dictionary.
+func (nc *NgramCollector) countCharNgrams(attributes []int) {
+	col := nc.ngramConf.VertColumns[0].Idx
+	marker := nc.ngramConf.CharNgrams.GetBoundaryMarker()
+	chars := []rune(marker + nc.wordDict.Get(attributes[col]) + marker)
+	size := nc.ngramConf.CharNgrams.Size
+	for i := 0; i+size <= len(chars); i++ {
+		position := make([]int, len(attributes))
+		position[col] = nc.wordDict.Add(string(chars[i : i+size]))
+		key := sentenceNgramKey([][]int{position}, 0, 1, nc.keyCols)
+		if !nc.counts.Inc(key, 1) {
+			ngram := NewNgramCounter(1)
+			ngram.AddToken(position)
+			nc.counts.Add(key, ngram)
+		}
+		nc.trackOccurrence(key)
+	}
+}
+
+// countSkipGrams counts all the n-grams ending with the last token
+// of the current sentence with up to MaxSkip tokens skipped
+func (nc *NgramCollector) countSkipGrams() {
+	size := nc.ngramConf.NgramSize
+	last := len(nc.currSentence) - 1
+	if last+1 < size {
+		return
+	}
+	first := last - size + 1 - nc.ngramConf.MaxSkip
+	if first < 0 {
+		first = 0
+	}
+	positions := make([]int, size)
+	positions[size-1] = last
+	nc.combinePositions(positions, 0, first, last)
+}
+
+// combinePositions fills positions (except for the last one) with
+// all the increasing sequences of sentence positions from the interval
+// [from, to) and counts the resulting n-grams
+func (nc *NgramCollector) combinePositions(positions []int, idx, from, to int) {
+	if idx == len(positions)-1 {
+		nc.countPositions(positions)
+		return
+	}
+	remaining := len(positions) - 1 - idx
+	for p := from; p <= to-remaining; p++ {
+		positions[idx] = p
+		nc.combinePositions(positions, idx+1, p+1, to)
+	}
+}
+
+// countPositions counts an n-gram composed of tokens
+// at the provided positions of the current sentence
+func (nc *NgramCollector) countPositions(positions []int) {
+	if nc.hasStopword(positions) {
+		return
+	}
+	key := positionsNgramKey(nc.currSentence, positions, nc.keyCols)
+	if !nc.counts.Inc(key, 1) {
+		ngram := NewNgramCounter(len(positions))
+		for _, p := range positions {
+			ngram.AddToken(nc.currSentence[p])
+		}
+		nc.counts.Add(key, ngram)
+	}
+	nc.trackOccurrence(key)
+}
+
+// ResetSentence starts a new sentence so no n-gram
+// will contain both previous and following tokens.
+func (nc *NgramCollector) ResetSentence() {
+	nc.currSentence = nc.currSentence[:0]
+	nc.currStops = nc.currStops[:0]
+}
+
+// Counts returns all the counted n-grams
+func (nc *NgramCollector) Counts() *NgramMap {
+	return nc.counts
+}
+
+// WordDict returns a dictionary used to encode
+// n-gram values
+func (nc *NgramCollector) WordDict() *WordDict {
+	return nc.wordDict
+}
+
+// Fork creates a new collector sharing the word dictionary
+// and n-gram counts with the original one. Only the current
+// sentence is independent so multiple goroutines can count
+// n-grams concurrently, each using its own fork.
+func (nc *NgramCollector) Fork() *NgramCollector {
+	return &NgramCollector{
+		ngramConf:     nc.ngramConf,
+		columnModders: nc.columnModders,
+		wordDict:      nc.wordDict,
+		counts:        nc.counts,
+		currSentence:  make([][]int, 0, 20),
+		keyCols:       nc.keyCols,
+		tokenFilter:   nc.tokenFilter,
+
+		recordPositions: nc.recordPositions,
+		currDoc:         nc.currDoc,
+	}
+}
+
+// NewNgramCollector creates a new collector with its own word dictionary.
+// The configured n-grams must fit into NgramKey (see CheckNgramKeySize).
+func NewNgramCollector(
+	ngramConf *cnf.NgramConf,
+	columnModders []*modders.StringTransformerChain,
+) *NgramCollector {
+	return &NgramCollector{
+		ngramConf:     ngramConf,
+		columnModders: columnModders,
+		wordDict:      NewWordDict(),
+		counts:        NewNgramMap(ngramConf.NumShards),
+		currSentence:  make([][]int, 0, 20),
+		keyCols:       keyColumns(ngramConf),
+	}
+}
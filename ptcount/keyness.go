@@ -0,0 +1,61 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"math"
+)
+
+// Keyness contains keyness scores of an item comparing its frequency
+// in a focus corpus with a frequency in a reference corpus
+type Keyness struct {
+
+	// LogLikelihood is a log-likelihood (G2) score. Positive values
+	// mean the item is relatively more frequent in the focus corpus,
+	// negative values mean it is more frequent in the reference corpus.
+	LogLikelihood float64
+
+	// OddsRatio is an odds ratio of the item occurring in the focus
+	// corpus. To handle zero frequencies, 0.5 is added to all
+	// the values of the contingency table.
+	OddsRatio float64
+}
+
+// CalcKeyness calculates keyness of an item with frequency focusFreq
+// in a corpus of focusSize tokens and frequency refFreq in a reference
+// corpus of refSize tokens
+func CalcKeyness(focusFreq, refFreq, focusSize, refSize int) Keyness {
+	a, b := float64(focusFreq), float64(refFreq)
+	c, d := float64(focusSize), float64(refSize)
+	e1 := c * (a + b) / (c + d)
+	e2 := d * (a + b) / (c + d)
+	var ll float64
+	if a > 0 {
+		ll += a * math.Log(a/e1)
+	}
+	if b > 0 {
+		ll += b * math.Log(b/e2)
+	}
+	ll *= 2
+	if a/c < b/d {
+		ll = -ll
+	}
+	return Keyness{
+		LogLikelihood: ll,
+		OddsRatio:     ((a + 0.5) / (c - a + 0.5)) / ((b + 0.5) / (d - b + 0.5)),
+	}
+}
@@ -17,21 +17,166 @@
 package modders
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
-	TransformerToLower       = "toLower"
-	TransformerIdentity      = "identity"
-	TransformerFirstChar     = "firstChar"
-	TransformerPosPenn       = "penn"
-	TransformerPosCSCNC2020  = "cs_cnc2020"
-	TransformerPosCSCNC2000  = "cs_cnc2000"
-	TransformerPosCNC2000Spk = "cs_cnc2000_spk"
+	TransformerToLower          = "toLower"
+	TransformerRemoveDiacritics = "removeDiacritics"
+	TransformerIdentity         = "identity"
+	TransformerFirstChar        = "firstChar"
+	TransformerPosPenn          = "penn"
+	TransformerPosCSCNC2020     = "cs_cnc2020"
+	TransformerPosCSCNC2000     = "cs_cnc2000"
+	TransformerPosCNC2000Spk    = "cs_cnc2000_spk"
+	TransformerTrim             = "trim"
+	TransformerUDUPOS           = "upos"
+	TransformerNFC              = "nfc"
+	TransformerNFD              = "nfd"
+	TransformerNFKC             = "nfkc"
+	TransformerNFKD             = "nfkd"
+	TransformerUDNormalizeFeats = "normalizeFeats"
+
+	// Parametrized transformers take arguments following their name
+	// (e.g. firstN:3). Colons within the arguments must be escaped (\:).
+
+	TransformerRegexp      = "regexp"
+	TransformerFirstN      = "firstN"
+	TransformerPad         = "pad"
+	TransformerReplace     = "replace"
+	TransformerUDFeat      = "feat"
+	TransformerLookup      = "lookup"
+	TransformerLua         = "lua"
+	TransformerSlice       = "slice"
+	TransformerStripPrefix = "stripPrefix"
+	TransformerStripSuffix = "stripSuffix"
 )
 
+// paramTransformer describes a transformer taking
+// a fixed number of arguments
+type paramTransformer struct {
+	numArgs int
+	create  func(args []string) (StringTransformer, error)
+}
+
+var paramTransformers = map[string]paramTransformer{
+	// regexp:<pattern>:<replacement>
+	TransformerRegexp: {
+		numArgs: 2,
+		create: func(args []string) (StringTransformer, error) {
+			rr, err := NewRegexpReplace(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			return rr, nil
+		},
+	},
+	// firstN:<num. of characters>
+	TransformerFirstN: {
+		numArgs: 1,
+		create: func(args []string) (StringTransformer, error) {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid number of characters %s", args[0])
+			}
+			return FirstN{n: n}, nil
+		},
+	},
+	// slice:<from>:<to> (an empty value of "to" means "until the end")
+	TransformerSlice: {
+		numArgs: 2,
+		create: func(args []string) (StringTransformer, error) {
+			from, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid slice start %s", args[0])
+			}
+			ans := Slice{from: from, toEnd: args[1] == ""}
+			if !ans.toEnd {
+				ans.to, err = strconv.Atoi(args[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid slice end %s", args[1])
+				}
+			}
+			return ans, nil
+		},
+	},
+	// pad:<length>:<padding character>
+	TransformerPad: {
+		numArgs: 2,
+		create: func(args []string) (StringTransformer, error) {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid length %s", args[0])
+			}
+			if len([]rune(args[1])) != 1 {
+				return nil, fmt.Errorf("padding must be a single character, got '%s'", args[1])
+			}
+			return Pad{length: n, padding: args[1]}, nil
+		},
+	},
+	// feat:<feature name>
+	TransformerUDFeat: {
+		numArgs: 1,
+		create: func(args []string) (StringTransformer, error) {
+			if args[0] == "" {
+				return nil, fmt.Errorf("feature name must not be empty")
+			}
+			return UDFeat{name: args[0]}, nil
+		},
+	},
+	// lookup:<path to a TSV file>:<fallback (keep, empty, error)>
+	TransformerLookup: {
+		numArgs: 2,
+		create: func(args []string) (StringTransformer, error) {
+			lt, err := NewLookupTable(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			return lt, nil
+		},
+	},
+	// lua:<path to a script>
+	TransformerLua: {
+		numArgs: 1,
+		create: func(args []string) (StringTransformer, error) {
+			ls, err := NewLuaScriptFromFile(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return ls, nil
+		},
+	},
+	// stripPrefix:<prefix>
+	TransformerStripPrefix: {
+		numArgs: 1,
+		create: func(args []string) (StringTransformer, error) {
+			return StripPrefix{prefix: args[0]}, nil
+		},
+	},
+	// stripSuffix:<suffix>
+	TransformerStripSuffix: {
+		numArgs: 1,
+		create: func(args []string) (StringTransformer, error) {
+			return StripSuffix{suffix: args[0]}, nil
+		},
+	},
+	// replace:<old>:<new>
+	TransformerReplace: {
+		numArgs: 2,
+		create: func(args []string) (StringTransformer, error) {
+			if args[0] == "" {
+				return nil, fmt.Errorf("replaced string must not be empty")
+			}
+			return Replace{old: args[0], new: args[1]}, nil
+		},
+	},
+}
+
 // StringTransformer represents a type which is able
 // to modify a string (e.g. to take a substring)
 type StringTransformer interface {
@@ -42,16 +187,93 @@ type StringTransformerChain struct {
 	fn []StringTransformer
 }
 
+// splitSpecif splits a chain specification by colons.
+// Escaped colons (\:) are kept as part of the items.
+func splitSpecif(specif string) []string {
+	ans := make([]string, 0, 3)
+	var curr strings.Builder
+	for i := 0; i < len(specif); i++ {
+		if specif[i] == '\\' && i+1 < len(specif) && specif[i+1] == ':' {
+			curr.WriteByte(':')
+			i++
+
+		} else if specif[i] == ':' {
+			ans = append(ans, curr.String())
+			curr.Reset()
+
+		} else {
+			curr.WriteByte(specif[i])
+		}
+	}
+	return append(ans, curr.String())
+}
+
+// ParseStringTransformerChain creates a chain of transformers
+// specified by colon-separated names (and arguments in case of
+// parametrized transformers). Unknown functions and invalid
+// arguments are reported as errors.
+func ParseStringTransformerChain(specif string) (*StringTransformerChain, error) {
+	values := splitSpecif(specif)
+	mod := make([]StringTransformer, 0, len(values))
+	for i := 0; i < len(values); i++ {
+		var tr StringTransformer
+		pt, ok := paramTransformers[values[i]]
+		if !ok {
+			pt, ok = registeredTransformer(values[i])
+		}
+		if ok {
+			if i+pt.numArgs >= len(values) {
+				return nil, fmt.Errorf("%s modder requires %d argument(s)", values[i], pt.numArgs)
+			}
+			var err error
+			tr, err = pt.create(values[i+1 : i+1+pt.numArgs])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s modder: %w", values[i], err)
+			}
+			i += pt.numArgs
+
+		} else {
+			tr = builtinTransformer(values[i])
+			if tr == nil {
+				return nil, fmt.Errorf("unknown modder function %s", values[i])
+			}
+		}
+		mod = append(mod, tr)
+	}
+	return &StringTransformerChain{mod}, nil
+}
+
+// NewStringTransformerChain is a lenient variant of ParseStringTransformerChain.
+// In case of an invalid specification, a warning is logged and an identity
+// chain is returned.
 func NewStringTransformerChain(specif string) *StringTransformerChain {
-	values := strings.Split(specif, ":")
-	if len(values) > 0 {
-		mod := make([]StringTransformer, 0, len(values))
-		for _, v := range values {
-			mod = append(mod, StringTransformerFactory(v))
+	ans, err := ParseStringTransformerChain(specif)
+	if err != nil {
+		log.Warn().Err(err).Str("function", specif).Msg("invalid modder specification, using identity")
+		return &StringTransformerChain{fn: []StringTransformer{}}
+	}
+	return ans
+}
+
+// Append adds a transformer to the end of the chain
+func (m *StringTransformerChain) Append(tr StringTransformer) {
+	m.fn = append(m.fn, tr)
+}
+
+// Err returns the first error recorded by any of the chained
+// transformers while transforming values (see LookupTable).
+func (m *StringTransformerChain) Err() error {
+	if m == nil {
+		return nil
+	}
+	for _, mod := range m.fn {
+		if fm, ok := mod.(interface{ Err() error }); ok {
+			if err := fm.Err(); err != nil {
+				return err
+			}
 		}
-		return &StringTransformerChain{mod}
 	}
-	return &StringTransformerChain{fn: []StringTransformer{}}
+	return nil
 }
 
 func (m *StringTransformerChain) Transform(s string) string {
@@ -65,10 +287,40 @@ func (m *StringTransformerChain) Transform(s string) string {
 	return ans
 }
 
+// StringTransformerFactory creates a transformer without arguments
+// (either built-in or registered via Register)
 func StringTransformerFactory(name string) StringTransformer {
+	if tr := builtinTransformer(name); tr != nil {
+		return tr
+	}
+	if rt, ok := registeredTransformer(name); ok && rt.numArgs == 0 {
+		tr, err := rt.create([]string{})
+		if err != nil {
+			log.Warn().Err(err).Str("function", name).Msg("failed to create modder")
+			return nil
+		}
+		return tr
+	}
+	log.Warn().Str("function", name).Msg("unknown modder function")
+	return nil
+}
+
+func builtinTransformer(name string) StringTransformer {
 	switch name {
 	case TransformerToLower:
 		return ToLower{}
+	case TransformerRemoveDiacritics:
+		return RemoveDiacritics{}
+	case TransformerTrim:
+		return Trim{}
+	case TransformerNFC:
+		return UnicodeNormalize{form: norm.NFC}
+	case TransformerNFD:
+		return UnicodeNormalize{form: norm.NFD}
+	case TransformerNFKC:
+		return UnicodeNormalize{form: norm.NFKC}
+	case TransformerNFKD:
+		return UnicodeNormalize{form: norm.NFKD}
 	case TransformerFirstChar,
 		TransformerPosCSCNC2020,
 		TransformerPosCSCNC2000,
@@ -76,9 +328,15 @@ func StringTransformerFactory(name string) StringTransformer {
 		return FirstChar{}
 	case TransformerPosPenn:
 		return Penn2Pos{}
+	case TransformerUDUPOS:
+		return UDUPOS{}
+	case TransformerUDNormalizeFeats:
+		return UDNormalizeFeats{}
 	case "", TransformerIdentity:
 		return Identity{}
 	}
-	log.Warn().Str("function", name).Msg("unknown modder function")
+	if pos, ok := csTagPositions[name]; ok {
+		return CzechTagPosition{pos: pos}
+	}
 	return nil
 }
@@ -0,0 +1,82 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+const (
+	// csTagInvalidValue is returned for values which are
+	// not valid Czech positional tags ("X" means "unknown"
+	// in the tagset)
+	csTagInvalidValue = "X"
+
+	// csTagNotApplicable is returned for positions missing
+	// in the tag (e.g. aspect in CNC 2000 tags)
+	csTagNotApplicable = "-"
+
+	csTagMinLength = 15
+	csTagMaxLength = 16
+)
+
+// csTagPositions maps modder names to (zero-based) positions
+// within Czech positional tags (both the 15-position
+// Prague/CNC 2000 tags and the 16-position CNC 2020 tags)
+var csTagPositions = map[string]int{
+	"csTagPOS":        0,
+	"csTagSubPOS":     1,
+	"csTagGender":     2,
+	"csTagNumber":     3,
+	"csTagCase":       4,
+	"csTagPossGender": 5,
+	"csTagPossNumber": 6,
+	"csTagPerson":     7,
+	"csTagTense":      8,
+	"csTagGrade":      9,
+	"csTagNegation":   10,
+	"csTagVoice":      11,
+	"csTagVariant":    14,
+	"csTagAspect":     15,
+}
+
+// isCzechPositionalTag tests whether s has a proper length and
+// contains only printable ASCII characters
+func isCzechPositionalTag(s string) bool {
+	if len(s) < csTagMinLength || len(s) > csTagMaxLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// CzechTagPosition extracts a single position (e.g. case)
+// from a Czech positional tag. For invalid tags, "X"
+// is returned.
+type CzechTagPosition struct {
+	pos int
+}
+
+func (m CzechTagPosition) Transform(s string) string {
+	if !isCzechPositionalTag(s) {
+		return csTagInvalidValue
+	}
+	if m.pos >= len(s) {
+		return csTagNotApplicable
+	}
+	return s[m.pos : m.pos+1]
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// LookupFallbackKeep keeps values missing in a lookup table
+	LookupFallbackKeep = "keep"
+
+	// LookupFallbackEmpty replaces values missing in a lookup
+	// table with an empty string
+	LookupFallbackEmpty = "empty"
+
+	// LookupFallbackError makes the processing fail once a value
+	// missing in a lookup table is encountered (see LookupTable.Err)
+	LookupFallbackError = "error"
+)
+
+// LookupTable maps values using a mapping loaded from a TSV file
+// with two columns (input value, output value).
+type LookupTable struct {
+	mapping  map[string]string
+	fallback string
+
+	// errValue is the first value missing in the mapping
+	// (used with LookupFallbackError)
+	errValue *string
+	errLock  sync.Mutex
+}
+
+func (m *LookupTable) Transform(s string) string {
+	v, ok := m.mapping[s]
+	if ok {
+		return v
+	}
+	switch m.fallback {
+	case LookupFallbackEmpty:
+		return ""
+	case LookupFallbackError:
+		m.errLock.Lock()
+		if m.errValue == nil {
+			m.errValue = &s
+		}
+		m.errLock.Unlock()
+	}
+	return s
+}
+
+// Err returns an error in case the fallback policy is LookupFallbackError
+// and a value missing in the mapping has been encountered.
+func (m *LookupTable) Err() error {
+	m.errLock.Lock()
+	defer m.errLock.Unlock()
+	if m.errValue != nil {
+		return fmt.Errorf("value '%s' not found in the lookup table", *m.errValue)
+	}
+	return nil
+}
+
+// NewLookupTable loads a mapping from a TSV file. Empty lines and
+// lines starting with '#' are ignored.
+func NewLookupTable(path, fallback string) (*LookupTable, error) {
+	switch fallback {
+	case LookupFallbackKeep, LookupFallbackEmpty, LookupFallbackError:
+	default:
+		return nil, fmt.Errorf(
+			"invalid fallback policy '%s' (expected one of: %s, %s, %s)",
+			fallback, LookupFallbackKeep, LookupFallbackEmpty, LookupFallbackError)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lookup table: %w", err)
+	}
+	defer f.Close()
+	mapping := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.Split(line, "\t")
+		if len(items) != 2 {
+			return nil, fmt.Errorf("invalid lookup table line %d in %s: expected 2 columns", lineNum, path)
+		}
+		mapping[items[0]] = items[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lookup table: %w", err)
+	}
+	return &LookupTable{mapping: mapping, fallback: fallback}, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupTable(t *testing.T) {
+	mapPath := filepath.Join(t.TempDir(), "lemmas.tsv")
+	assert.NoError(t, os.WriteFile(mapPath, []byte("# lemma normalization\nbejt\tbýt\njsem\tbýt\n\nbýt\tbýt\n"), 0644))
+	for _, tc := range []struct {
+		fallback string
+		input    string
+		expected string
+	}{
+		{LookupFallbackKeep, "bejt", "být"},
+		{LookupFallbackKeep, "jsem", "být"},
+		{LookupFallbackKeep, "auto", "auto"},
+		{LookupFallbackEmpty, "být", "být"},
+		{LookupFallbackEmpty, "auto", ""},
+		{LookupFallbackError, "jsem", "být"},
+	} {
+		chain, err := ParseStringTransformerChain("lookup:" + mapPath + ":" + tc.fallback)
+		if assert.NoError(t, err, tc.fallback) {
+			assert.Equal(t, tc.expected, chain.Transform(tc.input), tc.fallback)
+			assert.NoError(t, chain.Err(), tc.fallback)
+		}
+	}
+}
+
+func TestLookupTableErrorFallback(t *testing.T) {
+	mapPath := filepath.Join(t.TempDir(), "lemmas.tsv")
+	assert.NoError(t, os.WriteFile(mapPath, []byte("bejt\tbýt\n"), 0644))
+	lt, err := NewLookupTable(mapPath, LookupFallbackError)
+	assert.NoError(t, err)
+	assert.Equal(t, "auto", lt.Transform("auto"))
+	assert.Equal(t, "kolo", lt.Transform("kolo"))
+	assert.EqualError(t, lt.Err(), "value 'auto' not found in the lookup table")
+}
+
+func TestLookupTableInvalidFile(t *testing.T) {
+	mapPath := filepath.Join(t.TempDir(), "lemmas.tsv")
+	assert.NoError(t, os.WriteFile(mapPath, []byte("bejt\tbýt\njsem\n"), 0644))
+	_, err := NewLookupTable(mapPath, LookupFallbackKeep)
+	assert.ErrorContains(t, err, "invalid lookup table line 2")
+	_, err = NewLookupTable(filepath.Join(t.TempDir(), "missing.tsv"), LookupFallbackKeep)
+	assert.ErrorContains(t, err, "failed to open lookup table")
+}
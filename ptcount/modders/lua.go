@@ -0,0 +1,142 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	// luaTransformFn is a name of a function each script
+	// must define
+	luaTransformFn = "transform"
+)
+
+// luaState is a Lua interpreter with a loaded script.
+// Interpreters cannot be shared among goroutines so
+// LuaScript keeps a pool of them.
+type luaState struct {
+	L  *lua.LState
+	fn lua.LValue
+}
+
+// LuaScript transforms values using a user-defined Lua function
+// transform(value) which must return a string. Script errors
+// are recorded (see Err) and the original value is kept.
+type LuaScript struct {
+	name   string
+	proto  *lua.FunctionProto
+	states sync.Pool
+
+	err     error
+	errLock sync.Mutex
+}
+
+func (m *LuaScript) newState() (*luaState, error) {
+	L := lua.NewState()
+	L.Push(L.NewFunctionFromProto(m.proto))
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("failed to run Lua script %s: %w", m.name, err)
+	}
+	fn := L.GetGlobal(luaTransformFn)
+	if fn.Type() != lua.LTFunction {
+		L.Close()
+		return nil, fmt.Errorf("Lua script %s does not define function %s(value)", m.name, luaTransformFn)
+	}
+	return &luaState{L: L, fn: fn}, nil
+}
+
+func (m *LuaScript) setErr(err error) {
+	m.errLock.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.errLock.Unlock()
+}
+
+func (m *LuaScript) Transform(s string) string {
+	st, ok := m.states.Get().(*luaState)
+	if !ok {
+		var err error
+		st, err = m.newState()
+		if err != nil {
+			m.setErr(err)
+			return s
+		}
+	}
+	defer m.states.Put(st)
+	st.L.Push(st.fn)
+	st.L.Push(lua.LString(s))
+	if err := st.L.PCall(1, 1, nil); err != nil {
+		m.setErr(fmt.Errorf("failed to transform value '%s' using Lua script %s: %w", s, m.name, err))
+		return s
+	}
+	ans := st.L.Get(-1)
+	st.L.Pop(1)
+	switch ans.Type() {
+	case lua.LTString, lua.LTNumber:
+		return ans.String()
+	case lua.LTNil:
+		return ""
+	}
+	m.setErr(fmt.Errorf(
+		"Lua script %s returned %s instead of a string for value '%s'", m.name, ans.Type(), s))
+	return s
+}
+
+// Err returns the first error occurred while transforming values
+func (m *LuaScript) Err() error {
+	m.errLock.Lock()
+	defer m.errLock.Unlock()
+	return m.err
+}
+
+// NewLuaScript compiles a Lua script. The name is used
+// in error messages only.
+func NewLuaScript(name, src string) (*LuaScript, error) {
+	chunk, err := parse.Parse(strings.NewReader(src), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Lua script %s: %w", name, err)
+	}
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile Lua script %s: %w", name, err)
+	}
+	ans := &LuaScript{name: name, proto: proto}
+	st, err := ans.newState()
+	if err != nil {
+		return nil, err
+	}
+	ans.states.Put(st)
+	return ans, nil
+}
+
+// NewLuaScriptFromFile loads and compiles a Lua script file
+func NewLuaScriptFromFile(path string) (*LuaScript, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Lua script: %w", err)
+	}
+	return NewLuaScript(path, string(src))
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuaScript(t *testing.T) {
+	for _, tc := range []struct {
+		script   string
+		input    string
+		expected string
+	}{
+		{"function transform(v)\n  return (string.gsub(v, \"%d+$\", \"\"))\nend", "abc22", "abc"},
+		{"function transform(v)\n  return v .. \"!\"\nend", "NN", "NN!"},
+		{"function transform(v)\n  return string.len(v)\nend", "abcd", "4"},
+		{"function transform(v)\n  return nil\nend", "abcd", ""},
+	} {
+		ls, err := NewLuaScript("test", tc.script)
+		if assert.NoError(t, err, tc.script) {
+			assert.Equal(t, tc.expected, ls.Transform(tc.input), tc.script)
+			assert.NoError(t, ls.Err(), tc.script)
+		}
+	}
+}
+
+func TestLuaScriptFromFile(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "tag.lua")
+	assert.NoError(t, os.WriteFile(scriptPath, []byte("function transform(v)\n  return v .. \"!\"\nend\n"), 0644))
+	chain, err := ParseStringTransformerChain("toLower:lua:" + scriptPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "vb!", chain.Transform("VB"))
+}
+
+func TestLuaScriptErrors(t *testing.T) {
+	ls, err := NewLuaScript("test", "function transform(v)\n  return {}\nend")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", ls.Transform("abc"))
+	assert.ErrorContains(t, ls.Err(), "returned table instead of a string")
+
+	_, err = NewLuaScript("test", "x = 1")
+	assert.ErrorContains(t, err, "does not define function transform(value)")
+	_, err = NewLuaScript("test", "function transform(v")
+	assert.ErrorContains(t, err, "failed to parse Lua script test")
+}
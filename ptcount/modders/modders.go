@@ -16,9 +16,25 @@
 
 package modders
 
-import "strings"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
 
 var (
+	// undecomposableLetters maps letters with diacritics which
+	// have no Unicode decomposition to their base letters
+	undecomposableLetters = map[rune]rune{
+		'ł': 'l', 'Ł': 'L', 'ø': 'o', 'Ø': 'O', 'đ': 'd', 'Đ': 'D',
+		'ħ': 'h', 'Ħ': 'H', 'ŧ': 't', 'Ŧ': 'T', 'ı': 'i', 'ƀ': 'b',
+		'ƶ': 'z', 'Ƶ': 'Z', 'ɨ': 'i', 'ʉ': 'u',
+	}
+
 	pennTags = map[string]string{
 		"CC":   "J", //  Coordinating conjunction
 		"CD":   "C", //  Cardinal number
@@ -65,10 +81,45 @@ func (m ToLower) Transform(s string) string {
 	return strings.ToLower(s)
 }
 
+// RemoveDiacritics removes combining marks from
+// (decomposed) characters, e.g. "čárka" => "carka".
+// Letters with diacritics which cannot be decomposed
+// (e.g. "ł", "ø") are replaced by their base letters.
+type RemoveDiacritics struct{}
+
+func (m RemoveDiacritics) Transform(s string) string {
+	var ans strings.Builder
+	ans.Grow(len(s))
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if base, ok := undecomposableLetters[r]; ok {
+			ans.WriteRune(base)
+
+		} else {
+			ans.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(ans.String())
+}
+
+// UnicodeNormalize converts a string into
+// a Unicode normalization form (NFC, NFKC,...)
+type UnicodeNormalize struct {
+	form norm.Form
+}
+
+func (m UnicodeNormalize) Transform(s string) string {
+	return m.form.String(s)
+}
+
+// FirstChar takes the first character (not byte) of a string.
+// Empty strings are kept.
 type FirstChar struct{}
 
 func (m FirstChar) Transform(s string) string {
-	return s[:1]
+	return FirstN{n: 1}.Transform(s)
 }
 
 type Identity struct{}
@@ -86,3 +137,123 @@ func (pp Penn2Pos) Transform(s string) string {
 	}
 	return v
 }
+
+// RegexpReplace replaces all the matches of a regular
+// expression with a replacement (which may refer to
+// submatches using $1, ${name} etc.)
+type RegexpReplace struct {
+	rx          *regexp.Regexp
+	replacement string
+}
+
+func (rr *RegexpReplace) Transform(s string) string {
+	return rr.rx.ReplaceAllString(s, rr.replacement)
+}
+
+func NewRegexpReplace(pattern, replacement string) (*RegexpReplace, error) {
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile modder regexp: %w", err)
+	}
+	return &RegexpReplace{rx: rx, replacement: replacement}, nil
+}
+
+// FirstN takes first n characters (not bytes) of a string.
+// Shorter strings (including empty ones) are kept.
+type FirstN struct {
+	n int
+}
+
+func (m FirstN) Transform(s string) string {
+	var i int
+	for pos := range s {
+		if i == m.n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+// Slice extracts a range of characters [from, to) of a string.
+// Negative indexes are counted from the end of the string and
+// indexes out of the string's range are clamped.
+type Slice struct {
+	from  int
+	to    int
+	toEnd bool
+}
+
+func (m Slice) Transform(s string) string {
+	chars := []rune(s)
+	normIdx := func(idx int) int {
+		if idx < 0 {
+			idx += len(chars)
+		}
+		if idx < 0 {
+			return 0
+		}
+		if idx > len(chars) {
+			return len(chars)
+		}
+		return idx
+	}
+	from := normIdx(m.from)
+	to := len(chars)
+	if !m.toEnd {
+		to = normIdx(m.to)
+	}
+	if from >= to {
+		return ""
+	}
+	return string(chars[from:to])
+}
+
+// Pad appends padding characters to strings shorter
+// than the specified length
+type Pad struct {
+	length  int
+	padding string
+}
+
+func (m Pad) Transform(s string) string {
+	if n := m.length - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(m.padding, n)
+	}
+	return s
+}
+
+// Replace replaces all the occurrences of a substring
+type Replace struct {
+	old string
+	new string
+}
+
+func (m Replace) Transform(s string) string {
+	return strings.ReplaceAll(s, m.old, m.new)
+}
+
+// StripPrefix removes a prefix (if present)
+type StripPrefix struct {
+	prefix string
+}
+
+func (m StripPrefix) Transform(s string) string {
+	return strings.TrimPrefix(s, m.prefix)
+}
+
+// StripSuffix removes a suffix (if present)
+type StripSuffix struct {
+	suffix string
+}
+
+func (m StripSuffix) Transform(s string) string {
+	return strings.TrimSuffix(s, m.suffix)
+}
+
+// Trim removes leading and trailing whitespace
+type Trim struct{}
+
+func (m Trim) Transform(s string) string {
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringTransformerChain(t *testing.T) {
+	for _, tc := range []struct {
+		specif   string
+		input    string
+		expected string
+	}{
+		{"", "Abc", "Abc"},
+		{"identity", "Abc", "Abc"},
+		{"toLower:regexp:[0-9]+$::regexp:\\::_", "ab12", "ab"},
+		{"toLower:regexp:[0-9]+$::regexp:\\::_", "AB3", "ab"},
+		{"toLower:regexp:[0-9]+$::regexp:\\::_", "c:d", "c_d"},
+		{"toLower:removeDiacritics:firstChar", "Čárka", "c"},
+		{"toLower:removeDiacritics:firstChar", "Řeka", "r"},
+		{"replace:-:+:firstN:2:pad:3:_", "abcd", "ab_"},
+		{"replace:-:+:firstN:2:pad:3:_", "x", "x__"},
+		{"replace:-:+:firstN:2:pad:3:_", "a-b", "a+_"},
+		{"csTagCase", "NNFS1-----A----", "1"},
+		{"csTagCase", "NNFP4-----A----", "4"},
+		{"csTagCase", "VB-S---3P-AA---", "-"},
+		{"csTagCase", "Z:-------------", "-"},
+		{"csTagCase", "???", "X"},
+		{"upos", "noun", "NOUN"},
+		{"upos", "foo", "X"},
+		{"feat:Case", "Case=Nom|Number=Sing", "Nom"},
+		{"feat:Case", "_", "_"},
+		{"normalizeFeats", "Number=Sing|Case=Nom", "Case=Nom|Number=Sing"},
+		{"normalizeFeats", "_", "_"},
+		{"nfkc", "\u00e9", "\u00e9"},
+		{"nfkc", "e\u0301", "\u00e9"},
+		{"nfkc", "\ufb01x", "fix"},
+		{"nfd", "\u00e9", "e\u0301"},
+		{"removeDiacritics:toLower", "Łódź", "lodz"},
+		{"removeDiacritics:toLower", "Čárka", "carka"},
+		{"removeDiacritics:toLower", "Øresund", "oresund"},
+		{"firstChar", "čas", "č"},
+		{"firstN:3", "NNIS1", "NNI"},
+		{"firstN:3", "NN", "NN"},
+		{"firstN:3", "", ""},
+		{"slice:-2:", "čáp", "áp"},
+		{"slice:2:5", "NNMS1-----A----", "MS1"},
+		{"slice:2:5", "NN", ""},
+		{"trim:stripSuffix:-1:stripPrefix:_x_", "run-1 ", "run"},
+		{"trim:stripSuffix:-1:stripPrefix:_x_", "_x_run-1", "run"},
+		{"trim:stripSuffix:-1:stripPrefix:_x_", "run", "run"},
+	} {
+		chain, err := ParseStringTransformerChain(tc.specif)
+		if assert.NoError(t, err, tc.specif) {
+			assert.Equal(t, tc.expected, chain.Transform(tc.input), tc.specif)
+			assert.NoError(t, chain.Err(), tc.specif)
+		}
+	}
+}
+
+func TestParseStringTransformerChainErrors(t *testing.T) {
+	for specif, expected := range map[string]string{
+		"toLower:foo":     "unknown modder function foo",
+		"firstN":          "firstN modder requires 1 argument(s)",
+		"regexp:a":        "regexp modder requires 2 argument(s)",
+		"firstN:x":        "invalid number of characters x",
+		"slice:a:2":       "invalid slice start a",
+		"pad:3:ab":        "padding must be a single character",
+		"replace::x":      "replaced string must not be empty",
+		"feat:":           "feature name must not be empty",
+		"regexp:[a::":     "invalid regexp modder",
+		"lookup:x.tsv:no": "invalid fallback policy",
+	} {
+		_, err := ParseStringTransformerChain(specif)
+		assert.ErrorContains(t, err, expected, specif)
+	}
+}
+
+func TestNewStringTransformerChainFallback(t *testing.T) {
+	chain := NewStringTransformerChain("toLower:foo")
+	assert.Equal(t, "Abc", chain.Transform("Abc"))
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	registry     = make(map[string]paramTransformer)
+	registryLock sync.RWMutex
+)
+
+// Register makes a custom transformer available in modFn specifications
+// (e.g. for applications embedding vert-tagextract). The numArgs specifies
+// how many colon-separated arguments following the name are passed to
+// the factory (e.g. with numArgs = 1, "myFn:3" passes "3"). Built-in
+// transformers cannot be overridden and each name can be registered
+// only once.
+func Register(
+	name string,
+	numArgs int,
+	factory func(args ...string) (StringTransformer, error),
+) error {
+	if name == "" || strings.ContainsAny(name, ":\\") {
+		return fmt.Errorf("invalid modder name '%s'", name)
+	}
+	if numArgs < 0 {
+		return fmt.Errorf("invalid number of arguments %d of modder %s", numArgs, name)
+	}
+	if _, ok := paramTransformers[name]; ok || builtinTransformer(name) != nil {
+		return fmt.Errorf("cannot register modder %s - the name is used by a built-in modder", name)
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("modder %s is already registered", name)
+	}
+	registry[name] = paramTransformer{
+		numArgs: numArgs,
+		create: func(args []string) (StringTransformer, error) {
+			tr, err := factory(args...)
+			if err != nil {
+				return nil, err
+			}
+			if tr == nil {
+				return nil, fmt.Errorf("modder factory returned nil")
+			}
+			return tr, nil
+		},
+	}
+	return nil
+}
+
+func registeredTransformer(name string) (paramTransformer, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	pt, ok := registry[name]
+	return pt, ok
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSuffixModder struct {
+	suffix string
+}
+
+func (m testSuffixModder) Transform(s string) string {
+	return s + m.suffix
+}
+
+func unregister(name string) {
+	registryLock.Lock()
+	delete(registry, name)
+	registryLock.Unlock()
+}
+
+func TestRegister(t *testing.T) {
+	factory := func(args ...string) (StringTransformer, error) {
+		return testSuffixModder{suffix: args[0]}, nil
+	}
+	assert.NoError(t, Register("testSuffix", 1, factory))
+	t.Cleanup(func() { unregister("testSuffix") })
+	assert.ErrorContains(t, Register("testSuffix", 1, factory), "already registered")
+	assert.ErrorContains(t, Register("toLower", 0, factory), "built-in")
+	assert.ErrorContains(t, Register("firstN", 1, factory), "built-in")
+	assert.ErrorContains(t, Register("a:b", 0, factory), "invalid modder name")
+	assert.ErrorContains(t, Register("testNeg", -1, factory), "invalid number of arguments")
+
+	chain, err := ParseStringTransformerChain("toLower:testSuffix:_x")
+	assert.NoError(t, err)
+	assert.Equal(t, "a_x", chain.Transform("A"))
+	_, err = ParseStringTransformerChain("testSuffix")
+	assert.ErrorContains(t, err, "testSuffix modder requires 1 argument(s)")
+}
+
+func TestRegisterNilFactoryResult(t *testing.T) {
+	assert.NoError(t, Register("testNil", 0, func(args ...string) (StringTransformer, error) {
+		return nil, nil
+	}))
+	t.Cleanup(func() { unregister("testNil") })
+	_, err := ParseStringTransformerChain("testNil")
+	assert.ErrorContains(t, err, "modder factory returned nil")
+	assert.Nil(t, StringTransformerFactory("testNil"))
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modders
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// udEmptyValue is used by Universal Dependencies
+	// for unspecified values
+	udEmptyValue = "_"
+)
+
+var (
+	udUPOSTags = map[string]bool{
+		"ADJ": true, "ADP": true, "ADV": true, "AUX": true, "CCONJ": true, "DET": true,
+		"INTJ": true, "NOUN": true, "NUM": true, "PART": true, "PRON": true, "PROPN": true,
+		"PUNCT": true, "SCONJ": true, "SYM": true, "VERB": true, "X": true,
+	}
+)
+
+// UDUPOS normalizes a Universal Dependencies UPOS value.
+// Values not being valid UPOS tags are replaced by "X".
+type UDUPOS struct{}
+
+func (m UDUPOS) Transform(s string) string {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if !udUPOSTags[v] {
+		return "X"
+	}
+	return v
+}
+
+// UDFeat extracts a value of a named feature from a FEATS
+// string (e.g. "Case=Nom|Number=Sing"). In case the feature
+// is not present, "_" is returned.
+type UDFeat struct {
+	name string
+}
+
+func (m UDFeat) Transform(s string) string {
+	for _, item := range strings.Split(s, "|") {
+		name, value, ok := strings.Cut(item, "=")
+		if ok && name == m.name {
+			return value
+		}
+	}
+	return udEmptyValue
+}
+
+// UDNormalizeFeats converts a FEATS string into the canonical
+// form - features are sorted by their names (case-insensitive),
+// values of multivalued features are sorted too and duplicate
+// features are removed.
+type UDNormalizeFeats struct{}
+
+func (m UDNormalizeFeats) Transform(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || s == udEmptyValue {
+		return udEmptyValue
+	}
+	feats := make(map[string]string)
+	for _, item := range strings.Split(s, "|") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || name == "" {
+			continue
+		}
+		values := strings.Split(value, ",")
+		sort.Strings(values)
+		feats[name] = strings.Join(values, ",")
+	}
+	if len(feats) == 0 {
+		return udEmptyValue
+	}
+	names := make([]string, 0, len(feats))
+	for name := range feats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = name + "=" + feats[name]
+	}
+	return strings.Join(items, "|")
+}
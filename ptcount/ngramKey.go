@@ -0,0 +1,128 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"fmt"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+)
+
+const (
+	// MaxNgramKeySize specifies max. number of values (= n-gram size
+	// multiplied by number of counted columns) an NgramKey can hold
+	MaxNgramKeySize = 16
+)
+
+// NgramKey is a comparable identifier of an n-gram composed of
+// WordDict IDs of the n-gram's values. Values are stored position
+// by position and within a position, column by column (in the order
+// of configured vertical columns). Compared with string identifiers,
+// no allocation is needed to create a key.
+type NgramKey [MaxNgramKeySize]int32
+
+// Less compares two keys lexicographically
+func (k NgramKey) Less(other NgramKey) bool {
+	for i := 0; i < MaxNgramKeySize; i++ {
+		if k[i] != other[i] {
+			return k[i] < other[i]
+		}
+	}
+	return false
+}
+
+// hash calculates FNV-1a hash of the key
+func (k NgramKey) hash() uint32 {
+	var h uint32 = 2166136261
+	for _, v := range k {
+		h ^= uint32(v)
+		h *= 16777619
+	}
+	return h
+}
+
+// keyColumns returns indices of vertical columns used in n-gram keys
+func keyColumns(ngramConf *cnf.NgramConf) []int {
+	ans := make([]int, len(ngramConf.VertColumns))
+	for i, vc := range ngramConf.VertColumns {
+		ans[i] = vc.Idx
+	}
+	return ans
+}
+
+// sentenceNgramKey creates a key of an n-gram of the size size starting
+// at the position start of a sentence (= a list of positions with
+// encoded column values).
+func sentenceNgramKey(sentence [][]int, start, size int, cols []int) NgramKey {
+	var ans NgramKey
+	for i := 0; i < size; i++ {
+		for j, col := range cols {
+			ans[i*len(cols)+j] = int32(sentence[start+i][col])
+		}
+	}
+	return ans
+}
+
+// positionsNgramKey creates a key of an n-gram composed of tokens
+// at the provided (not necessarily adjacent) positions of a sentence
+func positionsNgramKey(sentence [][]int, positions []int, cols []int) NgramKey {
+	var ans NgramKey
+	for i, pos := range positions {
+		for j, col := range cols {
+			ans[i*len(cols)+j] = int32(sentence[pos][col])
+		}
+	}
+	return ans
+}
+
+// Key creates a comparable identifier of the n-gram
+// out of values of the provided columns
+func (c *NgramCounter) Key(cols []int) NgramKey {
+	var ans NgramKey
+	for i, pos := range c.tokens {
+		for j, col := range cols {
+			ans[i*len(cols)+j] = int32(pos.Columns[col])
+		}
+	}
+	return ans
+}
+
+// ngramFromKey restores an n-gram from its key. The width argument
+// specifies number of columns stored per position.
+func ngramFromKey(key NgramKey, ngramSize int, cols []int, width, count int) *NgramCounter {
+	ans := NewNgramCounter(ngramSize)
+	ans.count = count
+	for i := 0; i < ngramSize; i++ {
+		pos := make([]int, width)
+		for j, col := range cols {
+			pos[col] = int(key[i*len(cols)+j])
+		}
+		ans.AddToken(pos)
+	}
+	return ans
+}
+
+// CheckNgramKeySize tests whether n-grams of the configured size
+// and number of columns fit into NgramKey
+func CheckNgramKeySize(ngramConf *cnf.NgramConf) error {
+	if size := ngramConf.NgramSize * len(ngramConf.VertColumns); size > MaxNgramKeySize {
+		return fmt.Errorf(
+			"n-gram size multiplied by number of columns (%d) exceeds the limit %d",
+			size, MaxNgramKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	DfltNumShards = 64
+)
+
+type ngramShard struct {
+	sync.Mutex
+	data map[NgramKey]*NgramCounter
+}
+
+// NgramMap is a sharded map of n-gram counters (identified
+// by NgramKey). Compared with a single map, it can
+// be updated by multiple goroutines concurrently (each shard has
+// its own lock) and its shards can be iterated in parallel.
+// Also, smaller maps are less demanding when growing.
+type NgramMap struct {
+	shards []*ngramShard
+	size   int64
+}
+
+func (m *NgramMap) shardIdx(key NgramKey) int {
+	return int(key.hash() % uint32(len(m.shards)))
+}
+
+// Inc increases count of an existing n-gram by n. In case
+// there is no such n-gram, false is returned.
+func (m *NgramMap) Inc(key NgramKey, n int) bool {
+	shard := m.shards[m.shardIdx(key)]
+	shard.Lock()
+	cnt, ok := shard.data[key]
+	if ok {
+		cnt.count += n
+	}
+	shard.Unlock()
+	return ok
+}
+
+// Add adds an n-gram to the map. In case the n-gram
+// is already present, its count is increased by the count
+// of the provided one.
+func (m *NgramMap) Add(key NgramKey, ngram *NgramCounter) {
+	shard := m.shards[m.shardIdx(key)]
+	shard.Lock()
+	cnt, ok := shard.data[key]
+	if !ok {
+		shard.data[key] = ngram
+		atomic.AddInt64(&m.size, 1)
+
+	} else {
+		cnt.count += ngram.count
+	}
+	shard.Unlock()
+}
+
+// Get returns an n-gram counter identified by key
+func (m *NgramMap) Get(key NgramKey) (*NgramCounter, bool) {
+	shard := m.shards[m.shardIdx(key)]
+	shard.Lock()
+	ans, ok := shard.data[key]
+	shard.Unlock()
+	return ans, ok
+}
+
+// Len returns total number of stored n-grams
+func (m *NgramMap) Len() int {
+	return int(atomic.LoadInt64(&m.size))
+}
+
+func (m *NgramMap) NumShards() int {
+	return len(m.shards)
+}
+
+// ForEachInShard calls fn for all the n-grams stored in the shard
+// with the index shardIdx. In case fn returns false, the iteration
+// stops. The shard must not be modified by fn.
+func (m *NgramMap) ForEachInShard(shardIdx int, fn func(key NgramKey, ngram *NgramCounter) bool) bool {
+	shard := m.shards[shardIdx]
+	shard.Lock()
+	defer shard.Unlock()
+	for k, v := range shard.data {
+		if !fn(k, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ForEach calls fn for all the stored n-grams. In case fn returns
+// false, the iteration stops. The map must not be modified by fn.
+func (m *NgramMap) ForEach(fn func(key NgramKey, ngram *NgramCounter) bool) {
+	for i := range m.shards {
+		if !m.ForEachInShard(i, fn) {
+			return
+		}
+	}
+}
+
+// DrainShard removes all the n-grams from the shard
+// with the index shardIdx and returns them.
+func (m *NgramMap) DrainShard(shardIdx int) map[NgramKey]*NgramCounter {
+	shard := m.shards[shardIdx]
+	shard.Lock()
+	ans := shard.data
+	shard.data = make(map[NgramKey]*NgramCounter)
+	atomic.AddInt64(&m.size, -int64(len(ans)))
+	shard.Unlock()
+	return ans
+}
+
+// NewNgramMap creates a new map with numShards shards. In case
+// numShards is not a positive number, DfltNumShards is used.
+func NewNgramMap(numShards int) *NgramMap {
+	if numShards <= 0 {
+		numShards = DfltNumShards
+	}
+	ans := &NgramMap{shards: make([]*ngramShard, numShards)}
+	for i := range ans.shards {
+		ans.shards[i] = &ngramShard{data: make(map[NgramKey]*NgramCounter)}
+	}
+	return ans
+}
@@ -0,0 +1,242 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+)
+
+const (
+	DfltSpillMaxNgrams = 10000000
+)
+
+// NgramSpiller implements counting of n-grams which do not fit
+// into memory. Once the number of n-grams stored in a NgramMap reaches
+// a limit, the n-grams are written to a temporary file ("run") sorted
+// by their keys and removed from the map. In the end, all the runs
+// are merged and counts of matching n-grams are summed.
+type NgramSpiller struct {
+	mu        sync.Mutex
+	dir       string
+	maxNgrams int
+	ngramSize int
+	keyCols   []int
+	width     int
+	runs      []string
+}
+
+// SpillIfNeeded writes all the n-grams from m to a new run
+// in case the size of m reached the limit.
+func (s *NgramSpiller) SpillIfNeeded(m *NgramMap) error {
+	if m.Len() < s.maxNgrams {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m.Len() < s.maxNgrams { // someone else has already spilled the data
+		return nil
+	}
+	return s.spill(m)
+}
+
+func (s *NgramSpiller) spill(m *NgramMap) error {
+	entries := make([]spillEntry, 0, m.Len())
+	for i := 0; i < m.NumShards(); i++ {
+		for k, v := range m.DrainShard(i) {
+			entries = append(entries, spillEntry{key: k, count: v.Count()})
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key.Less(entries[j].key) })
+	f, err := os.CreateTemp(s.dir, "vte-ngrams-*.run")
+	if err != nil {
+		return fmt.Errorf("failed to create n-gram spill file: %w", err)
+	}
+	s.runs = append(s.runs, f.Name())
+	wr := bufio.NewWriter(f)
+	keyLen := s.ngramSize * len(s.keyCols)
+	for _, e := range entries {
+		for i := 0; i < keyLen; i++ {
+			wr.WriteString(strconv.Itoa(int(e.key[i])))
+			wr.WriteByte(' ')
+		}
+		if _, err := fmt.Fprintf(wr, "%d\n", e.count); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write n-gram spill file: %w", err)
+		}
+	}
+	if err := wr.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write n-gram spill file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write n-gram spill file: %w", err)
+	}
+	log.Info().
+		Str("file", f.Name()).
+		Int("numNgrams", len(entries)).
+		Msg("spilled n-gram counts to disk")
+	return nil
+}
+
+// Merge writes the remaining n-grams from m to a run and then merges
+// all the runs calling fn for each unique n-gram (in the order of their
+// keys). In case fn returns false, the merging stops.
+func (s *NgramSpiller) Merge(m *NgramMap, fn func(ngram *NgramCounter) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.spill(m); err != nil {
+		return err
+	}
+	var rh runHeap
+	for _, path := range s.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open n-gram spill file: %w", err)
+		}
+		defer f.Close()
+		rd := &runReader{sc: bufio.NewScanner(f), path: path}
+		ok, err := rd.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			rh = append(rh, rd)
+		}
+	}
+	heap.Init(&rh)
+	for len(rh) > 0 {
+		key := rh[0].curr.key
+		var count int
+		for len(rh) > 0 && rh[0].curr.key == key {
+			count += rh[0].curr.count
+			ok, err := rh[0].next()
+			if err != nil {
+				return err
+			}
+			if ok {
+				heap.Fix(&rh, 0)
+
+			} else {
+				heap.Pop(&rh)
+			}
+		}
+		if !fn(ngramFromKey(key, s.ngramSize, s.keyCols, s.width, count)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Close removes all the runs
+func (s *NgramSpiller) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range s.runs {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove n-gram spill file: %w", err)
+		}
+	}
+	s.runs = s.runs[:0]
+	return nil
+}
+
+// NewNgramSpiller creates a new spiller writing runs to the directory dir
+// (empty value means the default directory for temporary files) once the
+// number of n-grams in memory reaches maxNgrams (non-positive value means
+// DfltSpillMaxNgrams).
+func NewNgramSpiller(dir string, maxNgrams int, ngramConf *cnf.NgramConf) *NgramSpiller {
+	if maxNgrams <= 0 {
+		maxNgrams = DfltSpillMaxNgrams
+	}
+	return &NgramSpiller{
+		dir:       dir,
+		maxNgrams: maxNgrams,
+		ngramSize: ngramConf.NgramSize,
+		keyCols:   keyColumns(ngramConf),
+		width:     ngramConf.MaxRequiredColumn() + 1,
+	}
+}
+
+// ------------------------------
+
+type spillEntry struct {
+	key   NgramKey
+	count int
+}
+
+type runReader struct {
+	sc   *bufio.Scanner
+	path string
+	curr *spillEntry
+}
+
+func (r *runReader) next() (bool, error) {
+	if !r.sc.Scan() {
+		if err := r.sc.Err(); err != nil {
+			return false, fmt.Errorf("failed to read n-gram spill file %s: %w", r.path, err)
+		}
+		return false, nil
+	}
+	items := strings.Fields(r.sc.Text())
+	if len(items) == 0 || len(items) > MaxNgramKeySize+1 {
+		return false, fmt.Errorf("invalid record in n-gram spill file %s", r.path)
+	}
+	var entry spillEntry
+	for i, item := range items {
+		v, err := strconv.Atoi(item)
+		if err != nil {
+			return false, fmt.Errorf("invalid record in n-gram spill file %s: %w", r.path, err)
+		}
+		if i < len(items)-1 {
+			entry.key[i] = int32(v)
+
+		} else {
+			entry.count = v
+		}
+	}
+	r.curr = &entry
+	return true, nil
+}
+
+// runHeap is a min-heap of runs ordered by their current keys
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].curr.key.Less(h[j].curr.key) }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	ans := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return ans
+}
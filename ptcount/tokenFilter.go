@@ -0,0 +1,149 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Charles University, Faculty of Arts,
+//                Institute of the Czech National Corpus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptcount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/czcorpus/vert-tagextract/v2/cnf"
+	"github.com/czcorpus/vert-tagextract/v2/db"
+	"github.com/czcorpus/vert-tagextract/v2/ptcount/modders"
+	"github.com/tomachalek/vertigo/v5"
+)
+
+// Stopwords holds values of counted columns (mapped by
+// column index) which cannot be part of counted n-grams
+type Stopwords map[int]map[string]bool
+
+// Contains tests whether a value of a column is a stopword
+func (sw Stopwords) Contains(colIdx int, value string) bool {
+	words, ok := sw[colIdx]
+	return ok && words[value]
+}
+
+// LoadStopwords loads stopword files of all the columns
+// with the file configured. The files contain one value
+// per line, empty lines are ignored.
+func LoadStopwords(cols db.VertColumns) (Stopwords, error) {
+	ans := make(Stopwords)
+	for _, col := range cols {
+		if col.Stopwords == "" {
+			continue
+		}
+		f, err := os.Open(col.Stopwords)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stopwords of column %d: %w", col.Idx, err)
+		}
+		words := make(map[string]bool)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if w := strings.TrimSpace(scanner.Text()); w != "" {
+				words[w] = true
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to load stopwords of column %d: %w", col.Idx, err)
+		}
+		ans[col.Idx] = words
+	}
+	return ans, nil
+}
+
+// TokenFilter decides which tokens take part in counted n-grams.
+// Excluded tokens are skipped as if they were not present in the
+// vertical at all while n-grams containing a stopword are not counted.
+// The filter also applies "default" and "skip" empty value policies
+// of the columns. A nil filter accepts all the tokens.
+type TokenFilter struct {
+	stopwords     Stopwords
+	exclude       map[int]*regexp.Regexp
+	emptyPolicies map[int]*db.EmptyValuePolicy
+}
+
+// NewTokenFilter loads stopwords and compiles exclusion
+// patterns of all the configured counted columns
+func NewTokenFilter(cols db.VertColumns) (*TokenFilter, error) {
+	stopwords, err := LoadStopwords(cols)
+	if err != nil {
+		return nil, err
+	}
+	ans := &TokenFilter{
+		stopwords:     stopwords,
+		exclude:       make(map[int]*regexp.Regexp),
+		emptyPolicies: make(map[int]*db.EmptyValuePolicy),
+	}
+	for _, col := range cols {
+		if col.OnEmpty != nil {
+			if err := col.OnEmpty.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid onEmpty of column %d: %w", col.Idx, err)
+			}
+			ans.emptyPolicies[col.Idx] = col.OnEmpty
+		}
+		if col.Exclude == "" {
+			continue
+		}
+		ans.exclude[col.Idx], err = regexp.Compile(col.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion pattern of column %d: %w", col.Idx, err)
+		}
+	}
+	return ans, nil
+}
+
+// encodeToken encodes values of counted columns of a token using
+// a word dictionary. It also tells whether the token is a stopword
+// and whether it is excluded from counting (in such case, no values
+// are encoded).
+func encodeToken(
+	tk *vertigo.Token,
+	ngramConf *cnf.NgramConf,
+	columnModders []*modders.StringTransformerChain,
+	wordDict *WordDict,
+	filter *TokenFilter,
+) (attributes []int, isStop bool, excluded bool) {
+	values := make([]string, ngramConf.MaxRequiredColumn()+1)
+	for _, vertCol := range ngramConf.VertColumns {
+		v := columnModders[vertCol.Idx].Transform(tk.PosAttrByIndex(vertCol.Idx))
+		if filter != nil {
+			if v == "" {
+				if tv, skip := filter.emptyPolicies[vertCol.Idx].Apply(v); skip {
+					return nil, false, true
+
+				} else if sv, ok := tv.(string); ok {
+					v = sv
+				}
+			}
+			if ptn, ok := filter.exclude[vertCol.Idx]; ok && ptn.MatchString(v) {
+				return nil, false, true
+			}
+			if filter.stopwords.Contains(vertCol.Idx, v) {
+				isStop = true
+			}
+		}
+		values[vertCol.Idx] = v
+	}
+	attributes = make([]int, len(values))
+	for _, vertCol := range ngramConf.VertColumns {
+		attributes[vertCol.Idx] = wordDict.Add(values[vertCol.Idx])
+	}
+	return attributes, isStop, false
+}
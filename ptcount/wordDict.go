@@ -16,36 +16,57 @@
 
 package ptcount
 
+import (
+	"sync"
+
+	"github.com/czcorpus/vert-tagextract/v2/intern"
+)
+
 // WordDict is basically a bidirectional map for mapping
 // between words and ints and ints and words. It is used to
 // reduce memory usage when collecting n-grams.
+// The dictionary can be used by multiple goroutines.
 type WordDict struct {
+	lock    sync.RWMutex
 	counter int
 	data    map[string]int
 	dataRev map[int]string
 }
 
 // Add adds a word to the dictionary and returns
-// its numeric representation.
+// its numeric representation. New words are copied
+// so they do not retain memory of the strings they
+// were sliced from (e.g. whole vertical lines).
 func (w *WordDict) Add(word string) int {
+	w.lock.RLock()
 	v, ok := w.data[word]
+	w.lock.RUnlock()
+	if ok {
+		return v
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	v, ok = w.data[word]
 	if !ok {
+		word = intern.Clone(word)
 		w.counter++
 		w.data[word] = w.counter
 		w.dataRev[w.counter] = word
 		return w.counter
-
-	} else {
-		return v
 	}
+	return v
 }
 
 // Get returns a word based on its integer representation.
 func (w *WordDict) Get(idx int) string {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
 	return w.dataRev[idx]
 }
 
 func (w *WordDict) Size() int {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
 	return len(w.data)
 }
 